@@ -5,6 +5,7 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -12,11 +13,16 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/mysterybee07/result-distribution-system/initializers"
 	"github.com/mysterybee07/result-distribution-system/routes"
+	"github.com/mysterybee07/result-distribution-system/utils"
 )
 
 func init() {
 	initializers.Connect()
 	initializers.LoadEnvironment()
+	utils.LoadSigningKeys()
+	utils.LoadDocumentSigningKey()
+	utils.LoadAdmitCardKey()
+	utils.LoadMailer()
 }
 
 func main() {
@@ -27,9 +33,11 @@ func main() {
 	}
 	log.Println("Starting the server on port " + port + "..........")
 
-	app := fiber.New(fiber.Config{
+	config := fiber.Config{
 		// Views: engine,
-	})
+	}
+	trustProxies(&config)
+	app := fiber.New(config)
 
 	// Initialize session store
 	// store := session.New()
@@ -91,3 +99,26 @@ func main() {
 
 	log.Println("Server exited gracefully")
 }
+
+// trustProxies makes c.IP() read the client address from PROXY_HEADER, X-Real-IP by default, but
+// only for requests that come from one of the comma separated TRUSTED_PROXIES. Without trusted
+// proxies the header is ignored, so clients cannot pick the IP the rate limits see.
+func trustProxies(config *fiber.Config) {
+	var proxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	if len(proxies) == 0 {
+		return
+	}
+
+	config.ProxyHeader = os.Getenv("PROXY_HEADER")
+	if config.ProxyHeader == "" {
+		config.ProxyHeader = "X-Real-IP"
+	}
+	config.EnableTrustedProxyCheck = true
+	config.TrustedProxies = proxies
+	config.EnableIPValidation = true
+}
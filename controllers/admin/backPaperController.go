@@ -0,0 +1,188 @@
+package controllers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mysterybee07/result-distribution-system/initializers"
+	"github.com/mysterybee07/result-distribution-system/middleware/validation"
+	"github.com/mysterybee07/result-distribution-system/models"
+	"github.com/mysterybee07/result-distribution-system/utils"
+)
+
+func GetBackPaperRegistrations(c *fiber.Ctx) error {
+	access, err := utils.RequestAccess(c)
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+	query := access.Scope(initializers.DB.Preload("Student").Preload("Course").Order("semester_id, course_id, student_id"), "program_id", "")
+	// Registrations carry no college, so college scopes go through the student
+	if len(access.CollegeIDs) > 0 {
+		query = query.Where("student_id IN (?)", initializers.DB.Model(&models.Student{}).Select("id").Where("college_id IN ?", access.CollegeIDs))
+	}
+
+	// Apply filters if provided
+	if batchID := c.Query("batch_id"); batchID != "" {
+		query = query.Where("batch_id = ?", batchID)
+	}
+	if programID := c.Query("program_id"); programID != "" {
+		query = query.Where("program_id = ?", programID)
+	}
+	if semesterID := c.Query("semester_id"); semesterID != "" {
+		query = query.Where("semester_id = ?", semesterID)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if routineID := c.Query("exam_routine_id"); routineID != "" {
+		query = query.Where("exam_routine_id = ?", routineID)
+	}
+
+	var registrations []models.BackPaperRegistration
+	if err := query.Find(&registrations).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch back paper registrations"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"registrations": registrations,
+	})
+}
+
+// RegisterBackPapers enrols the students who failed courses of a semester for their next attempt
+func RegisterBackPapers(c *fiber.Ctx) error {
+	var req models.BackPaperRegistrationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	if err := checkCohortAccess(c, req.ProgramID); err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
+	registrations, skipped, err := utils.RegisterBackPapers(req)
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message":       "Back papers registered successfully",
+		"registrations": registrations,
+		"skipped":       skipped,
+	})
+}
+
+func CancelBackPaperRegistration(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid registration id"})
+	}
+
+	// Registrations of students outside the user's scope are reported like missing ones
+	var existing models.BackPaperRegistration
+	if err := initializers.DB.First(&existing, id).Error; err != nil || checkStudentAccess(c, existing.StudentID) != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Back paper registration not found"})
+	}
+
+	registration, err := utils.CancelBackPaperRegistration(uint(id))
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":      "Back paper registration cancelled",
+		"registration": registration,
+	})
+}
+
+// ScheduleBackPapers builds a back paper routine for the open registrations of a semester
+func ScheduleBackPapers(c *fiber.Ctx) error {
+	var req models.ExamRoutineRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	if err := validation.ValidateExamScheduleRequest(&req); err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+	if err := checkCohortAccess(c, req.ProgramID); err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
+	fileName, examSchedules, score, err := utils.BackPaperRoutine(req)
+	if err != nil {
+		var infeasible *utils.ScheduleInfeasibleError
+		if errors.As(err, &infeasible) {
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+				"error":   "No exam timetable fits the given window",
+				"reasons": infeasible.Reasons,
+			})
+		}
+		return utils.RespondFiberError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":       "Back paper routine created successfully",
+		"fileName":      fileName,
+		"examSchedules": examSchedules,
+		"score":         score,
+	})
+}
+
+// CreateBackPaperMarks records the marks of a back paper as the student's next attempt
+func CreateBackPaperMarks(c *fiber.Ctx) error {
+	var payload models.MarksPayload
+	if err := c.BodyParser(&payload); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid JSON"})
+	}
+
+	if err := validate.Struct(payload); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	// The entry deadline is for the regular exam, so back papers only need the assignment
+	userID, _ := c.Locals("userID").(string)
+	if err := utils.CheckExaminer(userID, payload.BatchID, payload.CourseID); err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+	for _, mark := range payload.Marks {
+		if err := checkStudentAccess(c, mark.StudentID); err != nil {
+			return utils.RespondFiberError(c, err)
+		}
+	}
+
+	marks, err := utils.RecordBackPaperMarks(payload)
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "Back paper marks created successfully",
+		"marks":   marks,
+	})
+}
+
+// RepublishResults applies back paper marks to a published result
+func RepublishResults(c *fiber.Ctx) error {
+	var req struct {
+		BatchID    uint `json:"batch_id" form:"batch_id"`
+		ProgramID  uint `json:"program_id" form:"program_id"`
+		SemesterID uint `json:"semester_id" form:"semester_id"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid form data"})
+	}
+	if err := checkCohortAccess(c, req.ProgramID); err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
+	result, outcomes, err := utils.RepublishResults(req.BatchID, req.ProgramID, req.SemesterID)
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":  "Results republished with back paper marks",
+		"result":   result,
+		"students": outcomes,
+	})
+}
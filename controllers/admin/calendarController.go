@@ -0,0 +1,188 @@
+package controllers
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mysterybee07/result-distribution-system/initializers"
+	"github.com/mysterybee07/result-distribution-system/models"
+	"github.com/mysterybee07/result-distribution-system/utils"
+)
+
+func CreateCalendarEvent(c *fiber.Ctx) error {
+	var input models.CalendarEventInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	event, err := utils.CalendarEventFromInput(input)
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
+	if err := initializers.DB.Create(&event).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not create calendar event"})
+	}
+
+	// Warn about exams that were scheduled before the day was closed
+	conflicts, err := utils.CalendarConflicts(event)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message":   "Calendar event created successfully",
+		"event":     event,
+		"conflicts": calendarConflictResponse(conflicts),
+	})
+}
+
+// GetCalendarEvents lists events, optionally only those that apply to a program or batch
+// or that overlap the from/to dates
+func GetCalendarEvents(c *fiber.Ctx) error {
+	query := initializers.DB.Order("start_date")
+
+	// Apply filters if provided
+	if scope := c.Query("scope"); scope != "" {
+		query = query.Where("scope = ?", scope)
+	}
+	if programID := c.Query("program_id"); programID != "" {
+		query = query.Where("program_id = ?", programID)
+	}
+	if batchID := c.Query("batch_id"); batchID != "" {
+		query = query.Where("batch_id = ?", batchID)
+	}
+	if from := c.Query("from"); from != "" {
+		query = query.Where("end_date >= ?", from)
+	}
+	if to := c.Query("to"); to != "" {
+		query = query.Where("start_date <= ?", to)
+	}
+
+	var events []models.CalendarEvent
+	if err := query.Find(&events).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch calendar events"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"events": events,
+	})
+}
+
+func UpdateCalendarEvent(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var event models.CalendarEvent
+	if err := initializers.DB.First(&event, id).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Calendar event not found"})
+	}
+
+	var input models.CalendarEventInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	updated, err := utils.CalendarEventFromInput(input)
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+	updated.Model = event.Model
+	updated.UID = event.UID
+
+	if err := initializers.DB.Save(&updated).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not update calendar event"})
+	}
+
+	conflicts, err := utils.CalendarConflicts(updated)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":   "Calendar event updated successfully",
+		"event":     updated,
+		"conflicts": calendarConflictResponse(conflicts),
+	})
+}
+
+func DeleteCalendarEvent(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var event models.CalendarEvent
+	if err := initializers.DB.First(&event, id).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Calendar event not found"})
+	}
+
+	if err := initializers.DB.Delete(&event).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not delete calendar event"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Calendar event deleted successfully",
+	})
+}
+
+// ImportCalendar reads an uploaded .ics file and stores its events under the given scope
+func ImportCalendar(c *fiber.Ctx) error {
+	file, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "File upload failed"})
+	}
+
+	reader, err := file.Open()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to open file"})
+	}
+	defer reader.Close()
+
+	events, err := utils.ParseICal(reader)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	programID, err := optionalFormID(c, "program_id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid program_id"})
+	}
+	batchID, err := optionalFormID(c, "batch_id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid batch_id"})
+	}
+
+	created, updated, err := utils.ImportCalendarEvents(events, c.FormValue("scope"), programID, batchID)
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Calendar imported successfully",
+		"created": created,
+		"updated": updated,
+	})
+}
+
+func optionalFormID(c *fiber.Ctx, key string) (*uint, error) {
+	value := c.FormValue(key)
+	if value == "" {
+		return nil, nil
+	}
+	id, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return nil, err
+	}
+	result := uint(id)
+	return &result, nil
+}
+
+func calendarConflictResponse(schedules []models.ExamSchedules) []fiber.Map {
+	response := []fiber.Map{}
+	for _, schedule := range schedules {
+		response = append(response, fiber.Map{
+			"exam_routine_id": schedule.ExamRoutineID,
+			"course_code":     schedule.Course.CourseCode,
+			"exam_date":       schedule.ExamDate.Format("2006-01-02"),
+			"published":       schedule.ExamRoutine.Status,
+		})
+	}
+	return response
+}
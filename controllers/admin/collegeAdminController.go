@@ -0,0 +1,106 @@
+package controllers
+
+import (
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mysterybee07/result-distribution-system/initializers"
+	"github.com/mysterybee07/result-distribution-system/models"
+	"github.com/mysterybee07/result-distribution-system/utils"
+)
+
+// GetCollegeAdmins lists the accounts that sign in for a college
+func GetCollegeAdmins(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var users []models.User
+	if err := initializers.DB.Where("college_id = ? AND role = ?", id, models.RoleCollegeAdmin).
+		Order("email").Find(&users).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch college admins"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"users": users,
+	})
+}
+
+// CreateCollegeAdmin creates a login for a college. The account can only use the college portal
+// and only sees the college's own data.
+func CreateCollegeAdmin(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var college models.College
+	if err := initializers.DB.First(&college, id).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "College not found"})
+	}
+
+	var input models.CollegeAdminInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	input.Email = strings.TrimSpace(input.Email)
+	if _, err := mail.ParseAddress(input.Email); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "A valid email is required"})
+	}
+	if len(input.Password) < 8 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Password must be at least 8 characters long"})
+	}
+
+	var count int64
+	if err := initializers.DB.Model(&models.User{}).Where("email = ?", input.Email).Count(&count).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+	if count > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Email is already taken"})
+	}
+
+	hashedPassword, err := utils.HashPassword(input.Password)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to process password"})
+	}
+
+	// The central office hands the account over, so the email counts as verified
+	verifiedAt := time.Now()
+	user := models.User{
+		Email:           input.Email,
+		Password:        hashedPassword,
+		Role:            models.RoleCollegeAdmin,
+		CollegeID:       &college.ID,
+		EmailVerifiedAt: &verifiedAt,
+	}
+	if err := initializers.DB.Omit("Batch", "Program", "College").Create(&user).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not create college admin"})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "College admin created successfully",
+		"user":    user,
+	})
+}
+
+// RemoveCollegeAdmin takes the college admin role and the link to the college away from a user
+func RemoveCollegeAdmin(c *fiber.Ctx) error {
+	id := c.Params("userID")
+
+	var user models.User
+	if err := initializers.DB.First(&user, id).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+	}
+	if user.Role != models.RoleCollegeAdmin {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "User is not a college admin"})
+	}
+
+	if err := initializers.DB.Model(&user).Updates(map[string]interface{}{
+		"role":       models.RoleUser,
+		"college_id": nil,
+	}).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not remove college admin"})
+	}
+	utils.InvalidateAccess()
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "College admin removed successfully",
+	})
+}
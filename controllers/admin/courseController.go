@@ -82,6 +82,16 @@ func CreateCourses(c *fiber.Ctx) error {
 				"error": "Course code and name cannot be empty",
 			})
 		}
+		if course.CreditHours < 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Credit hours cannot be negative",
+			})
+		}
+		if course.IsCompulsory && course.ElectiveGroup != "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Only optional courses can belong to an elective group",
+			})
+		}
 
 		course.ProgramID = payload.ProgramID
 		course.SemesterID = payload.SemesterID
@@ -128,6 +138,17 @@ func UpdateCourse(c *fiber.Ctx) error {
 		})
 	}
 
+	if course.CreditHours < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Credit hours cannot be negative",
+		})
+	}
+	if course.IsCompulsory && course.ElectiveGroup != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Only optional courses can belong to an elective group",
+		})
+	}
+
 	var existingCourse models.Course
 	if err := initializers.DB.Where("course_code = ? AND program_id = ?", course.CourseCode, course.ProgramID).First(&existingCourse).Error; err == nil {
 		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
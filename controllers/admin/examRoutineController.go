@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/gofiber/fiber/v2"
@@ -25,8 +26,23 @@ func CreateExamRoutine(c *fiber.Ctx) error {
 	}
 
 	// Call the function to generate the exam routine
-	fileName, examSchedules, err := utils.ExamRoutine(req.BatchID, req.ProgramID, req.SemesterID, req.StartDate, req.EndDate)
+	fileName, examSchedules, score, err := utils.ExamRoutine(req)
 	if err != nil {
+		// Explain every reason when the window cannot hold the timetable
+		var infeasible *utils.ScheduleInfeasibleError
+		if errors.As(err, &infeasible) {
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+				"error":   "No exam timetable fits the given window",
+				"reasons": infeasible.Reasons,
+			})
+		}
+		var clash *utils.CenterClashError
+		if errors.As(err, &clash) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error":   "Exam centers would be over capacity",
+				"clashes": clash.Clashes,
+			})
+		}
 		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
 			"error": err.Error(),
 		})
@@ -37,6 +53,7 @@ func CreateExamRoutine(c *fiber.Ctx) error {
 		"message":       "Exams routine published successfully",
 		"fileName":      fileName,
 		"examSchedules": examSchedules,
+		"score":         score,
 	})
 }
 
@@ -67,6 +84,25 @@ func PublishExamRoutine(c *fiber.Ctx) error {
 		})
 	}
 
+	// A routine cannot be published while an exam sits on a day the calendar has closed
+	if requestBody.Status {
+		clashes, err := utils.RoutineClosedDays(examRoutine)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		if len(clashes) > 0 {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error":   "Exams fall on days closed by the academic calendar",
+				"clashes": clashes,
+			})
+		}
+
+		// Centers shared with other programs must have room on every exam day
+		if err := utils.CheckRoutineCenterClashes(examRoutine); err != nil {
+			return utils.RespondFiberError(c, err)
+		}
+	}
+
 	// Update the status field
 	examRoutine.Status = requestBody.Status
 
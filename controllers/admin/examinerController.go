@@ -0,0 +1,257 @@
+package controllers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mysterybee07/result-distribution-system/initializers"
+	"github.com/mysterybee07/result-distribution-system/models"
+	"github.com/mysterybee07/result-distribution-system/utils"
+	"gorm.io/gorm"
+)
+
+// GetExaminerAssignments lists which examiner may enter which course for which batch
+func GetExaminerAssignments(c *fiber.Ctx) error {
+	query := initializers.DB.Preload("User").Preload("Course").Preload("Batch").Order("batch_id, course_id")
+
+	// Apply filters if provided
+	if userID := c.Query("user_id"); userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+	if courseID := c.Query("course_id"); courseID != "" {
+		query = query.Where("course_id = ?", courseID)
+	}
+	if batchID := c.Query("batch_id"); batchID != "" {
+		query = query.Where("batch_id = ?", batchID)
+	}
+
+	var assignments []models.ExaminerAssignment
+	if err := query.Find(&assignments).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch examiner assignments"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"assignments": assignments,
+	})
+}
+
+// GetMyExaminerCourses lists the courses assigned to the signed in examiner with their deadlines
+func GetMyExaminerCourses(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userID").(string)
+
+	var assignments []models.ExaminerAssignment
+	if err := initializers.DB.Preload("Course").Preload("Batch").Where("user_id = ?", userID).
+		Order("batch_id, course_id").Find(&assignments).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch examiner assignments"})
+	}
+
+	courses := []fiber.Map{}
+	for _, assignment := range assignments {
+		var closesAt *time.Time
+		var deadline models.MarkEntryDeadline
+		if err := initializers.DB.Where("batch_id = ? AND course_id = ?", assignment.BatchID, assignment.CourseID).
+			First(&deadline).Error; err == nil {
+			closesAt = &deadline.ClosesAt
+		}
+		courses = append(courses, fiber.Map{
+			"assignment": assignment,
+			"closes_at":  closesAt,
+			"open":       closesAt == nil || time.Now().Before(*closesAt),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"courses": courses,
+	})
+}
+
+// AppointExaminer gives a user the examiner role
+func AppointExaminer(c *fiber.Ctx) error {
+	id := c.Params("userID")
+
+	var user models.User
+	if err := initializers.DB.First(&user, id).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+	}
+	if user.Role != models.RoleUser {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Only a user without another role can be appointed examiner"})
+	}
+
+	if err := initializers.DB.Model(&user).Update("role", models.RoleExaminer).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not appoint examiner"})
+	}
+	utils.InvalidateAccess()
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Examiner appointed successfully",
+		"user":    user,
+	})
+}
+
+// DismissExaminer takes the examiner role and every course assignment away from a user
+func DismissExaminer(c *fiber.Ctx) error {
+	id := c.Params("userID")
+
+	var user models.User
+	if err := initializers.DB.First(&user, id).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+	}
+	if user.Role != models.RoleExaminer {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "User is not an examiner"})
+	}
+
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("user_id = ?", user.ID).Delete(&models.ExaminerAssignment{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&user).Update("role", models.RoleUser).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not dismiss examiner"})
+	}
+	utils.InvalidateAccess()
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Examiner dismissed successfully",
+	})
+}
+
+func CreateExaminerAssignment(c *fiber.Ctx) error {
+	var assignment models.ExaminerAssignment
+	if err := c.BodyParser(&assignment); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	assignment.ID = 0
+
+	var user models.User
+	if err := initializers.DB.First(&user, assignment.UserID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+	}
+	if user.Role != models.RoleExaminer {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "User is not an examiner"})
+	}
+	var course models.Course
+	if err := initializers.DB.First(&course, assignment.CourseID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Course not found"})
+	}
+	var batch models.Batch
+	if err := initializers.DB.First(&batch, assignment.BatchID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Batch not found"})
+	}
+
+	var existing models.ExaminerAssignment
+	err := initializers.DB.Where("user_id = ? AND course_id = ? AND batch_id = ?", assignment.UserID, assignment.CourseID, assignment.BatchID).
+		First(&existing).Error
+	if err == nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Examiner is already assigned to the course for the batch"})
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+
+	if err := initializers.DB.Omit("User", "Course", "Batch").Create(&assignment).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not create examiner assignment"})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message":    "Examiner assigned successfully",
+		"assignment": assignment,
+	})
+}
+
+func DeleteExaminerAssignment(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var assignment models.ExaminerAssignment
+	if err := initializers.DB.First(&assignment, id).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Examiner assignment not found"})
+	}
+
+	// Removed for good so the examiner can be assigned again
+	if err := initializers.DB.Unscoped().Delete(&assignment).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not delete examiner assignment"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Examiner assignment deleted successfully",
+	})
+}
+
+func GetMarkEntryDeadlines(c *fiber.Ctx) error {
+	query := initializers.DB.Preload("Course").Order("closes_at")
+
+	if courseID := c.Query("course_id"); courseID != "" {
+		query = query.Where("course_id = ?", courseID)
+	}
+	if batchID := c.Query("batch_id"); batchID != "" {
+		query = query.Where("batch_id = ?", batchID)
+	}
+
+	var deadlines []models.MarkEntryDeadline
+	if err := query.Find(&deadlines).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch mark entry deadlines"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"deadlines": deadlines,
+	})
+}
+
+// SetMarkEntryDeadline sets or moves the time mark entry of a course closes for a batch. Moving
+// it into the future opens entry again.
+func SetMarkEntryDeadline(c *fiber.Ctx) error {
+	var req struct {
+		CourseID uint      `json:"course_id"`
+		BatchID  uint      `json:"batch_id"`
+		ClosesAt time.Time `json:"closes_at"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input, closes_at must be an RFC 3339 time"})
+	}
+	if req.ClosesAt.IsZero() {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "closes_at is required"})
+	}
+
+	var course models.Course
+	if err := initializers.DB.First(&course, req.CourseID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Course not found"})
+	}
+	var batch models.Batch
+	if err := initializers.DB.First(&batch, req.BatchID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Batch not found"})
+	}
+
+	var deadline models.MarkEntryDeadline
+	err := initializers.DB.Where("course_id = ? AND batch_id = ?", req.CourseID, req.BatchID).First(&deadline).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+	deadline.CourseID = req.CourseID
+	deadline.BatchID = req.BatchID
+	deadline.ClosesAt = req.ClosesAt
+	if err := initializers.DB.Omit("Course").Save(&deadline).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not save mark entry deadline"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":  "Mark entry deadline saved",
+		"deadline": deadline,
+	})
+}
+
+func DeleteMarkEntryDeadline(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var deadline models.MarkEntryDeadline
+	if err := initializers.DB.First(&deadline, id).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Mark entry deadline not found"})
+	}
+
+	if err := initializers.DB.Unscoped().Delete(&deadline).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not delete mark entry deadline"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Mark entry deadline deleted successfully",
+	})
+}
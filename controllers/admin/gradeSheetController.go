@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mysterybee07/result-distribution-system/utils"
+)
+
+// GetGradeSheet renders the grade sheet of a student for a published semester as a PDF
+func GetGradeSheet(c *fiber.Ctx) error {
+	studentID, err := strconv.ParseUint(c.Params("studentID"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid student id"})
+	}
+	semesterID, err := strconv.ParseUint(c.Params("semesterID"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid semester id"})
+	}
+	if err := checkStudentAccess(c, uint(studentID)); err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
+	sheet, err := utils.LoadGradeSheet(uint(studentID), uint(semesterID))
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
+	content, err := utils.RenderGradeSheetsPDF([]utils.GradeSheet{*sheet})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	c.Attachment(fmt.Sprintf("GradeSheet_%s_Semester%d.pdf", utils.SanitizeFileName(sheet.Student.SymbolNumber), sheet.Result.Semester.SemesterName))
+	c.Type("pdf")
+	return c.Send(content)
+}
+
+// GetTranscript renders the transcript of a student over every published semester as a PDF
+func GetTranscript(c *fiber.Ctx) error {
+	studentID, err := strconv.ParseUint(c.Params("studentID"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid student id"})
+	}
+	if err := checkStudentAccess(c, uint(studentID)); err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
+	transcript, err := utils.LoadTranscript(uint(studentID))
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
+	content, err := utils.RenderTranscriptPDF(*transcript)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	c.Attachment(fmt.Sprintf("Transcript_%s.pdf", utils.SanitizeFileName(transcript.Student.SymbolNumber)))
+	c.Type("pdf")
+	return c.Send(content)
+}
+
+// GenerateGradeSheets sends a zip with the grade sheet of every student of a published semester
+func GenerateGradeSheets(c *fiber.Ctx) error {
+	var req struct {
+		BatchID    uint `json:"batch_id" form:"batch_id"`
+		ProgramID  uint `json:"program_id" form:"program_id"`
+		SemesterID uint `json:"semester_id" form:"semester_id"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid form data"})
+	}
+	if err := checkCohortAccess(c, req.ProgramID); err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
+	path, _, err := utils.WriteCohortGradeSheets(req.BatchID, req.ProgramID, req.SemesterID)
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
+	return c.Download(path, fmt.Sprintf("GradeSheets_Batch%d_Program%d_Semester%d.zip", req.BatchID, req.ProgramID, req.SemesterID))
+}
+
+func checkStudentAccess(c *fiber.Ctx, studentID uint) error {
+	access, err := utils.RequestAccess(c)
+	if err != nil {
+		return err
+	}
+	return access.CheckStudent(studentID)
+}
+
+func checkCohortAccess(c *fiber.Ctx, programID uint) error {
+	access, err := utils.RequestAccess(c)
+	if err != nil {
+		return err
+	}
+	return access.CheckCohort(programID)
+}
@@ -0,0 +1,134 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mysterybee07/result-distribution-system/initializers"
+	"github.com/mysterybee07/result-distribution-system/middleware/validation"
+	"github.com/mysterybee07/result-distribution-system/models"
+	"github.com/mysterybee07/result-distribution-system/utils"
+	"gorm.io/gorm"
+)
+
+func GetGradingSchemes(c *fiber.Ctx) error {
+	var schemes []models.GradingScheme
+	if err := initializers.DB.Preload("Bands", func(db *gorm.DB) *gorm.DB {
+		return db.Order("min_percentage desc")
+	}).Find(&schemes).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch grading schemes"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"grading_schemes": schemes,
+		"default_bands":   utils.DefaultGradeBands, // Used when no scheme is marked as default
+	})
+}
+
+func CreateGradingScheme(c *fiber.Ctx) error {
+	var scheme models.GradingScheme
+	if err := c.BodyParser(&scheme); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	scheme.ID = 0
+
+	if err := validation.ValidateGradingScheme(&scheme); err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		// Only one scheme can be the default
+		if scheme.IsDefault {
+			if err := tx.Model(&models.GradingScheme{}).Where("is_default = ?", true).Update("is_default", false).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Create(&scheme).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not create grading scheme"})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message":        "Grading scheme created successfully",
+		"grading_scheme": scheme,
+	})
+}
+
+// UpdateGradingScheme replaces the name, default flag and bands of a scheme
+func UpdateGradingScheme(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var existing models.GradingScheme
+	if err := initializers.DB.First(&existing, id).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Grading scheme not found"})
+	}
+
+	var scheme models.GradingScheme
+	if err := c.BodyParser(&scheme); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	scheme.Model = existing.Model
+
+	if err := validation.ValidateGradingScheme(&scheme); err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if scheme.IsDefault {
+			if err := tx.Model(&models.GradingScheme{}).Where("is_default = ? AND id <> ?", true, scheme.ID).Update("is_default", false).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Unscoped().Where("grading_scheme_id = ?", scheme.ID).Delete(&models.GradeBand{}).Error; err != nil {
+			return err
+		}
+		for i := range scheme.Bands {
+			scheme.Bands[i].ID = 0
+			scheme.Bands[i].GradingSchemeID = scheme.ID
+		}
+		if err := tx.Create(&scheme.Bands).Error; err != nil {
+			return err
+		}
+		return tx.Omit("Bands").Save(&scheme).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not update grading scheme"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":        "Grading scheme updated successfully",
+		"grading_scheme": scheme,
+	})
+}
+
+func DeleteGradingScheme(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var scheme models.GradingScheme
+	if err := initializers.DB.First(&scheme, id).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Grading scheme not found"})
+	}
+
+	// Programs would silently fall back to the default scheme otherwise
+	var programs int64
+	if err := initializers.DB.Model(&models.Program{}).Where("grading_scheme_id = ?", scheme.ID).Count(&programs).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+	if programs > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Grading scheme is used by a program"})
+	}
+
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		// Removed for good so the name can be used again
+		if err := tx.Unscoped().Where("grading_scheme_id = ?", scheme.ID).Delete(&models.GradeBand{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&scheme).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not delete grading scheme"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Grading scheme deleted successfully",
+	})
+}
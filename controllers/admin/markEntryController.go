@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mysterybee07/result-distribution-system/initializers"
+	"github.com/mysterybee07/result-distribution-system/models"
+	"github.com/mysterybee07/result-distribution-system/utils"
+)
+
+// GetMarkEntries lists the entries of the signed in user. Users do not see each other's entries
+// so the second entry stays independent.
+func GetMarkEntries(c *fiber.Ctx) error {
+	enteredBy, _ := c.Locals("userID").(string)
+	query := initializers.DB.Where("entered_by = ?", enteredBy).Order("created_at desc")
+
+	// Apply filters if provided
+	if batchID := c.Query("batch_id"); batchID != "" {
+		query = query.Where("batch_id = ?", batchID)
+	}
+	if programID := c.Query("program_id"); programID != "" {
+		query = query.Where("program_id = ?", programID)
+	}
+	if semesterID := c.Query("semester_id"); semesterID != "" {
+		query = query.Where("semester_id = ?", semesterID)
+	}
+	if courseID := c.Query("course_id"); courseID != "" {
+		query = query.Where("course_id = ?", courseID)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var entries []models.MarkEntry
+	if err := query.Find(&entries).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch marks entries"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"entries": entries,
+	})
+}
+
+// GetMarkReconciliations is the queue of entries that disagreed, open ones by default
+func GetMarkReconciliations(c *fiber.Ctx) error {
+	status := c.Query("status", "open")
+	query := initializers.DB.Preload("FirstEntry").Preload("SecondEntry").Preload("Course").Preload("Student").
+		Where("status = ?", status).Order("created_at")
+
+	if batchID := c.Query("batch_id"); batchID != "" {
+		query = query.Where("batch_id = ?", batchID)
+	}
+	if programID := c.Query("program_id"); programID != "" {
+		query = query.Where("program_id = ?", programID)
+	}
+	if semesterID := c.Query("semester_id"); semesterID != "" {
+		query = query.Where("semester_id = ?", semesterID)
+	}
+	if courseID := c.Query("course_id"); courseID != "" {
+		query = query.Where("course_id = ?", courseID)
+	}
+
+	var reconciliations []models.MarkReconciliation
+	if err := query.Find(&reconciliations).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch reconciliations"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"reconciliations": reconciliations,
+	})
+}
+
+// ResolveMarkReconciliation records the marks a supervisor decided on for a disagreement
+func ResolveMarkReconciliation(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid reconciliation id"})
+	}
+
+	var req models.MarkReconciliationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid JSON"})
+	}
+	if err := validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	resolvedBy, _ := c.Locals("userID").(string)
+	reconciliation, err := utils.ResolveReconciliation(uint(id), req, resolvedBy)
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":        "Reconciliation resolved and marks recorded",
+		"reconciliation": reconciliation,
+	})
+}
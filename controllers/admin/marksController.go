@@ -2,12 +2,14 @@ package controllers
 
 import (
 	"errors"
+	"strconv"
 
 	"github.com/go-playground/validator"
 	"github.com/gofiber/fiber/v2"
 	"github.com/mysterybee07/result-distribution-system/initializers"
 	"github.com/mysterybee07/result-distribution-system/middleware/validation"
 	"github.com/mysterybee07/result-distribution-system/models"
+	"github.com/mysterybee07/result-distribution-system/utils"
 	"gorm.io/gorm"
 )
 
@@ -15,6 +17,11 @@ import (
 var validate = validator.New()
 
 func Marks(c *fiber.Ctx) error {
+	access, err := utils.RequestAccess(c)
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
 	var batches []models.Batch
 	if err := initializers.DB.Find(&batches).Error; err != nil {
 		c.Status(fiber.StatusInternalServerError).SendString("Error fetching batches")
@@ -22,13 +29,13 @@ func Marks(c *fiber.Ctx) error {
 	}
 
 	var courses []models.Course
-	if err := initializers.DB.Find(&courses).Error; err != nil {
+	if err := access.Scope(initializers.DB, "program_id", "").Find(&courses).Error; err != nil {
 		c.Status(fiber.StatusInternalServerError).SendString("Error fetching courses")
 		return err
 	}
 
 	var programs []models.Program
-	if err := initializers.DB.Preload("Semesters").Find(&programs).Error; err != nil {
+	if err := access.Scope(initializers.DB, "id", "").Preload("Semesters").Find(&programs).Error; err != nil {
 		c.Status(fiber.StatusInternalServerError).SendString("Error fetching programs")
 		return err
 	}
@@ -39,7 +46,7 @@ func Marks(c *fiber.Ctx) error {
 		return err
 	}
 
-	err := c.Render("dashboard/marks/add", fiber.Map{
+	err = c.Render("dashboard/marks/add", fiber.Map{
 		"Students":  []models.Student{}, // Empty initially
 		"Courses":   courses,
 		"Batches":   batches,
@@ -52,6 +59,8 @@ func Marks(c *fiber.Ctx) error {
 	return nil
 }
 
+// CreateMarks stages the marks of a course as the signed in user's entry. Marks reach the student
+// record when a second user enters the same marks.
 func CreateMarks(c *fiber.Ctx) error {
 	// Parse incoming JSON request body into payload struct
 	var payload models.MarksPayload
@@ -75,6 +84,30 @@ func CreateMarks(c *fiber.Ctx) error {
 		})
 	}
 
+	// Examiners only enter the courses assigned to them, and only until the deadline
+	userID, _ := c.Locals("userID").(string)
+	if err := utils.CheckMarkEntry(userID, payload.BatchID, payload.CourseID); err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+	for _, mark := range payload.Marks {
+		if err := checkStudentAccess(c, mark.StudentID); err != nil {
+			return utils.RespondFiberError(c, err)
+		}
+	}
+
+	// A published result is only changed through an amendment
+	published, err := utils.ResultPublished(payload.BatchID, payload.ProgramID, payload.SemesterID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+	if published {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "The result of this semester is published; marks can only be amended",
+		})
+	}
+
 	// Fetch course details
 	var course models.Course
 	if err := initializers.DB.First(&course, payload.CourseID).Error; err != nil {
@@ -88,10 +121,8 @@ func CreateMarks(c *fiber.Ctx) error {
 		})
 	}
 
-	// Create marks for each student
-	var marks []models.Mark
+	// Check that obtained marks do not exceed total marks
 	for _, mark := range payload.Marks {
-		// Check that obtained marks do not exceed total marks
 		if mark.SemesterMarks > course.SemesterTotalMarks ||
 			(course.PracticalTotalMarks != nil && mark.PracticalMarks > *course.PracticalTotalMarks) ||
 			(course.AssistantTotalMarks != nil && mark.AssistantMarks > *course.AssistantTotalMarks) {
@@ -99,58 +130,23 @@ func CreateMarks(c *fiber.Ctx) error {
 				"error": "Obtained marks cannot exceed total marks",
 			})
 		}
-
-		newMark := models.Mark{
-			BatchID:        payload.BatchID,
-			ProgramID:      payload.ProgramID,
-			SemesterID:     payload.SemesterID,
-			CourseID:       payload.CourseID,
-			StudentID:      mark.StudentID,
-			SemesterMarks:  mark.SemesterMarks,
-			AssistantMarks: mark.AssistantMarks,
-			PracticalMarks: mark.PracticalMarks,
-		}
-
-		// Determine pass/fail status
-		if newMark.SemesterMarks < course.SemesterPassMarks ||
-			(course.PracticalPassMarks != nil && newMark.PracticalMarks < *course.PracticalPassMarks) ||
-			(course.AssistantPassMarks != nil && newMark.AssistantMarks < *course.AssistantPassMarks) {
-			newMark.Status = "failed"
-		} else {
-			newMark.Status = "pass"
-		}
-
-		marks = append(marks, newMark)
 	}
 
-	// Bulk insert the marks
-	if err := initializers.DB.Create(&marks).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Could not create marks",
-		})
-	}
-
-	// Preload associations for each mark
-	for i := range marks {
-		if err := initializers.DB.Preload("Batch").
-			Preload("Program").
-			Preload("Semester").
-			Preload("Course").
-			Preload("Student").
-			First(&marks[i], marks[i].ID).Error; err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Could not preload associations",
-			})
-		}
+	// Marks are staged as this user's entry and only recorded once a second user agrees
+	outcomes, err := utils.StageMarks(payload, userID)
+	if err != nil {
+		return utils.RespondFiberError(c, err)
 	}
 
 	// Return success message
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
-		"message": "Marks created successfully",
-		"marks":   marks,
+		"message": "Marks entry saved",
+		"entries": outcomes,
 	})
 }
 
+// UpdateMarks stages corrected marks of a course as the signed in user's entry. The marks only
+// change once a second user enters the same correction.
 func UpdateMarks(c *fiber.Ctx) error {
 	var input models.MarksPayload
 	if err := c.BodyParser(&input); err != nil {
@@ -173,6 +169,17 @@ func UpdateMarks(c *fiber.Ctx) error {
 		})
 	}
 
+	// Examiners only edit the courses assigned to them, and only until the deadline
+	userID, _ := c.Locals("userID").(string)
+	if err := utils.CheckMarkEntry(userID, input.BatchID, input.CourseID); err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+	for _, markEntry := range input.Marks {
+		if err := checkStudentAccess(c, markEntry.StudentID); err != nil {
+			return utils.RespondFiberError(c, err)
+		}
+	}
+
 	// Fetch the course details to get the pass marks and total marks
 	var course models.Course
 	if err := initializers.DB.First(&course, input.CourseID).Error; err != nil {
@@ -181,10 +188,8 @@ func UpdateMarks(c *fiber.Ctx) error {
 		})
 	}
 
-	// Create a slice to store the updated marks
-	var updatedMarks []models.Mark
+	// Check that obtained marks do not exceed total marks
 	for _, markEntry := range input.Marks {
-		// Check that obtained marks do not exceed total marks
 		if markEntry.SemesterMarks > course.SemesterTotalMarks ||
 			(course.PracticalTotalMarks != nil && markEntry.PracticalMarks > *course.PracticalTotalMarks) ||
 			(course.AssistantTotalMarks != nil && markEntry.AssistantMarks > *course.AssistantTotalMarks) {
@@ -192,57 +197,18 @@ func UpdateMarks(c *fiber.Ctx) error {
 				"error": "Obtained marks cannot exceed total marks",
 			})
 		}
-
-		// Find the existing mark record
-		var mark models.Mark
-		if err := initializers.DB.Where("student_id = ? AND course_id = ?", markEntry.StudentID, input.CourseID).First(&mark).Error; err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Could not find the mark record",
-			})
-		}
-
-		// Update the mark fields
-		mark.SemesterMarks = markEntry.SemesterMarks
-		mark.AssistantMarks = markEntry.AssistantMarks
-		mark.PracticalMarks = markEntry.PracticalMarks
-
-		// Check pass/fail status based on the course's pass marks
-		if mark.SemesterMarks < course.SemesterPassMarks ||
-			(course.PracticalPassMarks != nil && mark.PracticalMarks < *course.PracticalPassMarks) ||
-			(course.AssistantPassMarks != nil && mark.AssistantMarks < *course.AssistantPassMarks) {
-			mark.Status = "failed"
-		} else {
-			mark.Status = "pass"
-		}
-
-		// Save the updated mark
-		if err := initializers.DB.Save(&mark).Error; err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Could not update the mark",
-			})
-		}
-
-		updatedMarks = append(updatedMarks, mark)
 	}
 
-	// Preload associations for each updated mark
-	for i := range updatedMarks {
-		if err := initializers.DB.Preload("Batch").
-			Preload("Program").
-			Preload("Semester").
-			Preload("Course").
-			Preload("Student").
-			First(&updatedMarks[i], updatedMarks[i].ID).Error; err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Could not preload associations",
-			})
-		}
+	// Corrections apply to the latest attempt of the course and go through a second entry
+	outcomes, err := utils.StageMarkCorrections(input, userID)
+	if err != nil {
+		return utils.RespondFiberError(c, err)
 	}
 
 	// Return success message
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
-		"message": "Marks updated successfully",
-		"marks":   updatedMarks,
+		"message": "Marks correction saved",
+		"entries": outcomes,
 	})
 }
 
@@ -269,6 +235,9 @@ func GetMarksBySymbolNumber(c *fiber.Ctx) error {
 			"error": "Could not retrieve student",
 		})
 	}
+	if err := checkStudentAccess(c, student.ID); err != nil {
+		return utils.RespondFiberError(c, err)
+	}
 
 	// Find the marks for the student
 	var marks []models.Mark
@@ -284,25 +253,143 @@ func GetMarksBySymbolNumber(c *fiber.Ctx) error {
 		})
 	}
 
+	// Earlier attempts are returned as well, but only counted attempts make up the result
+	counted, err := utils.CountedMarks(marks)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not retrieve marks",
+		})
+	}
+
 	totalMarks := 0
 	status := "pass"
-	for i := range marks {
-		mark := &marks[i]
+	for i := range counted {
+		mark := &counted[i]
 
-		if mark.SemesterMarks < mark.Course.SemesterPassMarks ||
-			(mark.Course.AssistantPassMarks != nil && mark.AssistantMarks < *mark.Course.AssistantPassMarks) ||
-			(mark.Course.PracticalPassMarks != nil && mark.PracticalMarks < *mark.Course.PracticalPassMarks) {
+		if mark.Status != "pass" {
 			status = "failed"
 		}
 
 		totalMarks += mark.TotalMarks
 	}
 
+	// Letter grades with the SGPA of every semester and the CGPA
+	reports, err := utils.BuildGradeReports(marks)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not compute grades",
+		})
+	}
+	grades, ok := reports[student.ID]
+	if !ok {
+		grades = &utils.GradeReport{StudentID: student.ID}
+	}
+
+	// Corrections made after publication
+	var amendments []models.MarkAmendment
+	if err := initializers.DB.Preload("Course").Where("student_id = ?", student.ID).Order("created_at").Find(&amendments).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not retrieve amendments",
+		})
+	}
+
 	// Return the marks and overall status
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"student":    student,
-		"marks":      marks,
+		"marks":      counted,
+		"attempts":   marks,
 		"totalMarks": totalMarks,
 		"status":     status,
+		"grades":     grades,
+		"amendments": amendments,
+	})
+}
+
+// AmendMarks corrects marks of a published result with a reason and takes a new result snapshot
+func AmendMarks(c *fiber.Ctx) error {
+	var req models.MarkAmendmentRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid JSON",
+		})
+	}
+
+	if err := validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	// An amendment revises the result of the whole cohort
+	if err := checkCohortAccess(c, req.ProgramID); err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
+	amendedBy, _ := c.Locals("userID").(string)
+	snapshot, amendments, err := utils.AmendMarks(req, amendedBy)
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":    "Marks amended and result revised",
+		"amendments": amendments,
+		"snapshot":   snapshot,
+	})
+}
+
+// ImportMarks reads a CSV or XLSX marks sheet keyed by symbol number and course code and stages
+// its rows as the uploader's entries. Every row is checked and reported; with partial set the
+// valid rows are saved even when others fail.
+func ImportMarks(c *fiber.Ctx) error {
+	file, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "File upload failed"})
+	}
+
+	reader, err := file.Open()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to open file"})
+	}
+	defer reader.Close()
+
+	rows, err := utils.ReadSpreadsheet(file.Filename, reader)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	batchID, err := strconv.ParseUint(c.FormValue("batch_id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid batch_id"})
+	}
+	programID, err := strconv.ParseUint(c.FormValue("program_id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid program_id"})
+	}
+	semesterID, err := strconv.ParseUint(c.FormValue("semester_id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid semester_id"})
+	}
+	partial, _ := strconv.ParseBool(c.FormValue("partial"))
+
+	access, err := utils.RequestAccess(c)
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+	enteredBy, _ := c.Locals("userID").(string)
+	report, err := utils.ImportMarks(uint(batchID), uint(programID), uint(semesterID), rows, partial, enteredBy, access)
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
+	if !report.Committed {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"error":  "No marks were saved; fix the listed rows or import with partial set",
+			"report": report,
+		})
+	}
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "Marks entries imported successfully",
+		"report":  report,
 	})
 }
@@ -0,0 +1,224 @@
+package controllers
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mysterybee07/result-distribution-system/initializers"
+	"github.com/mysterybee07/result-distribution-system/middleware/validation"
+	"github.com/mysterybee07/result-distribution-system/models"
+	"github.com/mysterybee07/result-distribution-system/utils"
+)
+
+func GetModerationRules(c *fiber.Ctx) error {
+	access, err := utils.RequestAccess(c)
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+	query := access.Scope(initializers.DB.Order("created_at desc"), "program_id", "")
+
+	// Apply filters if provided
+	if programID := c.Query("program_id"); programID != "" {
+		query = query.Where("program_id = ?", programID)
+	}
+	if semesterID := c.Query("semester_id"); semesterID != "" {
+		query = query.Where("semester_id = ?", semesterID)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var rules []models.ModerationRule
+	if err := query.Find(&rules).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch moderation rules"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"rules": rules,
+	})
+}
+
+// GetModerationRule returns a rule with the raw and moderated marks of every mark it changed
+func GetModerationRule(c *fiber.Ctx) error {
+	rule, err := scopedModerationRule(c, c.Params("id"))
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
+	var moderations []models.MarkModeration
+	if err := initializers.DB.Where("moderation_rule_id = ?", rule.ID).Order("course_code, symbol_number").Find(&moderations).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch moderations"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"rule":        rule,
+		"moderations": moderations,
+	})
+}
+
+func CreateModerationRule(c *fiber.Ctx) error {
+	var rule models.ModerationRule
+	if err := c.BodyParser(&rule); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	rule.ID = 0
+	rule.Status = "draft"
+
+	if err := validation.ValidateModerationRule(&rule); err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+	if err := checkCohortAccess(c, rule.ProgramID); err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
+	if err := initializers.DB.Create(&rule).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not create moderation rule"})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "Moderation rule created successfully",
+		"rule":    rule,
+	})
+}
+
+// UpdateModerationRule changes a rule that has not been applied yet
+func UpdateModerationRule(c *fiber.Ctx) error {
+	existing, err := scopedModerationRule(c, c.Params("id"))
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+	if existing.Status != "draft" {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Only a draft moderation rule can be changed"})
+	}
+
+	var rule models.ModerationRule
+	if err := c.BodyParser(&rule); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	rule.Model = existing.Model
+	rule.Status = "draft"
+
+	if err := validation.ValidateModerationRule(&rule); err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+	if err := checkCohortAccess(c, rule.ProgramID); err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
+	if err := initializers.DB.Save(&rule).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not update moderation rule"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Moderation rule updated successfully",
+		"rule":    rule,
+	})
+}
+
+func DeleteModerationRule(c *fiber.Ctx) error {
+	rule, err := scopedModerationRule(c, c.Params("id"))
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+	// Applied rules are part of the audit trail
+	if rule.Status != "draft" {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Only a draft moderation rule can be deleted"})
+	}
+
+	if err := initializers.DB.Delete(rule).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not delete moderation rule"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Moderation rule deleted successfully",
+	})
+}
+
+// PreviewModerationRule shows what a saved rule, or one given in the body, would change
+func PreviewModerationRule(c *fiber.Ctx) error {
+	var rule models.ModerationRule
+	if id := c.Params("id"); id != "" {
+		saved, err := scopedModerationRule(c, id)
+		if err != nil {
+			return utils.RespondFiberError(c, err)
+		}
+		rule = *saved
+	} else {
+		if err := c.BodyParser(&rule); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+		}
+		rule.ID = 0
+		if err := validation.ValidateModerationRule(&rule); err != nil {
+			return utils.RespondFiberError(c, err)
+		}
+		if err := checkCohortAccess(c, rule.ProgramID); err != nil {
+			return utils.RespondFiberError(c, err)
+		}
+	}
+
+	preview, err := utils.PreviewModeration(rule)
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"preview": preview,
+	})
+}
+
+func ApplyModerationRule(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid moderation rule id"})
+	}
+	if _, err := scopedModerationRule(c, id); err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
+	appliedBy, _ := c.Locals("userID").(string)
+	preview, err := utils.ApplyModerationRule(uint(id), appliedBy)
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Moderation rule applied",
+		"result":  preview,
+	})
+}
+
+func RevertModerationRule(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid moderation rule id"})
+	}
+	if _, err := scopedModerationRule(c, id); err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
+	revertedBy, _ := c.Locals("userID").(string)
+	rule, reverted, skipped, err := utils.RevertModerationRule(uint(id), revertedBy)
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":  "Moderation rule reverted",
+		"rule":     rule,
+		"reverted": reverted,
+		"skipped":  skipped, // Published or changed again since the rule was applied
+	})
+}
+
+// scopedModerationRule loads a rule the user may act on. Rules moderate a whole program cohort,
+// so users limited to other programs or to some colleges are turned away.
+func scopedModerationRule(c *fiber.Ctx, id interface{}) (*models.ModerationRule, error) {
+	var rule models.ModerationRule
+	if err := initializers.DB.First(&rule, id).Error; err != nil {
+		return nil, fiber.NewError(fiber.StatusNotFound, "Moderation rule not found")
+	}
+	if err := checkCohortAccess(c, rule.ProgramID); err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
@@ -1,11 +1,13 @@
 package controllers
 
 import (
+	"errors"
 	"log"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/mysterybee07/result-distribution-system/initializers"
 	"github.com/mysterybee07/result-distribution-system/models"
+	"github.com/mysterybee07/result-distribution-system/utils"
 )
 
 func Program(c *fiber.Ctx) error {
@@ -41,6 +43,12 @@ func CreateProgram(c *fiber.Ctx) error {
 	// Log the parsed data for debugging
 	log.Printf("Parsed Program: %+v\n", program)
 
+	if err := validateBackPaperRules(&program); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
 	var existingProgram models.Program
 	// Check if the program already exists
 	if err := initializers.DB.Where("program_name = ?", program.ProgramName).First(&existingProgram).Error; err == nil {
@@ -87,9 +95,15 @@ func UpdateProgram(c *fiber.Ctx) error {
 		})
 	}
 
+	if err := validateBackPaperRules(&program); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
 	var existingProgram models.Program
-	// Check if the program already exists
-	if err := initializers.DB.Where("program_name = ?", program.ProgramName).First(&existingProgram).Error; err == nil {
+	// Check if another program already has the name
+	if err := initializers.DB.Where("program_name = ? AND id <> ?", program.ProgramName, program.ID).First(&existingProgram).Error; err == nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "Program already exists",
 		})
@@ -119,3 +133,33 @@ func GetPrograms(c *fiber.Ctx) error {
 		"programs": programs,
 	})
 }
+
+// validateBackPaperRules fills in the defaults of the back paper and promotion settings and
+// rejects unknown rules or grading schemes
+func validateBackPaperRules(program *models.Program) error {
+	if program.BackPaperRule == "" {
+		program.BackPaperRule = "latest"
+	}
+	if !utils.BackPaperRules[program.BackPaperRule] {
+		return errors.New("back_paper_rule must be latest or best")
+	}
+	if program.PromotionRule == "" {
+		program.PromotionRule = "carry"
+	}
+	if !utils.PromotionRules[program.PromotionRule] {
+		return errors.New("promotion_rule must be carry or hold")
+	}
+	if program.MaxAttempts == 0 {
+		program.MaxAttempts = 3
+	}
+	if program.MaxAttempts < 1 {
+		return errors.New("max_attempts must be at least 1")
+	}
+	if program.GradingSchemeID != nil {
+		var scheme models.GradingScheme
+		if err := initializers.DB.First(&scheme, *program.GradingSchemeID).Error; err != nil {
+			return errors.New("grading scheme not found")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,283 @@
+package controllers
+
+import (
+	"regexp"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mysterybee07/result-distribution-system/initializers"
+	"github.com/mysterybee07/result-distribution-system/models"
+	"github.com/mysterybee07/result-distribution-system/utils"
+	"gorm.io/gorm"
+)
+
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{1,19}$`)
+
+func GetPermissions(c *fiber.Ctx) error {
+	var permissions []models.Permission
+	if err := initializers.DB.Order("name").Find(&permissions).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch permissions"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"permissions": permissions,
+	})
+}
+
+// GetRoles returns the permission matrix: every role with the permissions it holds
+func GetRoles(c *fiber.Ctx) error {
+	var roles []models.Role
+	if err := initializers.DB.Preload("Permissions").Order("name").Find(&roles).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch roles"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"roles": roles,
+	})
+}
+
+func CreateRole(c *fiber.Ctx) error {
+	var input models.RoleInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	if !roleNamePattern.MatchString(input.Name) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Role name must be 2 to 20 lowercase letters, digits, - or _"})
+	}
+
+	var count int64
+	if err := initializers.DB.Model(&models.Role{}).Where("name = ?", input.Name).Count(&count).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+	if count > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "A role with this name already exists"})
+	}
+
+	permissions, err := rolePermissions(input.Permissions)
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
+	role := models.Role{Name: input.Name, Description: input.Description, Permissions: permissions}
+	if err := initializers.DB.Create(&role).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not create role"})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "Role created successfully",
+		"role":    role,
+	})
+}
+
+// UpdateRole changes the description and replaces the permissions of a role. Seeded roles keep
+// their name, and the superadmin role keeps roles:manage so the matrix cannot lock everyone out.
+func UpdateRole(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var role models.Role
+	if err := initializers.DB.First(&role, id).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Role not found"})
+	}
+
+	var input models.RoleInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	if input.Name != "" && input.Name != role.Name {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Roles cannot be renamed"})
+	}
+
+	permissions, err := rolePermissions(input.Permissions)
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+	if role.Name == models.RoleSuperadmin {
+		kept := false
+		for _, permission := range permissions {
+			if permission.Name == "roles:manage" {
+				kept = true
+			}
+		}
+		if !kept {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "The superadmin role must keep roles:manage"})
+		}
+	}
+
+	err = initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&role).Update("description", input.Description).Error; err != nil {
+			return err
+		}
+		return tx.Model(&role).Association("Permissions").Replace(permissions)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not update role"})
+	}
+	utils.InvalidateAccess()
+	role.Permissions = permissions
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Role updated successfully",
+		"role":    role,
+	})
+}
+
+func DeleteRole(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var role models.Role
+	if err := initializers.DB.First(&role, id).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Role not found"})
+	}
+	if role.System {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Seeded roles cannot be deleted"})
+	}
+
+	var holders int64
+	if err := initializers.DB.Model(&models.User{}).Where("role = ?", role.Name).Count(&holders).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+	if holders > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "The role is still held by users"})
+	}
+
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&role).Association("Permissions").Clear(); err != nil {
+			return err
+		}
+		// Removed for good so the name can be used again
+		return tx.Unscoped().Delete(&role).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not delete role"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Role deleted successfully",
+	})
+}
+
+// SetUserRole gives a user another role. Users cannot change their own role.
+func SetUserRole(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var input struct {
+		Role string `json:"role"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	var user models.User
+	if err := initializers.DB.First(&user, id).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+	}
+	if userID, _ := c.Locals("userID").(string); userID == id {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "You cannot change your own role"})
+	}
+
+	// College accounts are tied to a college, so they are only made and removed from the college
+	if input.Role == models.RoleCollegeAdmin || user.Role == models.RoleCollegeAdmin {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "College admin accounts are managed from the college"})
+	}
+
+	var role models.Role
+	if err := initializers.DB.Where("name = ?", input.Role).First(&role).Error; err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Role not found"})
+	}
+
+	if err := initializers.DB.Model(&user).Update("role", role.Name).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not update user role"})
+	}
+	utils.InvalidateAccess()
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "User role updated successfully",
+		"user":    user,
+	})
+}
+
+func GetUserScopes(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var scopes []models.UserScope
+	if err := initializers.DB.Preload("Program").Preload("College").Where("user_id = ?", id).Find(&scopes).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch user scopes"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"scopes": scopes,
+	})
+}
+
+// SetUserScopes replaces the programs and colleges a user is limited to. Empty lists lift the
+// limit.
+func SetUserScopes(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var user models.User
+	if err := initializers.DB.First(&user, id).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+	}
+
+	var input models.UserScopeInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	var scopes []models.UserScope
+	for _, programID := range input.ProgramIDs {
+		var program models.Program
+		if err := initializers.DB.First(&program, programID).Error; err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Program not found"})
+		}
+		scopes = append(scopes, models.UserScope{UserID: user.ID, ProgramID: &program.ID})
+	}
+	for _, collegeID := range input.CollegeIDs {
+		var college models.College
+		if err := initializers.DB.First(&college, collegeID).Error; err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "College not found"})
+		}
+		scopes = append(scopes, models.UserScope{UserID: user.ID, CollegeID: &college.ID})
+	}
+
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("user_id = ?", user.ID).Delete(&models.UserScope{}).Error; err != nil {
+			return err
+		}
+		if len(scopes) == 0 {
+			return nil
+		}
+		return tx.Create(&scopes).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not update user scopes"})
+	}
+	utils.InvalidateAccess()
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "User scopes updated successfully",
+		"scopes":  scopes,
+	})
+}
+
+// rolePermissions loads the named permissions, failing on a name that does not exist
+func rolePermissions(names []string) ([]models.Permission, error) {
+	permissions := []models.Permission{}
+	if len(names) == 0 {
+		return permissions, nil
+	}
+	if err := initializers.DB.Where("name IN ?", names).Find(&permissions).Error; err != nil {
+		return nil, err
+	}
+	if len(permissions) != len(uniqueNames(names)) {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "Unknown permission in the list")
+	}
+	return permissions, nil
+}
+
+func uniqueNames(names []string) map[string]bool {
+	unique := make(map[string]bool)
+	for _, name := range names {
+		unique[name] = true
+	}
+	return unique
+}
@@ -0,0 +1,131 @@
+package controllers
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mysterybee07/result-distribution-system/initializers"
+	"github.com/mysterybee07/result-distribution-system/models"
+	"github.com/mysterybee07/result-distribution-system/utils"
+)
+
+func GetReevaluations(c *fiber.Ctx) error {
+	query := initializers.DB.Preload("Course").Preload("Student").Preload("Reviewer").Order("created_at")
+
+	// Apply filters if provided
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if batchID := c.Query("batch_id"); batchID != "" {
+		query = query.Where("batch_id = ?", batchID)
+	}
+	if programID := c.Query("program_id"); programID != "" {
+		query = query.Where("program_id = ?", programID)
+	}
+	if semesterID := c.Query("semester_id"); semesterID != "" {
+		query = query.Where("semester_id = ?", semesterID)
+	}
+	if courseID := c.Query("course_id"); courseID != "" {
+		query = query.Where("course_id = ?", courseID)
+	}
+
+	var requests []models.ReevaluationRequest
+	if err := query.Find(&requests).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch re-evaluation requests"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"requests": requests,
+	})
+}
+
+// GetAssignedReevaluations lists the requests the signed in reviewer still has to decide
+func GetAssignedReevaluations(c *fiber.Ctx) error {
+	reviewerID, _ := c.Locals("userID").(string)
+
+	var requests []models.ReevaluationRequest
+	if err := initializers.DB.Preload("Course").Preload("Student").
+		Where("reviewer_id = ? AND status = ?", reviewerID, "under_review").
+		Order("assigned_at").Find(&requests).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch re-evaluation requests"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"requests": requests,
+	})
+}
+
+func RecordReevaluationFee(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid re-evaluation request id"})
+	}
+
+	var req struct {
+		FeeReference string `json:"fee_reference"`
+	}
+	if err := c.BodyParser(&req); err != nil || req.FeeReference == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "fee_reference is required"})
+	}
+
+	request, err := utils.RecordReevaluationFee(uint(id), req.FeeReference)
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Re-evaluation fee recorded",
+		"request": request,
+	})
+}
+
+func AssignReevaluationReviewer(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid re-evaluation request id"})
+	}
+
+	var req struct {
+		ReviewerID uint `json:"reviewer_id"`
+	}
+	if err := c.BodyParser(&req); err != nil || req.ReviewerID == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "reviewer_id is required"})
+	}
+
+	request, err := utils.AssignReevaluationReviewer(uint(id), req.ReviewerID)
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Reviewer assigned",
+		"request": request,
+	})
+}
+
+// DecideReevaluation records the outcome of a review; only the assigned reviewer can decide
+func DecideReevaluation(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid re-evaluation request id"})
+	}
+
+	var decision models.ReevaluationDecision
+	if err := c.BodyParser(&decision); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	if decision.SemesterMarks < 0 || decision.AssistantMarks < 0 || decision.PracticalMarks < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Marks cannot be negative"})
+	}
+
+	reviewerID, _ := c.Locals("userID").(string)
+	request, err := utils.DecideReevaluation(uint(id), reviewerID, decision)
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Re-evaluation decided",
+		"request": request,
+	})
+}
@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mysterybee07/result-distribution-system/initializers"
+	"github.com/mysterybee07/result-distribution-system/models"
+	"github.com/mysterybee07/result-distribution-system/utils"
+)
+
+// GetRegistrationLists lists the student lists colleges submitted, oldest first so pending ones
+// are reviewed in order
+func GetRegistrationLists(c *fiber.Ctx) error {
+	access, err := utils.RequestAccess(c)
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+	query := access.Scope(initializers.DB.Preload("College").Order("created_at"), "program_id", "college_id")
+
+	// Apply filters if provided
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if collegeID := c.Query("college_id"); collegeID != "" {
+		query = query.Where("college_id = ?", collegeID)
+	}
+	if batchID := c.Query("batch_id"); batchID != "" {
+		query = query.Where("batch_id = ?", batchID)
+	}
+	if programID := c.Query("program_id"); programID != "" {
+		query = query.Where("program_id = ?", programID)
+	}
+
+	var lists []models.StudentRegistrationList
+	if err := query.Find(&lists).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch registration lists"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"lists": lists,
+	})
+}
+
+func GetRegistrationList(c *fiber.Ctx) error {
+	access, err := utils.RequestAccess(c)
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+	id := c.Params("id")
+
+	var list models.StudentRegistrationList
+	if err := access.Scope(initializers.DB.Preload("Entries"), "program_id", "college_id").
+		First(&list, id).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Registration list not found"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"list": list,
+	})
+}
+
+func ApproveRegistrationList(c *fiber.Ctx) error {
+	return reviewRegistrationList(c, true)
+}
+
+func RejectRegistrationList(c *fiber.Ctx) error {
+	return reviewRegistrationList(c, false)
+}
+
+func reviewRegistrationList(c *fiber.Ctx, approve bool) error {
+	access, err := utils.RequestAccess(c)
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid registration list id"})
+	}
+
+	var input struct {
+		Remarks string `json:"remarks"`
+	}
+	if err := c.BodyParser(&input); err != nil && len(c.Body()) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	if !approve && input.Remarks == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "remarks are required to reject a list"})
+	}
+
+	list, err := utils.ReviewRegistrationList(uint(id), access, approve, input.Remarks)
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Registration list " + strings.ToLower(list.Status),
+		"list":    list,
+	})
+}
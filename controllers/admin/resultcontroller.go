@@ -4,15 +4,19 @@ import (
 	"errors"
 	"log"
 	"sort"
+	"strconv"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/mysterybee07/result-distribution-system/initializers"
 	"github.com/mysterybee07/result-distribution-system/models"
 	"github.com/mysterybee07/result-distribution-system/utils"
-	"gorm.io/gorm"
 )
 
 func Result(c *fiber.Ctx) error {
+	access, err := utils.RequestAccess(c)
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
 
 	var batches []models.Batch
 	if err := initializers.DB.Find(&batches).Error; err != nil {
@@ -21,7 +25,7 @@ func Result(c *fiber.Ctx) error {
 	}
 
 	var programs []models.Program
-	if err := initializers.DB.Preload("Semesters").Find(&programs).Error; err != nil {
+	if err := access.Scope(initializers.DB, "id", "").Preload("Semesters").Find(&programs).Error; err != nil {
 		log.Printf("Failed to fetch programs: %v\n", err)
 		return c.Status(fiber.StatusInternalServerError).SendString("Failed to fetch programs")
 	}
@@ -32,8 +36,9 @@ func Result(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).SendString("Failed to fetch semesters")
 	}
 
+	// Results cover whole cohorts, so they are limited to the user's programs
 	var results []models.Result
-	if err := initializers.DB.Preload("Batch").Preload("Program").Preload("Semester").Find(&results).Error; err != nil {
+	if err := access.Scope(initializers.DB, "program_id", "").Preload("Batch").Preload("Program").Preload("Semester").Find(&results).Error; err != nil {
 		log.Printf("Failed to fetch results: %v\n", err)
 		return c.Status(fiber.StatusInternalServerError).SendString("Failed to fetch results")
 	}
@@ -46,12 +51,16 @@ func Result(c *fiber.Ctx) error {
 	})
 }
 
+// PublishResults promotes the cohort and publishes the semester result all at once. With dry_run
+// it only reports missing marks and who would be promoted or graduate. A withdrawn result is
+// published again under its next revision.
 func PublishResults(c *fiber.Ctx) error {
 	// Get batch and semester from request body or query params
 	type PublishRequest struct {
 		BatchID    uint `json:"batch_id" form:"batch_id"`
 		ProgramID  uint `json:"program_id" form:"program_id"`
 		SemesterID uint `json:"semester_id" form:"semester_id"`
+		DryRun     bool `json:"dry_run" form:"dry_run"`
 	}
 
 	var req PublishRequest
@@ -59,130 +68,121 @@ func PublishResults(c *fiber.Ctx) error {
 		log.Println("Unable to parse form data:", err)
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid form data"})
 	}
-
-	// Check if results are already published for the given batch, program, and semester
-	var existingResult models.Result
-	if err := initializers.DB.Where("batch_id = ? AND program_id = ? AND semester_id = ?", req.BatchID, req.ProgramID, req.SemesterID).First(&existingResult).Error; err == nil {
-		// Result already exists, return an error
-		log.Printf("Result already published for batch %d, program %d, and semester %d\n", req.BatchID, req.ProgramID, req.SemesterID)
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Result already published for the given semester with batch and program"})
-	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
-		// Other database error
-		log.Printf("Failed to fetch existing result: %v\n", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to check existing result"})
+	if c.QueryBool("dry_run") {
+		req.DryRun = true
 	}
-
-	var students []models.Student
-	if err := initializers.DB.Where("status = ? AND batch_id = ? AND program_id = ?", "active", req.BatchID, req.ProgramID).Find(&students).Error; err != nil {
-		log.Printf("Failed to fetch students: %v\n", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch students"})
+	if err := checkCohortAccess(c, req.ProgramID); err != nil {
+		return utils.RespondFiberError(c, err)
 	}
 
-	// Check if all students have marks for all courses in the current semester
-	// Check if all students have marks for all compulsory courses and exactly one optional course in the current semester
-	for _, student := range students {
-		var marks []models.Mark
-		if err := initializers.DB.Where("student_id = ? AND semester_id = ?", student.ID, req.SemesterID).Find(&marks).Error; err != nil {
-			log.Printf("Failed to fetch marks for student %d: %v\n", student.ID, err)
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch marks"})
-		}
-
-		var courses []models.Course
-		if err := initializers.DB.Where("program_id = ? AND semester_id = ?", student.ProgramID, req.SemesterID).Find(&courses).Error; err != nil {
-			log.Printf("Failed to fetch courses for student %d: %v\n", student.ID, err)
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch courses"})
-		}
-
-		// Separate compulsory and optional courses
-		compulsoryCourses := make(map[uint]bool)
-		optionalCourses := make(map[uint]bool)
-
-		for _, course := range courses {
-			if course.IsCompulsory {
-				compulsoryCourses[course.ID] = false
-			} else {
-				optionalCourses[course.ID] = false
-			}
+	report, result, err := utils.PublishResults(req.BatchID, req.ProgramID, req.SemesterID, req.DryRun)
+	if err != nil {
+		var fiberErr *fiber.Error
+		if errors.As(err, &fiberErr) && report != nil {
+			// Show which students hold the publication up
+			return c.Status(fiberErr.Code).JSON(fiber.Map{
+				"error":  fiberErr.Message,
+				"report": report,
+			})
 		}
+		log.Printf("Failed to publish result for batch %d, program %d, and semester %d: %v\n", req.BatchID, req.ProgramID, req.SemesterID, err)
+		return utils.RespondFiberError(c, err)
+	}
 
-		// Mark courses as completed based on the student's marks
-		for _, mark := range marks {
-			if _, exists := compulsoryCourses[mark.CourseID]; exists {
-				compulsoryCourses[mark.CourseID] = true
-			} else if _, exists := optionalCourses[mark.CourseID]; exists {
-				optionalCourses[mark.CourseID] = true
-			}
-		}
+	if req.DryRun {
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message": "Dry run, nothing was changed",
+			"report":  report,
+		})
+	}
 
-		// Check if all compulsory courses are marked
-		allCompulsoryMarked := true
-		for _, marked := range compulsoryCourses {
-			if !marked {
-				allCompulsoryMarked = false
-				break
-			}
-		}
+	message := "Results published and semesters updated"
+	if result.Revision > 1 {
+		message = "Results republished and semesters updated"
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": message,
+		"result":  result,
+		"report":  report,
+	})
+}
 
-		// Count the number of marked optional courses
-		optionalMarkedCount := 0
-		for _, marked := range optionalCourses {
-			if marked {
-				optionalMarkedCount++
-			}
-		}
+// WithdrawResult takes a published result back and undoes the promotions it applied
+func WithdrawResult(c *fiber.Ctx) error {
+	var req struct {
+		BatchID    uint   `json:"batch_id" form:"batch_id"`
+		ProgramID  uint   `json:"program_id" form:"program_id"`
+		SemesterID uint   `json:"semester_id" form:"semester_id"`
+		Reason     string `json:"reason" form:"reason"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid form data"})
+	}
+	if req.Reason == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "A reason is required to withdraw a result"})
+	}
+	if err := checkCohortAccess(c, req.ProgramID); err != nil {
+		return utils.RespondFiberError(c, err)
+	}
 
-		// Validate that all compulsory courses are marked and exactly one optional course is marked
-		if !allCompulsoryMarked || optionalMarkedCount != 1 {
-			log.Printf("All compulsory courses or exactly one optional course are not marked for student %d in semester %d\n", student.ID, req.SemesterID)
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": "All compulsory courses and exactly one optional course are required to be marked for each student",
-			})
-		}
+	result, skipped, err := utils.WithdrawResult(req.BatchID, req.ProgramID, req.SemesterID, req.Reason)
+	if err != nil {
+		return utils.RespondFiberError(c, err)
 	}
 
-	// If all students have marks for all courses, proceed to publish results
-	for _, student := range students {
-		// Increment the semester
-		if student.CurrentSemester < 8 {
-			student.CurrentSemester++
-		} else {
-			student.Status = "Graduated"
-		}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Result withdrawn and promotions undone",
+		"result":  result,
+		"skipped": skipped, // Students whose semester or status changed since the publication
+	})
+}
 
-		if err := initializers.DB.Save(&student).Error; err != nil {
-			log.Printf("Failed to update semester for student %d: %v\n", student.ID, err)
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Failed to update semester",
-			})
-		}
+// GetResultHistory lists every version of a result with what students saw in it
+func GetResultHistory(c *fiber.Ctx) error {
+	var req struct {
+		BatchID    uint `query:"batch_id"`
+		ProgramID  uint `query:"program_id"`
+		SemesterID uint `query:"semester_id"`
+	}
+	if err := c.QueryParser(&req); err != nil || req.BatchID == 0 || req.ProgramID == 0 || req.SemesterID == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "batch_id, program_id and semester_id are required"})
+	}
+	if err := checkCohortAccess(c, req.ProgramID); err != nil {
+		return utils.RespondFiberError(c, err)
 	}
 
-	// Save the result in the database
-	newResult := models.Result{
-		BatchID:    req.BatchID,
-		ProgramID:  req.ProgramID,
-		SemesterID: req.SemesterID,
-		Status:     "Published",
+	result, err := utils.ResultHistory(req.BatchID, req.ProgramID, req.SemesterID)
+	if err != nil {
+		return utils.RespondFiberError(c, err)
 	}
-	if err := initializers.DB.Create(&newResult).Error; err != nil {
-		log.Printf("Failed to save result: %v\n", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to save result",
-		})
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"result": result,
+	})
+}
+
+// RebuildTranscripts fills the stored transcripts again from the published results, for results
+// published before transcripts were stored
+func RebuildTranscripts(c *fiber.Ctx) error {
+	cohorts, err := utils.RebuildAllTranscripts()
+	if err != nil {
+		return utils.RespondFiberError(c, err)
 	}
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
-		"message": "Results published and semesters updated",
+		"message": "Transcripts rebuilt",
+		"cohorts": cohorts,
 	})
 }
 
-// To get only pass students by semester and rank them by total marks they obtained in all subject
+// To get only pass students by semester and rank them by their grade point average, SGPA when a
+// semester is given and CGPA otherwise, with total marks breaking ties
 func PassingStudentsBySemester(c *fiber.Ctx) error {
 	programID := c.Query("program_id")
 	batchID := c.Query("batch_id")
 	semesterID := c.Query("semester_id")
 
-	// Retrieve students based on program, batch, and semester
+	// Retrieve students based on program and batch
 	var students []models.Student
 	query := initializers.DB.Preload("Program")
 
@@ -193,14 +193,19 @@ func PassingStudentsBySemester(c *fiber.Ctx) error {
 	if batchID != "" {
 		query = query.Where("batch_id = ?", batchID)
 	}
-	if semesterID != "" {
-		query = query.Where("semester_id = ?", semesterID)
-	}
 
 	if err := query.Find(&students).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).SendString("Error fetching students")
 	}
 
+	var semester uint64
+	if semesterID != "" {
+		var err error
+		if semester, err = strconv.ParseUint(semesterID, 10, 32); err != nil {
+			return c.Status(fiber.StatusBadRequest).SendString("Invalid semester id")
+		}
+	}
+
 	// Get pass status for the semester
 	passStatus, err := utils.GetPassStatusBySemester(semesterID)
 	if err != nil {
@@ -211,6 +216,8 @@ func PassingStudentsBySemester(c *fiber.Ctx) error {
 	var studentsData []struct {
 		models.Student
 		TotalMarks int
+		SGPA       float64
+		CGPA       float64
 		PassStatus string
 		Rank       int
 	}
@@ -219,48 +226,80 @@ func PassingStudentsBySemester(c *fiber.Ctx) error {
 	studentMarks := make(map[uint]int)
 
 	var marks []models.Mark
-	if err := initializers.DB.Find(&marks).Error; err != nil {
+	if err := initializers.DB.Preload("Course").Find(&marks).Error; err != nil {
 		log.Printf("Failed to fetch marks: %v\n", err)
 		return c.Status(fiber.StatusInternalServerError).SendString("Error fetching marks")
 	}
 
-	for _, mark := range marks {
+	counted, err := utils.CountedMarks(marks)
+	if err != nil {
+		log.Printf("Failed to pick counted marks: %v\n", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("Error fetching marks")
+	}
+	for _, mark := range counted {
+		if semesterID != "" && mark.SemesterID != uint(semester) {
+			continue
+		}
 		studentMarks[mark.StudentID] += mark.TotalMarks
 	}
 
+	reports, err := utils.BuildGradeReports(marks)
+	if err != nil {
+		log.Printf("Failed to compute grades: %v\n", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("Error computing grades")
+	}
 	// Populate the studentsData slice
 	for _, student := range students {
-		totalMarks := studentMarks[student.ID]
+		totalMarks, ok := studentMarks[student.ID]
+		if !ok {
+			continue // Skip students without marks in the semester
+		}
 		status := passStatus[student.ID]
 		if status == "fail" {
 			continue // Skip students with a "fail" status
 		}
+		var sgpa, cgpa float64
+		if report, ok := reports[student.ID]; ok {
+			sgpa, cgpa = report.SGPA(uint(semester)), report.CGPA
+		}
 		studentsData = append(studentsData, struct {
 			models.Student
 			TotalMarks int
+			SGPA       float64
+			CGPA       float64
 			PassStatus string
 			Rank       int
 		}{
 			Student:    student,
 			TotalMarks: totalMarks,
+			SGPA:       sgpa,
+			CGPA:       cgpa,
 			PassStatus: status,
 		})
 	}
 
-	// Sort studentsData by TotalMarks in descending order
-	sort.Slice(studentsData, func(i, j int) bool {
+	gpa := func(i int) float64 {
+		if semesterID != "" {
+			return studentsData[i].SGPA
+		}
+		return studentsData[i].CGPA
+	}
+
+	// Sort studentsData by grade point average, then TotalMarks, in descending order
+	sort.SliceStable(studentsData, func(i, j int) bool {
+		if gpa(i) != gpa(j) {
+			return gpa(i) > gpa(j)
+		}
 		return studentsData[i].TotalMarks > studentsData[j].TotalMarks
 	})
 
-	// Assign ranks
+	// Assign ranks, sharing a rank only when both the average and the marks are equal
 	rank := 1
-	previousMarks := -1
 	for i, student := range studentsData {
-		if previousMarks == -1 || student.TotalMarks != previousMarks {
+		if i == 0 || gpa(i) != gpa(i-1) || student.TotalMarks != studentsData[i-1].TotalMarks {
 			rank = i + 1
 		}
 		studentsData[i].Rank = rank
-		previousMarks = student.TotalMarks
 	}
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
@@ -268,6 +307,30 @@ func PassingStudentsBySemester(c *fiber.Ctx) error {
 	})
 }
 
+// GetPublishedResultGrades lists the letter grades, SGPA and CGPA of every student in a published result
+func GetPublishedResultGrades(c *fiber.Ctx) error {
+	var req struct {
+		BatchID    uint `query:"batch_id"`
+		ProgramID  uint `query:"program_id"`
+		SemesterID uint `query:"semester_id"`
+	}
+	if err := c.QueryParser(&req); err != nil || req.BatchID == 0 || req.ProgramID == 0 || req.SemesterID == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "batch_id, program_id and semester_id are required"})
+	}
+	if err := checkCohortAccess(c, req.ProgramID); err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
+	grades, err := utils.ResultGrades(req.BatchID, req.ProgramID, req.SemesterID)
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"students": grades,
+	})
+}
+
 func FailedStudentsByCourse(c *fiber.Ctx) error {
 	batchID := c.Query("batch_id")
 	programID := c.Query("program_id")
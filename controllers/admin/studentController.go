@@ -5,11 +5,13 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/mysterybee07/result-distribution-system/initializers"
 	"github.com/mysterybee07/result-distribution-system/middleware/validation"
 	"github.com/mysterybee07/result-distribution-system/models"
+	"github.com/mysterybee07/result-distribution-system/utils"
 )
 
 func Student(c *fiber.Ctx) error {
@@ -35,6 +37,11 @@ func Student(c *fiber.Ctx) error {
 }
 
 func CreateStudents(c *fiber.Ctx) error {
+	access, err := utils.RequestAccess(c)
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
 	// Check if a file is uploaded
 	file, err := c.FormFile("file")
 	if err == nil {
@@ -98,11 +105,27 @@ func CreateStudents(c *fiber.Ctx) error {
 				CollegeID:          collegeID,
 			}
 
+			// An optional fifth column holds the date of birth
+			if len(fields) > 4 && strings.TrimSpace(fields[4]) != "" {
+				dob, err := time.Parse("2006-01-02", strings.TrimSpace(fields[4]))
+				if err != nil {
+					return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+						"error": fmt.Sprintf("Invalid date of birth for symbol number %s, use 2006-01-02", symbolNumber),
+					})
+				}
+				student.DateOfBirth = &dob
+			}
+
 			if err := validation.ValidateStudent(&student, false); err != nil {
 				return c.Status(err.(*fiber.Error).Code).JSON(fiber.Map{
 					"error": err.Error(),
 				})
 			}
+			if !access.AllowsStudent(student) {
+				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+					"error": fmt.Sprintf("Symbol number %s is outside your programs or colleges", symbolNumber),
+				})
+			}
 
 			students = append(students, student)
 		}
@@ -128,7 +151,8 @@ func CreateStudents(c *fiber.Ctx) error {
 			Fullname           string      `json:"fullname"`
 			SymbolNumber       string      `json:"symbol_number"`
 			RegistrationNumber string      `json:"registration_number"`
-			CollegeID          interface{} `json:"college_id"` // Use interface{} to allow for both uint and string
+			DateOfBirth        string      `json:"date_of_birth"` // 2006-01-02, optional
+			CollegeID          interface{} `json:"college_id"`    // Use interface{} to allow for both uint and string
 		} `json:"students"`
 	}
 
@@ -167,12 +191,26 @@ func CreateStudents(c *fiber.Ctx) error {
 			ProgramID:          input.ProgramID,
 			CollegeID:          collegeID,
 		}
+		if s.DateOfBirth != "" {
+			dob, err := time.Parse("2006-01-02", s.DateOfBirth)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": fmt.Sprintf("Invalid date of birth for symbol number %s, use 2006-01-02", s.SymbolNumber),
+				})
+			}
+			student.DateOfBirth = &dob
+		}
 
 		if err := validation.ValidateStudent(&student, false); err != nil {
 			return c.Status(err.(*fiber.Error).Code).JSON(fiber.Map{
 				"error": err.Error(),
 			})
 		}
+		if !access.AllowsStudent(student) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": fmt.Sprintf("Symbol number %s is outside your programs or colleges", s.SymbolNumber),
+			})
+		}
 
 		students = append(students, student)
 	}
@@ -206,6 +244,7 @@ func UpdateStudent(c *fiber.Ctx) error {
 		Fullname           string `json:"fullname"`
 		SymbolNumber       string `json:"symbol_number"`
 		RegistrationNumber string `json:"registration_number"`
+		DateOfBirth        string `json:"date_of_birth"` // 2006-01-02, optional
 		BatchID            uint   `json:"batch_id"`
 		ProgramID          uint   `json:"program_id"`
 		CollegeID          uint   `json:"college_id"`
@@ -218,9 +257,14 @@ func UpdateStudent(c *fiber.Ctx) error {
 		})
 	}
 
+	access, err := utils.RequestAccess(c)
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
 	// Find the student by ID
 	var student models.Student
-	if err := initializers.DB.First(&student, id).Error; err != nil {
+	if err := initializers.DB.First(&student, id).Error; err != nil || !access.AllowsStudent(student) {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"message": "Student not found",
 		})
@@ -233,6 +277,15 @@ func UpdateStudent(c *fiber.Ctx) error {
 	student.BatchID = input.BatchID
 	student.ProgramID = input.ProgramID
 	student.CollegeID = input.CollegeID
+	if input.DateOfBirth != "" {
+		dob, err := time.Parse("2006-01-02", input.DateOfBirth)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid date of birth, use 2006-01-02",
+			})
+		}
+		student.DateOfBirth = &dob
+	}
 
 	// Validate updated student data
 	if err := validation.ValidateStudent(&student, true); err != nil {
@@ -240,6 +293,11 @@ func UpdateStudent(c *fiber.Ctx) error {
 			"error": err.Error(),
 		})
 	}
+	if !access.AllowsStudent(student) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "The student cannot be moved outside your programs or colleges",
+		})
+	}
 
 	// Save the updated student data
 	if err := initializers.DB.Save(&student).Error; err != nil {
@@ -248,6 +306,13 @@ func UpdateStudent(c *fiber.Ctx) error {
 		})
 	}
 
+	// Lookups verify students against their stored transcript
+	if err := utils.SyncTranscriptStudent(student); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not update the student's published transcript",
+		})
+	}
+
 	return c.JSON(fiber.Map{
 		"message": "Student updated successfully",
 		"student": student,
@@ -255,10 +320,15 @@ func UpdateStudent(c *fiber.Ctx) error {
 }
 
 func GetStudents(c *fiber.Ctx) error {
+	access, err := utils.RequestAccess(c)
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
 	var students []models.Student
 
-	// Use Preload to include Batch and Program associations
-	result := initializers.DB.Preload("Batch").Preload("Program").Find(&students)
+	// Use Preload to include Batch and Program associations, within the user's scope
+	result := access.Scope(initializers.DB, "program_id", "college_id").Preload("Batch").Preload("Program").Find(&students)
 	if result.Error != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Could not retrieve students",
@@ -273,8 +343,14 @@ func GetStudents(c *fiber.Ctx) error {
 
 func GetStudentById(c *fiber.Ctx) error {
 	id := c.Params("id")
+	access, err := utils.RequestAccess(c)
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
 	var student models.Student
-	if err := initializers.DB.Preload("Batch").Preload("Program").Preload("Semester").First(&student, id).Error; err != nil {
+	if err := access.Scope(initializers.DB, "program_id", "college_id").
+		Preload("Batch").Preload("Program").Preload("Semester").First(&student, id).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Student not found",
 		})
@@ -290,8 +366,13 @@ func GetFilteredStudents(c *fiber.Ctx) error {
 	programID := c.Query("program_id")
 	semesterID := c.Query("semester_id")
 
+	access, err := utils.RequestAccess(c)
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
 	var students []models.Student
-	if err := initializers.DB.Preload("Batch").Preload("Program").Preload("Semester").
+	if err := access.Scope(initializers.DB, "program_id", "college_id").Preload("Batch").Preload("Program").Preload("Semester").
 		Where("batch_id = ? AND program_id = ? AND current_semester = ?", batchID, programID, semesterID).
 		Find(&students).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -306,8 +387,13 @@ func GetFilteredStudents(c *fiber.Ctx) error {
 
 func DeleteStudent(c *fiber.Ctx) error {
 	id := c.Params("id")
+	access, err := utils.RequestAccess(c)
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
 	var student models.Student
-	if err := initializers.DB.First(&student, id).Error; err != nil {
+	if err := access.Scope(initializers.DB, "program_id", "college_id").First(&student, id).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Student not found",
 		})
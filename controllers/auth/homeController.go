@@ -4,7 +4,7 @@ import (
 	"errors"
 	"log"
 	"os"
-	"time"
+	"strconv"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/mysterybee07/result-distribution-system/initializers"
@@ -33,7 +33,7 @@ func StoreRegister(c *fiber.Ctx) error {
 		RegistrationNumber: userInput.RegistrationNumber,
 		Email:              userInput.Email,
 		Password:           userInput.Password,
-		Role:               userInput.Role,
+		Role:               models.RoleUser, // Other roles are given through the access control API
 	}
 
 	// Hash the password
@@ -52,6 +52,27 @@ func StoreRegister(c *fiber.Ctx) error {
 		})
 	}
 
+	// A taken email or a student who already has an account gets the same answer as a new
+	// registration; the owner of the existing account is told by mail instead
+	var existing []models.User
+	if err := initializers.DB.
+		Where("email = ?", user.Email).
+		Or("symbol_number = ? AND batch_id = ? AND program_id = ?", user.SymbolNumber, user.BatchID, user.ProgramID).
+		Or("registration_number = ? AND batch_id = ? AND program_id = ?", user.RegistrationNumber, user.BatchID, user.ProgramID).
+		Find(&existing).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "User creation failed",
+		})
+	}
+	if len(existing) > 0 {
+		for _, owner := range existing {
+			utils.NotifyRegistrationAttempt(owner.Email)
+		}
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message": registrationReceived,
+		})
+	}
+
 	// Save the user to the database first (before saving the image to the file system)
 	if err := initializers.DB.Create(&user).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -81,13 +102,19 @@ func StoreRegister(c *fiber.Ctx) error {
 		})
 	}
 
+	// The account cannot log in until the emailed link is opened
+	if err := utils.SendEmailVerification(user); err != nil {
+		log.Println("Failed to send email verification:", err)
+	}
+
 	// Return a success message
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
-		"message": "User created successfully",
-		"user":    user,
+		"message": registrationReceived,
 	})
 }
 
+const registrationReceived = "Registration received, open the link mailed to you to verify your email before logging in"
+
 func LoginUser(c *fiber.Ctx) error {
 	type LoginData struct {
 		Identifier string `json:"identifier"`
@@ -109,10 +136,12 @@ func LoginUser(c *fiber.Ctx) error {
 
 	// Find user by email or symbol
 	if err := initializers.DB.Where("email = ? OR symbol_number = ?", loginData.Identifier, loginData.Identifier).First(&user).Error; err != nil {
+		// An unknown account is answered like a wrong password, and as slowly
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			utils.CheckPasswordOfMissingUser(loginData.Password)
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 				"errors": fiber.Map{
-					"identifier": "User not found for the provided email or symbol",
+					"password": "Incorrect password or identifier",
 				},
 			})
 		}
@@ -132,9 +161,18 @@ func LoginUser(c *fiber.Ctx) error {
 		})
 	}
 
-	// Generate JWT token after successful login
-	_, err := utils.GenerateJwt(user.ID, user.Role, c)
-	if err != nil {
+	// Only told after the right password, so it does not show which accounts exist
+	if user.EmailVerifiedAt == nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"errors": fiber.Map{
+				"email": "Verify your email before logging in",
+			},
+		})
+	}
+
+	// Start a session: a short-lived access token and a refresh token to renew it
+	if _, err := utils.StartSession(c, user); err != nil {
+		log.Println("Failed to start session:", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"errors": fiber.Map{"message": "Failed to generate JWT token"},
 		})
@@ -165,6 +203,23 @@ func UpdateUser(c *fiber.Ctx) error {
 	id := c.Params("id")
 	var user models.User
 
+	// Users change their own account; anyone else needs users:manage
+	userID, _ := c.Locals("userID").(string)
+	self := userID == id
+	var editor *utils.Access
+	if !self {
+		access, err := utils.RequestAccess(c)
+		if err != nil {
+			return utils.RespondFiberError(c, err)
+		}
+		if !access.Can("users:manage") {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"message": "forbidden",
+			})
+		}
+		editor = access
+	}
+
 	// Find user
 	if err := initializers.DB.First(&user, id).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
@@ -185,11 +240,35 @@ func UpdateUser(c *fiber.Ctx) error {
 		})
 	}
 	// Update the user fields only if they are provided
-	if updateData.Email != "" && updateData.Email != user.Email {
+	// A new email has to be verified again
+	emailChanged := updateData.Email != "" && updateData.Email != user.Email
+	if emailChanged && !utils.ValidateEmail(updateData.Email) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid email format",
+		})
+	}
+	// Another user's email is a way into their account, so it only changes when the editor already
+	// holds every permission the account has
+	if emailChanged && !self {
+		target, err := utils.UserAccess(id)
+		if err != nil {
+			return utils.RespondFiberError(c, err)
+		}
+		if !editor.Covers(target) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"message": "You cannot change the email of an account with permissions you do not have",
+			})
+		}
+	}
+	if emailChanged {
 		user.Email = updateData.Email
+		user.EmailVerifiedAt = nil
 	}
 
-	if updateData.Password != "" {
+	// Other users' passwords are never set directly; they get a reset link instead
+	passwordChanged := self && updateData.Password != ""
+	passwordReset := !self && updateData.Password != ""
+	if passwordChanged {
 		hashpassword, err := utils.HashPassword(updateData.Password)
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -219,6 +298,26 @@ func UpdateUser(c *fiber.Ctx) error {
 		})
 	}
 
+	if emailChanged {
+		if err := utils.SendEmailVerification(user); err != nil {
+			log.Println("Failed to send email verification:", err)
+		}
+	}
+
+	// A new password signs the account out everywhere else
+	if passwordChanged {
+		current, _ := c.Locals("sessionID").(uint)
+		if _, err := utils.RevokeSessions(user.ID, current); err != nil {
+			log.Println("Failed to revoke sessions after a password change:", err)
+		}
+	}
+	if passwordReset {
+		go utils.RequestPasswordReset(user.Email)
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message": "Account updated; a link to reset the password has been sent to the user",
+		})
+	}
+
 	// Return success message as JSON
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		// "user":    existingUser,
@@ -226,22 +325,132 @@ func UpdateUser(c *fiber.Ctx) error {
 	})
 }
 
-// LogoutUser logs out the user by clearing the JWT cookie
+// LogoutUser revokes the session of the request and clears its cookies
 func LogoutUser(c *fiber.Ctx) error {
-	// Clear the cookie
-	cookies := fiber.Cookie{
-		Name:     "jwt",
-		Value:    "",
-		Expires:  time.Now().Add(-1 * time.Second), // Set the cookie to expire immediately
-		HTTPOnly: true,
+	if err := utils.EndSession(c); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to log out",
+		})
 	}
-	c.Cookie(&cookies)
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"message": "logout successfully",
 	})
 }
 
+// RefreshToken renews the access token with the refresh cookie, which is replaced as well
+func RefreshToken(c *fiber.Ctx) error {
+	user, err := utils.RefreshSession(c)
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Token refreshed",
+		"user": fiber.Map{
+			"id":    user.ID,
+			"email": user.Email,
+			"role":  user.Role,
+		},
+	})
+}
+
+// LogoutAllSessions revokes every session of the signed in user, this one included
+func LogoutAllSessions(c *fiber.Ctx) error {
+	userID, err := strconv.ParseUint(c.Locals("userID").(string), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "unauthorized"})
+	}
+
+	revoked, err := utils.RevokeSessions(uint(userID), 0)
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+	utils.ClearAuthCookies(c)
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":  "Logged out of all sessions",
+		"sessions": revoked,
+	})
+}
+
+// GetSessions lists the active sessions of the signed in user
+func GetSessions(c *fiber.Ctx) error {
+	userID, err := strconv.ParseUint(c.Locals("userID").(string), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "unauthorized"})
+	}
+	current, _ := c.Locals("sessionID").(uint)
+
+	sessions, err := utils.ActiveSessions(uint(userID))
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
+	result := []fiber.Map{}
+	for _, session := range sessions {
+		result = append(result, fiber.Map{
+			"id":           session.ID,
+			"user_agent":   session.UserAgent,
+			"ip_address":   session.IPAddress,
+			"created_at":   session.CreatedAt,
+			"last_used_at": session.LastUsedAt,
+			"expires_at":   session.ExpiresAt,
+			"current":      session.ID == current,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"sessions": result,
+	})
+}
+
+// RevokeSession ends one session of the signed in user, such as one on a lost device
+func RevokeSession(c *fiber.Ctx) error {
+	userID, err := strconv.ParseUint(c.Locals("userID").(string), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "unauthorized"})
+	}
+	sessionID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid session id"})
+	}
+
+	if err := utils.RevokeSession(uint(userID), uint(sessionID)); err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+	if current, _ := c.Locals("sessionID").(uint); current == uint(sessionID) {
+		utils.ClearAuthCookies(c)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Session revoked",
+	})
+}
+
+// RevokeUserSessions signs a user out everywhere, for admins dealing with a compromised account
+func RevokeUserSessions(c *fiber.Ctx) error {
+	userID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid user id"})
+	}
+
+	var user models.User
+	if err := initializers.DB.First(&user, userID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "User not found"})
+	}
+
+	revoked, err := utils.RevokeSessions(user.ID, 0)
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":  "Sessions revoked",
+		"sessions": revoked,
+	})
+}
+
 func AuthorizedUser(c *fiber.Ctx) error {
 	userID := c.Locals("userID").(string) // Retrieve user ID from locals
 
@@ -298,11 +507,83 @@ func GetUserById(c *fiber.Ctx) error {
 	})
 }
 
+// ForgotPassword mails a reset link. The answer is the same whether or not the email has an
+// account.
 func ForgotPassword(c *fiber.Ctx) error {
-	err := c.Render("users/forgot-password", fiber.Map{})
-	if err != nil {
-		c.Status(fiber.StatusInternalServerError).SendString("Error rendering page")
-		return err
+	var input struct {
+		Email string `json:"email"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request payload",
+		})
+	}
+
+	// In the background, so the answer takes as long whether or not an account exists
+	go utils.RequestPasswordReset(input.Email)
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "If an account exists for the email, a link to reset the password has been sent",
+	})
+}
+
+// ResetPassword sets a new password with the token from a reset link
+func ResetPassword(c *fiber.Ctx) error {
+	var input struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request payload",
+		})
+	}
+
+	if err := utils.ResetPassword(input.Token, input.Password); err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Password reset successfully, log in with the new password",
+	})
+}
+
+// VerifyEmail verifies the email with the token from a verification link
+func VerifyEmail(c *fiber.Ctx) error {
+	var input struct {
+		Token string `json:"token"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request payload",
+		})
+	}
+
+	if err := utils.VerifyEmail(input.Token); err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Email verified, you can log in now",
+	})
+}
+
+// ResendVerification mails a new verification link to an account that is not verified yet,
+// answering the same for any email
+func ResendVerification(c *fiber.Ctx) error {
+	var input struct {
+		Email string `json:"email"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request payload",
+		})
 	}
-	return nil
+
+	// In the background, so the answer takes as long whether or not an account exists
+	go utils.ResendEmailVerification(input.Email)
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "If an unverified account exists for the email, a new verification link has been sent",
+	})
 }
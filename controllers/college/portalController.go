@@ -0,0 +1,291 @@
+package controllers
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mysterybee07/result-distribution-system/initializers"
+	"github.com/mysterybee07/result-distribution-system/models"
+	"github.com/mysterybee07/result-distribution-system/utils"
+	"gorm.io/gorm"
+)
+
+// portalCollege is the college of the signed in college admin; every portal query is limited
+// to it
+func portalCollege(c *fiber.Ctx) (uint, error) {
+	access, err := utils.RequestAccess(c)
+	if err != nil {
+		return 0, err
+	}
+	return access.PortalCollege()
+}
+
+// GetPortalStudents lists the students of the college
+func GetPortalStudents(c *fiber.Ctx) error {
+	collegeID, err := portalCollege(c)
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+	query := initializers.DB.Preload("Batch").Preload("Program").
+		Where("college_id = ?", collegeID).
+		Order("batch_id, program_id, symbol_number")
+
+	// Apply filters if provided
+	if batchID := c.Query("batch_id"); batchID != "" {
+		query = query.Where("batch_id = ?", batchID)
+	}
+	if programID := c.Query("program_id"); programID != "" {
+		query = query.Where("program_id = ?", programID)
+	}
+	if semester := c.Query("semester"); semester != "" {
+		query = query.Where("current_semester = ?", semester)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var students []models.Student
+	if err := query.Find(&students).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch students"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"students": students,
+	})
+}
+
+// GetPortalExamCenters lists the centers the college's students were sent to by published
+// allocations
+func GetPortalExamCenters(c *fiber.Ctx) error {
+	collegeID, err := portalCollege(c)
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+	query := initializers.DB.Preload("ExamRoutine").
+		Preload("Items", "college_id = ?", collegeID).
+		Preload("Items.Center").
+		Where("status = ?", "Published").
+		Where("id IN (?)", initializers.DB.Model(&models.CenterAllocationItem{}).
+			Select("center_allocation_id").Where("college_id = ?", collegeID)).
+		Order("exam_routine_id")
+
+	// Apply filters if provided
+	if routineID := c.Query("exam_routine_id"); routineID != "" {
+		query = query.Where("exam_routine_id = ?", routineID)
+	}
+	if batchID := c.Query("batch_id"); batchID != "" {
+		query = query.Where("batch_id = ?", batchID)
+	}
+	if programID := c.Query("program_id"); programID != "" {
+		query = query.Where("program_id = ?", programID)
+	}
+
+	var allocations []models.CenterAllocation
+	if err := query.Find(&allocations).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch exam centers"})
+	}
+
+	assignments := []fiber.Map{}
+	for _, allocation := range allocations {
+		assignments = append(assignments, fiber.Map{
+			"exam_routine": allocation.ExamRoutine,
+			"published_at": allocation.PublishedAt,
+			"centers":      allocation.Items,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"assignments": assignments,
+	})
+}
+
+// GetPortalSeats lists where each student of the college sits for an exam routine
+func GetPortalSeats(c *fiber.Ctx) error {
+	collegeID, err := portalCollege(c)
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+	routineID, err := strconv.ParseUint(c.Query("exam_routine_id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "exam_routine_id is required"})
+	}
+
+	// Only the seat plan of the published allocation counts
+	var seats []models.SeatAssignment
+	if err := initializers.DB.Preload("Student").Preload("Center").Preload("ExamRoom").
+		Joins("JOIN students ON students.id = seat_assignments.student_id").
+		Joins("JOIN center_allocations ON center_allocations.id = seat_assignments.center_allocation_id").
+		Where("seat_assignments.exam_routine_id = ? AND students.college_id = ? AND center_allocations.status = ?",
+			routineID, collegeID, "Published").
+		Order("students.symbol_number").
+		Find(&seats).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch seat plan"})
+	}
+
+	rows := []fiber.Map{}
+	for _, seat := range seats {
+		rows = append(rows, fiber.Map{
+			"student_id":    seat.StudentID,
+			"symbol_number": seat.Student.SymbolNumber,
+			"fullname":      seat.Student.Fullname,
+			"center":        seat.Center.CollegeName,
+			"room":          seat.ExamRoom.Name,
+			"seat_number":   seat.SeatNumber,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"seats": rows,
+	})
+}
+
+// DownloadPortalAdmitCards sends the college's zip from the latest completed admit card batch
+// of an exam routine
+func DownloadPortalAdmitCards(c *fiber.Ctx) error {
+	collegeID, err := portalCollege(c)
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+	routineID := c.Params("routineID")
+
+	var batch models.AdmitCardBatch
+	if err := initializers.DB.Where("exam_routine_id = ? AND status = ?", routineID, "Completed").
+		Order("finished_at desc").First(&batch).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Admit cards have not been generated for the exam routine",
+		})
+	}
+
+	path := utils.AdmitCardZipPath(batch, collegeID)
+	if _, err := os.Stat(path); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "No admit cards were generated for your college",
+		})
+	}
+
+	return c.Download(path, fmt.Sprintf("AdmitCards_Routine%d.zip", batch.ExamRoutineID))
+}
+
+// portalNotices limits a notice query to published notices for the programs and batches the
+// college has students in. Notices without a batch are for every batch of the program.
+func portalNotices(collegeID uint) *gorm.DB {
+	cohorts := initializers.DB.Model(&models.Student{}).Select("program_id").Where("college_id = ?", collegeID)
+	batches := initializers.DB.Model(&models.Student{}).Select("batch_id").Where("college_id = ?", collegeID)
+	return initializers.DB.Preload("Program").Preload("Batch").Preload("Semester").
+		Where("status = ?", "Published").
+		Where("program_id IN (?)", cohorts).
+		Where("batch_id IS NULL OR batch_id IN (?)", batches)
+}
+
+func GetPortalNotices(c *fiber.Ctx) error {
+	collegeID, err := portalCollege(c)
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
+	var allNotices []models.Notice
+	if err := portalNotices(collegeID).Order("created_at desc").Find(&allNotices).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch notices"})
+	}
+
+	notices := []fiber.Map{}
+	for _, notice := range allNotices {
+		notices = append(notices, fiber.Map{
+			"ID":          notice.ID,
+			"Title":       notice.Title,
+			"Description": notice.Description,
+			"Program":     notice.Program.ProgramName,
+			"Batch":       notice.Batch.Batch,
+			"Semester":    notice.Semester.SemesterName,
+			"Created_at":  notice.CreatedAt,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"notices": notices,
+	})
+}
+
+func DownloadPortalNotice(c *fiber.Ctx) error {
+	collegeID, err := portalCollege(c)
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+	id := c.Params("id")
+
+	var notice models.Notice
+	if err := portalNotices(collegeID).First(&notice, id).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Notice not found"})
+	}
+	if notice.FilePath == "" {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "The notice has no file"})
+	}
+
+	return c.Download(notice.FilePath)
+}
+
+// SubmitRegistrationList sends a list of new students of the college for approval
+func SubmitRegistrationList(c *fiber.Ctx) error {
+	access, err := utils.RequestAccess(c)
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+	collegeID, err := access.PortalCollege()
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
+	var input models.StudentRegistrationInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	list, err := utils.SubmitRegistrationList(collegeID, access.UserID, input)
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "Registration list submitted for approval",
+		"list":    list,
+	})
+}
+
+func GetPortalRegistrationLists(c *fiber.Ctx) error {
+	collegeID, err := portalCollege(c)
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+	query := initializers.DB.Where("college_id = ?", collegeID).Order("created_at desc")
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var lists []models.StudentRegistrationList
+	if err := query.Find(&lists).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch registration lists"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"lists": lists,
+	})
+}
+
+func GetPortalRegistrationList(c *fiber.Ctx) error {
+	collegeID, err := portalCollege(c)
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+	id := c.Params("id")
+
+	var list models.StudentRegistrationList
+	if err := initializers.DB.Preload("Entries").Where("college_id = ?", collegeID).First(&list, id).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Registration list not found"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"list": list,
+	})
+}
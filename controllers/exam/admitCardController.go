@@ -0,0 +1,160 @@
+package controller
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mysterybee07/result-distribution-system/initializers"
+	"github.com/mysterybee07/result-distribution-system/models"
+	"github.com/mysterybee07/result-distribution-system/utils"
+)
+
+// GetAdmitCard renders the admit card of one student as a PDF, for the student themself or for
+// staff and college accounts whose scope covers the student
+func GetAdmitCard(c *fiber.Ctx) error {
+	routineID, err := strconv.ParseUint(c.Params("routineID"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid exam routine id"})
+	}
+	symbolNumber := c.Params("symbolNumber")
+
+	cards, _, err := utils.LoadAdmitCards(uint(routineID), 0, symbolNumber)
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+	// Cards the user may not see are reported like missing ones, so symbol numbers cannot be probed
+	if len(cards) == 0 || !mayViewAdmitCard(c, cards[0].Student) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Student has no seat for the exam routine",
+		})
+	}
+
+	content, err := utils.RenderAdmitCardsPDF(cards)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	c.Attachment(fmt.Sprintf("AdmitCard_%s.pdf", utils.SanitizeFileName(symbolNumber)))
+	c.Type("pdf")
+	return c.Send(content)
+}
+
+// mayViewAdmitCard reports whether the signed in user is the student, through the account
+// registered with their symbol number, or staff whose scope covers the student
+func mayViewAdmitCard(c *fiber.Ctx, student models.Student) bool {
+	access, err := utils.RequestAccess(c)
+	if err != nil {
+		return false
+	}
+	if access.Can("exams:manage") || access.Can("college:portal") {
+		return access.AllowsStudent(student)
+	}
+
+	var user models.User
+	if err := initializers.DB.First(&user, access.UserID).Error; err != nil {
+		return false
+	}
+	return user.SymbolNumber == student.SymbolNumber &&
+		user.BatchID != nil && *user.BatchID == student.BatchID &&
+		user.ProgramID != nil && *user.ProgramID == student.ProgramID
+}
+
+// GenerateAdmitCards starts the background job that writes a zip of admit cards per college
+func GenerateAdmitCards(c *fiber.Ctx) error {
+	routineID, err := strconv.ParseUint(c.Params("routineID"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid exam routine id"})
+	}
+
+	batch, err := utils.StartAdmitCardBatch(uint(routineID))
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"message": "Admit card generation started",
+		"batch":   batch,
+	})
+}
+
+func GetAdmitCardBatch(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	// A batch whose job was lost shows as failed rather than running forever
+	if err := utils.FailStaleAdmitCardBatches(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	var batch models.AdmitCardBatch
+	if err := initializers.DB.First(&batch, id).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Admit card batch not found"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"batch": batch,
+	})
+}
+
+// DownloadCollegeAdmitCards sends the zip of one college from a completed batch
+func DownloadCollegeAdmitCards(c *fiber.Ctx) error {
+	id := c.Params("id")
+	collegeID, err := strconv.ParseUint(c.Params("collegeID"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid college id"})
+	}
+
+	var batch models.AdmitCardBatch
+	if err := initializers.DB.First(&batch, id).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Admit card batch not found"})
+	}
+	if batch.Status != "Completed" {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": fmt.Sprintf("Admit card batch is %s", batch.Status),
+		})
+	}
+
+	path := utils.AdmitCardZipPath(batch, uint(collegeID))
+	if _, err := os.Stat(path); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "No admit cards were generated for the college",
+		})
+	}
+
+	return c.Download(path, fmt.Sprintf("AdmitCards_Routine%d_College%d.zip", batch.ExamRoutineID, collegeID))
+}
+
+// VerifyAdmitCard is the public page the QR code on an admit card points to
+func VerifyAdmitCard(c *fiber.Ctx) error {
+	routineID, err := strconv.ParseUint(c.Query("routine_id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid routine_id"})
+	}
+	symbolNumber := c.Query("symbol_number")
+	code := c.Query("code")
+
+	if symbolNumber == "" || code != utils.AdmitCardCode(uint(routineID), symbolNumber) {
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"valid": false,
+		})
+	}
+
+	cards, _, err := utils.LoadAdmitCards(uint(routineID), 0, symbolNumber)
+	if err != nil || len(cards) == 0 {
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"valid": false,
+		})
+	}
+
+	card := cards[0]
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"valid":         true,
+		"fullname":      card.Student.Fullname,
+		"symbol_number": card.Student.SymbolNumber,
+		"college":       card.Student.College.CollegeName,
+		"center":        card.Seat.Center.CollegeName,
+		"room":          card.Seat.ExamRoom.Name,
+		"seat_number":   card.Seat.SeatNumber,
+	})
+}
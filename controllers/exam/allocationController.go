@@ -0,0 +1,136 @@
+package controller
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mysterybee07/result-distribution-system/initializers"
+	"github.com/mysterybee07/result-distribution-system/models"
+	"github.com/mysterybee07/result-distribution-system/utils"
+)
+
+// CreateCenterAllocation solves the allocation for an exam routine and stores it as a new draft
+func CreateCenterAllocation(c *fiber.Ctx) error {
+	var req models.CenterAllocationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	if req.BatchID == 0 || req.ProgramID == 0 || req.ExamRoutineID == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "batch_id, program_id and exam_routine_id are required",
+		})
+	}
+
+	allocation, err := utils.CreateCenterAllocation(req)
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message":    "Center allocation saved as draft",
+		"allocation": allocation,
+	})
+}
+
+func ListCenterAllocations(c *fiber.Ctx) error {
+	query := initializers.DB.Order("exam_routine_id, version DESC")
+
+	// Apply filters if provided
+	if batchID := c.Query("batch_id"); batchID != "" {
+		query = query.Where("batch_id = ?", batchID)
+	}
+	if programID := c.Query("program_id"); programID != "" {
+		query = query.Where("program_id = ?", programID)
+	}
+	if examRoutineID := c.Query("exam_routine_id"); examRoutineID != "" {
+		query = query.Where("exam_routine_id = ?", examRoutineID)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var allocations []models.CenterAllocation
+	if err := query.Find(&allocations).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch center allocations",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"allocations": allocations,
+	})
+}
+
+func GetCenterAllocation(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var allocation models.CenterAllocation
+	if err := initializers.DB.Preload("Items.College").Preload("Items.Center").First(&allocation, id).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Center allocation not found",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"allocation": allocation,
+	})
+}
+
+// DiffCenterAllocations compares two versions given as ?from=<id>&to=<id>
+func DiffCenterAllocations(c *fiber.Ctx) error {
+	fromID, err := strconv.ParseUint(c.Query("from"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid from"})
+	}
+	toID, err := strconv.ParseUint(c.Query("to"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid to"})
+	}
+
+	changes, err := utils.DiffCenterAllocations(uint(fromID), uint(toID))
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"from":    fromID,
+		"to":      toID,
+		"changes": changes,
+	})
+}
+
+func PublishCenterAllocation(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid allocation id"})
+	}
+
+	allocation, err := utils.PublishCenterAllocation(uint(id))
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":    "Center allocation published successfully",
+		"allocation": allocation,
+	})
+}
+
+// RollbackCenterAllocation republishes an earlier version as the newest version
+func RollbackCenterAllocation(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid allocation id"})
+	}
+
+	allocation, err := utils.RollbackCenterAllocation(uint(id))
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":    "Center allocation rolled back successfully",
+		"allocation": allocation,
+	})
+}
@@ -0,0 +1,44 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mysterybee07/result-distribution-system/utils"
+)
+
+// GetCenterClashes reports the seats needed against the capacity of every center on every exam
+// date between from and to
+func GetCenterClashes(c *fiber.Ctx) error {
+	from, err := time.Parse("2006-01-02", c.Query("from"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "from must be a date in the format 2006-01-02"})
+	}
+	to, err := time.Parse("2006-01-02", c.Query("to"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "to must be a date in the format 2006-01-02"})
+	}
+	if to.Before(from) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "to cannot be before from"})
+	}
+
+	report, err := utils.AnalyzeCenterClashes(from, to, nil)
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
+	// Only show the overloaded centers when asked to
+	if c.Query("over_capacity") == "true" {
+		var clashes []utils.CenterLoad
+		for _, load := range report.Loads {
+			if load.OverCapacity {
+				clashes = append(clashes, load)
+			}
+		}
+		report.Loads = clashes
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"report": report,
+	})
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/mysterybee07/result-distribution-system/utils"
 )
 
+// AssignCentersHandler previews the solver result for a batch/program without storing it
 func AssignCentersHandler(c *fiber.Ctx) error {
 	// Parse batch and program IDs from the query parameters
 	batchID, err := strconv.ParseUint(c.Query("batch_id"), 10, 32)
@@ -24,21 +25,27 @@ func AssignCentersHandler(c *fiber.Ctx) error {
 		})
 	}
 
+	// The radius can be overridden per request, otherwise it comes from the environment
+	opts := utils.CenterSolverOptions{RadiusKm: utils.CenterRadiusFromEnv()}
+	if radius := c.Query("radius_km"); radius != "" {
+		radiusKm, err := strconv.ParseFloat(radius, 64)
+		if err != nil || radiusKm <= 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid radius_km",
+			})
+		}
+		opts.RadiusKm = radiusKm
+	}
+
 	// Call the AssignCenters function with the parsed batchID and programID
-	assignments, err := utils.AssignCenters(uint(batchID), uint(programID))
+	solution, err := utils.AssignCenters(uint(batchID), uint(programID), opts)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": fmt.Sprintf("Failed to assign centers: %s", err.Error()),
 		})
 	}
 
-	// Write the results to a CSV file
-	if err := utils.WriteResultToFile(assignments); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": fmt.Sprintf("Failed to write result to file: %s", err.Error()),
-		})
-	}
-
-	// Return the assignments in the response
-	return c.Status(fiber.StatusOK).JSON(assignments)
+	// Nothing is stored here; use CreateCenterAllocation to keep a version of the result
+	// Return the assignments together with the solver report
+	return c.Status(fiber.StatusOK).JSON(solution)
 }
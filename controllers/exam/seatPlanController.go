@@ -0,0 +1,216 @@
+package controller
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mysterybee07/result-distribution-system/initializers"
+	"github.com/mysterybee07/result-distribution-system/models"
+	"github.com/mysterybee07/result-distribution-system/utils"
+)
+
+func CreateExamRooms(c *fiber.Ctx) error {
+	var input struct {
+		CenterID uint `json:"center_id"`
+		Rooms    []struct {
+			Name     string `json:"name"`
+			Capacity int    `json:"capacity"`
+		} `json:"rooms"`
+	}
+
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	var center models.College
+	if err := initializers.DB.First(&center, input.CenterID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Center college not found"})
+	}
+
+	if len(input.Rooms) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "No rooms provided"})
+	}
+
+	var rooms []models.ExamRoom
+	for _, room := range input.Rooms {
+		if room.Name == "" || room.Capacity <= 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Each room needs a name and a positive capacity",
+			})
+		}
+		rooms = append(rooms, models.ExamRoom{
+			CenterID: input.CenterID,
+			Name:     room.Name,
+			Capacity: room.Capacity,
+		})
+	}
+
+	if err := initializers.DB.Create(&rooms).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not create rooms"})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "Rooms created successfully",
+		"rooms":   rooms,
+	})
+}
+
+func GetExamRooms(c *fiber.Ctx) error {
+	centerID := c.Query("center_id")
+	if centerID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "center_id is required"})
+	}
+
+	var rooms []models.ExamRoom
+	if err := initializers.DB.Where("center_id = ?", centerID).Order("id").Find(&rooms).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch rooms"})
+	}
+
+	totalCapacity := 0
+	for _, room := range rooms {
+		totalCapacity += room.Capacity
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"rooms":          rooms,
+		"total_capacity": totalCapacity,
+	})
+}
+
+func UpdateExamRoom(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var room models.ExamRoom
+	if err := initializers.DB.First(&room, id).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Room not found"})
+	}
+
+	var input struct {
+		Name     string `json:"name"`
+		Capacity int    `json:"capacity"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	if input.Name != "" {
+		room.Name = input.Name
+	}
+	if input.Capacity > 0 {
+		room.Capacity = input.Capacity
+	}
+
+	if err := initializers.DB.Save(&room).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not update room"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Room updated successfully",
+		"room":    room,
+	})
+}
+
+func DeleteExamRoom(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var room models.ExamRoom
+	if err := initializers.DB.First(&room, id).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Room not found"})
+	}
+
+	// Rooms that already hold seats must keep existing so seat plans stay readable
+	var seatCount int64
+	initializers.DB.Model(&models.SeatAssignment{}).Where("exam_room_id = ?", room.ID).Count(&seatCount)
+	if seatCount > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Room is used by a seat plan"})
+	}
+
+	if err := initializers.DB.Delete(&room).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not delete room"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Room deleted successfully",
+	})
+}
+
+// GenerateSeatPlan seats every student of a published center allocation
+func GenerateSeatPlan(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid allocation id"})
+	}
+
+	seats, unseated, err := utils.GenerateSeatPlan(uint(id))
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":  "Seat plan generated successfully",
+		"seated":   len(seats),
+		"unseated": unseated,
+	})
+}
+
+func GetSeatPlan(c *fiber.Ctx) error {
+	examRoutineID := c.Query("exam_routine_id")
+	if examRoutineID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "exam_routine_id is required"})
+	}
+
+	query := initializers.DB.Preload("Student").Preload("ExamRoom").
+		Where("exam_routine_id = ?", examRoutineID).
+		Order("center_id, exam_room_id, seat_number")
+
+	// Apply filters if provided
+	if centerID := c.Query("center_id"); centerID != "" {
+		query = query.Where("center_id = ?", centerID)
+	}
+	if roomID := c.Query("room_id"); roomID != "" {
+		query = query.Where("exam_room_id = ?", roomID)
+	}
+
+	var seats []models.SeatAssignment
+	if err := query.Find(&seats).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch seat plan"})
+	}
+
+	var response []fiber.Map
+	for _, seat := range seats {
+		response = append(response, fiber.Map{
+			"symbol_number": seat.Student.SymbolNumber,
+			"fullname":      seat.Student.Fullname,
+			"college_id":    seat.Student.CollegeID,
+			"center_id":     seat.CenterID,
+			"room":          seat.ExamRoom.Name,
+			"seat_number":   seat.SeatNumber,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"seats": response,
+	})
+}
+
+// ExportRoomSeatPlan downloads the seat plan of a room as CSV
+func ExportRoomSeatPlan(c *fiber.Ctx) error {
+	roomID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid room id"})
+	}
+	examRoutineID, err := strconv.ParseUint(c.Query("exam_routine_id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid exam_routine_id"})
+	}
+
+	content, err := utils.SeatPlanCSV(uint(examRoutineID), uint(roomID))
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
+	c.Attachment(fmt.Sprintf("SeatPlan_Routine%d_Room%d.csv", examRoutineID, roomID))
+	c.Type("csv")
+	return c.Send(content)
+}
@@ -0,0 +1,41 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mysterybee07/result-distribution-system/utils"
+)
+
+// VerifyDocument is the page the QR code on a grade sheet or transcript points to. It tells
+// whether the document is valid, tampered with, withdrawn or superseded by a revision.
+func VerifyDocument(c *fiber.Ctx) error {
+	code := c.Query("code")
+	if code == "" {
+		var req struct {
+			Code string `json:"code" form:"code"`
+		}
+		if err := c.BodyParser(&req); err == nil {
+			code = req.Code
+		}
+	}
+	if code == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Verification code is required"})
+	}
+
+	verification, err := utils.VerifyDocument(code)
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"verification": verification,
+	})
+}
+
+// GetDocumentPublicKey returns the Ed25519 key documents are signed with, for offline checks
+func GetDocumentPublicKey(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"algorithm":  "Ed25519",
+		"public_key": utils.DocumentPublicKey(),
+	})
+}
@@ -0,0 +1,35 @@
+package controllers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mysterybee07/result-distribution-system/utils"
+)
+
+// LookupResult lets a student read their published results with their symbol number and either
+// their date of birth or registration number. Wrong details all get the same answer and count
+// towards the throttle of the IP.
+func LookupResult(c *fiber.Ctx) error {
+	var req struct {
+		SymbolNumber       string `json:"symbol_number" form:"symbol_number"`
+		DateOfBirth        string `json:"date_of_birth" form:"date_of_birth"`
+		RegistrationNumber string `json:"registration_number" form:"registration_number"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	lookup, err := utils.LookupPublishedResults(req.SymbolNumber, req.DateOfBirth, req.RegistrationNumber)
+	if err != nil {
+		if errors.Is(err, utils.ErrLookupMismatch) {
+			utils.RecordLookupFailure(c.IP())
+		}
+		return utils.RespondFiberError(c, err)
+	}
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"result": lookup,
+	})
+}
@@ -0,0 +1,50 @@
+package controllers
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mysterybee07/result-distribution-system/initializers"
+	"github.com/mysterybee07/result-distribution-system/models"
+)
+
+// GetNotifications lists the notifications of the signed in user, newest first. ?unread=true
+// leaves out the ones already read.
+func GetNotifications(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userID").(string)
+	query := initializers.DB.Where("user_id = ?", userID).Order("created_at desc")
+	if c.QueryBool("unread") {
+		query = query.Where("read_at IS NULL")
+	}
+
+	var notifications []models.Notification
+	if err := query.Find(&notifications).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch notifications"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"notifications": notifications,
+	})
+}
+
+func MarkNotificationRead(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userID").(string)
+	id := c.Params("id")
+
+	var notification models.Notification
+	if err := initializers.DB.Where("user_id = ?", userID).First(&notification, id).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Notification not found"})
+	}
+
+	if notification.ReadAt == nil {
+		now := time.Now()
+		if err := initializers.DB.Model(&notification).Update("read_at", now).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not update notification"})
+		}
+		notification.ReadAt = &now
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"notification": notification,
+	})
+}
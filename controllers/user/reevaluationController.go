@@ -0,0 +1,45 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mysterybee07/result-distribution-system/initializers"
+	"github.com/mysterybee07/result-distribution-system/models"
+	"github.com/mysterybee07/result-distribution-system/utils"
+)
+
+// FileReevaluation lets a student ask for re-totaling or re-checking of one published mark
+func FileReevaluation(c *fiber.Ctx) error {
+	var filing models.ReevaluationFiling
+	if err := c.BodyParser(&filing); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	if filing.MarkID == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "mark_id is required"})
+	}
+
+	userID, _ := c.Locals("userID").(string)
+	request, err := utils.FileReevaluation(userID, filing)
+	if err != nil {
+		return utils.RespondFiberError(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "Re-evaluation request submitted",
+		"request": request,
+	})
+}
+
+// GetMyReevaluations lists the requests the signed in student filed
+func GetMyReevaluations(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userID").(string)
+
+	var requests []models.ReevaluationRequest
+	if err := initializers.DB.Preload("Course").Where("user_id = ?", userID).
+		Order("created_at desc").Find(&requests).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch re-evaluation requests"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"requests": requests,
+	})
+}
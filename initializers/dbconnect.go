@@ -49,28 +49,69 @@ func Connect() {
 	// Log successful connection
 	log.Println("Connected to database successfully")
 
+	// Accounts from before email verification have no verification date and are trusted as they are
+	trustExistingEmails := DB.Migrator().HasTable(&models.User{}) && !DB.Migrator().HasColumn(&models.User{}, "EmailVerifiedAt")
+
 	// Perform migrations for the models
 	if err := DB.AutoMigrate(
 		&models.User{},
+		&models.Role{},
+		&models.Permission{},
+		&models.UserScope{},
+		&models.Session{},
+		&models.RefreshToken{},
+		&models.UserToken{},
 		&models.Batch{},
+		&models.GradingScheme{},
+		&models.GradeBand{},
 		&models.Program{},
 		&models.Semester{},
 		&models.Course{},
 		&models.Student{},
 		&models.Mark{},
 		&models.Result{},
+		&models.ResultSnapshot{},
+		&models.ResultSnapshotStudent{},
+		&models.ResultSnapshotCourse{},
+		&models.MarkAmendment{},
+		&models.PublishedTranscript{},
+		&models.IssuedDocument{},
+		&models.MarkEntry{},
+		&models.MarkReconciliation{},
+		&models.ModerationRule{},
+		&models.MarkModeration{},
+		&models.ExaminerAssignment{},
+		&models.MarkEntryDeadline{},
+		&models.ReevaluationRequest{},
+		&models.Notification{},
 		&models.Notice{},
 		&models.College{},
 		&models.CapacityAndCount{},
 		&models.ExamRoutine{},
 		&models.ExamSchedules{},
+		&models.CenterAllocation{},
+		&models.CenterAllocationItem{},
+		&models.ExamRoom{},
+		&models.SeatAssignment{},
+		&models.AdmitCardBatch{},
+		&models.CalendarEvent{},
+		&models.BackPaperRegistration{},
+		&models.StudentRegistrationList{},
+		&models.StudentRegistrationEntry{},
 	); err != nil {
 		log.Fatalf("Error migrating database: %v", err)
 	}
+	if trustExistingEmails {
+		if err := DB.Model(&models.User{}).Where("email_verified_at IS NULL").
+			Update("email_verified_at", gorm.Expr("created_at")).Error; err != nil {
+			log.Fatalf("Error marking existing emails verified: %v", err)
+		}
+	}
 
 	// Seed the database with initial data
 	SeedBatches()
 	SeedProgramsAndSemesters()
+	SeedRoles()
 	SeedUsers()
 	SeedStudents()
 }
@@ -0,0 +1,61 @@
+package initializers
+
+import (
+	"errors"
+	"log"
+
+	"github.com/mysterybee07/result-distribution-system/models"
+	"gorm.io/gorm"
+)
+
+// SeedRoles makes sure every permission of the catalog and every system role exists. A
+// permission seen for the first time is granted to the system roles that have it by default;
+// permissions an admin took away later are not granted again.
+func SeedRoles() {
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		roles := make(map[string]*models.Role)
+		for name := range models.DefaultRolePermissions {
+			var role models.Role
+			err := tx.Where("name = ?", name).First(&role).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				role = models.Role{Name: name, System: true}
+				if err := tx.Create(&role).Error; err != nil {
+					return err
+				}
+			} else if err != nil {
+				return err
+			}
+			roles[name] = &role
+		}
+
+		for _, entry := range models.PermissionCatalog {
+			var permission models.Permission
+			err := tx.Where("name = ?", entry.Name).First(&permission).Error
+			if err == nil {
+				continue
+			} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+
+			permission = models.Permission{Name: entry.Name, Description: entry.Description}
+			if err := tx.Create(&permission).Error; err != nil {
+				return err
+			}
+			for name, defaults := range models.DefaultRolePermissions {
+				for _, granted := range defaults {
+					if granted != entry.Name {
+						continue
+					}
+					if err := tx.Model(roles[name]).Association("Permissions").Append(&permission); err != nil {
+						return err
+					}
+				}
+			}
+			log.Println("Permission seeded:", entry.Name)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Println("Failed to seed roles and permissions:", err)
+	}
+}
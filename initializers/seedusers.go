@@ -2,6 +2,7 @@ package initializers
 
 import (
 	"log"
+	"time"
 
 	"github.com/mysterybee07/result-distribution-system/models"
 	"golang.org/x/crypto/bcrypt"
@@ -88,13 +89,15 @@ func SeedUsers() {
 			return
 		}
 
+		verifiedAt := time.Now()
 		users := []models.User{
 			{
-				SymbolNumber: "SYM001",
-				Email:        "admin@example.com",
-				Password:     string(hashedPassword),
-				Role:         "admin",
-				ImageURL:     "/static/images/uploads/default.png",
+				SymbolNumber:    "SYM001",
+				Email:           "admin@example.com",
+				Password:        string(hashedPassword),
+				Role:            "admin",
+				ImageURL:        "/static/images/uploads/default.png",
+				EmailVerifiedAt: &verifiedAt,
 				// No batch and program for admin
 			},
 			{
@@ -106,6 +109,7 @@ func SeedUsers() {
 				Password:           string(hashedPassword),
 				Role:               "user",
 				ImageURL:           "/static/images/uploads/default.png",
+				EmailVerifiedAt:    &verifiedAt,
 			},
 		}
 
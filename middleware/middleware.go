@@ -4,84 +4,44 @@ import (
 	"log"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/mysterybee07/result-distribution-system/initializers"
-	"github.com/mysterybee07/result-distribution-system/models"
 	"github.com/mysterybee07/result-distribution-system/utils"
 )
 
 func AuthRequired(c *fiber.Ctx) error {
-	// Get token from cookies
-	token := c.Cookies("jwt")
-
-	if token == "" {
+	if !authenticate(c) {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"message": "unauthorized",
 		})
 	}
 
-	// Validate token and get user ID and role
-	userID, _, err := utils.ParseJwt(token)
-	if err != nil {
-		log.Printf("Failed to parse JWT: %v\n", err)
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"message": "unauthorized user",
-		})
-	}
-
-	// log.Printf("Authenticated user ID: %s, Role: %s\n", userID, role)
-
-	// Set user ID and role in locals
-	c.Locals("userID", userID)
-
 	return c.Next()
 }
 
-func AdminRequired(c *fiber.Ctx) error {
-	// Get user ID from locals
-	userID := c.Locals("userID")
-	if userID == nil {
-		log.Println("User ID not found in locals")
-		return c.Redirect("/login", fiber.StatusFound)
-	}
-
-	// Fetch user from database to check role
-	var user models.User
-	if err := initializers.DB.Where("id = ?", userID).First(&user).Error; err != nil {
-		log.Printf("Failed to fetch user: %v\n", err)
-		return c.Redirect("/login", fiber.StatusFound)
-	}
+// authenticate sets the user and session IDs in locals from the JWT cookie and reports whether
+// it was valid and its session has not been revoked
+func authenticate(c *fiber.Ctx) bool {
+	// Get token from cookies
+	token := c.Cookies("jwt")
 
-	if user.Role != "admin" && user.Role != "superadmin" {
-		log.Println("User is not an admin")
-		return c.Redirect("/login", fiber.StatusFound)
+	if token == "" {
+		return false
 	}
 
-	return c.Next()
-}
-
-func SuperadminRequired(c *fiber.Ctx) error {
-	// Get user ID from locals
-	userID := c.Locals("userID")
-	if userID == nil {
-		log.Println("User ID not found in locals")
-		return c.Redirect("/login", fiber.StatusFound)
+	// Validate token and get user ID and session
+	claims, err := utils.ParseJwt(token)
+	if err != nil {
+		log.Printf("Failed to parse JWT: %v\n", err)
+		return false
 	}
-
-	// Fetch user from the database to check role
-	var user models.User
-	if err := initializers.DB.Where("id = ?", userID).First(&user).Error; err != nil {
-		log.Printf("Failed to fetch user: %v\n", err)
-		return c.Redirect("/login", fiber.StatusFound)
+	if !utils.SessionActive(claims.SessionID, claims.UserID) {
+		return false
 	}
 
-	// Check if the user is a superadmin
-	if user.Role != "superadmin" {
-		log.Println("User is not a superadmin")
-		// Redirect to another route (404 page or dashboard)
-		return c.Redirect("/404", fiber.StatusFound)
-	}
+	// Set user ID and session ID in locals
+	c.Locals("userID", claims.UserID)
+	c.Locals("sessionID", claims.SessionID)
 
-	return c.Next()
+	return true
 }
 
 // var store = session.New()
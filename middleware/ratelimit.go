@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"math"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/limiter"
+	"github.com/mysterybee07/result-distribution-system/utils"
+)
+
+// PublicLookupLimiter caps the result lookups per IP to PUBLIC_LOOKUP_RATE_LIMIT a minute, 10 by default
+func PublicLookupLimiter() fiber.Handler {
+	max := 10
+	if value, err := strconv.Atoi(os.Getenv("PUBLIC_LOOKUP_RATE_LIMIT")); err == nil && value > 0 {
+		max = value
+	}
+
+	return limiter.New(limiter.Config{
+		Max:        max,
+		Expiration: time.Minute,
+		KeyGenerator: func(c *fiber.Ctx) string {
+			return c.IP()
+		},
+		LimitReached: func(c *fiber.Ctx) error {
+			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+				"error": "Too many lookups, please try again in a minute",
+			})
+		},
+	})
+}
+
+// LookupThrottle turns away an IP that has to wait after too many lookups that did not match
+func LookupThrottle(c *fiber.Ctx) error {
+	if wait := utils.LookupWait(c.IP()); wait > 0 {
+		seconds := int(math.Ceil(wait.Seconds()))
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(seconds))
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+			"error":       "Too many lookups with wrong details, please wait before trying again",
+			"retry_after": seconds,
+		})
+	}
+	return c.Next()
+}
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mysterybee07/result-distribution-system/models"
+	"github.com/mysterybee07/result-distribution-system/utils"
+)
+
+// RequirePermission lets a request through when the signed in user's role holds the permission.
+// It signs the user in from the cookie when AuthRequired has not run yet.
+func RequirePermission(permission string) fiber.Handler {
+	return RequireAnyPermission(permission)
+}
+
+// RequireAnyPermission lets a request through when the user's role holds one of the permissions
+func RequireAnyPermission(permissions ...string) fiber.Handler {
+	for _, permission := range permissions {
+		if !knownPermission(permission) {
+			log.Fatalf("Route requires unknown permission %q", permission)
+		}
+	}
+
+	return func(c *fiber.Ctx) error {
+		if c.Locals("userID") == nil && !authenticate(c) {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"message": "unauthorized",
+			})
+		}
+
+		access, err := utils.RequestAccess(c)
+		if err != nil {
+			return utils.RespondFiberError(c, err)
+		}
+		for _, permission := range permissions {
+			if access.Can(permission) {
+				return c.Next()
+			}
+		}
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "forbidden",
+		})
+	}
+}
+
+func knownPermission(name string) bool {
+	for _, permission := range models.PermissionCatalog {
+		if permission.Name == name {
+			return true
+		}
+	}
+	return false
+}
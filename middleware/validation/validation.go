@@ -164,25 +164,8 @@ func ValidateUser(data *models.User, isUpdate bool) error {
 		return fiber.NewError(fiber.StatusBadRequest, "Invalid symbol or registration for the specified batch and program")
 	}
 
-	// Check for unique email
-	var existingUser models.User
-	if err := initializers.DB.Where("email = ?", data.Email).First(&existingUser).Error; err == nil && existingUser.ID != data.ID {
-		log.Println("Email already taken:", data.Email)
-		return fiber.NewError(fiber.StatusBadRequest, "Email is already taken")
-	}
-
-	// Check if symbol number is unique for the batch and program
-	if err := initializers.DB.Where("symbol_number = ? AND batch_id = ? AND program_id = ?", data.SymbolNumber, data.BatchID, data.ProgramID).First(&existingUser).Error; err == nil && existingUser.ID != data.ID {
-		log.Println("Symbol number already taken:", data.SymbolNumber)
-		return fiber.NewError(fiber.StatusBadRequest, "Symbol number is already taken for the specified batch and program")
-	}
-
-	// Check if registration number is unique for the batch and program
-	if err := initializers.DB.Where("registration_number = ? AND batch_id = ? AND program_id = ?", data.RegistrationNumber, data.BatchID, data.ProgramID).First(&existingUser).Error; err == nil && existingUser.ID != data.ID {
-		log.Println("Registration number already taken:", data.RegistrationNumber)
-		return fiber.NewError(fiber.StatusBadRequest, "Registration number is already taken for the specified batch and program")
-	}
-
+	// Taken emails, symbol numbers and registration numbers are not reported here; registration
+	// answers the same either way so it does not show which students have accounts
 	return nil
 }
 
@@ -203,6 +186,35 @@ func ValidateExamScheduleRequest(req *models.ExamRoutineRequest) error {
 		return fiber.NewError(fiber.StatusBadRequest, "End date must be after the start date")
 	}
 
+	// Validate the scheduling constraints
+	offDays, err := utils.ParseWeekdays(req.OffDays)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	// The same day given twice is one off-day
+	if len(offDays) >= 7 {
+		return fiber.NewError(fiber.StatusBadRequest, "At least one day of the week must be open for exams")
+	}
+	for _, holiday := range req.Holidays {
+		if _, err := time.Parse("2006-01-02", holiday); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "Holidays must be dates in the format 2006-01-02")
+		}
+	}
+	if req.MinRestDays < 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "Minimum rest days cannot be negative")
+	}
+
+	// The window must not start or end on a day the academic calendar closes
+	closed, err := utils.ClosedDates(req.BatchID, req.ProgramID, req.StartDate, req.EndDate)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to check the academic calendar")
+	}
+	for _, date := range []time.Time{req.StartDate, req.EndDate} {
+		if title, ok := closed[utils.DateKey(date)]; ok {
+			return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("%s is closed for exams (%s)", utils.DateKey(date), title))
+		}
+	}
+
 	// Validate foreign keys for Batch, Program, and Semester
 	var batch models.Batch
 	var program models.Program
@@ -220,3 +232,99 @@ func ValidateExamScheduleRequest(req *models.ExamRoutineRequest) error {
 	// Validation passed
 	return nil
 }
+
+// ValidateGradingScheme checks the bands of a grading scheme before it is stored
+func ValidateGradingScheme(scheme *models.GradingScheme) error {
+	if scheme.Name == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "Grading scheme name is required")
+	}
+	if len(scheme.Bands) == 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "A grading scheme needs at least one band")
+	}
+
+	seen := make(map[float64]bool)
+	for _, band := range scheme.Bands {
+		if band.Letter == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "Every band needs a letter")
+		}
+		if band.MinPercentage < 0 || band.MinPercentage > 100 {
+			return fiber.NewError(fiber.StatusBadRequest, "Band percentages must be between 0 and 100")
+		}
+		if seen[band.MinPercentage] {
+			return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("More than one band starts at %g%%", band.MinPercentage))
+		}
+		seen[band.MinPercentage] = true
+		if band.GradePoint < 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "Grade points cannot be negative")
+		}
+	}
+
+	if scheme.FailLetter == "" {
+		scheme.FailLetter = "F"
+	}
+
+	// Names are unique across schemes
+	var existing models.GradingScheme
+	if err := initializers.DB.Where("name = ? AND id <> ?", scheme.Name, scheme.ID).First(&existing).Error; err == nil {
+		return fiber.NewError(fiber.StatusConflict, "A grading scheme with this name already exists")
+	}
+	return nil
+}
+
+func ValidateModerationRule(rule *models.ModerationRule) error {
+	if rule.Name == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "Moderation rule name is required")
+	}
+
+	var program models.Program
+	if err := initializers.DB.First(&program, rule.ProgramID).Error; err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Program not found")
+	}
+	if rule.BatchID != nil {
+		var batch models.Batch
+		if err := initializers.DB.First(&batch, *rule.BatchID).Error; err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "Batch not found")
+		}
+	}
+	if rule.SemesterID != nil {
+		var semester models.Semester
+		if err := initializers.DB.Where("id = ? AND program_id = ?", *rule.SemesterID, rule.ProgramID).First(&semester).Error; err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "Semester not found for the given program")
+		}
+	}
+
+	// The course alone decides which marks a course rule covers, so its semester is filled in
+	var course models.Course
+	if rule.CourseID != nil {
+		if err := initializers.DB.Where("id = ? AND program_id = ?", *rule.CourseID, rule.ProgramID).First(&course).Error; err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "Course not found for the given program")
+		}
+		if rule.SemesterID != nil && *rule.SemesterID != course.SemesterID {
+			return fiber.NewError(fiber.StatusBadRequest, "Course does not belong to the given semester")
+		}
+		rule.SemesterID = &course.SemesterID
+	}
+
+	switch rule.Kind {
+	case "grace":
+		if rule.GraceMarks <= 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "Grace marks must be more than zero")
+		}
+		rule.Component = ""
+		rule.ScaleFactor = 0
+	case "scale":
+		if rule.Component == "" {
+			rule.Component = "semester"
+		}
+		if rule.Component != "semester" && rule.Component != "practical" && rule.Component != "assistant" {
+			return fiber.NewError(fiber.StatusBadRequest, "Component must be semester, practical or assistant")
+		}
+		if rule.ScaleFactor <= 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "Scale factor must be more than zero")
+		}
+		rule.GraceMarks = 0
+	default:
+		return fiber.NewError(fiber.StatusBadRequest, "Kind must be grace or scale")
+	}
+	return nil
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AdmitCardBatch tracks one run of the admit card job for an exam routine
+type AdmitCardBatch struct {
+	gorm.Model
+	ExamRoutineID uint        `gorm:"not null;index" json:"exam_routine_id"`
+	Status        string      `gorm:"type:varchar(20);not null;default:Running" json:"status"` // Running, Completed or Failed
+	Generated     int         `json:"generated"`
+	Skipped       int         `json:"skipped"` // Students without a seat
+	OutputDir     string      `json:"output_dir"`
+	Error         string      `json:"error,omitempty"`
+	FinishedAt    *time.Time  `json:"finished_at,omitempty"`
+	ExamRoutine   ExamRoutine `gorm:"foreignKey:ExamRoutineID" json:"-"`
+}
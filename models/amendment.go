@@ -0,0 +1,31 @@
+package models
+
+import "gorm.io/gorm"
+
+// MarkAmendment records a correction to a mark whose result was already published. Every
+// amendment request takes a new snapshot of the result.
+type MarkAmendment struct {
+	gorm.Model
+	ResultID          uint   `gorm:"not null;index" json:"result_id"`
+	ResultSnapshotID  uint   `gorm:"not null;index" json:"result_snapshot_id"`
+	MarkID            uint   `gorm:"not null;index" json:"mark_id"`
+	StudentID         uint   `gorm:"not null;index" json:"student_id"`
+	CourseID          uint   `gorm:"not null" json:"course_id"`
+	Reason            string `gorm:"not null" json:"reason"`
+	AmendedBy         string `json:"amended_by,omitempty"`
+	OldSemesterMarks  int    `json:"old_semester_marks"`
+	OldAssistantMarks int    `json:"old_assistant_marks"`
+	OldPracticalMarks int    `json:"old_practical_marks"`
+	OldStatus         string `json:"old_status"`
+	NewSemesterMarks  int    `json:"new_semester_marks"`
+	NewAssistantMarks int    `json:"new_assistant_marks"`
+	NewPracticalMarks int    `json:"new_practical_marks"`
+	NewStatus         string `json:"new_status"`
+	Course            Course `gorm:"foreignKey:CourseID" json:"course,omitempty"`
+}
+
+// MarkAmendmentRequest corrects the marks of one course in a published result
+type MarkAmendmentRequest struct {
+	MarksPayload
+	Reason string `json:"reason" validate:"required"`
+}
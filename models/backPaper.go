@@ -0,0 +1,27 @@
+package models
+
+import "gorm.io/gorm"
+
+// BackPaperRegistration enrols a student who failed a course for another attempt at it
+type BackPaperRegistration struct {
+	gorm.Model
+	StudentID     uint     `gorm:"not null;index" json:"student_id"`
+	CourseID      uint     `gorm:"not null;index" json:"course_id"`
+	BatchID       uint     `gorm:"not null" json:"batch_id"`
+	ProgramID     uint     `gorm:"not null" json:"program_id"`
+	SemesterID    uint     `gorm:"not null" json:"semester_id"`
+	Attempt       int      `gorm:"not null" json:"attempt"`
+	Status        string   `gorm:"type:varchar(20);not null;default:Registered" json:"status"` // Registered, Scheduled, Completed or Cancelled
+	ExamRoutineID *uint    `gorm:"index" json:"exam_routine_id,omitempty"`
+	Student       Student  `gorm:"foreignKey:StudentID" json:"student"`
+	Course        Course   `gorm:"foreignKey:CourseID" json:"course"`
+	Semester      Semester `gorm:"foreignKey:SemesterID" json:"-"`
+}
+
+type BackPaperRegistrationRequest struct {
+	BatchID    uint   `json:"batch_id"`
+	ProgramID  uint   `json:"program_id"`
+	SemesterID uint   `json:"semester_id"`
+	CourseIDs  []uint `json:"course_ids"`  // Empty registers every failed course of the semester
+	StudentIDs []uint `json:"student_ids"` // Empty registers every student who failed
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CalendarEvent closes one or more days for exams, either everywhere or for a program or batch
+type CalendarEvent struct {
+	gorm.Model
+	Title     string    `gorm:"not null" json:"title"`
+	Kind      string    `gorm:"type:varchar(20);not null;default:holiday" json:"kind"` // holiday, festival or blackout
+	StartDate time.Time `gorm:"type:date;not null;index" json:"start_date"`
+	EndDate   time.Time `gorm:"type:date;not null;index" json:"end_date"`              // Inclusive
+	Scope     string    `gorm:"type:varchar(20);not null;default:global" json:"scope"` // global, program or batch
+	ProgramID *uint     `gorm:"index" json:"program_id,omitempty"`
+	BatchID   *uint     `gorm:"index" json:"batch_id,omitempty"`
+	UID       string    `gorm:"type:varchar(255);index" json:"uid,omitempty"` // UID of an imported iCal event
+	Program   *Program  `gorm:"foreignKey:ProgramID" json:"-"`
+	Batch     *Batch    `gorm:"foreignKey:BatchID" json:"-"`
+}
+
+type CalendarEventInput struct {
+	Title     string `form:"title" json:"title"`
+	Kind      string `form:"kind" json:"kind"`
+	StartDate string `form:"start_date" json:"start_date"` // 2006-01-02
+	EndDate   string `form:"end_date" json:"end_date"`     // 2006-01-02, defaults to the start date
+	Scope     string `form:"scope" json:"scope"`
+	ProgramID *uint  `form:"program_id" json:"program_id"`
+	BatchID   *uint  `form:"batch_id" json:"batch_id"`
+}
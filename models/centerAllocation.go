@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CenterAllocation is one version of the college→center assignment for an exam routine
+type CenterAllocation struct {
+	gorm.Model
+	BatchID            uint                   `gorm:"not null;index:idx_allocation_key" json:"batch_id"`
+	ProgramID          uint                   `gorm:"not null;index:idx_allocation_key" json:"program_id"`
+	ExamRoutineID      uint                   `gorm:"not null;index:idx_allocation_key;uniqueIndex:idx_allocation_version" json:"exam_routine_id"`
+	Version            int                    `gorm:"not null;uniqueIndex:idx_allocation_version" json:"version"` // Numbered per exam routine
+	Status             string                 `gorm:"type:varchar(20);not null;default:Draft" json:"status"`      // Draft, Published or Archived
+	RadiusKm           float64                `json:"radius_km"`
+	TotalStudents      int                    `json:"total_students"`
+	AssignedStudents   int                    `json:"assigned_students"`
+	UnassignedStudents int                    `json:"unassigned_students"`
+	TotalDistanceKm    float64                `json:"total_distance_km"`
+	OptimalityGap      float64                `json:"optimality_gap"`
+	RolledBackFromID   *uint                  `json:"rolled_back_from_id,omitempty"`
+	PublishedAt        *time.Time             `json:"published_at,omitempty"`
+	Items              []CenterAllocationItem `gorm:"foreignKey:CenterAllocationID" json:"items,omitempty"`
+	Batch              Batch                  `gorm:"foreignKey:BatchID" json:"-"`
+	Program            Program                `gorm:"foreignKey:ProgramID" json:"-"`
+	ExamRoutine        ExamRoutine            `gorm:"foreignKey:ExamRoutineID" json:"-"`
+}
+
+// CenterAllocationItem is the number of seats a college gets at one center
+type CenterAllocationItem struct {
+	gorm.Model
+	CenterAllocationID uint    `gorm:"not null;index" json:"center_allocation_id"`
+	CollegeID          uint    `gorm:"not null" json:"college_id"`
+	CenterID           uint    `gorm:"not null" json:"center_id"` // College ID of the center
+	AssignedSeat       int     `gorm:"not null" json:"assigned_seat"`
+	DistanceKm         float64 `json:"distance_km"`
+	College            College `gorm:"foreignKey:CollegeID" json:"college"`
+	Center             College `gorm:"foreignKey:CenterID" json:"center"`
+}
+
+type CenterAllocationRequest struct {
+	BatchID       uint    `json:"batch_id"`
+	ProgramID     uint    `json:"program_id"`
+	ExamRoutineID uint    `json:"exam_routine_id"`
+	RadiusKm      float64 `json:"radius_km"`
+}
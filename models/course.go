@@ -17,10 +17,23 @@ type Course struct {
 	ProgramID           uint     `gorm:"not null" json:"program_id"`
 	SemesterID          uint     `gorm:"not null" json:"semester_id"`
 	IsCompulsory        bool     `gorm:"not null" json:"is_compulsory" default:"false"`
+	ElectiveGroup       string   `gorm:"type:varchar(50)" json:"elective_group,omitempty"` // Optional courses of a group are alternatives; a student takes one of each group
+	CreditHours         float64  `gorm:"not null;default:3" json:"credit_hours"`
+	Difficulty          int      `gorm:"not null;default:3" json:"difficulty"` // 1 (easy) to 5 (hard), used to spread exams apart
 	Program             Program  `gorm:"foreignKey:ProgramID"`
 	Semester            Semester `gorm:"foreignKey:SemesterID"`
 }
 
+// MarkStatus decides whether marks pass the course: every component with a pass mark must reach it
+func (course Course) MarkStatus(semesterMarks, practicalMarks, assistantMarks int) string {
+	if semesterMarks < course.SemesterPassMarks ||
+		(course.PracticalPassMarks != nil && practicalMarks < *course.PracticalPassMarks) ||
+		(course.AssistantPassMarks != nil && assistantMarks < *course.AssistantPassMarks) {
+		return "failed"
+	}
+	return "pass"
+}
+
 type CoursesPayload struct {
 	ProgramID  uint     `json:"program_id" validate:"required"`
 	SemesterID uint     `json:"semester_id" validate:"required"`
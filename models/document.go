@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// IssuedDocument registers every grade sheet and transcript handed out, so the serial number and
+// hash printed on a copy can be checked later
+type IssuedDocument struct {
+	gorm.Model
+	SerialNumber string    `gorm:"type:varchar(30);not null;uniqueIndex" json:"serial_number"`
+	Kind         string    `gorm:"type:varchar(20);not null;index:idx_document_lookup" json:"kind"` // grade_sheet or transcript
+	StudentID    uint      `gorm:"not null;index:idx_document_lookup" json:"student_id"`
+	SemesterID   *uint     `json:"semester_id,omitempty"`    // Empty for a transcript
+	Revision     int       `gorm:"not null" json:"revision"` // Result revision a grade sheet was built from
+	Hash         string    `gorm:"type:char(64);not null;index:idx_document_lookup" json:"hash"`
+	IssuedAt     time.Time `gorm:"not null" json:"issued_at"`
+	Student      Student   `gorm:"foreignKey:StudentID" json:"-"`
+}
@@ -14,6 +14,7 @@ type ExamRoutine struct {
 	ProgramID  uint      `json:"program_id"`
 	SemesterID uint      `json:"semester_id"`
 	Status     bool      `gorm:"not null; default:false" json:"status"`
+	Kind       string    `gorm:"type:varchar(20);not null;default:regular" json:"kind"` // regular or back_paper
 
 	// Foreign key associations
 	Batch    Batch    `gorm:"foreignKey:BatchID"`
@@ -32,9 +33,12 @@ type ExamSchedules struct {
 }
 
 type ExamRoutineRequest struct {
-	BatchID    uint      `json:"batch_id"`
-	ProgramID  uint      `json:"program_id"`
-	SemesterID uint      `json:"semester_id"`
-	StartDate  time.Time `json:"start_date"`
-	EndDate    time.Time `json:"end_date"`
+	BatchID     uint      `json:"batch_id"`
+	ProgramID   uint      `json:"program_id"`
+	SemesterID  uint      `json:"semester_id"`
+	StartDate   time.Time `json:"start_date"`
+	EndDate     time.Time `json:"end_date"`
+	OffDays     []string  `json:"off_days"`      // Weekly off-days such as "saturday"; defaults to the weekend
+	Holidays    []string  `json:"holidays"`      // Extra closed dates as 2006-01-02
+	MinRestDays int       `json:"min_rest_days"` // Free days between two exams of the cohort
 }
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ExaminerAssignment lets an examiner enter and edit the marks of a course for a batch
+type ExaminerAssignment struct {
+	gorm.Model
+	UserID   uint   `gorm:"not null;uniqueIndex:idx_examiner_course" json:"user_id"`
+	CourseID uint   `gorm:"not null;uniqueIndex:idx_examiner_course" json:"course_id"`
+	BatchID  uint   `gorm:"not null;uniqueIndex:idx_examiner_course" json:"batch_id"`
+	User     User   `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Course   Course `gorm:"foreignKey:CourseID" json:"course,omitempty"`
+	Batch    Batch  `gorm:"foreignKey:BatchID" json:"batch,omitempty"`
+}
+
+// MarkEntryDeadline closes mark entry and editing of a course for a batch
+type MarkEntryDeadline struct {
+	gorm.Model
+	CourseID uint      `gorm:"not null;uniqueIndex:idx_entry_deadline" json:"course_id"`
+	BatchID  uint      `gorm:"not null;uniqueIndex:idx_entry_deadline" json:"batch_id"`
+	ClosesAt time.Time `gorm:"not null" json:"closes_at"`
+	Course   Course    `gorm:"foreignKey:CourseID" json:"course,omitempty"`
+}
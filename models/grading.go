@@ -0,0 +1,21 @@
+package models
+
+import "gorm.io/gorm"
+
+// GradingScheme turns a course percentage into a letter grade and grade point
+type GradingScheme struct {
+	gorm.Model
+	Name       string      `gorm:"not null;unique" json:"name"`
+	IsDefault  bool        `gorm:"not null;default:false" json:"is_default"` // Used by programs without a scheme of their own
+	FailLetter string      `gorm:"type:varchar(5);not null;default:F" json:"fail_letter"`
+	Bands      []GradeBand `gorm:"foreignKey:GradingSchemeID" json:"bands"`
+}
+
+// GradeBand awards a letter and grade point from MinPercentage upwards
+type GradeBand struct {
+	gorm.Model
+	GradingSchemeID uint    `gorm:"not null;index" json:"grading_scheme_id"`
+	MinPercentage   float64 `gorm:"not null" json:"min_percentage"`
+	Letter          string  `gorm:"type:varchar(5);not null" json:"letter"`
+	GradePoint      float64 `gorm:"not null" json:"grade_point"`
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MarkEntry is one user's entry of a student's marks in a course. A mark is only recorded, or
+// corrected, once two different users have entered the same marks.
+type MarkEntry struct {
+	gorm.Model
+	BatchID        uint   `gorm:"not null" json:"batch_id"`
+	ProgramID      uint   `gorm:"not null" json:"program_id"`
+	SemesterID     uint   `gorm:"not null" json:"semester_id"`
+	CourseID       uint   `gorm:"not null;index:idx_mark_entry_student" json:"course_id"`
+	StudentID      uint   `gorm:"not null;index:idx_mark_entry_student" json:"student_id"`
+	SemesterMarks  int    `gorm:"not null" json:"semester_marks"`
+	AssistantMarks int    `gorm:"not null" json:"assistant_marks"`
+	PracticalMarks int    `gorm:"not null" json:"practical_marks"`
+	EnteredBy      string `gorm:"type:varchar(20);not null;index" json:"entered_by"`
+	Status         string `gorm:"type:varchar(20);not null;default:pending;index" json:"status"` // pending, matched, mismatched or resolved
+	MarkID         *uint  `json:"mark_id,omitempty"`                                             // The mark the entry ended up in
+	CorrectsMarkID *uint  `gorm:"index" json:"corrects_mark_id,omitempty"`                       // The recorded mark a correction replaces
+}
+
+// MarkReconciliation is a disagreement between two entries, waiting for a supervisor to decide
+// the marks
+type MarkReconciliation struct {
+	gorm.Model
+	BatchID       uint       `gorm:"not null" json:"batch_id"`
+	ProgramID     uint       `gorm:"not null" json:"program_id"`
+	SemesterID    uint       `gorm:"not null" json:"semester_id"`
+	CourseID      uint       `gorm:"not null;index:idx_reconciliation_student" json:"course_id"`
+	StudentID     uint       `gorm:"not null;index:idx_reconciliation_student" json:"student_id"`
+	FirstEntryID  uint       `gorm:"not null" json:"first_entry_id"`
+	SecondEntryID uint       `gorm:"not null" json:"second_entry_id"`
+	Status        string     `gorm:"type:varchar(20);not null;default:open;index" json:"status"` // open or resolved
+	ResolvedBy    string     `json:"resolved_by,omitempty"`
+	ResolvedAt    *time.Time `json:"resolved_at,omitempty"`
+	Note          string     `json:"note,omitempty"`
+	MarkID        *uint      `json:"mark_id,omitempty"`
+	FirstEntry    MarkEntry  `gorm:"foreignKey:FirstEntryID" json:"first_entry"`
+	SecondEntry   MarkEntry  `gorm:"foreignKey:SecondEntryID" json:"second_entry"`
+	Course        Course     `gorm:"foreignKey:CourseID" json:"course,omitempty"`
+	Student       Student    `gorm:"foreignKey:StudentID" json:"student,omitempty"`
+}
+
+// MarkReconciliationRequest holds the marks a supervisor settles a reconciliation with
+type MarkReconciliationRequest struct {
+	SemesterMarks  int    `json:"semester_marks" validate:"required"`
+	AssistantMarks int    `json:"assistant_marks"`
+	PracticalMarks int    `json:"practical_marks"`
+	Note           string `json:"note"`
+}
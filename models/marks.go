@@ -18,6 +18,7 @@ type Mark struct {
 	PracticalMarks int      `gorm:"not null" json:"practical_marks"`
 	TotalMarks     int      `gorm:"->;type:int GENERATED ALWAYS AS (semester_marks + assistant_marks + practical_marks) STORED" json:"total_marks"`
 	Status         string   `gorm:"default:pass" json:"status"`
+	Attempt        int      `gorm:"not null;default:1" json:"attempt"` // Back papers add attempts and keep the earlier ones
 	Batch          Batch    `gorm:"foreignkey:BatchID"`
 	Program        Program  `gorm:"foreignkey:ProgramID"`
 	Semester       Semester `gorm:"foreignkey:SemesterID"`
@@ -25,16 +26,19 @@ type Mark struct {
 	Student        Student  `gorm:"foreignkey:StudentID"`
 }
 type MarksPayload struct {
-	BatchID    uint `json:"batch_id" validate:"required"`
-	ProgramID  uint `json:"program_id" validate:"required"`
-	SemesterID uint `json:"semester_id" validate:"required"`
-	CourseID   uint `json:"course_id" validate:"required"`
-	Marks      []struct {
-		StudentID      uint `json:"student_id" validate:"required"`
-		SemesterMarks  int  `json:"semester_marks" validate:"required"`
-		AssistantMarks int  `json:"assistant_marks"`
-		PracticalMarks int  `json:"practical_marks"`
-	} `json:"marks" validate:"required,dive"`
+	BatchID    uint           `json:"batch_id" validate:"required"`
+	ProgramID  uint           `json:"program_id" validate:"required"`
+	SemesterID uint           `json:"semester_id" validate:"required"`
+	CourseID   uint           `json:"course_id" validate:"required"`
+	Marks      []StudentMarks `json:"marks" validate:"required,dive"`
+}
+
+// StudentMarks are the marks of one student in a MarksPayload
+type StudentMarks struct {
+	StudentID      uint `json:"student_id" validate:"required"`
+	SemesterMarks  int  `json:"semester_marks" validate:"required"`
+	AssistantMarks int  `json:"assistant_marks"`
+	PracticalMarks int  `json:"practical_marks"`
 }
 
 func (m *Mark) BeforeSave(tx *gorm.DB) (err error) {
@@ -43,13 +47,7 @@ func (m *Mark) BeforeSave(tx *gorm.DB) (err error) {
 		return err
 	}
 
-	if m.SemesterMarks < course.SemesterPassMarks ||
-		(course.PracticalPassMarks != nil && m.PracticalMarks < *course.PracticalPassMarks) ||
-		(course.AssistantPassMarks != nil && m.AssistantMarks < *course.AssistantPassMarks) {
-		m.Status = "failed"
-	} else {
-		m.Status = "pass"
-	}
+	m.Status = course.MarkStatus(m.SemesterMarks, m.PracticalMarks, m.AssistantMarks)
 
 	return
 }
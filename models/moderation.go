@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ModerationRule adjusts marks after an exam. It covers one course when CourseID is set,
+// otherwise every course of the semester, or of the program when no semester is given.
+type ModerationRule struct {
+	gorm.Model
+	Name        string     `gorm:"not null" json:"name"`
+	Kind        string     `gorm:"type:varchar(10);not null" json:"kind"` // grace or scale
+	BatchID     *uint      `json:"batch_id,omitempty"`                    // Empty for every batch
+	ProgramID   uint       `gorm:"not null;index" json:"program_id"`
+	SemesterID  *uint      `json:"semester_id,omitempty"`
+	CourseID    *uint      `json:"course_id,omitempty"`
+	AllAttempts bool       `gorm:"not null;default:false" json:"all_attempts"`  // Back paper attempts are left alone unless set
+	GraceMarks  int        `json:"grace_marks,omitempty"`                       // Grace: most marks added to a course so a student passes
+	Component   string     `gorm:"type:varchar(10)" json:"component,omitempty"` // Scale: semester, practical or assistant
+	ScaleFactor float64    `json:"scale_factor,omitempty"`                      // Scale: raw marks are multiplied by it, up to the full marks
+	Reason      string     `json:"reason"`
+	Status      string     `gorm:"type:varchar(10);not null;default:draft" json:"status"` // draft, applied or reverted
+	AppliedBy   string     `json:"applied_by,omitempty"`
+	AppliedAt   *time.Time `json:"applied_at,omitempty"`
+	RevertedBy  string     `json:"reverted_by,omitempty"`
+	RevertedAt  *time.Time `json:"reverted_at,omitempty"`
+}
+
+// MarkModeration keeps the marks of a mark before and after a rule changed them
+type MarkModeration struct {
+	gorm.Model
+	ModerationRuleID        uint   `gorm:"not null;index" json:"moderation_rule_id"`
+	MarkID                  uint   `gorm:"not null;index" json:"mark_id"`
+	StudentID               uint   `gorm:"not null;index" json:"student_id"`
+	CourseID                uint   `gorm:"not null" json:"course_id"`
+	SymbolNumber            string `json:"symbol_number"`
+	CourseCode              string `json:"course_code"`
+	RawSemesterMarks        int    `json:"raw_semester_marks"`
+	RawPracticalMarks       int    `json:"raw_practical_marks"`
+	RawAssistantMarks       int    `json:"raw_assistant_marks"`
+	RawStatus               string `json:"raw_status"`
+	ModeratedSemesterMarks  int    `json:"moderated_semester_marks"`
+	ModeratedPracticalMarks int    `json:"moderated_practical_marks"`
+	ModeratedAssistantMarks int    `json:"moderated_assistant_marks"`
+	ModeratedStatus         string `json:"moderated_status"`
+	Reverted                bool   `gorm:"not null;default:false" json:"reverted"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Notification is a message to one user, shown until they read it
+type Notification struct {
+	gorm.Model
+	UserID  uint       `gorm:"not null;index" json:"user_id"`
+	Title   string     `gorm:"not null" json:"title"`
+	Message string     `gorm:"type:text" json:"message"`
+	Link    string     `json:"link,omitempty"`
+	ReadAt  *time.Time `json:"read_at,omitempty"`
+}
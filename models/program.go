@@ -4,6 +4,11 @@ import "gorm.io/gorm"
 
 type Program struct {
 	gorm.Model
-	ProgramName string     `gorm:"not null" json:"program_name"`
-	Semesters   []Semester `gorm:"foreignKey:ProgramID"`
+	ProgramName     string         `gorm:"not null" json:"program_name"`
+	BackPaperRule   string         `gorm:"type:varchar(20);not null;default:latest" json:"back_paper_rule"` // Attempt that counts: latest or best
+	MaxAttempts     int            `gorm:"not null;default:3" json:"max_attempts"`                          // Attempts at a course including the first
+	PromotionRule   string         `gorm:"type:varchar(20);not null;default:carry" json:"promotion_rule"`   // Failed students move up with back papers (carry) or repeat the semester (hold)
+	GradingSchemeID *uint          `json:"grading_scheme_id,omitempty"`                                     // Falls back to the default scheme when empty
+	Semesters       []Semester     `gorm:"foreignKey:ProgramID"`
+	GradingScheme   *GradingScheme `gorm:"foreignKey:GradingSchemeID" json:"grading_scheme,omitempty"`
 }
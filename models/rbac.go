@@ -0,0 +1,101 @@
+package models
+
+import "gorm.io/gorm"
+
+// Role is a named set of permissions. Users hold one role by name through User.Role.
+type Role struct {
+	gorm.Model
+	Name        string       `gorm:"type:varchar(20);uniqueIndex;not null" json:"name"`
+	Description string       `json:"description"`
+	System      bool         `gorm:"not null;default:false" json:"system"` // Seeded roles cannot be renamed or deleted
+	Permissions []Permission `gorm:"many2many:role_permissions" json:"permissions"`
+}
+
+// Permission allows one kind of action, named area:action
+type Permission struct {
+	gorm.Model
+	Name        string `gorm:"type:varchar(50);uniqueIndex;not null" json:"name"`
+	Description string `json:"description"`
+}
+
+// UserScope limits a user to the students of a program or of a college. Program and college
+// scopes apply together; a user without scopes is limited by permissions alone.
+type UserScope struct {
+	gorm.Model
+	UserID    uint     `gorm:"not null;index" json:"user_id"`
+	ProgramID *uint    `gorm:"index" json:"program_id,omitempty"`
+	CollegeID *uint    `gorm:"index" json:"college_id,omitempty"`
+	Program   *Program `gorm:"foreignKey:ProgramID" json:"program,omitempty"`
+	College   *College `gorm:"foreignKey:CollegeID" json:"college,omitempty"`
+}
+
+// RoleInput creates or changes a role; the permissions replace the ones it had
+type RoleInput struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// UserScopeInput replaces the scopes of a user
+type UserScopeInput struct {
+	ProgramIDs []uint `json:"program_ids"`
+	CollegeIDs []uint `json:"college_ids"`
+}
+
+// Seeded role names
+const (
+	RoleSuperadmin   = "superadmin"
+	RoleAdmin        = "admin"
+	RoleExaminer     = "examiner"
+	RoleUser         = "user"
+	RoleCollegeAdmin = "collegeadmin"
+)
+
+// PermissionCatalog is every permission the routes check. Seeding adds new ones to the roles
+// that have them by default.
+var PermissionCatalog = []Permission{
+	{Name: "users:manage", Description: "List and change user accounts"},
+	{Name: "roles:manage", Description: "Change roles, permissions and user scopes"},
+	{Name: "students:read", Description: "View students"},
+	{Name: "students:write", Description: "Add, change and delete students"},
+	{Name: "colleges:manage", Description: "Add, change and delete colleges"},
+	{Name: "academics:manage", Description: "Manage batches, programs, semesters, courses, grading schemes and the calendar"},
+	{Name: "marks:read", Description: "View marks"},
+	{Name: "marks:write", Description: "Enter and edit marks of any course"},
+	{Name: "marks:write-assigned", Description: "Enter and edit marks of assigned courses only"},
+	{Name: "marks:amend", Description: "Correct marks of published results"},
+	{Name: "marks:reconcile", Description: "Resolve disagreeing double entries"},
+	{Name: "marks:moderate", Description: "Create, apply and revert moderation rules"},
+	{Name: "examiners:manage", Description: "Appoint examiners, assign courses and set entry deadlines"},
+	{Name: "results:read", Description: "View results, grades and result history"},
+	{Name: "results:publish", Description: "Publish, withdraw and revise results"},
+	{Name: "documents:issue", Description: "Issue grade sheets and transcripts"},
+	{Name: "backpapers:manage", Description: "Register, schedule and mark back papers"},
+	{Name: "reevaluations:manage", Description: "Record fees and assign reviewers for re-evaluations"},
+	{Name: "reevaluations:review", Description: "Decide re-evaluations assigned to the user"},
+	{Name: "exams:manage", Description: "Manage exam routines, centers, rooms, seat plans and admit cards"},
+	{Name: "notices:manage", Description: "Create, change and publish notices"},
+	{Name: "college:portal", Description: "Use the portal of the college the account belongs to"},
+}
+
+// DefaultRolePermissions is the matrix seeded for the system roles
+var DefaultRolePermissions = map[string][]string{
+	RoleSuperadmin: permissionNames(),
+	RoleAdmin: {
+		"users:manage", "students:read", "students:write", "colleges:manage", "academics:manage",
+		"marks:read", "marks:write", "marks:amend", "marks:reconcile", "marks:moderate", "examiners:manage",
+		"results:read", "results:publish", "documents:issue", "backpapers:manage",
+		"reevaluations:manage", "reevaluations:review", "exams:manage", "notices:manage",
+	},
+	RoleExaminer:     {"marks:read", "marks:write-assigned", "reevaluations:review"},
+	RoleCollegeAdmin: {"college:portal"},
+	RoleUser:         {},
+}
+
+func permissionNames() []string {
+	var names []string
+	for _, permission := range PermissionCatalog {
+		names = append(names, permission.Name)
+	}
+	return names
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ReevaluationRequest is a student's request to re-total or re-check one published mark. It moves
+// from submitted to fee_paid to under_review, and is decided as changed or unchanged.
+type ReevaluationRequest struct {
+	gorm.Model
+	UserID            uint       `gorm:"not null;index" json:"user_id"` // Account of the student who filed it
+	StudentID         uint       `gorm:"not null;index" json:"student_id"`
+	MarkID            uint       `gorm:"not null;index" json:"mark_id"`
+	BatchID           uint       `gorm:"not null" json:"batch_id"`
+	ProgramID         uint       `gorm:"not null" json:"program_id"`
+	SemesterID        uint       `gorm:"not null" json:"semester_id"`
+	CourseID          uint       `gorm:"not null" json:"course_id"`
+	Kind              string     `gorm:"type:varchar(20);not null" json:"kind"` // retotaling or rechecking
+	Reason            string     `json:"reason,omitempty"`
+	Status            string     `gorm:"type:varchar(20);not null;default:submitted;index" json:"status"`
+	FeeReference      string     `json:"fee_reference,omitempty"`
+	FeePaidAt         *time.Time `json:"fee_paid_at,omitempty"`
+	ReviewerID        *uint      `gorm:"index" json:"reviewer_id,omitempty"`
+	AssignedAt        *time.Time `json:"assigned_at,omitempty"`
+	OldSemesterMarks  int        `json:"old_semester_marks"`
+	OldAssistantMarks int        `json:"old_assistant_marks"`
+	OldPracticalMarks int        `json:"old_practical_marks"`
+	OldStatus         string     `json:"old_status"`
+	NewSemesterMarks  *int       `json:"new_semester_marks,omitempty"`
+	NewAssistantMarks *int       `json:"new_assistant_marks,omitempty"`
+	NewPracticalMarks *int       `json:"new_practical_marks,omitempty"`
+	NewStatus         string     `json:"new_status,omitempty"`
+	OldStanding       string     `json:"old_standing,omitempty"` // Semester pass or fail before the decision
+	NewStanding       string     `json:"new_standing,omitempty"`
+	OldSGPA           float64    `json:"old_sgpa"`
+	NewSGPA           float64    `json:"new_sgpa"`
+	MarkAmendmentID   *uint      `json:"mark_amendment_id,omitempty"` // The correction an accepted change made
+	Remarks           string     `json:"remarks,omitempty"`
+	DecidedAt         *time.Time `json:"decided_at,omitempty"`
+	Course            Course     `gorm:"foreignKey:CourseID" json:"course,omitempty"`
+	Student           Student    `gorm:"foreignKey:StudentID" json:"student,omitempty"`
+	Reviewer          *User      `gorm:"foreignKey:ReviewerID" json:"reviewer,omitempty"`
+}
+
+// ReevaluationFiling is what a student sends to ask for a re-evaluation
+type ReevaluationFiling struct {
+	MarkID uint   `json:"mark_id" validate:"required"`
+	Kind   string `json:"kind" validate:"required"`
+	Reason string `json:"reason"`
+}
+
+// ReevaluationDecision is the reviewer's outcome. Marks equal to the published ones leave the
+// request unchanged.
+type ReevaluationDecision struct {
+	SemesterMarks  int    `json:"semester_marks"`
+	AssistantMarks int    `json:"assistant_marks"`
+	PracticalMarks int    `json:"practical_marks"`
+	Remarks        string `json:"remarks"`
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// StudentRegistrationList is a list of students a college submits for the central office to
+// approve. Students are only created when the list is approved.
+type StudentRegistrationList struct {
+	gorm.Model
+	CollegeID   uint                       `gorm:"not null;index" json:"college_id"`
+	BatchID     uint                       `gorm:"not null" json:"batch_id"`
+	ProgramID   uint                       `gorm:"not null" json:"program_id"`
+	SubmittedBy uint                       `gorm:"not null" json:"submitted_by"`
+	Status      string                     `gorm:"type:varchar(20);not null;default:Pending;index" json:"status"` // Pending, Approved or Rejected
+	Remarks     string                     `json:"remarks,omitempty"`
+	ReviewedBy  *uint                      `json:"reviewed_by,omitempty"`
+	ReviewedAt  *time.Time                 `json:"reviewed_at,omitempty"`
+	Entries     []StudentRegistrationEntry `gorm:"foreignKey:ListID" json:"entries,omitempty"`
+	College     College                    `gorm:"foreignKey:CollegeID" json:"-"`
+	Batch       Batch                      `gorm:"foreignKey:BatchID" json:"-"`
+	Program     Program                    `gorm:"foreignKey:ProgramID" json:"-"`
+}
+
+// StudentRegistrationEntry is one student of a registration list
+type StudentRegistrationEntry struct {
+	gorm.Model
+	ListID             uint       `gorm:"not null;index" json:"list_id"`
+	SymbolNumber       string     `gorm:"not null" json:"symbol_number"`
+	RegistrationNumber string     `gorm:"not null" json:"registration_number"`
+	Fullname           string     `gorm:"not null" json:"fullname"`
+	DateOfBirth        *time.Time `gorm:"type:date" json:"date_of_birth,omitempty"`
+	StudentID          *uint      `json:"student_id,omitempty"` // Set when the list is approved
+}
+
+// StudentRegistrationInput is the list a college submits; the college comes from the account
+type StudentRegistrationInput struct {
+	BatchID   uint `json:"batch_id"`
+	ProgramID uint `json:"program_id"`
+	Students  []struct {
+		Fullname           string `json:"fullname"`
+		SymbolNumber       string `json:"symbol_number"`
+		RegistrationNumber string `json:"registration_number"`
+		DateOfBirth        string `json:"date_of_birth"` // 2006-01-02, optional
+	} `json:"students"`
+}
+
+// CollegeAdminInput creates a login for a college
+type CollegeAdminInput struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
@@ -1,16 +1,83 @@
 package models
 
 import (
+	"errors"
+	"time"
+
 	"gorm.io/gorm"
 )
 
 type Result struct {
 	*gorm.Model
-	BatchID    uint     `gorm:"not null" json:"batch_id"`
-	ProgramID  uint     `gorm:"not null" json:"program_id"`
-	SemesterID uint     `gorm:"not null" json:"semester_id"`
-	Batch      Batch    `gorm:"foreignKey:BatchID"`
-	Program    Program  `gorm:"foreignKey:ProgramID"`
-	Semester   Semester `gorm:"foreignKey:SemesterID"`
-	Status     string   `gorm:"not null; default:NotPublished" json:"status"`
+	BatchID       uint             `gorm:"not null;uniqueIndex:idx_result_cohort" json:"batch_id"` // One result per batch, program and semester
+	ProgramID     uint             `gorm:"not null;uniqueIndex:idx_result_cohort" json:"program_id"`
+	SemesterID    uint             `gorm:"not null;uniqueIndex:idx_result_cohort" json:"semester_id"`
+	Batch         Batch            `gorm:"foreignKey:BatchID"`
+	Program       Program          `gorm:"foreignKey:ProgramID"`
+	Semester      Semester         `gorm:"foreignKey:SemesterID"`
+	Status        string           `gorm:"not null; default:NotPublished" json:"status"` // NotPublished, Published or Withdrawn
+	Revision      int              `gorm:"not null;default:1" json:"revision"`           // Raised each time the result is published again or revised
+	RepublishedAt *time.Time       `json:"republished_at,omitempty"`
+	WithdrawnAt   *time.Time       `json:"withdrawn_at,omitempty"`
+	Snapshots     []ResultSnapshot `gorm:"foreignKey:ResultID" json:"snapshots,omitempty"`
+}
+
+// ResultSnapshot is one version of a result: what students saw after a publication, or the
+// moment it was withdrawn
+type ResultSnapshot struct {
+	gorm.Model
+	ResultID uint                    `gorm:"not null;index" json:"result_id"`
+	Version  int                     `gorm:"not null" json:"version"`
+	Action   string                  `gorm:"type:varchar(20);not null" json:"action"` // published, republished, revised, amended or withdrawn
+	Revision int                     `gorm:"not null" json:"revision"`                // Revision of the result the snapshot was taken at
+	Reason   string                  `json:"reason,omitempty"`
+	Students []ResultSnapshotStudent `gorm:"foreignKey:ResultSnapshotID" json:"students,omitempty"`
+}
+
+// ResultSnapshotStudent is the published line of one student, with the promotion the
+// publication applied so a withdrawal can undo it
+type ResultSnapshotStudent struct {
+	gorm.Model
+	ResultSnapshotID uint                   `gorm:"not null;index" json:"result_snapshot_id"`
+	StudentID        uint                   `gorm:"not null;index" json:"student_id"`
+	SymbolNumber     string                 `json:"symbol_number"`
+	Fullname         string                 `json:"fullname"`
+	Status           string                 `json:"status"` // pass or fail
+	TotalMarks       int                    `json:"total_marks"`
+	SGPA             float64                `json:"sgpa"`
+	CGPA             float64                `json:"cgpa"`
+	Action           string                 `json:"action"` // promote or graduate
+	PreviousSemester uint                   `json:"previous_semester"`
+	PreviousStatus   string                 `json:"previous_status"`
+	Courses          []ResultSnapshotCourse `gorm:"foreignKey:ResultSnapshotStudentID" json:"courses,omitempty"`
 }
+
+// ResultSnapshotCourse is the counted mark of one course as it was published
+type ResultSnapshotCourse struct {
+	gorm.Model
+	ResultSnapshotStudentID uint    `gorm:"not null;index" json:"result_snapshot_student_id"`
+	CourseID                uint    `gorm:"not null" json:"course_id"`
+	CourseCode              string  `json:"course_code"`
+	CourseName              string  `json:"course_name"`
+	Attempt                 int     `json:"attempt"`
+	CreditHours             float64 `json:"credit_hours"`
+	SemesterMarks           int     `json:"semester_marks"`
+	PracticalMarks          int     `json:"practical_marks"`
+	AssistantMarks          int     `json:"assistant_marks"`
+	TotalMarks              int     `json:"total_marks"`
+	FullMarks               int     `json:"full_marks"`
+	Letter                  string  `json:"letter"`
+	GradePoint              float64 `json:"grade_point"`
+	Status                  string  `json:"status"`
+}
+
+// ErrFrozenSnapshot is returned when something tries to change a stored snapshot. Corrections
+// go through an amendment, which takes a new snapshot instead.
+var ErrFrozenSnapshot = errors.New("result snapshots cannot be changed")
+
+func (s *ResultSnapshot) BeforeUpdate(tx *gorm.DB) error        { return ErrFrozenSnapshot }
+func (s *ResultSnapshot) BeforeDelete(tx *gorm.DB) error        { return ErrFrozenSnapshot }
+func (s *ResultSnapshotStudent) BeforeUpdate(tx *gorm.DB) error { return ErrFrozenSnapshot }
+func (s *ResultSnapshotStudent) BeforeDelete(tx *gorm.DB) error { return ErrFrozenSnapshot }
+func (s *ResultSnapshotCourse) BeforeUpdate(tx *gorm.DB) error  { return ErrFrozenSnapshot }
+func (s *ResultSnapshotCourse) BeforeDelete(tx *gorm.DB) error  { return ErrFrozenSnapshot }
@@ -0,0 +1,28 @@
+package models
+
+import "gorm.io/gorm"
+
+// ExamRoom is a hall inside a center college
+type ExamRoom struct {
+	gorm.Model
+	CenterID uint    `gorm:"not null;index" json:"center_id"` // College ID of the center
+	Name     string  `gorm:"type:varchar(100);not null" json:"name"`
+	Capacity int     `gorm:"not null" json:"capacity"`
+	Center   College `gorm:"foreignKey:CenterID" json:"-"`
+}
+
+// SeatAssignment places one student in a room of a center for an exam routine
+type SeatAssignment struct {
+	gorm.Model
+	ExamRoutineID      uint             `gorm:"not null;index" json:"exam_routine_id"`
+	CenterAllocationID uint             `gorm:"not null;index" json:"center_allocation_id"`
+	StudentID          uint             `gorm:"not null;index" json:"student_id"`
+	CenterID           uint             `gorm:"not null" json:"center_id"`
+	ExamRoomID         uint             `gorm:"not null;index" json:"exam_room_id"`
+	SeatNumber         int              `gorm:"not null" json:"seat_number"`
+	Student            Student          `gorm:"foreignKey:StudentID" json:"student"`
+	Center             College          `gorm:"foreignKey:CenterID" json:"-"`
+	ExamRoom           ExamRoom         `gorm:"foreignKey:ExamRoomID" json:"exam_room"`
+	ExamRoutine        ExamRoutine      `gorm:"foreignKey:ExamRoutineID" json:"-"`
+	CenterAllocation   CenterAllocation `gorm:"foreignKey:CenterAllocationID" json:"-"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import "gorm.io/gorm"
+
+// PublishedTranscript is the finished transcript of one student over every published semester,
+// stored ready to serve so result lookups never go through marks and courses. It is rebuilt for
+// the whole cohort whenever one of its results is published, revised or withdrawn.
+type PublishedTranscript struct {
+	gorm.Model
+	StudentID          uint   `gorm:"not null;uniqueIndex" json:"student_id"`
+	SymbolNumber       string `gorm:"type:varchar(50);not null;index" json:"symbol_number"`
+	BatchID            uint   `gorm:"not null;index:idx_transcript_cohort" json:"batch_id"`
+	ProgramID          uint   `gorm:"not null;index:idx_transcript_cohort" json:"program_id"`
+	RegistrationNumber string `gorm:"not null" json:"-"`
+	DateOfBirth        string `gorm:"type:varchar(10)" json:"-"` // 2006-01-02, empty when unknown
+	Fullname           string `gorm:"not null" json:"fullname"`
+	ProgramName        string `json:"program_name"`
+	Batch              string `json:"batch"`
+	Semesters          string `gorm:"type:longtext;not null" json:"-"` // JSON of the published semesters
+}
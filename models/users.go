@@ -1,22 +1,27 @@
 package models
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 )
 
 // User represents the structure of the registration form data
 type User struct {
 	gorm.Model
-	BatchID            *uint    `gorm:"type:bigint;index" json:"batch_id,omitempty"`   // Nullable BatchID
-	ProgramID          *uint    `gorm:"type:bigint;index" json:"program_id,omitempty"` // Nullable ProgramID
-	SymbolNumber       string   `gorm:"type:varchar(100);not null" json:"symbol_number"`
-	RegistrationNumber string   `gorm:"type:varchar(100);not null" json:"registration_number"`
-	Email              string   `gorm:"type:varchar(100);unique;not null" json:"email"`
-	Password           string   `gorm:"type:varchar(100);not null" json:"-"`
-	Role               string   `gorm:"type:varchar(20);default:user" json:"role"`
-	ImageURL           string   `gorm:"type:varchar(255)" json:"image_url,omitempty"`
-	Batch              *Batch   `gorm:"foreignkey:BatchID;constraint:OnDelete:SET NULL;"`   // Nullable foreign key
-	Program            *Program `gorm:"foreignkey:ProgramID;constraint:OnDelete:SET NULL;"` // Nullable foreign key
+	BatchID            *uint      `gorm:"type:bigint;index" json:"batch_id,omitempty"`   // Nullable BatchID
+	ProgramID          *uint      `gorm:"type:bigint;index" json:"program_id,omitempty"` // Nullable ProgramID
+	SymbolNumber       string     `gorm:"type:varchar(100);not null" json:"symbol_number"`
+	RegistrationNumber string     `gorm:"type:varchar(100);not null" json:"registration_number"`
+	Email              string     `gorm:"type:varchar(100);unique;not null" json:"email"`
+	Password           string     `gorm:"type:varchar(100);not null" json:"-"`
+	Role               string     `gorm:"type:varchar(20);default:user" json:"role"`
+	ImageURL           string     `gorm:"type:varchar(255)" json:"image_url,omitempty"`
+	CollegeID          *uint      `gorm:"index" json:"college_id,omitempty"`                  // Set for college admin accounts
+	EmailVerifiedAt    *time.Time `json:"email_verified_at,omitempty"`                        // Users cannot log in before verifying their email
+	Batch              *Batch     `gorm:"foreignkey:BatchID;constraint:OnDelete:SET NULL;"`   // Nullable foreign key
+	Program            *Program   `gorm:"foreignkey:ProgramID;constraint:OnDelete:SET NULL;"` // Nullable foreign key
+	College            *College   `gorm:"foreignkey:CollegeID" json:"college,omitempty"`
 }
 
 type UserInput struct {
@@ -26,5 +31,5 @@ type UserInput struct {
 	RegistrationNumber string `form:"registration_number" json:"registration_number"`
 	Email              string `form:"email" json:"email"`
 	Password           string `form:"password" json:"password"`
-	Role               string `form:"role" json:"role"`
+	Role               string `form:"role" json:"role"` // Ignored on registration, which always creates a user
 }
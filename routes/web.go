@@ -127,9 +127,11 @@ import (
 
 	// controllers "github.com/mysterybee07/result-distribution-system/controllers/admin"
 	authController "github.com/mysterybee07/result-distribution-system/controllers/auth"
+	collegeController "github.com/mysterybee07/result-distribution-system/controllers/college"
 	errorController "github.com/mysterybee07/result-distribution-system/controllers/error"
 	examController "github.com/mysterybee07/result-distribution-system/controllers/exam"
 	noticeController "github.com/mysterybee07/result-distribution-system/controllers/notice"
+	publicController "github.com/mysterybee07/result-distribution-system/controllers/public"
 	userController "github.com/mysterybee07/result-distribution-system/controllers/user"
 	"github.com/mysterybee07/result-distribution-system/middleware"
 )
@@ -147,38 +149,58 @@ func SetupRoutes(app *fiber.App) {
 	// user.Get("/login", authController.Login)
 	user.Post("/login", authController.LoginUser)
 	user.Post("/logout", authController.LogoutUser)
-	user.Get("/forgot-password", authController.ForgotPassword)
-	user.Put("/update/:id", authController.UpdateUser)
+	user.Post("/refresh", authController.RefreshToken)
+	user.Post("/logout-all", middleware.AuthRequired, authController.LogoutAllSessions)
+	user.Get("/sessions", middleware.AuthRequired, authController.GetSessions)
+	user.Delete("/sessions/delete/:id", middleware.AuthRequired, authController.RevokeSession)
+	user.Post("/forgot-password", authController.ForgotPassword)
+	user.Post("/reset-password", authController.ResetPassword)
+	user.Post("/verify-email", authController.VerifyEmail)
+	user.Post("/resend-verification", authController.ResendVerification)
+	user.Put("/update/:id", middleware.AuthRequired, authController.UpdateUser)
 	user.Get("/active", middleware.AuthRequired, authController.AuthorizedUser)
-	user.Get("", authController.GetAllUsers)
-	user.Get("/:id", authController.GetUserById)
+	user.Get("", middleware.RequirePermission("users:manage"), authController.GetAllUsers)
+	user.Get("/:id", middleware.RequirePermission("users:manage"), authController.GetUserById)
+	user.Post("/:id/sessions/revoke", middleware.RequirePermission("users:manage"), authController.RevokeUserSessions)
 	// user.Get("/logout", controllers.LogoutUser)
 
 	// Profile Routes
 	profile := app.Group("/profile")
 	profile.Get("", middleware.AuthRequired, userController.GetUserProfile)
 
+	// Notification Routes
+	notification := app.Group("/notifications", middleware.AuthRequired)
+	notification.Get("", userController.GetNotifications)
+	notification.Put("/read/:id", userController.MarkNotificationRead)
+
 	// Student Routes
 	student := app.Group("/students")
 	// student := app.Group("/students", middleware.AuthRequired, middleware.AdminRequired)
 	// student.Get("/add", adminController.Student)
 	// student.Post("/add", adminController.StoreStudents)
-	student.Get("", middleware.AuthRequired, adminController.GetStudents)
-	student.Put("/update/:id", adminController.UpdateStudent)
-	student.Get("/:id", adminController.GetStudentById)
-	student.Get("/edit/:id", adminController.EditStudent)
-	student.Post("/create", adminController.CreateStudents)
-	student.Get("/filter", adminController.GetFilteredStudents)
-	student.Delete("/delete", adminController.DeleteStudent)
-	student.Get("/pass-students-by-semester", adminController.PassingStudentsBySemester)
-	student.Get("/fail-students-by-course", adminController.FailedStudentsByCourse)
+	student.Get("", middleware.RequirePermission("students:read"), adminController.GetStudents)
+	student.Put("/update/:id", middleware.RequirePermission("students:write"), adminController.UpdateStudent)
+	student.Get("/:id", middleware.RequirePermission("students:read"), adminController.GetStudentById)
+	student.Get("/edit/:id", middleware.RequirePermission("students:write"), adminController.EditStudent)
+	student.Post("/create", middleware.RequirePermission("students:write"), adminController.CreateStudents)
+	student.Get("/filter", middleware.RequirePermission("students:read"), adminController.GetFilteredStudents)
+	student.Delete("/delete", middleware.RequirePermission("students:write"), adminController.DeleteStudent)
+	student.Get("/pass-students-by-semester", middleware.RequirePermission("results:read"), adminController.PassingStudentsBySemester)
+	student.Get("/fail-students-by-course", middleware.RequirePermission("results:read"), adminController.FailedStudentsByCourse)
+
+	// Student lists submitted by colleges, created as students once approved
+	registrationList := app.Group("/registration-lists", middleware.RequirePermission("students:write"))
+	registrationList.Get("", adminController.GetRegistrationLists)
+	registrationList.Get("/:id", adminController.GetRegistrationList)
+	registrationList.Put("/approve/:id", adminController.ApproveRegistrationList)
+	registrationList.Put("/reject/:id", adminController.RejectRegistrationList)
 
 	// Batch Routes
 	batch := app.Group("/batch")
 	// batch := app.Group("/batches", middleware.AuthRequired, middleware.SuperadminRequired)
 	batch.Get("", adminController.GetBatches)
-	batch.Post("/create", adminController.CreateBatch)
-	batch.Put("/update/:id", adminController.UpdateBatch)
+	batch.Post("/create", middleware.RequirePermission("academics:manage"), adminController.CreateBatch)
+	batch.Put("/update/:id", middleware.RequirePermission("academics:manage"), adminController.UpdateBatch)
 	// batch.Get("/")
 
 	// Program Routes
@@ -186,45 +208,126 @@ func SetupRoutes(app *fiber.App) {
 	// program := app.Group("/programs", middleware.AuthRequired, middleware.SuperadminRequired)
 	program.Get("", middleware.AuthRequired, adminController.GetPrograms)
 	// program.Post("/add", adminController.CreateProgram)
-	program.Post("/create", middleware.AuthRequired, adminController.CreateProgram)
-	program.Put("/update/:id", adminController.UpdateProgram)
+	program.Post("/create", middleware.RequirePermission("academics:manage"), adminController.CreateProgram)
+	program.Put("/update/:id", middleware.RequirePermission("academics:manage"), adminController.UpdateProgram)
 
 	// Semester Routes
 	semester := app.Group("/semester")
 	// semester := app.Group("/semesters", middleware.AuthRequired, middleware.SuperadminRequired)
 	semester.Get("/", adminController.Semester)
 	// semester.Post("/", adminController.CreateSemester)
-	semester.Post("/create", adminController.CreateSemester)
-	semester.Put("/update/:id", adminController.UpdateSemester)
+	semester.Post("/create", middleware.RequirePermission("academics:manage"), adminController.CreateSemester)
+	semester.Put("/update/:id", middleware.RequirePermission("academics:manage"), adminController.UpdateSemester)
 	semester.Get("/by-program/:id", adminController.GetSemestersByProgramID)
 
 	// Course Routes
 	course := app.Group("/courses")
 	// course := app.Group("/courses", middleware.AuthRequired, middleware.SuperadminRequired)
 	course.Get("", adminController.GetAllCourses)
-	// course.Post("/create", adminController.CreateCourses)
-	// TODO: get all courses
-	course.Post("/create", adminController.CreateCourses)
-	course.Put("/update/:id", adminController.UpdateCourse)
+	course.Post("/create", middleware.RequirePermission("academics:manage"), adminController.CreateCourses)
+	course.Put("/update/:id", middleware.RequirePermission("academics:manage"), adminController.UpdateCourse)
 	course.Get("/filter", adminController.GetFilteredCourses)
 	course.Get("/:id", adminController.GetCourseById)
 
 	// Mark Routes
 	mark := app.Group("/marks")
-	mark.Get("/", adminController.Marks)
-	// mark.Post("/create", middleware.AuthRequired, middleware.AdminRequired, adminController.CreateMarks)
-	mark.Post("/create", adminController.CreateMarks)
-	// mark.Put("/update/:id", middleware.AuthRequired, middleware.AdminRequired, adminController.UpdateMarks)
-	mark.Put("/update/:id", adminController.UpdateMarks)
+	mark.Get("/", middleware.RequirePermission("marks:read"), adminController.Marks)
+	// Changes to marks are attributed to the signed in user
+	mark.Post("/create", middleware.RequireAnyPermission("marks:write", "marks:write-assigned"), adminController.CreateMarks)
+	mark.Put("/update/:id", middleware.RequireAnyPermission("marks:write", "marks:write-assigned"), adminController.UpdateMarks)
+	mark.Post("/amend", middleware.RequirePermission("marks:amend"), adminController.AmendMarks)
+	mark.Post("/import", middleware.RequireAnyPermission("marks:write", "marks:write-assigned"), adminController.ImportMarks)
+	mark.Get("/entries", middleware.RequireAnyPermission("marks:write", "marks:write-assigned"), adminController.GetMarkEntries)
+	mark.Get("/reconciliations", middleware.RequirePermission("marks:reconcile"), adminController.GetMarkReconciliations)
+	mark.Post("/reconciliations/:id/resolve", middleware.RequirePermission("marks:reconcile"), adminController.ResolveMarkReconciliation)
 	// mark.Get("/:symbolNumber", middleware.AuthRequired, middleware.AdminRequired, adminController.GetMarksBySymbolNumber)
-	mark.Get("/:symbolNumber", adminController.GetMarksBySymbolNumber)
+	// Students use the public lookup; the full marks are for staff
+	mark.Get("/:symbolNumber", middleware.RequirePermission("marks:read"), adminController.GetMarksBySymbolNumber)
 	// app.Post("/publish-results", middleware.AuthRequired, middleware.AdminRequired, adminController.PublishResults)
 
 	// Result Routes
 	// result := app.Group("/result", middleware.AuthRequired, middleware.SuperadminRequired)
 	result := app.Group("/result")
-	result.Get("", adminController.Result)
-	result.Post("/publish", adminController.PublishResults)
+	result.Get("", middleware.RequirePermission("results:read"), adminController.Result)
+	result.Post("/publish", middleware.RequirePermission("results:publish"), adminController.PublishResults)
+	result.Get("/grades", middleware.RequirePermission("results:read"), adminController.GetPublishedResultGrades)
+	result.Post("/withdraw", middleware.RequirePermission("results:publish"), adminController.WithdrawResult)
+	result.Get("/history", middleware.RequirePermission("results:read"), adminController.GetResultHistory)
+	result.Post("/transcripts/rebuild", middleware.RequirePermission("results:publish"), adminController.RebuildTranscripts)
+	result.Get("/grade-sheet/:studentID/:semesterID", middleware.RequirePermission("documents:issue"), adminController.GetGradeSheet)
+	result.Get("/transcript/:studentID", middleware.RequirePermission("documents:issue"), adminController.GetTranscript)
+	result.Post("/grade-sheets", middleware.RequirePermission("documents:issue"), adminController.GenerateGradeSheets)
+
+	// Public result lookup, for students without an account
+	public := app.Group("/public")
+	public.Post("/results", middleware.PublicLookupLimiter(), middleware.LookupThrottle, publicController.LookupResult)
+
+	// Verification of printed grade sheets and transcripts, linked from their QR codes
+	verifyLimiter := middleware.PublicLookupLimiter()
+	app.Get("/verify", verifyLimiter, publicController.VerifyDocument)
+	app.Post("/verify", verifyLimiter, publicController.VerifyDocument)
+	app.Get("/verify/public-key", publicController.GetDocumentPublicKey)
+
+	// Examiner Routes
+	examiners := app.Group("/examiners", middleware.AuthRequired)
+	examiners.Get("/my-courses", adminController.GetMyExaminerCourses)
+	examiners.Get("/assignments", middleware.RequirePermission("examiners:manage"), adminController.GetExaminerAssignments)
+	examiners.Post("/assignments/create", middleware.RequirePermission("examiners:manage"), adminController.CreateExaminerAssignment)
+	examiners.Delete("/assignments/delete/:id", middleware.RequirePermission("examiners:manage"), adminController.DeleteExaminerAssignment)
+	examiners.Get("/deadlines", middleware.RequirePermission("examiners:manage"), adminController.GetMarkEntryDeadlines)
+	examiners.Put("/deadlines", middleware.RequirePermission("examiners:manage"), adminController.SetMarkEntryDeadline)
+	examiners.Delete("/deadlines/delete/:id", middleware.RequirePermission("examiners:manage"), adminController.DeleteMarkEntryDeadline)
+	examiners.Post("/appoint/:userID", middleware.RequirePermission("examiners:manage"), adminController.AppointExaminer)
+	examiners.Post("/dismiss/:userID", middleware.RequirePermission("examiners:manage"), adminController.DismissExaminer)
+
+	// Re-evaluation Routes
+	reevaluation := app.Group("/reevaluations", middleware.AuthRequired)
+	reevaluation.Post("/create", userController.FileReevaluation)
+	reevaluation.Get("/my", userController.GetMyReevaluations)
+	reevaluation.Get("/assigned", middleware.RequirePermission("reevaluations:review"), adminController.GetAssignedReevaluations)
+	reevaluation.Put("/decide/:id", middleware.RequirePermission("reevaluations:review"), adminController.DecideReevaluation)
+	reevaluation.Get("", middleware.RequirePermission("reevaluations:manage"), adminController.GetReevaluations)
+	reevaluation.Put("/fee/:id", middleware.RequirePermission("reevaluations:manage"), adminController.RecordReevaluationFee)
+	reevaluation.Put("/assign/:id", middleware.RequirePermission("reevaluations:manage"), adminController.AssignReevaluationReviewer)
+
+	// Access control Routes
+	rbac := app.Group("/rbac", middleware.RequirePermission("roles:manage"))
+	rbac.Get("/permissions", adminController.GetPermissions)
+	rbac.Get("/roles", adminController.GetRoles)
+	rbac.Post("/roles/create", adminController.CreateRole)
+	rbac.Put("/roles/update/:id", adminController.UpdateRole)
+	rbac.Delete("/roles/delete/:id", adminController.DeleteRole)
+	rbac.Put("/users/:id/role", adminController.SetUserRole)
+	rbac.Get("/users/:id/scopes", adminController.GetUserScopes)
+	rbac.Put("/users/:id/scopes", adminController.SetUserScopes)
+
+	// Moderation rule Routes
+	moderation := app.Group("/moderation-rules", middleware.RequirePermission("marks:moderate"))
+	moderation.Get("", adminController.GetModerationRules)
+	moderation.Post("/create", adminController.CreateModerationRule)
+	moderation.Post("/preview", adminController.PreviewModerationRule)
+	moderation.Get("/:id", adminController.GetModerationRule)
+	moderation.Put("/update/:id", adminController.UpdateModerationRule)
+	moderation.Delete("/delete/:id", adminController.DeleteModerationRule)
+	moderation.Get("/:id/preview", adminController.PreviewModerationRule)
+	moderation.Post("/:id/apply", adminController.ApplyModerationRule)
+	moderation.Post("/:id/revert", adminController.RevertModerationRule)
+
+	// Grading scheme Routes
+	grading := app.Group("/grading-schemes")
+	grading.Get("", middleware.AuthRequired, adminController.GetGradingSchemes)
+	grading.Post("/create", middleware.RequirePermission("academics:manage"), adminController.CreateGradingScheme)
+	grading.Put("/update/:id", middleware.RequirePermission("academics:manage"), adminController.UpdateGradingScheme)
+	grading.Delete("/delete/:id", middleware.RequirePermission("academics:manage"), adminController.DeleteGradingScheme)
+
+	// Back paper Routes
+	backPaper := app.Group("/back-papers")
+	backPaper.Get("", middleware.RequirePermission("backpapers:manage"), adminController.GetBackPaperRegistrations)
+	backPaper.Post("/register", middleware.RequirePermission("backpapers:manage"), adminController.RegisterBackPapers)
+	backPaper.Put("/cancel/:id", middleware.RequirePermission("backpapers:manage"), adminController.CancelBackPaperRegistration)
+	backPaper.Post("/schedule", middleware.RequirePermission("backpapers:manage"), adminController.ScheduleBackPapers)
+	backPaper.Post("/marks", middleware.RequireAnyPermission("marks:write", "marks:write-assigned"), adminController.CreateBackPaperMarks)
+	backPaper.Post("/republish", middleware.RequirePermission("backpapers:manage"), adminController.RepublishResults)
 
 	// Error Routes
 	errorGroup := app.Group("/error")
@@ -239,29 +342,70 @@ func SetupRoutes(app *fiber.App) {
 	//notice routes
 	notice := app.Group("/notice")
 	notice.Get("", noticeController.GetAllNotices)
-	notice.Post("/create", noticeController.CreateNotice)
-	notice.Delete("/delete/:id", noticeController.DeleteNotice)
-	notice.Put("/update/:id", noticeController.UpdateNotice)
+	notice.Post("/create", middleware.RequirePermission("notices:manage"), noticeController.CreateNotice)
+	notice.Delete("/delete/:id", middleware.RequirePermission("notices:manage"), noticeController.DeleteNotice)
+	notice.Put("/update/:id", middleware.RequirePermission("notices:manage"), noticeController.UpdateNotice)
 	notice.Get("/by-id/:id", noticeController.GetNoticeById)
 	notice.Get("/by-program", noticeController.GetNoticesByProgram)
 	notice.Get("/by-program-and-batch", noticeController.GetNoticesByProgramAndBatch)
-	notice.Post("/publish", noticeController.PublishNotice)
+	notice.Post("/publish", middleware.RequirePermission("notices:manage"), noticeController.PublishNotice)
 
 	exam := app.Group("/exam")
 	exam.Get("/routines", adminController.ListExamsRoutine)
 	exam.Get("/schedules", adminController.ListExamSchedules)
 	exam.Get("/schedules/by-batch-program", adminController.GetFilteredExamSchedules)
-	exam.Get("/assign-centers", examController.AssignCentersHandler)
-	exam.Post("/update-center-and-capacity", adminController.AssignCenterAndCapacity)
-	exam.Put("/update-capacity/:id", adminController.UpdateCapacity)
-	exam.Post("/schedule/create", adminController.CreateExamRoutine)
-	exam.Post("/schedule/publish/:id", adminController.PublishExamRoutine)
+	exam.Get("/assign-centers", middleware.RequirePermission("exams:manage"), examController.AssignCentersHandler)
+	exam.Post("/update-center-and-capacity", middleware.RequirePermission("exams:manage"), adminController.AssignCenterAndCapacity)
+	exam.Put("/update-capacity/:id", middleware.RequirePermission("exams:manage"), adminController.UpdateCapacity)
+	exam.Post("/schedule/create", middleware.RequirePermission("exams:manage"), adminController.CreateExamRoutine)
+	exam.Post("/schedule/publish/:id", middleware.RequirePermission("exams:manage"), adminController.PublishExamRoutine)
+	exam.Get("/clashes", middleware.RequirePermission("exams:manage"), examController.GetCenterClashes)
+	exam.Get("/allocations", middleware.RequirePermission("exams:manage"), examController.ListCenterAllocations)
+	exam.Post("/allocations", middleware.RequirePermission("exams:manage"), examController.CreateCenterAllocation)
+	exam.Get("/allocations/diff", middleware.RequirePermission("exams:manage"), examController.DiffCenterAllocations)
+	exam.Get("/allocations/:id", middleware.RequirePermission("exams:manage"), examController.GetCenterAllocation)
+	exam.Post("/allocations/:id/publish", middleware.RequirePermission("exams:manage"), examController.PublishCenterAllocation)
+	exam.Post("/allocations/:id/rollback", middleware.RequirePermission("exams:manage"), examController.RollbackCenterAllocation)
+	exam.Post("/allocations/:id/seat-plan", middleware.RequirePermission("exams:manage"), examController.GenerateSeatPlan)
+	exam.Get("/rooms", middleware.RequirePermission("exams:manage"), examController.GetExamRooms)
+	exam.Post("/rooms/create", middleware.RequirePermission("exams:manage"), examController.CreateExamRooms)
+	exam.Put("/rooms/update/:id", middleware.RequirePermission("exams:manage"), examController.UpdateExamRoom)
+	exam.Delete("/rooms/delete/:id", middleware.RequirePermission("exams:manage"), examController.DeleteExamRoom)
+	exam.Get("/rooms/:id/seat-plan/export", middleware.RequirePermission("exams:manage"), examController.ExportRoomSeatPlan)
+	exam.Get("/seat-plan", middleware.RequirePermission("exams:manage"), examController.GetSeatPlan)
+	exam.Get("/admit-cards/verify", examController.VerifyAdmitCard)
+	exam.Post("/admit-cards/:routineID/generate", middleware.RequirePermission("exams:manage"), examController.GenerateAdmitCards)
+	exam.Get("/admit-cards/:routineID/student/:symbolNumber", middleware.AuthRequired, examController.GetAdmitCard)
+	exam.Get("/admit-cards/batches/:id", middleware.RequirePermission("exams:manage"), examController.GetAdmitCardBatch)
+	exam.Get("/admit-cards/batches/:id/college/:collegeID", middleware.RequirePermission("exams:manage"), examController.DownloadCollegeAdmitCards)
+
+	calendar := app.Group("/calendar")
+	calendar.Get("", adminController.GetCalendarEvents)
+	calendar.Post("/create", middleware.RequirePermission("academics:manage"), adminController.CreateCalendarEvent)
+	calendar.Post("/import", middleware.RequirePermission("academics:manage"), adminController.ImportCalendar)
+	calendar.Put("/update/:id", middleware.RequirePermission("academics:manage"), adminController.UpdateCalendarEvent)
+	calendar.Delete("/delete/:id", middleware.RequirePermission("academics:manage"), adminController.DeleteCalendarEvent)
 
 	college := app.Group("/college")
 	college.Get("", adminController.GetColleges)
-	college.Post("/upload-college", adminController.UploadColleges)
+	college.Post("/upload-college", middleware.RequirePermission("colleges:manage"), adminController.UploadColleges)
 	college.Get("/centers-by-program-and-batch", adminController.GetCenterCollegesByProgramAndBatch)
 	// college.Get("/all-centers", adminController.GetAllCenterColleges)
-	college.Put("/update-college/:id", adminController.UpdateCollege)
-	college.Delete("/delete-college/:id", adminController.DeleteCollege)
+	college.Put("/update-college/:id", middleware.RequirePermission("colleges:manage"), adminController.UpdateCollege)
+	college.Delete("/delete-college/:id", middleware.RequirePermission("colleges:manage"), adminController.DeleteCollege)
+	college.Get("/:id/admins", middleware.RequirePermission("users:manage"), adminController.GetCollegeAdmins)
+	college.Post("/:id/admins/create", middleware.RequirePermission("users:manage"), adminController.CreateCollegeAdmin)
+	college.Delete("/admins/delete/:userID", middleware.RequirePermission("users:manage"), adminController.RemoveCollegeAdmin)
+
+	// College portal Routes, for the accounts of a college; everything is limited to that college
+	collegePortal := app.Group("/college-portal", middleware.RequirePermission("college:portal"))
+	collegePortal.Get("/students", collegeController.GetPortalStudents)
+	collegePortal.Get("/exam-centers", collegeController.GetPortalExamCenters)
+	collegePortal.Get("/seats", collegeController.GetPortalSeats)
+	collegePortal.Get("/admit-cards/:routineID", collegeController.DownloadPortalAdmitCards)
+	collegePortal.Get("/notices", collegeController.GetPortalNotices)
+	collegePortal.Get("/notices/:id/download", collegeController.DownloadPortalNotice)
+	collegePortal.Get("/registrations", collegeController.GetPortalRegistrationLists)
+	collegePortal.Post("/registrations/create", collegeController.SubmitRegistrationList)
+	collegePortal.Get("/registrations/:id", collegeController.GetPortalRegistrationList)
 }
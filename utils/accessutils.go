@@ -0,0 +1,191 @@
+package utils
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mysterybee07/result-distribution-system/initializers"
+	"github.com/mysterybee07/result-distribution-system/models"
+	"gorm.io/gorm"
+)
+
+// Access is what a user may do: the permissions of their role and the programs and colleges
+// they are limited to. Empty scopes mean no limit.
+type Access struct {
+	UserID      uint            `json:"user_id"`
+	Role        string          `json:"role"`
+	Permissions map[string]bool `json:"permissions"`
+	ProgramIDs  []uint          `json:"program_ids,omitempty"`
+	CollegeIDs  []uint          `json:"college_ids,omitempty"`
+	CollegeID   *uint           `json:"college_id,omitempty"` // The college of a college admin account
+}
+
+// Can reports whether the user holds a permission
+func (a *Access) Can(permission string) bool {
+	return a.Permissions[permission]
+}
+
+// AllowsProgram reports whether the program is within the user's scope
+func (a *Access) AllowsProgram(programID uint) bool {
+	return len(a.ProgramIDs) == 0 || containsID(a.ProgramIDs, programID)
+}
+
+// AllowsCollege reports whether the college is within the user's scope
+func (a *Access) AllowsCollege(collegeID uint) bool {
+	return len(a.CollegeIDs) == 0 || containsID(a.CollegeIDs, collegeID)
+}
+
+// AllowsStudent reports whether the student's program and college are within the user's scope
+func (a *Access) AllowsStudent(student models.Student) bool {
+	return a.AllowsProgram(student.ProgramID) && a.AllowsCollege(student.CollegeID)
+}
+
+// Covers reports whether the user holds every permission of other, so that taking over other's
+// account gains the user nothing
+func (a *Access) Covers(other *Access) bool {
+	for permission := range other.Permissions {
+		if !a.Can(permission) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckStudent fails as not found when the student does not exist or is outside the user's
+// scope, so scoped users cannot tell the two apart
+func (a *Access) CheckStudent(studentID uint) error {
+	var student models.Student
+	if err := initializers.DB.First(&student, studentID).Error; err != nil || !a.AllowsStudent(student) {
+		return fiber.NewError(fiber.StatusNotFound, "Student not found")
+	}
+	return nil
+}
+
+// CheckCohort fails unless the user may act on a whole program cohort: the program is in scope
+// and the user is not limited to some colleges
+func (a *Access) CheckCohort(programID uint) error {
+	if !a.AllowsProgram(programID) || len(a.CollegeIDs) > 0 {
+		return fiber.NewError(fiber.StatusForbidden, "The program is outside your scope")
+	}
+	return nil
+}
+
+// Scope limits a query to the user's programs and colleges. An empty column name skips that
+// scope, for tables without it.
+func (a *Access) Scope(query *gorm.DB, programColumn, collegeColumn string) *gorm.DB {
+	if programColumn != "" && len(a.ProgramIDs) > 0 {
+		query = query.Where(programColumn+" IN ?", a.ProgramIDs)
+	}
+	if collegeColumn != "" && len(a.CollegeIDs) > 0 {
+		query = query.Where(collegeColumn+" IN ?", a.CollegeIDs)
+	}
+	return query
+}
+
+// PortalCollege returns the college a college admin account belongs to
+func (a *Access) PortalCollege() (uint, error) {
+	if a.CollegeID == nil {
+		return 0, fiber.NewError(fiber.StatusForbidden, "Your account is not linked to a college")
+	}
+	return *a.CollegeID, nil
+}
+
+func containsID(ids []uint, id uint) bool {
+	for _, candidate := range ids {
+		if candidate == id {
+			return true
+		}
+	}
+	return false
+}
+
+const accessCacheTTL = time.Minute
+
+type cachedAccess struct {
+	access  *Access
+	expires time.Time
+}
+
+// accessCache keeps the access of recent users so permission checks do not query the user, role
+// and scopes on every request. Changes through the admin API clear it; the expiry catches
+// changes made by other instances.
+var accessCache = struct {
+	sync.RWMutex
+	entries map[string]cachedAccess
+}{entries: make(map[string]cachedAccess)}
+
+// UserAccess loads the access of a user, from the cache when it is fresh
+func UserAccess(userID string) (*Access, error) {
+	if userID == "" {
+		return nil, fiber.NewError(fiber.StatusUnauthorized, "unauthorized user")
+	}
+
+	accessCache.RLock()
+	entry, ok := accessCache.entries[userID]
+	accessCache.RUnlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.access, nil
+	}
+
+	var user models.User
+	if err := initializers.DB.First(&user, userID).Error; err != nil {
+		return nil, fiber.NewError(fiber.StatusUnauthorized, "unauthorized user")
+	}
+
+	access := &Access{UserID: user.ID, Role: user.Role, Permissions: make(map[string]bool)}
+	var role models.Role
+	if err := initializers.DB.Preload("Permissions").Where("name = ?", user.Role).Limit(1).Find(&role).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch role: %w", err)
+	}
+	for _, permission := range role.Permissions {
+		access.Permissions[permission.Name] = true
+	}
+
+	var scopes []models.UserScope
+	if err := initializers.DB.Where("user_id = ?", user.ID).Find(&scopes).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch user scopes: %w", err)
+	}
+	for _, scope := range scopes {
+		if scope.ProgramID != nil {
+			access.ProgramIDs = append(access.ProgramIDs, *scope.ProgramID)
+		}
+		if scope.CollegeID != nil {
+			access.CollegeIDs = append(access.CollegeIDs, *scope.CollegeID)
+		}
+	}
+	// A college account never sees past its own college, whatever scopes it was given
+	if user.Role == models.RoleCollegeAdmin && user.CollegeID != nil {
+		access.CollegeID = user.CollegeID
+		access.CollegeIDs = []uint{*user.CollegeID}
+	}
+
+	accessCache.Lock()
+	accessCache.entries[userID] = cachedAccess{access: access, expires: time.Now().Add(accessCacheTTL)}
+	accessCache.Unlock()
+	return access, nil
+}
+
+// InvalidateAccess drops every cached access, after roles, permissions, scopes or a user's role
+// change
+func InvalidateAccess() {
+	accessCache.Lock()
+	accessCache.entries = make(map[string]cachedAccess)
+	accessCache.Unlock()
+}
+
+// RequestAccess returns the access RequirePermission stored for the request, or loads it for
+// routes that only require sign in
+func RequestAccess(c *fiber.Ctx) (*Access, error) {
+	if access, ok := c.Locals("access").(*Access); ok {
+		return access, nil
+	}
+	userID, _ := c.Locals("userID").(string)
+	access, err := UserAccess(userID)
+	if err != nil {
+		return nil, err
+	}
+	c.Locals("access", access)
+	return access, nil
+}
@@ -0,0 +1,230 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mysterybee07/result-distribution-system/initializers"
+	"github.com/mysterybee07/result-distribution-system/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PasswordResetTTL is how long a reset link works, PASSWORD_RESET_TTL minutes or 60
+func PasswordResetTTL() time.Duration {
+	if minutes, err := strconv.Atoi(os.Getenv("PASSWORD_RESET_TTL")); err == nil && minutes > 0 {
+		return time.Duration(minutes) * time.Minute
+	}
+	return time.Hour
+}
+
+// EmailVerificationTTL is how long a verification link works, EMAIL_VERIFICATION_TTL hours or 48
+func EmailVerificationTTL() time.Duration {
+	if hours, err := strconv.Atoi(os.Getenv("EMAIL_VERIFICATION_TTL")); err == nil && hours > 0 {
+		return time.Duration(hours) * time.Hour
+	}
+	return 48 * time.Hour
+}
+
+// FrontendURL is the base URL of the pages mailed links open, FRONTEND_URL or the dev server
+func FrontendURL() string {
+	if url := os.Getenv("FRONTEND_URL"); url != "" {
+		return strings.TrimRight(url, "/")
+	}
+	return "http://localhost:5173"
+}
+
+var errInvalidUserToken = fiber.NewError(fiber.StatusBadRequest, "The link is invalid or has expired")
+
+// SendEmailVerification mails a link that verifies the user's email. Earlier links stop working.
+func SendEmailVerification(user models.User) error {
+	raw, err := issueUserToken(user.ID, models.TokenEmailVerification, EmailVerificationTTL())
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/verify-email?token=%s", FrontendURL(), url.QueryEscape(raw))
+	SendMail(Mail{
+		To:      user.Email,
+		Subject: "Verify your email",
+		Body: fmt.Sprintf("Open this link to verify your email and activate your account:\n\n%s\n\n"+
+			"The link works once and expires in %s. If you did not create an account, ignore this mail.\n",
+			link, EmailVerificationTTL()),
+	})
+	return nil
+}
+
+// ResendEmailVerification mails a new verification link when the email belongs to an account
+// that is not verified yet. It reports nothing either way, so callers cannot learn which emails
+// have accounts.
+func ResendEmailVerification(email string) {
+	var user models.User
+	if err := initializers.DB.Where("email = ? AND email_verified_at IS NULL", strings.TrimSpace(email)).
+		First(&user).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Println("Failed to look up account for verification:", err)
+		}
+		return
+	}
+	if err := SendEmailVerification(user); err != nil {
+		log.Println("Failed to send email verification:", err)
+	}
+}
+
+// VerifyEmail marks the email of the token's user verified
+func VerifyEmail(raw string) error {
+	return initializers.DB.Transaction(func(tx *gorm.DB) error {
+		token, err := consumeUserToken(tx, raw, models.TokenEmailVerification)
+		if err != nil {
+			return err
+		}
+		return tx.Model(&models.User{}).Where("id = ? AND email_verified_at IS NULL", token.UserID).
+			Update("email_verified_at", time.Now()).Error
+	})
+}
+
+// RequestPasswordReset mails a reset link when the email belongs to an account. Like
+// ResendEmailVerification it reports nothing, whether or not an account exists.
+func RequestPasswordReset(email string) {
+	var user models.User
+	if err := initializers.DB.Where("email = ?", strings.TrimSpace(email)).First(&user).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Println("Failed to look up account for password reset:", err)
+		}
+		return
+	}
+
+	raw, err := issueUserToken(user.ID, models.TokenPasswordReset, PasswordResetTTL())
+	if err != nil {
+		log.Println("Failed to issue password reset token:", err)
+		return
+	}
+
+	link := fmt.Sprintf("%s/reset-password?token=%s", FrontendURL(), url.QueryEscape(raw))
+	SendMail(Mail{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Open this link to choose a new password:\n\n%s\n\n"+
+			"The link works once and expires in %s. If you did not ask for it, ignore this mail; "+
+			"your password stays the same.\n", link, PasswordResetTTL()),
+	})
+}
+
+// ResetPassword sets a new password with a reset token. It also verifies the email, since the
+// link reached it, and signs the account out everywhere.
+func ResetPassword(raw, password string) error {
+	if len(password) < 8 {
+		return fiber.NewError(fiber.StatusBadRequest, "Password must be at least 8 characters long")
+	}
+	hashedPassword, err := HashPassword(password)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	var userID uint
+	err = initializers.DB.Transaction(func(tx *gorm.DB) error {
+		token, err := consumeUserToken(tx, raw, models.TokenPasswordReset)
+		if err != nil {
+			return err
+		}
+		userID = token.UserID
+
+		var user models.User
+		if err := tx.First(&user, token.UserID).Error; err != nil {
+			return errInvalidUserToken
+		}
+		updates := map[string]interface{}{"password": hashedPassword}
+		if user.EmailVerifiedAt == nil {
+			updates["email_verified_at"] = time.Now()
+		}
+		if err := tx.Model(&user).Updates(updates).Error; err != nil {
+			return fmt.Errorf("failed to update password: %w", err)
+		}
+
+		// Other reset links mailed before this one stop working too
+		return tx.Model(&models.UserToken{}).
+			Where("user_id = ? AND purpose = ? AND used_at IS NULL", user.ID, models.TokenPasswordReset).
+			Update("used_at", time.Now()).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := RevokeSessions(userID, 0); err != nil {
+		log.Println("Failed to revoke sessions after a password reset:", err)
+	}
+	return nil
+}
+
+// NotifyRegistrationAttempt tells the owner of an account that someone tried to register with
+// its email or student details, in place of telling the one registering that they are taken
+func NotifyRegistrationAttempt(email string) {
+	SendMail(Mail{
+		To:      email,
+		Subject: "Someone tried to register your account again",
+		Body: fmt.Sprintf("Someone tried to register with your email or your symbol and registration numbers. "+
+			"Your account already exists, so no new account was created.\n\n"+
+			"If you forgot your password, reset it at %s/forgot-password. If this was not you, ignore this mail.\n",
+			FrontendURL()),
+	})
+}
+
+// issueUserToken replaces the user's unused tokens of a purpose with a new one and returns it
+func issueUserToken(userID uint, purpose string, ttl time.Duration) (string, error) {
+	raw, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	err = initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+			Delete(&models.UserToken{}).Error; err != nil {
+			return fmt.Errorf("failed to remove old tokens: %w", err)
+		}
+		token := models.UserToken{
+			UserID:    userID,
+			Purpose:   purpose,
+			TokenHash: hashToken(raw),
+			ExpiresAt: time.Now().Add(ttl),
+		}
+		if err := tx.Create(&token).Error; err != nil {
+			return fmt.Errorf("failed to save token: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+// consumeUserToken uses up a token of a purpose, failing the same way whether it does not
+// exist, was used or expired
+func consumeUserToken(tx *gorm.DB, raw, purpose string) (*models.UserToken, error) {
+	if raw == "" {
+		return nil, errInvalidUserToken
+	}
+
+	var token models.UserToken
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("token_hash = ? AND purpose = ?", hashToken(raw), purpose).First(&token).Error; err != nil {
+		return nil, errInvalidUserToken
+	}
+	if token.UsedAt != nil || time.Now().After(token.ExpiresAt) {
+		return nil, errInvalidUserToken
+	}
+
+	now := time.Now()
+	if err := tx.Model(&token).Update("used_at", now).Error; err != nil {
+		return nil, fmt.Errorf("failed to use token: %w", err)
+	}
+	token.UsedAt = &now
+	return &token, nil
+}
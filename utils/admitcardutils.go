@@ -0,0 +1,405 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mysterybee07/result-distribution-system/initializers"
+	"github.com/mysterybee07/result-distribution-system/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AdmitCard holds everything printed on one student's admit card
+type AdmitCard struct {
+	Student   models.Student
+	Routine   models.ExamRoutine
+	Schedules []models.ExamSchedules
+	Seat      models.SeatAssignment
+	PhotoPath string
+	Code      string
+}
+
+var admitCardKey struct {
+	sync.Once
+	key []byte
+}
+
+// LoadAdmitCardKey reads ADMIT_CARD_SECRET, the key of the codes printed on admit cards, of at
+// least 32 characters. Without it the server does not start, except in development mode, which
+// makes up a random key and keeps it in .dev-keys.
+func LoadAdmitCardKey() {
+	admitCardKey.Do(func() {
+		secret := os.Getenv("ADMIT_CARD_SECRET")
+		switch {
+		case secret != "":
+			if len(secret) < 32 {
+				log.Fatalf("ADMIT_CARD_SECRET must be at least 32 characters long")
+			}
+			admitCardKey.key = []byte(secret)
+		case DevelopmentMode():
+			key, err := developmentKey("admit-card", 32)
+			if err != nil {
+				log.Fatalf("Failed to load the development admit card key: %v", err)
+			}
+			log.Println("ADMIT_CARD_SECRET is not set, admit card codes use a development key")
+			admitCardKey.key = key
+		default:
+			log.Fatalf("ADMIT_CARD_SECRET is not set; configure a secret of at least 32 characters")
+		}
+	})
+}
+
+// AdmitCardCode is the verification code printed on the card and encoded in its QR code
+func AdmitCardCode(examRoutineID uint, symbolNumber string) string {
+	LoadAdmitCardKey()
+	mac := hmac.New(sha256.New, admitCardKey.key)
+	fmt.Fprintf(mac, "%d|%s", examRoutineID, symbolNumber)
+	return strings.ToUpper(hex.EncodeToString(mac.Sum(nil)[:12]))
+}
+
+// AdmitCardVerifyURL is the link encoded in the QR code of a card
+func AdmitCardVerifyURL(examRoutineID uint, symbolNumber string) string {
+	return fmt.Sprintf("%s/exam/admit-cards/verify?routine_id=%d&symbol_number=%s&code=%s",
+		AppURL(), examRoutineID, url.QueryEscape(symbolNumber), AdmitCardCode(examRoutineID, symbolNumber))
+}
+
+// LoadAdmitCards collects the admit cards of an exam routine. A zero collegeID or an empty
+// symbolNumber disables that filter. Students without a seat are counted but get no card.
+func LoadAdmitCards(examRoutineID, collegeID uint, symbolNumber string) ([]AdmitCard, int, error) {
+	var routine models.ExamRoutine
+	if err := initializers.DB.Preload("Batch").Preload("Program").Preload("Semester").First(&routine, examRoutineID).Error; err != nil {
+		return nil, 0, fiber.NewError(fiber.StatusNotFound, "Exam routine not found")
+	}
+
+	var schedules []models.ExamSchedules
+	if err := initializers.DB.Preload("Course").
+		Where("exam_routine_id = ?", examRoutineID).
+		Order("exam_date").
+		Find(&schedules).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch exam schedules: %w", err)
+	}
+
+	query := initializers.DB.Preload("College").
+		Where("batch_id = ? AND program_id = ?", routine.BatchID, routine.ProgramID).
+		Order("college_id, symbol_number")
+
+	// Back paper candidates may have moved on or graduated, so they are picked by registration
+	var registrations []models.BackPaperRegistration
+	if routine.Kind == "back_paper" {
+		if err := initializers.DB.
+			Where("exam_routine_id = ? AND status IN ?", examRoutineID, []string{"Scheduled", "Completed"}).
+			Find(&registrations).Error; err != nil {
+			return nil, 0, fmt.Errorf("failed to fetch back paper registrations: %w", err)
+		}
+		var studentIDs []uint
+		for _, registration := range registrations {
+			studentIDs = append(studentIDs, registration.StudentID)
+		}
+		query = query.Where("id IN ?", studentIDs)
+	} else {
+		query = query.Where("status = ?", "active")
+	}
+	if collegeID != 0 {
+		query = query.Where("college_id = ?", collegeID)
+	}
+	if symbolNumber != "" {
+		query = query.Where("symbol_number = ?", symbolNumber)
+	}
+
+	var students []models.Student
+	if err := query.Find(&students).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch students: %w", err)
+	}
+	if len(students) == 0 {
+		return nil, 0, fiber.NewError(fiber.StatusNotFound, "No students found for the exam routine")
+	}
+
+	var seats []models.SeatAssignment
+	if err := initializers.DB.Preload("Center").Preload("ExamRoom").
+		Where("exam_routine_id = ?", examRoutineID).
+		Find(&seats).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch seat plan: %w", err)
+	}
+	seatByStudent := make(map[uint]models.SeatAssignment, len(seats))
+	for _, seat := range seats {
+		seatByStudent[seat.StudentID] = seat
+	}
+
+	// Photos come from the user account linked through the symbol number
+	var users []models.User
+	if err := initializers.DB.
+		Where("batch_id = ? AND program_id = ?", routine.BatchID, routine.ProgramID).
+		Find(&users).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch users: %w", err)
+	}
+	photoBySymbol := make(map[string]string, len(users))
+	for _, user := range users {
+		photoBySymbol[user.SymbolNumber] = user.ImageURL
+	}
+
+	// A back paper card lists only the courses the student registered for
+	coursesByStudent := make(map[uint]map[uint]bool)
+	for _, registration := range registrations {
+		if coursesByStudent[registration.StudentID] == nil {
+			coursesByStudent[registration.StudentID] = make(map[uint]bool)
+		}
+		coursesByStudent[registration.StudentID][registration.CourseID] = true
+	}
+
+	var cards []AdmitCard
+	skipped := 0
+	for _, student := range students {
+		seat, ok := seatByStudent[student.ID]
+		if !ok {
+			skipped++
+			continue
+		}
+		studentSchedules := schedules
+		if courses, ok := coursesByStudent[student.ID]; ok {
+			studentSchedules = nil
+			for _, schedule := range schedules {
+				if courses[schedule.CourseID] {
+					studentSchedules = append(studentSchedules, schedule)
+				}
+			}
+		}
+		cards = append(cards, AdmitCard{
+			Student:   student,
+			Routine:   routine,
+			Schedules: studentSchedules,
+			Seat:      seat,
+			PhotoPath: photoBySymbol[student.SymbolNumber],
+			Code:      AdmitCardCode(routine.ID, student.SymbolNumber),
+		})
+	}
+
+	return cards, skipped, nil
+}
+
+// RenderAdmitCardsPDF renders one page per admit card
+func RenderAdmitCardsPDF(cards []AdmitCard) ([]byte, error) {
+	pdf := newDocumentPDF("ADMIT CARD")
+
+	for i, card := range cards {
+		pdf.AddPage()
+		top := pdf.GetY()
+
+		// Photo box on the right
+		pdf.Rect(160, top, 35, 40, "D")
+		if !addPhoto(pdf, card.PhotoPath, 160, top, 35, 40) {
+			pdf.SetFont("Helvetica", "", 8)
+			pdf.SetXY(160, top+18)
+			pdf.CellFormat(35, 4, "Affix photo", "", 0, "C", false, 0, "")
+		}
+
+		// Student details on the left
+		details := [][2]string{
+			{"Name", card.Student.Fullname},
+			{"Symbol Number", card.Student.SymbolNumber},
+			{"Registration Number", card.Student.RegistrationNumber},
+			{"College", card.Student.College.CollegeName},
+			{"Program", card.Routine.Program.ProgramName},
+			{"Batch / Semester", fmt.Sprintf("%d / %d", card.Routine.Batch.Batch, card.Routine.Semester.SemesterName)},
+			{"Exam Center", card.Seat.Center.CollegeName},
+			{"Center Address", card.Seat.Center.Address},
+			{"Room / Seat", fmt.Sprintf("%s / %d", card.Seat.ExamRoom.Name, card.Seat.SeatNumber)},
+		}
+		pdf.SetXY(15, top)
+		for _, detail := range details {
+			pdf.SetFont("Helvetica", "B", 10)
+			pdf.CellFormat(45, 7, detail[0], "", 0, "L", false, 0, "")
+			pdf.SetFont("Helvetica", "", 10)
+			pdf.CellFormat(95, 7, detail[1], "", 1, "L", false, 0, "")
+		}
+
+		// Exam schedule
+		pdf.Ln(6)
+		pdf.SetFont("Helvetica", "B", 10)
+		pdf.SetFillColor(230, 230, 230)
+		pdf.CellFormat(35, 8, "Course Code", "1", 0, "C", true, 0, "")
+		pdf.CellFormat(105, 8, "Course Name", "1", 0, "C", true, 0, "")
+		pdf.CellFormat(40, 8, "Exam Date", "1", 1, "C", true, 0, "")
+		pdf.SetFont("Helvetica", "", 10)
+		for _, schedule := range card.Schedules {
+			pdf.CellFormat(35, 7, schedule.Course.CourseCode, "1", 0, "C", false, 0, "")
+			pdf.CellFormat(105, 7, schedule.Course.Name, "1", 0, "L", false, 0, "")
+			pdf.CellFormat(40, 7, schedule.ExamDate.Format("2006-01-02 (Mon)"), "1", 1, "C", false, 0, "")
+		}
+
+		// Verification QR code and code
+		pdf.Ln(8)
+		qrTop := pdf.GetY()
+		if err := addQRCode(pdf, fmt.Sprintf("admit-qr-%d", i), AdmitCardVerifyURL(card.Routine.ID, card.Student.SymbolNumber), 15, qrTop, 35); err != nil {
+			return nil, err
+		}
+		pdf.SetXY(55, qrTop+10)
+		pdf.SetFont("Helvetica", "", 9)
+		pdf.MultiCell(140, 5, "Scan the QR code or visit the verification page to confirm this admit card.\nVerification code: "+card.Code, "", "L", false)
+
+		pdf.SetXY(140, qrTop+40)
+		pdf.CellFormat(55, 5, "Controller of Examinations", "T", 1, "C", false, 0, "")
+	}
+
+	return pdfBytes(pdf)
+}
+
+// admitCardHeartbeat is how often a running batch touches its row; a batch not touched for
+// admitCardBatchTimeout was lost with the process that ran it
+const (
+	admitCardHeartbeat    = time.Minute
+	admitCardBatchTimeout = 5 * time.Minute
+)
+
+// StartAdmitCardBatch records a new admit card job and renders the cards in the background
+func StartAdmitCardBatch(examRoutineID uint) (*models.AdmitCardBatch, error) {
+	var batch models.AdmitCardBatch
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		// Locking the routine keeps two requests from both starting a batch
+		var routine models.ExamRoutine
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&routine, examRoutineID).Error; err != nil {
+			return fiber.NewError(fiber.StatusNotFound, "Exam routine not found")
+		}
+
+		if err := failStaleAdmitCardBatches(tx.Where("exam_routine_id = ?", examRoutineID)); err != nil {
+			return err
+		}
+		var running int64
+		if err := tx.Model(&models.AdmitCardBatch{}).
+			Where("exam_routine_id = ? AND status = ?", examRoutineID, "Running").
+			Count(&running).Error; err != nil {
+			return fmt.Errorf("failed to check running admit card batches: %w", err)
+		}
+		if running > 0 {
+			return fiber.NewError(fiber.StatusConflict, "Admit cards are already being generated for the exam routine")
+		}
+
+		batch = models.AdmitCardBatch{ExamRoutineID: examRoutineID, Status: "Running"}
+		if err := tx.Create(&batch).Error; err != nil {
+			return fmt.Errorf("failed to create admit card batch: %w", err)
+		}
+		batch.OutputDir = filepath.Join("data", "admit_cards", fmt.Sprintf("routine_%d", examRoutineID), fmt.Sprintf("batch_%d", batch.ID))
+		if err := tx.Save(&batch).Error; err != nil {
+			return fmt.Errorf("failed to update admit card batch: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	go runAdmitCardBatch(batch)
+
+	return &batch, nil
+}
+
+// FailStaleAdmitCardBatches marks running batches whose heartbeat stopped as failed, so a job
+// lost in a crash or restart does not block the routine forever
+func FailStaleAdmitCardBatches() error {
+	return failStaleAdmitCardBatches(initializers.DB)
+}
+
+func failStaleAdmitCardBatches(query *gorm.DB) error {
+	now := time.Now()
+	if err := query.Model(&models.AdmitCardBatch{}).
+		Where("status = ? AND updated_at < ?", "Running", now.Add(-admitCardBatchTimeout)).
+		Updates(map[string]interface{}{
+			"status":      "Failed",
+			"error":       "The job stopped responding, it was probably interrupted by a restart",
+			"finished_at": now,
+		}).Error; err != nil {
+		return fmt.Errorf("failed to expire stale admit card batches: %w", err)
+	}
+	return nil
+}
+
+// AdmitCardZipPath is where the bundle of a college is written by a batch
+func AdmitCardZipPath(batch models.AdmitCardBatch, collegeID uint) string {
+	return filepath.Join(batch.OutputDir, fmt.Sprintf("college_%d.zip", collegeID))
+}
+
+func runAdmitCardBatch(batch models.AdmitCardBatch) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(admitCardHeartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := initializers.DB.Model(&models.AdmitCardBatch{}).Where("id = ?", batch.ID).
+					Update("updated_at", time.Now()).Error; err != nil {
+					log.Printf("Failed to record heartbeat of admit card batch %d: %v\n", batch.ID, err)
+				}
+			}
+		}
+	}()
+	generated, skipped, err := writeAdmitCardBundles(batch)
+	close(done)
+
+	now := time.Now()
+	batch.Generated = generated
+	batch.Skipped = skipped
+	batch.FinishedAt = &now
+	batch.Status = "Completed"
+	if err != nil {
+		log.Printf("Admit card batch %d failed: %v\n", batch.ID, err)
+		batch.Status = "Failed"
+		batch.Error = err.Error()
+	}
+
+	if err := initializers.DB.Save(&batch).Error; err != nil {
+		log.Printf("Failed to update admit card batch %d: %v\n", batch.ID, err)
+	}
+}
+
+func writeAdmitCardBundles(batch models.AdmitCardBatch) (int, int, error) {
+	cards, skipped, err := LoadAdmitCards(batch.ExamRoutineID, 0, "")
+	if err != nil {
+		return 0, 0, err
+	}
+
+	byCollege := make(map[uint][]AdmitCard)
+	for _, card := range cards {
+		byCollege[card.Student.CollegeID] = append(byCollege[card.Student.CollegeID], card)
+	}
+	var collegeIDs []uint
+	for collegeID := range byCollege {
+		collegeIDs = append(collegeIDs, collegeID)
+	}
+	sort.Slice(collegeIDs, func(i, j int) bool { return collegeIDs[i] < collegeIDs[j] })
+
+	generated := 0
+	for _, collegeID := range collegeIDs {
+		var entries []ZipEntry
+		for _, card := range byCollege[collegeID] {
+			content, err := RenderAdmitCardsPDF([]AdmitCard{card})
+			if err != nil {
+				return generated, skipped, fmt.Errorf("student %s: %w", card.Student.SymbolNumber, err)
+			}
+			entries = append(entries, ZipEntry{
+				Name:    SanitizeFileName(card.Student.SymbolNumber) + ".pdf",
+				Content: content,
+			})
+		}
+
+		if err := WriteZip(AdmitCardZipPath(batch, collegeID), entries); err != nil {
+			return generated, skipped, err
+		}
+		generated += len(entries)
+	}
+
+	return generated, skipped, nil
+}
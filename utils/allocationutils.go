@@ -0,0 +1,318 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mysterybee07/result-distribution-system/initializers"
+	"github.com/mysterybee07/result-distribution-system/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AllocationChange describes how the seats of one college→center pair differ between two versions
+type AllocationChange struct {
+	CollegeID   uint   `json:"college_id"`
+	CollegeName string `json:"college_name"`
+	CenterID    uint   `json:"center_id"`
+	CenterName  string `json:"center_name"`
+	FromSeats   int    `json:"from_seats"`
+	ToSeats     int    `json:"to_seats"`
+	Change      string `json:"change"` // added, removed or changed
+}
+
+// CreateCenterAllocation runs the solver and stores its result as the next draft version
+func CreateCenterAllocation(req models.CenterAllocationRequest) (*models.CenterAllocation, error) {
+	var examRoutine models.ExamRoutine
+	if err := initializers.DB.First(&examRoutine, req.ExamRoutineID).Error; err != nil {
+		return nil, fiber.NewError(fiber.StatusNotFound, "Exam routine not found")
+	}
+	if examRoutine.BatchID != req.BatchID || examRoutine.ProgramID != req.ProgramID {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "Exam routine does not belong to the given batch and program")
+	}
+
+	if req.RadiusKm <= 0 {
+		req.RadiusKm = CenterRadiusFromEnv()
+	}
+
+	solution, err := AssignRoutineCenters(examRoutine, CenterSolverOptions{RadiusKm: req.RadiusKm})
+	if err != nil {
+		return nil, err
+	}
+	if len(solution.Assignments) == 0 {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "No students could be assigned to any center")
+	}
+
+	allocation := models.CenterAllocation{
+		BatchID:            req.BatchID,
+		ProgramID:          req.ProgramID,
+		ExamRoutineID:      req.ExamRoutineID,
+		Status:             "Draft",
+		RadiusKm:           solution.RadiusKm,
+		TotalStudents:      solution.TotalStudents,
+		AssignedStudents:   solution.AssignedStudents,
+		UnassignedStudents: solution.UnassignedStudents,
+		TotalDistanceKm:    solution.TotalDistanceKm,
+		OptimalityGap:      solution.OptimalityGap,
+	}
+	for _, assignment := range solution.Assignments {
+		allocation.Items = append(allocation.Items, models.CenterAllocationItem{
+			CollegeID:    assignment.CollegeID,
+			CenterID:     assignment.CenterID,
+			AssignedSeat: assignment.AssignedSeat,
+			DistanceKm:   assignment.DistanceKm,
+		})
+	}
+
+	if err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		return saveNextAllocationVersion(tx, &allocation)
+	}); err != nil {
+		return nil, err
+	}
+	return &allocation, nil
+}
+
+// PublishCenterAllocation publishes a draft and archives the version that was published before it
+func PublishCenterAllocation(id uint) (*models.CenterAllocation, error) {
+	var allocation models.CenterAllocation
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&allocation, id).Error; err != nil {
+			return fiber.NewError(fiber.StatusNotFound, "Center allocation not found")
+		}
+		if err := lockAllocationRoutine(tx, allocation.ExamRoutineID); err != nil {
+			return err
+		}
+		// Read again under the lock, in case another request published it meanwhile
+		if err := tx.First(&allocation, id).Error; err != nil {
+			return fiber.NewError(fiber.StatusNotFound, "Center allocation not found")
+		}
+		if allocation.Status != "Draft" {
+			return fiber.NewError(fiber.StatusConflict, "Only draft allocations can be published")
+		}
+		return checkAndPublishAllocation(tx, &allocation)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &allocation, nil
+}
+
+// RollbackCenterAllocation copies an earlier version into a new version and publishes it in the
+// same transaction, so a failure leaves no unpublished copy behind
+func RollbackCenterAllocation(id uint) (*models.CenterAllocation, error) {
+	var rollback models.CenterAllocation
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		var target models.CenterAllocation
+		if err := tx.First(&target, id).Error; err != nil {
+			return fiber.NewError(fiber.StatusNotFound, "Center allocation not found")
+		}
+		if err := lockAllocationRoutine(tx, target.ExamRoutineID); err != nil {
+			return err
+		}
+		if err := tx.Preload("Items").First(&target, id).Error; err != nil {
+			return fiber.NewError(fiber.StatusNotFound, "Center allocation not found")
+		}
+		if target.Status == "Published" {
+			return fiber.NewError(fiber.StatusConflict, "Allocation is already the published version")
+		}
+
+		rollback = rollbackCopy(target)
+		if err := saveNextAllocationVersion(tx, &rollback); err != nil {
+			return err
+		}
+		return checkAndPublishAllocation(tx, &rollback)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &rollback, nil
+}
+
+// rollbackCopy is a new draft with the seats of an earlier version
+func rollbackCopy(target models.CenterAllocation) models.CenterAllocation {
+	rollback := models.CenterAllocation{
+		BatchID:            target.BatchID,
+		ProgramID:          target.ProgramID,
+		ExamRoutineID:      target.ExamRoutineID,
+		Status:             "Draft",
+		RadiusKm:           target.RadiusKm,
+		TotalStudents:      target.TotalStudents,
+		AssignedStudents:   target.AssignedStudents,
+		UnassignedStudents: target.UnassignedStudents,
+		TotalDistanceKm:    target.TotalDistanceKm,
+		OptimalityGap:      target.OptimalityGap,
+		RolledBackFromID:   &target.ID,
+	}
+	for _, item := range target.Items {
+		rollback.Items = append(rollback.Items, models.CenterAllocationItem{
+			CollegeID:    item.CollegeID,
+			CenterID:     item.CenterID,
+			AssignedSeat: item.AssignedSeat,
+			DistanceKm:   item.DistanceKm,
+		})
+	}
+
+	return rollback
+}
+
+// DiffCenterAllocations lists the college→center pairs whose seats differ between two versions
+func DiffCenterAllocations(fromID, toID uint) ([]AllocationChange, error) {
+	var from, to models.CenterAllocation
+	if err := initializers.DB.Preload("Items.College").Preload("Items.Center").First(&from, fromID).Error; err != nil {
+		return nil, fiber.NewError(fiber.StatusNotFound, "Center allocation to compare from not found")
+	}
+	if err := initializers.DB.Preload("Items.College").Preload("Items.Center").First(&to, toID).Error; err != nil {
+		return nil, fiber.NewError(fiber.StatusNotFound, "Center allocation to compare to not found")
+	}
+	if from.BatchID != to.BatchID || from.ProgramID != to.ProgramID || from.ExamRoutineID != to.ExamRoutineID {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "Allocations belong to different exam routines")
+	}
+
+	changes := make(map[[2]uint]*AllocationChange)
+	for _, item := range from.Items {
+		changes[[2]uint{item.CollegeID, item.CenterID}] = &AllocationChange{
+			CollegeID:   item.CollegeID,
+			CollegeName: item.College.CollegeName,
+			CenterID:    item.CenterID,
+			CenterName:  item.Center.CollegeName,
+			FromSeats:   item.AssignedSeat,
+		}
+	}
+	for _, item := range to.Items {
+		key := [2]uint{item.CollegeID, item.CenterID}
+		if _, ok := changes[key]; !ok {
+			changes[key] = &AllocationChange{
+				CollegeID:   item.CollegeID,
+				CollegeName: item.College.CollegeName,
+				CenterID:    item.CenterID,
+				CenterName:  item.Center.CollegeName,
+			}
+		}
+		changes[key].ToSeats = item.AssignedSeat
+	}
+
+	var diff []AllocationChange
+	for _, change := range changes {
+		switch {
+		case change.FromSeats == change.ToSeats:
+			continue
+		case change.FromSeats == 0:
+			change.Change = "added"
+		case change.ToSeats == 0:
+			change.Change = "removed"
+		default:
+			change.Change = "changed"
+		}
+		diff = append(diff, *change)
+	}
+
+	sort.Slice(diff, func(i, j int) bool {
+		if diff[i].CollegeID != diff[j].CollegeID {
+			return diff[i].CollegeID < diff[j].CollegeID
+		}
+		return diff[i].CenterID < diff[j].CenterID
+	})
+	return diff, nil
+}
+
+// GetPublishedCenterAllocation returns the allocation currently in use for an exam routine
+func GetPublishedCenterAllocation(examRoutineID uint) (*models.CenterAllocation, error) {
+	var allocation models.CenterAllocation
+	if err := initializers.DB.Preload("Items.College").Preload("Items.Center").
+		Where("exam_routine_id = ? AND status = ?", examRoutineID, "Published").
+		First(&allocation).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fiber.NewError(fiber.StatusNotFound, "No published center allocation for the exam routine")
+		}
+		return nil, fmt.Errorf("failed to fetch center allocation: %w", err)
+	}
+	return &allocation, nil
+}
+
+// lockAllocationRoutine locks the exam routine row, which every change to the versions of its
+// allocation takes first, so numbering and publishing happen one request at a time
+func lockAllocationRoutine(tx *gorm.DB, examRoutineID uint) error {
+	var routine models.ExamRoutine
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&routine, examRoutineID).Error; err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "Exam routine not found")
+	}
+	return nil
+}
+
+// saveNextAllocationVersion numbers the allocation after the latest version of the same routine
+func saveNextAllocationVersion(tx *gorm.DB, allocation *models.CenterAllocation) error {
+	if err := lockAllocationRoutine(tx, allocation.ExamRoutineID); err != nil {
+		return err
+	}
+
+	var latest int
+	if err := tx.Model(&models.CenterAllocation{}).
+		Where("exam_routine_id = ?", allocation.ExamRoutineID).
+		Select("COALESCE(MAX(version), 0)").
+		Scan(&latest).Error; err != nil {
+		return fmt.Errorf("failed to fetch latest allocation version: %w", err)
+	}
+
+	allocation.Version = latest + 1
+	if err := tx.Create(allocation).Error; err != nil {
+		if isDuplicateKey(err) {
+			return fiber.NewError(fiber.StatusConflict, "Another version of the allocation was saved at the same time, try again")
+		}
+		return fmt.Errorf("failed to save center allocation: %w", err)
+	}
+	return nil
+}
+
+// checkAndPublishAllocation checks the allocation against the other routines and publishes it
+func checkAndPublishAllocation(tx *gorm.DB, allocation *models.CenterAllocation) error {
+	if err := checkAllocationClashes(tx, *allocation); err != nil {
+		return err
+	}
+	return publishAllocation(tx, allocation)
+}
+
+func publishAllocation(tx *gorm.DB, allocation *models.CenterAllocation) error {
+	if err := tx.Model(&models.CenterAllocation{}).
+		Where("exam_routine_id = ? AND status = ? AND id <> ?", allocation.ExamRoutineID, "Published", allocation.ID).
+		Update("status", "Archived").Error; err != nil {
+		return fmt.Errorf("failed to archive previous allocation: %w", err)
+	}
+
+	now := time.Now()
+	allocation.Status = "Published"
+	allocation.PublishedAt = &now
+	if err := tx.Save(allocation).Error; err != nil {
+		return fmt.Errorf("failed to publish center allocation: %w", err)
+	}
+	return nil
+}
+
+// checkAllocationClashes makes sure the seats of an allocation fit the centers on the exam days
+// of its routine next to every other routine
+func checkAllocationClashes(tx *gorm.DB, allocation models.CenterAllocation) error {
+	var items []models.CenterAllocationItem
+	if err := tx.Where("center_allocation_id = ?", allocation.ID).Find(&items).Error; err != nil {
+		return fmt.Errorf("failed to fetch allocation items: %w", err)
+	}
+	var schedules []models.ExamSchedules
+	if err := tx.Where("exam_routine_id = ?", allocation.ExamRoutineID).Find(&schedules).Error; err != nil {
+		return fmt.Errorf("failed to fetch exam schedules: %w", err)
+	}
+
+	candidate := ClashCandidate{
+		ExamRoutineID: allocation.ExamRoutineID,
+		BatchID:       allocation.BatchID,
+		ProgramID:     allocation.ProgramID,
+		Seats:         make(map[uint]int),
+	}
+	for _, item := range items {
+		candidate.Seats[item.CenterID] += item.AssignedSeat
+	}
+	for _, schedule := range schedules {
+		candidate.Dates = append(candidate.Dates, schedule.ExamDate)
+	}
+	return CheckCenterClashes(candidate)
+}
@@ -0,0 +1,220 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mysterybee07/result-distribution-system/initializers"
+	"github.com/mysterybee07/result-distribution-system/models"
+	"gorm.io/gorm"
+)
+
+// ResultPublished reports whether the result of a semester is out for a batch and program
+func ResultPublished(batchID, programID, semesterID uint) (bool, error) {
+	var count int64
+	if err := initializers.DB.Model(&models.Result{}).
+		Where("batch_id = ? AND program_id = ? AND semester_id = ? AND status = ?", batchID, programID, semesterID, "Published").
+		Count(&count).Error; err != nil {
+		return false, fmt.Errorf("failed to fetch result: %w", err)
+	}
+	return count > 0, nil
+}
+
+// MarkFrozen reports whether a mark is part of what a published result showed. Back paper marks
+// recorded after the last publication stay editable until they are applied.
+func MarkFrozen(mark models.Mark) (bool, error) {
+	return markFrozen(initializers.DB, mark)
+}
+
+func markFrozen(db *gorm.DB, mark models.Mark) (bool, error) {
+	published, cutoff, err := publicationCutoff(db, mark.BatchID, mark.ProgramID, mark.SemesterID)
+	if err != nil || !published {
+		return false, err
+	}
+	return !mark.CreatedAt.After(cutoff), nil
+}
+
+// publicationCutoff returns whether a semester result is published and the time of its latest
+// publication; marks created up to then are frozen
+func publicationCutoff(db *gorm.DB, batchID, programID, semesterID uint) (bool, time.Time, error) {
+	var result models.Result
+	err := db.Where("batch_id = ? AND program_id = ? AND semester_id = ? AND status = ?", batchID, programID, semesterID, "Published").
+		First(&result).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, time.Time{}, nil
+	} else if err != nil {
+		return false, time.Time{}, fmt.Errorf("failed to fetch result: %w", err)
+	}
+
+	var snapshot models.ResultSnapshot
+	err = db.Where("result_id = ? AND action <> ?", result.ID, "withdrawn").Order("version desc").First(&snapshot).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// Published before snapshots were kept, so every mark is frozen
+		return true, time.Now(), nil
+	} else if err != nil {
+		return false, time.Time{}, fmt.Errorf("failed to fetch result snapshot: %w", err)
+	}
+	return true, snapshot.CreatedAt, nil
+}
+
+// AmendMarks corrects marks of a published result. The marks change, each change is recorded
+// with the reason, students whose standing changes are promoted or held back again, and the
+// result gets a new revision and snapshot, all in one transaction.
+func AmendMarks(req models.MarkAmendmentRequest, amendedBy string) (*models.ResultSnapshot, []models.MarkAmendment, error) {
+	var course models.Course
+	if err := initializers.DB.Where("id = ? AND program_id = ? AND semester_id = ?", req.CourseID, req.ProgramID, req.SemesterID).
+		First(&course).Error; err != nil {
+		return nil, nil, fiber.NewError(fiber.StatusNotFound, "Course not found for the given batch, program and semester")
+	}
+
+	var snapshot *models.ResultSnapshot
+	var amendments []models.MarkAmendment
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		snapshot, amendments, err = amendMarks(tx, course, req, amendedBy)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	refreshPublicResults(req.BatchID, req.ProgramID)
+	return snapshot, amendments, nil
+}
+
+// amendMarks corrects marks of a published result inside tx, for callers that record more
+// alongside the amendment
+func amendMarks(tx *gorm.DB, course models.Course, req models.MarkAmendmentRequest, amendedBy string) (*models.ResultSnapshot, []models.MarkAmendment, error) {
+	var result models.Result
+	if err := tx.Where("batch_id = ? AND program_id = ? AND semester_id = ? AND status = ?", req.BatchID, req.ProgramID, req.SemesterID, "Published").
+		First(&result).Error; err != nil {
+		return nil, nil, fiber.NewError(fiber.StatusBadRequest, "The result is not published; update the marks directly")
+	}
+
+	published, err := latestPublication(tx, result.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var amendments []models.MarkAmendment
+	for _, entry := range req.Marks {
+		if entry.SemesterMarks < 0 || entry.AssistantMarks < 0 || entry.PracticalMarks < 0 {
+			return nil, nil, fiber.NewError(fiber.StatusBadRequest, "Obtained marks cannot be negative")
+		}
+		if entry.SemesterMarks > course.SemesterTotalMarks ||
+			(course.PracticalTotalMarks != nil && entry.PracticalMarks > *course.PracticalTotalMarks) ||
+			(course.AssistantTotalMarks != nil && entry.AssistantMarks > *course.AssistantTotalMarks) {
+			return nil, nil, fiber.NewError(fiber.StatusBadRequest, "Obtained marks cannot exceed total marks")
+		}
+
+		// Corrections apply to the latest attempt of the course
+		var mark models.Mark
+		if err := tx.Where("batch_id = ? AND program_id = ? AND semester_id = ? AND course_id = ? AND student_id = ?",
+			req.BatchID, req.ProgramID, req.SemesterID, req.CourseID, entry.StudentID).
+			Order("attempt desc").First(&mark).Error; err != nil {
+			return nil, nil, fiber.NewError(fiber.StatusNotFound, fmt.Sprintf("Mark entry not found for student %d", entry.StudentID))
+		}
+
+		amendment := models.MarkAmendment{
+			ResultID:          result.ID,
+			MarkID:            mark.ID,
+			StudentID:         mark.StudentID,
+			CourseID:          mark.CourseID,
+			Reason:            req.Reason,
+			AmendedBy:         amendedBy,
+			OldSemesterMarks:  mark.SemesterMarks,
+			OldAssistantMarks: mark.AssistantMarks,
+			OldPracticalMarks: mark.PracticalMarks,
+			OldStatus:         mark.Status,
+		}
+
+		mark.SemesterMarks = entry.SemesterMarks
+		mark.AssistantMarks = entry.AssistantMarks
+		mark.PracticalMarks = entry.PracticalMarks
+		if err := tx.Save(&mark).Error; err != nil {
+			return nil, nil, fmt.Errorf("failed to update mark: %w", err)
+		}
+
+		amendment.NewSemesterMarks = mark.SemesterMarks
+		amendment.NewAssistantMarks = mark.AssistantMarks
+		amendment.NewPracticalMarks = mark.PracticalMarks
+		amendment.NewStatus = mark.Status
+		amendments = append(amendments, amendment)
+	}
+
+	now := time.Now()
+	result.Revision++
+	result.RepublishedAt = &now
+	if err := tx.Save(&result).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to revise result: %w", err)
+	}
+
+	// The amendment keeps the promotions of the publication it corrects, except for the students
+	// whose standing the new marks change
+	students := carriedPromotions(published)
+	amended := make(map[uint]bool)
+	for _, amendment := range amendments {
+		amended[amendment.StudentID] = true
+	}
+	if err := revisePromotions(tx, result, students, amended); err != nil {
+		return nil, nil, err
+	}
+	snapshot, err := takeResultSnapshot(tx, &result, "amended", req.Reason, students)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for i := range amendments {
+		amendments[i].ResultSnapshotID = snapshot.ID
+	}
+	if err := tx.Create(&amendments).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to record amendments: %w", err)
+	}
+	return snapshot, amendments, nil
+}
+
+// revisePromotions decides the promotion of the given students again from their counted marks,
+// by the rule publishing uses, and moves those whose action changed. A student whose semester or
+// status changed since the publication is left alone and keeps the earlier action.
+func revisePromotions(tx *gorm.DB, result models.Result, students []models.ResultSnapshotStudent, only map[uint]bool) error {
+	var program models.Program
+	if err := tx.First(&program, result.ProgramID).Error; err != nil {
+		return fmt.Errorf("failed to fetch program: %w", err)
+	}
+	if !PromotionRules[program.PromotionRule] {
+		program.PromotionRule = "carry"
+	}
+	standings, _, err := cohortStandings(tx, program, result.BatchID, result.SemesterID)
+	if err != nil {
+		return err
+	}
+
+	for i, student := range students {
+		if !only[student.StudentID] {
+			continue
+		}
+		passed, outstanding := true, false
+		if standing, ok := standings[student.StudentID]; ok {
+			passed = len(standing.failed) == 0
+			outstanding = len(standing.outstanding) > 0
+		}
+		action := promotionAction(passed, student.PreviousSemester, outstanding, program.PromotionRule)
+		if action == student.Action {
+			continue
+		}
+
+		before, after := promotedStudent(student, student.Action), promotedStudent(student, action)
+		res := tx.Model(&models.Student{}).
+			Where("id = ? AND current_semester = ? AND status = ?", student.StudentID, before.CurrentSemester, before.Status).
+			Select("current_semester", "status").
+			Updates(after)
+		if res.Error != nil {
+			return fmt.Errorf("failed to revise the promotion of student %d: %w", student.StudentID, res.Error)
+		}
+		if res.RowsAffected > 0 || (before.CurrentSemester == after.CurrentSemester && before.Status == after.Status) {
+			students[i].Action = action
+		}
+	}
+	return nil
+}
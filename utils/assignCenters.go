@@ -5,11 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"log"
-	"math/rand"
 	"os"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/mysterybee07/result-distribution-system/initializers"
 	"github.com/mysterybee07/result-distribution-system/models"
@@ -17,10 +15,13 @@ import (
 )
 
 type CenterAssignment struct {
+	CollegeID     uint
+	CenterID      uint
 	CollegeName   string
 	CenterName    string
 	AssignedSeat  int
 	RemainingSeat int
+	DistanceKm    float64
 }
 
 // ParseColleges reads a TSV file and returns a slice of College structs
@@ -143,183 +144,3 @@ func ProcessRecord(collegeName string, batchID, programID uint, isCenter bool, c
 
 	return nil
 }
-
-func AssignCenters(batchID uint, programID uint) ([]CenterAssignment, error) {
-	// Fetch CapacityAndCount entries filtered by batch and program
-	var capacityAndCounts []models.CapacityAndCount
-	if err := initializers.DB.Preload("College").
-		Where("batch_id = ? AND program_id = ?", batchID, programID).
-		Find(&capacityAndCounts).Error; err != nil {
-		return nil, fmt.Errorf("failed to fetch capacity and count data: %w", err)
-	}
-
-	// Identify centers and load their capacity from CapacityAndCount
-	var centers []models.CapacityAndCount
-	if err := initializers.DB.Preload("College").
-		Where("capacity > 0 AND batch_id = ? AND program_id = ?", batchID, programID).
-		Find(&centers).Error; err != nil {
-		return nil, fmt.Errorf("failed to fetch centers: %w", err)
-	}
-
-	// Prepare to store assignments and track remaining capacities
-	var assignments []CenterAssignment
-	remainingCapacities := make(map[uint]int) // key is CollegeID, value is remaining capacity
-
-	// Initialize remaining capacities of each center
-	for _, center := range centers {
-		remainingCapacities[center.CollegeID] = center.Capacity
-	}
-
-	rand.Seed(time.Now().UnixNano())
-
-	// Assign students from each filtered college to available centers
-	for _, capCount := range capacityAndCounts {
-		college := capCount.College
-		remainingStudents := capCount.StudentsCount
-		totalAssigned := 0
-
-		// Assign students to centers
-		for remainingStudents > 0 {
-			// Filter available centers within 50km, excluding self-assignment and circular assignment
-			availableCenters := filterCentersWithCyclePrevention(centers, capCount, assignments, remainingCapacities)
-			if len(availableCenters) == 0 {
-				fmt.Printf("Warning: No available centers found for %s\n", college.CollegeName)
-				break
-			}
-
-			// Pick a center using weighted randomness
-			center := weightedRandom(availableCenters, remainingCapacities)
-			if center == nil {
-				break
-			}
-
-			// Determine how many students to assign
-			assignCount := min(remainingStudents, remainingCapacities[center.CollegeID])
-			if assignCount > 0 {
-				assignments = append(assignments, CenterAssignment{
-					CollegeName:   college.CollegeName,
-					CenterName:    center.College.CollegeName,
-					AssignedSeat:  assignCount,
-					RemainingSeat: remainingCapacities[center.CollegeID] - assignCount,
-				})
-
-				remainingStudents -= assignCount
-				totalAssigned += assignCount
-				remainingCapacities[center.CollegeID] -= assignCount
-			}
-		}
-
-		// Log unassigned students if any remain
-		if remainingStudents > 0 {
-			fmt.Printf("Warning: %s still has unassigned students: %d\n", college.CollegeName, remainingStudents)
-		}
-	}
-
-	return assignments, nil
-}
-
-func weightedRandom(centers []models.CapacityAndCount, capacities map[uint]int) *models.CapacityAndCount {
-	// Calculate total remaining capacity of available centers
-	totalCapacity := 0
-	for _, center := range centers {
-		totalCapacity += capacities[center.CollegeID]
-	}
-
-	if totalCapacity == 0 {
-		return nil // No available capacity
-	}
-
-	// Generate a random number within the total capacity
-	randValue := rand.Intn(totalCapacity)
-	runningSum := 0
-
-	// Select a center based on the random number
-	for _, center := range centers {
-		runningSum += capacities[center.CollegeID]
-		if randValue < runningSum {
-			return &center
-		}
-	}
-	return nil
-}
-
-func filterCentersWithCyclePrevention(
-	centers []models.CapacityAndCount,
-	capCount models.CapacityAndCount,
-	assignments []CenterAssignment,
-	remainingCapacities map[uint]int,
-) []models.CapacityAndCount {
-	var availableCenters []models.CapacityAndCount
-
-	for _, center := range centers {
-		// Prevent self-assignment
-		if capCount.CollegeID == center.CollegeID {
-			continue
-		}
-
-		// Skip centers with no remaining capacity
-		if remainingCapacities[center.CollegeID] <= 0 {
-			continue
-		}
-
-		// Prevent circular assignments
-		if hasAssignment(assignments, center.College.CollegeName, capCount.College.CollegeName) ||
-			hasAssignment(assignments, capCount.College.CollegeName, center.College.CollegeName) {
-			continue
-		}
-
-		// Check distance (within 50km)
-		distance := Haversine(capCount.College.Latitude, capCount.College.Longitude, center.College.Latitude, center.College.Longitude)
-		if distance > 50 {
-			continue
-		}
-
-		availableCenters = append(availableCenters, center)
-	}
-
-	return availableCenters
-}
-
-func hasAssignment(assignments []CenterAssignment, centerName, collegeName string) bool {
-	for _, assignment := range assignments {
-		if assignment.CenterName == centerName && assignment.CollegeName == collegeName {
-			return true
-		}
-	}
-	return false
-}
-
-func WriteResultToFile(assignments []CenterAssignment) error {
-	// Ensure the 'data' folder exists, create it if not
-	if err := os.MkdirAll("data", os.ModePerm); err != nil {
-		return fmt.Errorf("failed to create data directory: %w", err)
-	}
-
-	// Create the CSV file inside the 'data' folder
-	file, err := os.Create("data/center_assignment_results.csv")
-	if err != nil {
-		return fmt.Errorf("failed to create result file: %w", err)
-	}
-	defer file.Close()
-
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
-
-	// Write the header
-	if err := writer.Write([]string{"College Name", "Assigned Center", "Assigned Students"}); err != nil {
-		return fmt.Errorf("failed to write header to CSV: %w", err)
-	}
-
-	// Write each assignment
-	for _, assignment := range assignments {
-		if err := writer.Write([]string{
-			assignment.CollegeName,
-			assignment.CenterName,
-			strconv.Itoa(assignment.AssignedSeat),
-		}); err != nil {
-			return fmt.Errorf("failed to write assignment to file: %w", err)
-		}
-	}
-
-	return nil
-}
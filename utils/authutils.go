@@ -1,10 +1,16 @@
 package utils
 
 import (
+	"crypto/rand"
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"log"
+	"os"
 	"regexp"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
@@ -12,18 +18,99 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
-var JwtSecret = []byte("Ajfdslfjlsdfjldslfj")
+const developmentKid = "development"
 
-func GenerateJwt(userID uint, role string, c *fiber.Ctx) (string, error) {
-	expirationTime := time.Now().Add(time.Hour * 24) // Set token expiration time
+// signingKeys holds the keys access tokens are checked with, by kid, and the one new tokens
+// are signed with
+var signingKeys struct {
+	sync.Once
+	keys   map[string][]byte
+	active string
+}
+
+// LoadSigningKeys reads JWT_SIGNING_KEYS, a comma separated list of kid:secret pairs, and
+// JWT_SIGNING_KID, the kid new tokens are signed with; the first key signs when it is not set.
+// To rotate, add the new key, point JWT_SIGNING_KID at it and drop the old key once the
+// tokens signed with it have expired. Missing or bad configuration stops the server; only
+// development mode signs with a random key kept in .dev-keys.
+func LoadSigningKeys() {
+	signingKeys.Do(func() {
+		signingKeys.keys = make(map[string][]byte)
+		config := strings.TrimSpace(os.Getenv("JWT_SIGNING_KEYS"))
+		if config == "" {
+			if !DevelopmentMode() {
+				log.Fatalf("JWT_SIGNING_KEYS is not set; configure kid:secret pairs with secrets of at least 32 characters")
+			}
+			key, err := developmentKey("jwt", 32)
+			if err != nil {
+				log.Fatalf("Failed to load the development JWT signing key: %v", err)
+			}
+			log.Println("JWT_SIGNING_KEYS is not set, access tokens are signed with a development key")
+			signingKeys.keys[developmentKid] = key
+			signingKeys.active = developmentKid
+			return
+		}
+
+		for _, pair := range strings.Split(config, ",") {
+			kid, secret, ok := strings.Cut(strings.TrimSpace(pair), ":")
+			if !ok || kid == "" {
+				log.Fatalf("JWT_SIGNING_KEYS entries must be kid:secret")
+			}
+			if len(secret) < 32 {
+				log.Fatalf("The JWT signing key %s must be at least 32 characters long", kid)
+			}
+			if _, exists := signingKeys.keys[kid]; exists {
+				log.Fatalf("The JWT signing key %s is listed twice", kid)
+			}
+			signingKeys.keys[kid] = []byte(secret)
+			if signingKeys.active == "" {
+				signingKeys.active = kid
+			}
+		}
+		if kid := os.Getenv("JWT_SIGNING_KID"); kid != "" {
+			if _, ok := signingKeys.keys[kid]; !ok {
+				log.Fatalf("JWT_SIGNING_KID %s is not in JWT_SIGNING_KEYS", kid)
+			}
+			signingKeys.active = kid
+		}
+	})
+}
+
+// AccessTokenTTL is how long an access token is valid, ACCESS_TOKEN_TTL minutes or 15
+func AccessTokenTTL() time.Duration {
+	if minutes, err := strconv.Atoi(os.Getenv("ACCESS_TOKEN_TTL")); err == nil && minutes > 0 {
+		return time.Duration(minutes) * time.Minute
+	}
+	return 15 * time.Minute
+}
+
+// AccessClaims is who an access token was issued to and for which session
+type AccessClaims struct {
+	UserID    string
+	Role      string
+	SessionID uint
+}
+
+// GenerateJwt issues a short-lived access token for a session and sets it as the jwt cookie
+func GenerateJwt(userID uint, role string, sessionID uint, c *fiber.Ctx) (string, error) {
+	LoadSigningKeys()
+	jti, err := randomToken(16)
+	if err != nil {
+		return "", err
+	}
+	expirationTime := time.Now().Add(AccessTokenTTL()) // Set token expiration time
 	claims := jwt.MapClaims{
 		"userID": strconv.Itoa(int(userID)),
 		"role":   role,
+		"sid":    sessionID,
+		"jti":    jti,
+		"iat":    time.Now().Unix(),
 		"exp":    expirationTime.Unix(),
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = signingKeys.active
 
-	tokenString, err := token.SignedString(JwtSecret) // Use the correct signing key (HS256)
+	tokenString, err := token.SignedString(signingKeys.keys[signingKeys.active])
 	if err != nil {
 		return "", fmt.Errorf("failed to sign token: %w", err)
 	}
@@ -32,7 +119,7 @@ func GenerateJwt(userID uint, role string, c *fiber.Ctx) (string, error) {
 	c.Cookie(&fiber.Cookie{
 		Name:     "jwt",
 		Value:    tokenString,
-		Expires:  time.Now().Add(time.Hour * 24),
+		Expires:  expirationTime,
 		HTTPOnly: true,
 		Secure:   false,
 		SameSite: "None",
@@ -41,31 +128,55 @@ func GenerateJwt(userID uint, role string, c *fiber.Ctx) (string, error) {
 	return tokenString, nil
 }
 
-func ParseJwt(tokenStr string) (string, string, error) {
+// ParseJwt checks an access token against the key named by its kid
+func ParseJwt(tokenStr string) (*AccessClaims, error) {
+	LoadSigningKeys()
 	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-		return JwtSecret, nil
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+		kid, _ := token.Header["kid"].(string)
+		key, ok := signingKeys.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		return key, nil
 	})
 
 	if err != nil || !token.Valid {
-		return "", "", err
+		return nil, err
 	}
 
 	claims, ok := token.Claims.(jwt.MapClaims)
 	if !ok || !token.Valid {
-		return "", "", err
+		return nil, errors.New("invalid token claims")
 	}
 
 	userID, ok := claims["userID"].(string)
 	if !ok {
-		return "", "", errors.New("invalid token claims: userID not found")
+		return nil, errors.New("invalid token claims: userID not found")
 	}
 
 	role, ok := claims["role"].(string)
 	if !ok {
-		return "", "", errors.New("invalid token claims: role not found")
+		return nil, errors.New("invalid token claims: role not found")
 	}
 
-	return userID, role, nil
+	sessionID, ok := claims["sid"].(float64)
+	if !ok || sessionID <= 0 {
+		return nil, errors.New("invalid token claims: sid not found")
+	}
+
+	return &AccessClaims{UserID: userID, Role: role, SessionID: uint(sessionID)}, nil
+}
+
+// randomToken returns n random bytes, URL safe base64 encoded
+func randomToken(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
 }
 
 func ValidateEmail(email string) bool {
@@ -80,6 +191,15 @@ func HashPassword(password string) (string, error) {
 	return string(bytes), err
 }
 
+// missingUserHash is compared against when no account matches a login
+var missingUserHash, _ = bcrypt.GenerateFromPassword([]byte("no account matches"), bcrypt.DefaultCost)
+
+// CheckPasswordOfMissingUser spends the time of a password check, so a login for an account
+// that does not exist takes as long as one with a wrong password
+func CheckPasswordOfMissingUser(password string) {
+	bcrypt.CompareHashAndPassword(missingUserHash, []byte(password))
+}
+
 // CheckPasswordHash compares a plain text password with a hashed password
 func CheckPasswordHash(password, hash string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
@@ -0,0 +1,490 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mysterybee07/result-distribution-system/initializers"
+	"github.com/mysterybee07/result-distribution-system/models"
+	"gorm.io/gorm"
+)
+
+// BackPaperRules are the ways a program can pick the attempt that counts for a course
+var BackPaperRules = map[string]bool{"latest": true, "best": true}
+
+// PromotionRules are what publishing a result does to a student who failed a course: carry moves
+// them up with back papers registered for the failed courses, hold keeps them in the semester
+var PromotionRules = map[string]bool{"carry": true, "hold": true}
+
+// BackPaperOutcome is the counted result of one student after a back paper was marked
+type BackPaperOutcome struct {
+	StudentID    uint   `json:"student_id"`
+	SymbolNumber string `json:"symbol_number"`
+	Fullname     string `json:"fullname"`
+	Status       string `json:"status"`           // pass or fail over every counted course of the semester
+	Action       string `json:"action,omitempty"` // Promotion the revision applied to a student who was held back
+}
+
+// CountedMarks keeps one mark per student and course: the attempt the program's back paper rule
+// counts. Marks of a single attempt pass through unchanged.
+func CountedMarks(marks []models.Mark) ([]models.Mark, error) {
+	rules := make(map[uint]string)
+	for _, mark := range marks {
+		rules[mark.ProgramID] = "latest"
+	}
+	if len(rules) > 0 {
+		var programIDs []uint
+		for programID := range rules {
+			programIDs = append(programIDs, programID)
+		}
+		var programs []models.Program
+		if err := initializers.DB.Where("id IN ?", programIDs).Find(&programs).Error; err != nil {
+			return nil, fmt.Errorf("failed to fetch programs: %w", err)
+		}
+		for _, program := range programs {
+			if BackPaperRules[program.BackPaperRule] {
+				rules[program.ID] = program.BackPaperRule
+			}
+		}
+	}
+	return countedMarks(marks, rules), nil
+}
+
+func countedMarks(marks []models.Mark, rules map[uint]string) []models.Mark {
+	type attemptKey struct{ studentID, courseID uint }
+	chosen := make(map[attemptKey]int)
+	var order []attemptKey
+
+	for i, mark := range marks {
+		key := attemptKey{mark.StudentID, mark.CourseID}
+		current, ok := chosen[key]
+		if !ok {
+			chosen[key] = i
+			order = append(order, key)
+			continue
+		}
+		if countsOver(mark, marks[current], rules[mark.ProgramID]) {
+			chosen[key] = i
+		}
+	}
+
+	counted := make([]models.Mark, 0, len(order))
+	for _, key := range order {
+		counted = append(counted, marks[chosen[key]])
+	}
+	return counted
+}
+
+// countsOver reports whether candidate replaces current under the rule
+func countsOver(candidate, current models.Mark, rule string) bool {
+	if rule == "best" {
+		if (candidate.Status == "pass") != (current.Status == "pass") {
+			return candidate.Status == "pass"
+		}
+		if candidate.TotalMarks != current.TotalMarks {
+			return candidate.TotalMarks > current.TotalMarks
+		}
+	}
+	return candidate.Attempt > current.Attempt
+}
+
+// RegisterBackPapers enrols the students whose counted mark in a course is a fail. Students that
+// already hold an open registration or have used up their attempts are skipped with a reason.
+func RegisterBackPapers(req models.BackPaperRegistrationRequest) ([]models.BackPaperRegistration, []string, error) {
+	var program models.Program
+	if err := initializers.DB.First(&program, req.ProgramID).Error; err != nil {
+		return nil, nil, fiber.NewError(fiber.StatusNotFound, "Program not found")
+	}
+	if err := initializers.DB.First(&models.Batch{}, req.BatchID).Error; err != nil {
+		return nil, nil, fiber.NewError(fiber.StatusNotFound, "Batch not found")
+	}
+	if err := initializers.DB.First(&models.Semester{}, req.SemesterID).Error; err != nil {
+		return nil, nil, fiber.NewError(fiber.StatusNotFound, "Semester not found")
+	}
+
+	query := initializers.DB.Preload("Student").Preload("Course").
+		Where("batch_id = ? AND program_id = ? AND semester_id = ?", req.BatchID, req.ProgramID, req.SemesterID).
+		Order("student_id, course_id, attempt")
+	if len(req.CourseIDs) > 0 {
+		query = query.Where("course_id IN ?", req.CourseIDs)
+	}
+	if len(req.StudentIDs) > 0 {
+		query = query.Where("student_id IN ?", req.StudentIDs)
+	}
+
+	var marks []models.Mark
+	if err := query.Find(&marks).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch marks: %w", err)
+	}
+
+	var registrations []models.BackPaperRegistration
+	var skipped []string
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		registrations, skipped, err = registerFailedCourses(tx, program, req.BatchID, req.SemesterID, marks)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return registrations, skipped, nil
+}
+
+// registerFailedCourses registers a back paper for every counted fail among the marks, which
+// hold every attempt of their courses and have Student and Course loaded
+func registerFailedCourses(tx *gorm.DB, program models.Program, batchID, semesterID uint, marks []models.Mark) ([]models.BackPaperRegistration, []string, error) {
+	// The next attempt follows the highest one on record, whichever attempt counts
+	lastAttempt := make(map[[2]uint]int)
+	for _, mark := range marks {
+		key := [2]uint{mark.StudentID, mark.CourseID}
+		lastAttempt[key] = max(lastAttempt[key], mark.Attempt)
+	}
+
+	var registrations []models.BackPaperRegistration
+	var skipped []string
+	for _, mark := range countedMarks(marks, map[uint]string{program.ID: program.BackPaperRule}) {
+		if mark.Status == "pass" {
+			continue
+		}
+
+		var open int64
+		if err := tx.Model(&models.BackPaperRegistration{}).
+			Where("student_id = ? AND course_id = ? AND status IN ?", mark.StudentID, mark.CourseID, []string{"Registered", "Scheduled"}).
+			Count(&open).Error; err != nil {
+			return nil, nil, fmt.Errorf("failed to check registrations: %w", err)
+		}
+		if open > 0 {
+			skipped = append(skipped, fmt.Sprintf("%s %s: already registered", mark.Student.SymbolNumber, mark.Course.CourseCode))
+			continue
+		}
+
+		attempt := lastAttempt[[2]uint{mark.StudentID, mark.CourseID}] + 1
+		if attempt > program.MaxAttempts {
+			skipped = append(skipped, fmt.Sprintf("%s %s: all %d attempts used", mark.Student.SymbolNumber, mark.Course.CourseCode, program.MaxAttempts))
+			continue
+		}
+
+		registration := models.BackPaperRegistration{
+			StudentID:  mark.StudentID,
+			CourseID:   mark.CourseID,
+			BatchID:    batchID,
+			ProgramID:  program.ID,
+			SemesterID: semesterID,
+			Attempt:    attempt,
+			Status:     "Registered",
+		}
+		if err := tx.Create(&registration).Error; err != nil {
+			return nil, nil, fmt.Errorf("failed to save registration: %w", err)
+		}
+		registrations = append(registrations, registration)
+	}
+	return registrations, skipped, nil
+}
+
+// CancelBackPaperRegistration withdraws a registration that has not been marked yet
+func CancelBackPaperRegistration(id uint) (*models.BackPaperRegistration, error) {
+	var registration models.BackPaperRegistration
+	if err := initializers.DB.First(&registration, id).Error; err != nil {
+		return nil, fiber.NewError(fiber.StatusNotFound, "Back paper registration not found")
+	}
+	if registration.Status != "Registered" && registration.Status != "Scheduled" {
+		return nil, fiber.NewError(fiber.StatusConflict, fmt.Sprintf("A %s registration cannot be cancelled", registration.Status))
+	}
+
+	registration.Status = "Cancelled"
+	if err := initializers.DB.Save(&registration).Error; err != nil {
+		return nil, fmt.Errorf("failed to cancel registration: %w", err)
+	}
+	return &registration, nil
+}
+
+// BackPaperRoutine schedules the courses with open registrations of a semester as a back paper
+// routine and links the registrations to it
+func BackPaperRoutine(req models.ExamRoutineRequest) (string, []models.ExamSchedules, *TimetableScore, error) {
+	var registrations []models.BackPaperRegistration
+	if err := initializers.DB.
+		Where("batch_id = ? AND program_id = ? AND semester_id = ? AND status = ?", req.BatchID, req.ProgramID, req.SemesterID, "Registered").
+		Find(&registrations).Error; err != nil {
+		return "", nil, nil, fmt.Errorf("failed to fetch registrations: %w", err)
+	}
+	if len(registrations) == 0 {
+		return "", nil, nil, fiber.NewError(fiber.StatusBadRequest, "No open back paper registrations for the given semester")
+	}
+
+	courseIDs := make(map[uint]bool)
+	var registrationIDs []uint
+	for _, registration := range registrations {
+		courseIDs[registration.CourseID] = true
+		registrationIDs = append(registrationIDs, registration.ID)
+	}
+	var ids []uint
+	for id := range courseIDs {
+		ids = append(ids, id)
+	}
+
+	var courses []models.Course
+	if err := initializers.DB.Where("id IN ?", ids).Find(&courses).Error; err != nil {
+		return "", nil, nil, fmt.Errorf("failed to fetch courses: %w", err)
+	}
+
+	return scheduleRoutine(req, "back_paper", courses, func(tx *gorm.DB, routine models.ExamRoutine) error {
+		if err := tx.Model(&models.BackPaperRegistration{}).
+			Where("id IN ?", registrationIDs).
+			Updates(map[string]interface{}{"status": "Scheduled", "exam_routine_id": routine.ID}).Error; err != nil {
+			return fmt.Errorf("failed to link registrations: %w", err)
+		}
+		return nil
+	})
+}
+
+// RecordBackPaperMarks stores the marks of a back paper as a new attempt next to the earlier ones
+func RecordBackPaperMarks(payload models.MarksPayload) ([]models.Mark, error) {
+	var course models.Course
+	if err := initializers.DB.First(&course, payload.CourseID).Error; err != nil {
+		return nil, fiber.NewError(fiber.StatusNotFound, "Course not found")
+	}
+
+	var marks []models.Mark
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		for _, entry := range payload.Marks {
+			if entry.SemesterMarks > course.SemesterTotalMarks ||
+				(course.PracticalTotalMarks != nil && entry.PracticalMarks > *course.PracticalTotalMarks) ||
+				(course.AssistantTotalMarks != nil && entry.AssistantMarks > *course.AssistantTotalMarks) {
+				return fiber.NewError(fiber.StatusBadRequest, "Obtained marks cannot exceed total marks")
+			}
+
+			var registration models.BackPaperRegistration
+			if err := tx.Where("student_id = ? AND course_id = ? AND batch_id = ? AND program_id = ? AND semester_id = ? AND status = ?",
+				entry.StudentID, payload.CourseID, payload.BatchID, payload.ProgramID, payload.SemesterID, "Scheduled").
+				First(&registration).Error; err != nil {
+				return fiber.NewError(fiber.StatusNotFound, fmt.Sprintf("No scheduled back paper for student %d", entry.StudentID))
+			}
+
+			mark := models.Mark{
+				BatchID:        payload.BatchID,
+				ProgramID:      payload.ProgramID,
+				SemesterID:     payload.SemesterID,
+				CourseID:       payload.CourseID,
+				StudentID:      entry.StudentID,
+				SemesterMarks:  entry.SemesterMarks,
+				AssistantMarks: entry.AssistantMarks,
+				PracticalMarks: entry.PracticalMarks,
+				Attempt:        registration.Attempt,
+			}
+			if err := tx.Create(&mark).Error; err != nil {
+				return fmt.Errorf("failed to save marks: %w", err)
+			}
+
+			registration.Status = "Completed"
+			if err := tx.Save(&registration).Error; err != nil {
+				return fmt.Errorf("failed to update registration: %w", err)
+			}
+			marks = append(marks, mark)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return marks, nil
+}
+
+// RepublishResults applies back paper marks recorded since the last publication to a published
+// result and reports the counted outcome of every student they affect. Standing is worked out
+// again, so students held back who now passed are promoted or graduate.
+func RepublishResults(batchID, programID, semesterID uint) (*models.Result, []BackPaperOutcome, error) {
+	var result models.Result
+	if err := initializers.DB.Where("batch_id = ? AND program_id = ? AND semester_id = ? AND status = ?", batchID, programID, semesterID, "Published").
+		First(&result).Error; err != nil {
+		return nil, nil, fiber.NewError(fiber.StatusNotFound, "No published result for the given semester with batch and program")
+	}
+
+	since := result.UpdatedAt
+	if result.RepublishedAt != nil {
+		since = *result.RepublishedAt
+	}
+
+	var affected []uint
+	if err := initializers.DB.Model(&models.Mark{}).
+		Where("batch_id = ? AND program_id = ? AND semester_id = ? AND attempt > 1 AND created_at > ?", batchID, programID, semesterID, since).
+		Distinct().Pluck("student_id", &affected).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch back paper marks: %w", err)
+	}
+	if len(affected) == 0 {
+		return nil, nil, fiber.NewError(fiber.StatusBadRequest, "No back paper marks were recorded since the result was published")
+	}
+
+	var marks []models.Mark
+	if err := initializers.DB.Preload("Student").
+		Where("semester_id = ? AND student_id IN ?", semesterID, affected).
+		Find(&marks).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch marks: %w", err)
+	}
+	counted, err := CountedMarks(marks)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	outcomes := make(map[uint]*BackPaperOutcome)
+	for _, mark := range counted {
+		outcome, ok := outcomes[mark.StudentID]
+		if !ok {
+			outcome = &BackPaperOutcome{
+				StudentID:    mark.StudentID,
+				SymbolNumber: mark.Student.SymbolNumber,
+				Fullname:     mark.Student.Fullname,
+				Status:       "pass",
+			}
+			outcomes[mark.StudentID] = outcome
+		}
+		if mark.Status != "pass" {
+			outcome.Status = "fail"
+		}
+	}
+
+	// The revision keeps the promotions of the publication it revises and applies the ones the
+	// back papers earned
+	err = initializers.DB.Transaction(func(tx *gorm.DB) error {
+		published, err := latestPublication(tx, result.ID)
+		if err != nil {
+			return err
+		}
+		var program models.Program
+		if err := tx.First(&program, programID).Error; err != nil {
+			return fmt.Errorf("failed to fetch program: %w", err)
+		}
+		standings, _, err := cohortStandings(tx, program, batchID, semesterID)
+		if err != nil {
+			return err
+		}
+
+		students := carriedPromotions(published)
+		for i, student := range students {
+			outcome, ok := outcomes[student.StudentID]
+			standing := standings[student.StudentID]
+			if !ok || student.Action != "hold" || standing == nil || len(standing.failed) > 0 {
+				continue
+			}
+			action := promotionAction(true, student.PreviousSemester, len(standing.outstanding) > 0, program.PromotionRule)
+			if action == "hold" {
+				continue
+			}
+			// A student whose semester or status changed since is left alone
+			res := tx.Model(&models.Student{}).
+				Where("id = ? AND current_semester = ? AND status = ?", student.StudentID, student.PreviousSemester, student.PreviousStatus).
+				Select("current_semester", "status").
+				Updates(promotedStudent(student, action))
+			if res.Error != nil {
+				return fmt.Errorf("failed to promote student %d: %w", student.StudentID, res.Error)
+			}
+			if res.RowsAffected > 0 {
+				students[i].Action = action
+				outcome.Action = action
+			}
+		}
+
+		now := time.Now()
+		result.Revision++
+		result.RepublishedAt = &now
+		if err := tx.Save(&result).Error; err != nil {
+			return fmt.Errorf("failed to republish result: %w", err)
+		}
+
+		_, err = takeResultSnapshot(tx, &result, "revised", "Back paper marks applied", students)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	refreshPublicResults(batchID, programID)
+
+	var report []BackPaperOutcome
+	for _, outcome := range outcomes {
+		report = append(report, *outcome)
+	}
+	sort.Slice(report, func(i, j int) bool { return report[i].SymbolNumber < report[j].SymbolNumber })
+	return &result, report, nil
+}
+
+// promotedStudent is the semester and status a publication's action leaves a student with
+func promotedStudent(student models.ResultSnapshotStudent, action string) models.Student {
+	switch action {
+	case "graduate":
+		return models.Student{CurrentSemester: student.PreviousSemester, Status: "Graduated"}
+	case "hold":
+		return models.Student{CurrentSemester: student.PreviousSemester, Status: student.PreviousStatus}
+	}
+	return models.Student{CurrentSemester: student.PreviousSemester + 1, Status: student.PreviousStatus}
+}
+
+// AssignRoutineCenters solves the center allocation for the students who sit a routine. A back
+// paper routine only seats its registered students; a regular one seats the whole cohort.
+func AssignRoutineCenters(routine models.ExamRoutine, opts CenterSolverOptions) (*CenterSolution, error) {
+	if routine.Kind != "back_paper" {
+		return AssignCenters(routine.BatchID, routine.ProgramID, opts)
+	}
+
+	counts, err := backPaperCollegeCounts(routine.ID, routine.BatchID, routine.ProgramID, routine.SemesterID)
+	if err != nil {
+		return nil, err
+	}
+	return assignCentersWithCounts(routine.BatchID, routine.ProgramID, counts, opts)
+}
+
+// backPaperCollegeCounts counts the back paper students of each college. A zero routineID counts
+// the open registrations that a new routine would take.
+func backPaperCollegeCounts(routineID, batchID, programID, semesterID uint) (map[uint]int, error) {
+	query := initializers.DB.Table("back_paper_registrations").
+		Select("students.college_id AS college_id, COUNT(DISTINCT back_paper_registrations.student_id) AS students").
+		Joins("JOIN students ON students.id = back_paper_registrations.student_id").
+		Where("back_paper_registrations.deleted_at IS NULL").
+		Group("students.college_id")
+	if routineID != 0 {
+		query = query.Where("back_paper_registrations.exam_routine_id = ? AND back_paper_registrations.status IN ?", routineID, []string{"Scheduled", "Completed"})
+	} else {
+		query = query.Where("back_paper_registrations.batch_id = ? AND back_paper_registrations.program_id = ? AND back_paper_registrations.semester_id = ? AND back_paper_registrations.status = ?",
+			batchID, programID, semesterID, "Registered")
+	}
+
+	var rows []struct {
+		CollegeID uint
+		Students  int
+	}
+	if err := query.Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to count back paper students: %w", err)
+	}
+
+	counts := make(map[uint]int)
+	for _, row := range rows {
+		counts[row.CollegeID] = row.Students
+	}
+	return counts, nil
+}
+
+// assignCentersWithCounts solves the allocation of a cohort with the students counts replaced
+func assignCentersWithCounts(batchID, programID uint, counts map[uint]int, opts CenterSolverOptions) (*CenterSolution, error) {
+	var rows []models.CapacityAndCount
+	if err := initializers.DB.Preload("College").
+		Where("batch_id = ? AND program_id = ?", batchID, programID).
+		Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch capacity and count data: %w", err)
+	}
+	for i := range rows {
+		rows[i].StudentsCount = counts[rows[i].CollegeID]
+	}
+	return SolveCenterAllocation(rows, opts)
+}
+
+// BackPaperStudentIDs lists the students who sit a back paper routine
+func BackPaperStudentIDs(routineID uint) ([]uint, error) {
+	var ids []uint
+	if err := initializers.DB.Model(&models.BackPaperRegistration{}).
+		Where("exam_routine_id = ? AND status IN ?", routineID, []string{"Scheduled", "Completed"}).
+		Distinct().Pluck("student_id", &ids).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch back paper students: %w", err)
+	}
+	return ids, nil
+}
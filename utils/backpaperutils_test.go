@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/mysterybee07/result-distribution-system/models"
+)
+
+func attemptMark(programID, courseID uint, attempt, total int, status string) models.Mark {
+	return models.Mark{ProgramID: programID, StudentID: 1, CourseID: courseID, Attempt: attempt, TotalMarks: total, Status: status}
+}
+
+func TestCountedMarks(t *testing.T) {
+	tests := []struct {
+		name     string
+		rule     string
+		marks    []models.Mark
+		attempts []int // Counted attempt per course, in the order the courses first appear
+	}{
+		{
+			name: "latest counts the last attempt even when it scores lower",
+			rule: "latest",
+			marks: []models.Mark{
+				attemptMark(1, 10, 1, 60, "pass"),
+				attemptMark(1, 10, 2, 30, "failed"),
+			},
+			attempts: []int{2},
+		},
+		{
+			name: "latest ignores the order the marks come in",
+			rule: "latest",
+			marks: []models.Mark{
+				attemptMark(1, 10, 3, 40, "pass"),
+				attemptMark(1, 10, 1, 20, "failed"),
+				attemptMark(1, 10, 2, 10, "failed"),
+			},
+			attempts: []int{3},
+		},
+		{
+			name: "best prefers a pass over a higher failed total",
+			rule: "best",
+			marks: []models.Mark{
+				attemptMark(1, 10, 1, 70, "failed"),
+				attemptMark(1, 10, 2, 45, "pass"),
+			},
+			attempts: []int{2},
+		},
+		{
+			name: "best keeps the higher total among passes",
+			rule: "best",
+			marks: []models.Mark{
+				attemptMark(1, 10, 1, 65, "pass"),
+				attemptMark(1, 10, 2, 50, "pass"),
+			},
+			attempts: []int{1},
+		},
+		{
+			name: "best takes the later attempt on a tie",
+			rule: "best",
+			marks: []models.Mark{
+				attemptMark(1, 10, 1, 30, "failed"),
+				attemptMark(1, 10, 2, 30, "failed"),
+			},
+			attempts: []int{2},
+		},
+		{
+			name: "courses are chosen separately",
+			rule: "best",
+			marks: []models.Mark{
+				attemptMark(1, 10, 1, 30, "failed"),
+				attemptMark(1, 20, 1, 80, "pass"),
+				attemptMark(1, 10, 2, 50, "pass"),
+				attemptMark(1, 20, 2, 40, "pass"),
+			},
+			attempts: []int{2, 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counted := countedMarks(tt.marks, map[uint]string{1: tt.rule})
+			if len(counted) != len(tt.attempts) {
+				t.Fatalf("counted %d marks, want %d", len(counted), len(tt.attempts))
+			}
+			for i, mark := range counted {
+				if mark.Attempt != tt.attempts[i] {
+					t.Errorf("course %d counts attempt %d, want %d", mark.CourseID, mark.Attempt, tt.attempts[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCountedMarksRulePerProgram(t *testing.T) {
+	marks := []models.Mark{
+		attemptMark(1, 10, 1, 70, "pass"),
+		attemptMark(1, 10, 2, 40, "pass"),
+		attemptMark(2, 20, 1, 70, "pass"),
+		attemptMark(2, 20, 2, 40, "pass"),
+	}
+	counted := countedMarks(marks, map[uint]string{1: "best", 2: "latest"})
+
+	want := map[uint]int{10: 1, 20: 2}
+	for _, mark := range counted {
+		if mark.Attempt != want[mark.CourseID] {
+			t.Errorf("course %d counts attempt %d, want %d", mark.CourseID, mark.Attempt, want[mark.CourseID])
+		}
+	}
+}
@@ -0,0 +1,356 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mysterybee07/result-distribution-system/initializers"
+	"github.com/mysterybee07/result-distribution-system/models"
+	"gorm.io/gorm"
+)
+
+var calendarKinds = map[string]bool{"holiday": true, "festival": true, "blackout": true}
+
+// CalendarEventFromInput validates the input and turns it into an event
+func CalendarEventFromInput(input models.CalendarEventInput) (models.CalendarEvent, error) {
+	event := models.CalendarEvent{
+		Title: strings.TrimSpace(input.Title),
+		Kind:  strings.ToLower(strings.TrimSpace(input.Kind)),
+	}
+	if event.Title == "" {
+		return event, fiber.NewError(fiber.StatusBadRequest, "Title is required")
+	}
+	if event.Kind == "" {
+		event.Kind = "holiday"
+	}
+	if !calendarKinds[event.Kind] {
+		return event, fiber.NewError(fiber.StatusBadRequest, "Kind must be holiday, festival or blackout")
+	}
+
+	start, err := time.Parse("2006-01-02", input.StartDate)
+	if err != nil {
+		return event, fiber.NewError(fiber.StatusBadRequest, "start_date must be a date in the format 2006-01-02")
+	}
+	end := start
+	if input.EndDate != "" {
+		if end, err = time.Parse("2006-01-02", input.EndDate); err != nil {
+			return event, fiber.NewError(fiber.StatusBadRequest, "end_date must be a date in the format 2006-01-02")
+		}
+	}
+	if end.Before(start) {
+		return event, fiber.NewError(fiber.StatusBadRequest, "end_date cannot be before start_date")
+	}
+	event.StartDate, event.EndDate = start, end
+
+	if err := setCalendarScope(&event, input.Scope, input.ProgramID, input.BatchID); err != nil {
+		return event, err
+	}
+	return event, nil
+}
+
+// setCalendarScope checks that the scope names the program or batch it applies to
+func setCalendarScope(event *models.CalendarEvent, scope string, programID, batchID *uint) error {
+	event.Scope = strings.ToLower(strings.TrimSpace(scope))
+	if event.Scope == "" {
+		event.Scope = "global"
+	}
+	event.ProgramID, event.BatchID = nil, nil
+
+	switch event.Scope {
+	case "global":
+	case "program":
+		if programID == nil {
+			return fiber.NewError(fiber.StatusBadRequest, "program_id is required for a program event")
+		}
+		if err := initializers.DB.First(&models.Program{}, *programID).Error; err != nil {
+			return fiber.NewError(fiber.StatusNotFound, "Program not found")
+		}
+		event.ProgramID = programID
+	case "batch":
+		if batchID == nil {
+			return fiber.NewError(fiber.StatusBadRequest, "batch_id is required for a batch event")
+		}
+		if err := initializers.DB.First(&models.Batch{}, *batchID).Error; err != nil {
+			return fiber.NewError(fiber.StatusNotFound, "Batch not found")
+		}
+		event.BatchID = batchID
+		// A batch event may be narrowed to one program of the batch
+		if programID != nil {
+			if err := initializers.DB.First(&models.Program{}, *programID).Error; err != nil {
+				return fiber.NewError(fiber.StatusNotFound, "Program not found")
+			}
+			event.ProgramID = programID
+		}
+	default:
+		return fiber.NewError(fiber.StatusBadRequest, "Scope must be global, program or batch")
+	}
+	return nil
+}
+
+// calendarScopeFor limits a query to the events that apply to one batch of a program
+func calendarScopeFor(db *gorm.DB, batchID, programID uint) *gorm.DB {
+	return db.Where(
+		"scope = ? OR (scope = ? AND program_id = ?) OR (scope = ? AND batch_id = ? AND (program_id IS NULL OR program_id = ?))",
+		"global", "program", programID, "batch", batchID, programID,
+	)
+}
+
+// ClosedDates lists the days between start and end on which the calendar closes exams for a
+// batch of a program, keyed as 2006-01-02 and mapped to the event title
+func ClosedDates(batchID, programID uint, start, end time.Time) (map[string]string, error) {
+	start, end = truncateToDay(start), truncateToDay(end)
+
+	var events []models.CalendarEvent
+	query := initializers.DB.Where("start_date <= ? AND end_date >= ?", end, start)
+	if err := calendarScopeFor(query, batchID, programID).Order("start_date").Find(&events).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch calendar events: %w", err)
+	}
+
+	closed := make(map[string]string)
+	for _, event := range events {
+		from := time.Date(event.StartDate.Year(), event.StartDate.Month(), event.StartDate.Day(), 0, 0, 0, 0, start.Location())
+		to := time.Date(event.EndDate.Year(), event.EndDate.Month(), event.EndDate.Day(), 0, 0, 0, 0, start.Location())
+		for date := from; !date.After(to); date = date.AddDate(0, 0, 1) {
+			if date.Before(start) || date.After(end) {
+				continue
+			}
+			if _, ok := closed[DateKey(date)]; !ok {
+				closed[DateKey(date)] = event.Title
+			}
+		}
+	}
+	return closed, nil
+}
+
+// CalendarConflicts finds the exam schedules that already fall on the days of an event
+func CalendarConflicts(event models.CalendarEvent) ([]models.ExamSchedules, error) {
+	query := initializers.DB.Preload("Course").Preload("ExamRoutine").
+		Joins("JOIN exam_routines ON exam_routines.id = exam_schedules.exam_routine_id AND exam_routines.deleted_at IS NULL").
+		Where("exam_schedules.exam_date >= ? AND exam_schedules.exam_date < ?", event.StartDate, event.EndDate.AddDate(0, 0, 1))
+
+	if event.ProgramID != nil {
+		query = query.Where("exam_routines.program_id = ?", *event.ProgramID)
+	}
+	if event.BatchID != nil {
+		query = query.Where("exam_routines.batch_id = ?", *event.BatchID)
+	}
+
+	var schedules []models.ExamSchedules
+	if err := query.Order("exam_schedules.exam_date").Find(&schedules).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch exam schedules: %w", err)
+	}
+	return schedules, nil
+}
+
+// ParseICal reads the VEVENT entries of an iCalendar file. All-day events end the day before
+// their DTEND as the standard requires; recurrence rules are not expanded.
+func ParseICal(reader io.Reader) ([]models.CalendarEvent, error) {
+	lines, err := unfoldICalLines(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []models.CalendarEvent
+	var current *models.CalendarEvent
+	endIsDate := false
+	for number, line := range lines {
+		name, params, value := splitICalLine(line)
+
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			current = &models.CalendarEvent{Kind: "holiday"}
+			endIsDate = false
+		case current == nil:
+			continue
+		case name == "END" && value == "VEVENT":
+			if current.StartDate.IsZero() {
+				return nil, fmt.Errorf("event %q has no DTSTART", current.Title)
+			}
+			if current.EndDate.IsZero() {
+				current.EndDate = current.StartDate
+			} else if endIsDate && current.EndDate.After(current.StartDate) {
+				current.EndDate = current.EndDate.AddDate(0, 0, -1)
+			}
+			if current.EndDate.Before(current.StartDate) {
+				current.EndDate = current.StartDate
+			}
+			if current.Title == "" {
+				current.Title = "Holiday"
+			}
+			events = append(events, *current)
+			current = nil
+		case name == "SUMMARY":
+			current.Title = unescapeICalText(value)
+		case name == "UID":
+			current.UID = value
+		case name == "CATEGORIES":
+			for _, category := range strings.Split(value, ",") {
+				if kind := strings.ToLower(strings.TrimSpace(category)); calendarKinds[kind] {
+					current.Kind = kind
+					break
+				}
+			}
+		case name == "DTSTART" || name == "DTEND":
+			date, isDate, err := parseICalDate(value, params)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", number+1, err)
+			}
+			if name == "DTSTART" {
+				current.StartDate = date
+			} else {
+				current.EndDate = date
+				endIsDate = isDate
+			}
+		}
+	}
+
+	if len(events) == 0 {
+		return nil, fmt.Errorf("no events found in calendar file")
+	}
+	return events, nil
+}
+
+// ImportCalendarEvents stores parsed events under one scope. Events that were imported before
+// with the same UID and scope are updated instead of duplicated.
+func ImportCalendarEvents(events []models.CalendarEvent, scope string, programID, batchID *uint) (int, int, error) {
+	var scoped models.CalendarEvent
+	if err := setCalendarScope(&scoped, scope, programID, batchID); err != nil {
+		return 0, 0, err
+	}
+
+	created, updated := 0, 0
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		for _, event := range events {
+			event.Scope, event.ProgramID, event.BatchID = scoped.Scope, scoped.ProgramID, scoped.BatchID
+
+			if event.UID != "" {
+				var existing models.CalendarEvent
+				query := tx.Where("uid = ? AND scope = ?", event.UID, event.Scope)
+				if event.ProgramID != nil {
+					query = query.Where("program_id = ?", *event.ProgramID)
+				} else {
+					query = query.Where("program_id IS NULL")
+				}
+				if event.BatchID != nil {
+					query = query.Where("batch_id = ?", *event.BatchID)
+				} else {
+					query = query.Where("batch_id IS NULL")
+				}
+				if err := query.First(&existing).Error; err == nil {
+					existing.Title, existing.Kind = event.Title, event.Kind
+					existing.StartDate, existing.EndDate = event.StartDate, event.EndDate
+					if err := tx.Save(&existing).Error; err != nil {
+						return fmt.Errorf("failed to update event %s: %w", event.UID, err)
+					}
+					updated++
+					continue
+				}
+			}
+
+			if err := tx.Create(&event).Error; err != nil {
+				return fmt.Errorf("failed to save event %q: %w", event.Title, err)
+			}
+			created++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return created, updated, nil
+}
+
+// unfoldICalLines joins continuation lines, which start with a space or a tab
+func unfoldICalLines(reader io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read calendar file: %w", err)
+	}
+	return lines, nil
+}
+
+// splitICalLine splits "NAME;PARAM=VALUE:content" into its parts
+func splitICalLine(line string) (string, map[string]string, string) {
+	head, value, found := strings.Cut(line, ":")
+	if !found {
+		return "", nil, ""
+	}
+	parts := strings.Split(head, ";")
+	params := make(map[string]string)
+	for _, param := range parts[1:] {
+		if key, val, ok := strings.Cut(param, "="); ok {
+			params[strings.ToUpper(key)] = strings.Trim(val, `"`)
+		}
+	}
+	return strings.ToUpper(parts[0]), params, strings.TrimSpace(value)
+}
+
+// parseICalDate reads a DATE or DATE-TIME value and reports whether it was a plain date
+func parseICalDate(value string, params map[string]string) (time.Time, bool, error) {
+	if params["VALUE"] == "DATE" || len(value) == 8 {
+		date, err := time.Parse("20060102", value)
+		if err != nil {
+			return time.Time{}, true, fmt.Errorf("invalid date %q", value)
+		}
+		return date, true, nil
+	}
+
+	location := time.UTC
+	if tzid := params["TZID"]; tzid != "" {
+		if loaded, err := time.LoadLocation(tzid); err == nil {
+			location = loaded
+		}
+	}
+	var date time.Time
+	var err error
+	if strings.HasSuffix(value, "Z") {
+		date, err = time.Parse("20060102T150405Z", value)
+	} else {
+		date, err = time.ParseInLocation("20060102T150405", value, location)
+	}
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid date-time %q", value)
+	}
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC), false, nil
+}
+
+func unescapeICalText(value string) string {
+	replacer := strings.NewReplacer(`\n`, " ", `\N`, " ", `\,`, ",", `\;`, ";", `\\`, `\`)
+	return strings.TrimSpace(replacer.Replace(value))
+}
+
+// RoutineClosedDays lists the exams of a routine that fall on a day the calendar has closed
+// since the routine was generated
+func RoutineClosedDays(routine models.ExamRoutine) ([]string, error) {
+	closed, err := ClosedDates(routine.BatchID, routine.ProgramID, routine.StartDate, routine.EndDate)
+	if err != nil {
+		return nil, err
+	}
+
+	var schedules []models.ExamSchedules
+	if err := initializers.DB.Preload("Course").Where("exam_routine_id = ?", routine.ID).Order("exam_date").Find(&schedules).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch exam schedules: %w", err)
+	}
+
+	var clashes []string
+	for _, schedule := range schedules {
+		if title, ok := closed[DateKey(schedule.ExamDate)]; ok {
+			clashes = append(clashes, fmt.Sprintf("%s on %s (%s)", schedule.Course.CourseCode, DateKey(schedule.ExamDate), title))
+		}
+	}
+	return clashes, nil
+}
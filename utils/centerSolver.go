@@ -0,0 +1,378 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/mysterybee07/result-distribution-system/initializers"
+	"github.com/mysterybee07/result-distribution-system/models"
+	"gorm.io/gorm"
+)
+
+// DefaultCenterRadiusKm is used when neither the request nor CENTER_RADIUS_KM sets a radius
+const DefaultCenterRadiusKm = 50.0
+
+// CenterSolverOptions controls how colleges may be matched with centers
+type CenterSolverOptions struct {
+	RadiusKm float64 `json:"radius_km"` // Maximum distance between a college and its center
+}
+
+// CenterSolution is the outcome of a single run of the allocation solver
+type CenterSolution struct {
+	Assignments        []CenterAssignment `json:"assignments"`
+	TotalStudents      int                `json:"total_students"`
+	TotalCapacity      int                `json:"total_capacity"`
+	AssignedStudents   int                `json:"assigned_students"`
+	UnassignedStudents int                `json:"unassigned_students"`
+	UnassignedColleges map[string]int     `json:"unassigned_colleges,omitempty"`
+	TotalDistanceKm    float64            `json:"total_distance_km"` // Sum of seat * distance over all assignments
+	LowerBoundKm       float64            `json:"lower_bound_km"`    // Optimal cost when the no-reciprocal rule is relaxed
+	OptimalityGap      float64            `json:"optimality_gap"`    // (TotalDistanceKm - LowerBoundKm) / LowerBoundKm
+	ReciprocalLoss     int                `json:"reciprocal_loss"`   // Students that could only be seated by breaking the no-reciprocal rule
+	ForbiddenPairs     int                `json:"forbidden_pairs"`   // Directed college→center edges removed to honour the no-reciprocal rule
+	RadiusKm           float64            `json:"radius_km"`
+}
+
+// CenterRadiusFromEnv reads CENTER_RADIUS_KM, falling back to DefaultCenterRadiusKm
+func CenterRadiusFromEnv() float64 {
+	if value := os.Getenv("CENTER_RADIUS_KM"); value != "" {
+		if radius, err := strconv.ParseFloat(value, 64); err == nil && radius > 0 {
+			return radius
+		}
+	}
+	return DefaultCenterRadiusKm
+}
+
+// AssignCenters loads the capacity and count rows of a batch/program and solves the allocation
+func AssignCenters(batchID uint, programID uint, opts CenterSolverOptions) (*CenterSolution, error) {
+	var capacityAndCounts []models.CapacityAndCount
+	if err := initializers.DB.Preload("College").
+		Where("batch_id = ? AND program_id = ?", batchID, programID).
+		Find(&capacityAndCounts).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch capacity and count data: %w", err)
+	}
+
+	return SolveCenterAllocation(capacityAndCounts, opts)
+}
+
+// isExamCenter decides which capacity rows offer seats: the college is registered as a center and
+// has room. The solver and the clash check both go by it.
+func isExamCenter(row models.CapacityAndCount) bool {
+	return row.IsCenter && row.Capacity > 0
+}
+
+// examCenters limits a query of capacity rows to the ones isExamCenter accepts
+func examCenters(db *gorm.DB) *gorm.DB {
+	return db.Where("is_center = ? AND capacity > ?", true, 0)
+}
+
+// SolveCenterAllocation assigns students to centers as a min-cost flow where the cost of a seat is
+// the Haversine distance between the college and the center. The result only depends on the rows
+// passed in, so the same rows always produce the same assignment.
+func SolveCenterAllocation(rows []models.CapacityAndCount, opts CenterSolverOptions) (*CenterSolution, error) {
+	if opts.RadiusKm <= 0 {
+		opts.RadiusKm = DefaultCenterRadiusKm
+	}
+
+	// Sort by college so the network is always built in the same order
+	sorted := make([]models.CapacityAndCount, len(rows))
+	copy(sorted, rows)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].CollegeID < sorted[j].CollegeID
+	})
+
+	var colleges, centers []models.CapacityAndCount
+	seen := make(map[uint]bool)
+	for _, row := range sorted {
+		if seen[row.CollegeID] {
+			return nil, fmt.Errorf("duplicate capacity row for college %d", row.CollegeID)
+		}
+		seen[row.CollegeID] = true
+
+		if row.StudentsCount > 0 {
+			colleges = append(colleges, row)
+		}
+		if isExamCenter(row) {
+			centers = append(centers, row)
+		}
+	}
+
+	solver := centerSolver{colleges: colleges, centers: centers, radiusKm: opts.RadiusKm}
+	solver.buildDistances()
+
+	// Solve the relaxation without the no-reciprocal rule; its cost is a lower bound
+	forbidden := make(map[[2]int]bool)
+	relaxed := solver.solve(forbidden)
+	current := relaxed
+
+	// Break reciprocal pairs one at a time, keeping whichever direction costs less
+	for {
+		forward, backward, found := solver.firstReciprocal(current)
+		if !found {
+			break
+		}
+
+		withoutForward := copyForbidden(forbidden)
+		withoutForward[forward] = true
+		withoutBackward := copyForbidden(forbidden)
+		withoutBackward[backward] = true
+
+		first := solver.solve(withoutForward)
+		second := solver.solve(withoutBackward)
+		if second.betterThan(first) {
+			forbidden, current = withoutBackward, second
+		} else {
+			forbidden, current = withoutForward, first
+		}
+	}
+
+	solution := &CenterSolution{
+		Assignments:      solver.assignments(current),
+		AssignedStudents: current.flow,
+		TotalDistanceKm:  float64(current.cost) / 1000,
+		LowerBoundKm:     float64(relaxed.cost) / 1000,
+		ReciprocalLoss:   relaxed.flow - current.flow,
+		ForbiddenPairs:   len(forbidden),
+		RadiusKm:         opts.RadiusKm,
+	}
+
+	for _, college := range colleges {
+		solution.TotalStudents += college.StudentsCount
+	}
+	for _, center := range centers {
+		solution.TotalCapacity += center.Capacity
+	}
+	solution.UnassignedStudents = solution.TotalStudents - solution.AssignedStudents
+
+	// Report the colleges whose students could not be seated
+	placed := make(map[uint]int)
+	for _, assignment := range solution.Assignments {
+		placed[assignment.CollegeID] += assignment.AssignedSeat
+	}
+	for _, college := range colleges {
+		if missing := college.StudentsCount - placed[college.CollegeID]; missing > 0 {
+			if solution.UnassignedColleges == nil {
+				solution.UnassignedColleges = make(map[string]int)
+			}
+			solution.UnassignedColleges[college.College.CollegeName] = missing
+		}
+	}
+
+	// The gap is only meaningful when both solutions seat the same number of students
+	if relaxed.cost > 0 && relaxed.flow == current.flow {
+		solution.OptimalityGap = float64(current.cost-relaxed.cost) / float64(relaxed.cost)
+	}
+
+	return solution, nil
+}
+
+func copyForbidden(forbidden map[[2]int]bool) map[[2]int]bool {
+	copied := make(map[[2]int]bool, len(forbidden)+1)
+	for key, value := range forbidden {
+		copied[key] = value
+	}
+	return copied
+}
+
+// centerSolver holds the static part of the network; distances are stored in whole meters so the
+// solver never compares floats
+type centerSolver struct {
+	colleges  []models.CapacityAndCount
+	centers   []models.CapacityAndCount
+	radiusKm  float64
+	distances [][]int64 // distances[college][center], -1 when the pair is not allowed
+}
+
+type flowEdge struct {
+	to   int
+	rev  int
+	cap  int
+	cost int64
+}
+
+type flowResult struct {
+	flow  int
+	cost  int64
+	seats [][]int // seats[college][center]
+}
+
+func (r flowResult) betterThan(other flowResult) bool {
+	if r.flow != other.flow {
+		return r.flow > other.flow
+	}
+	return r.cost < other.cost
+}
+
+func (s *centerSolver) buildDistances() {
+	s.distances = make([][]int64, len(s.colleges))
+	for i, college := range s.colleges {
+		s.distances[i] = make([]int64, len(s.centers))
+		for j, center := range s.centers {
+			// Prevent self-assignment
+			if college.CollegeID == center.CollegeID {
+				s.distances[i][j] = -1
+				continue
+			}
+
+			distance := Haversine(college.College.Latitude, college.College.Longitude, center.College.Latitude, center.College.Longitude)
+			if distance > s.radiusKm {
+				s.distances[i][j] = -1
+				continue
+			}
+			s.distances[i][j] = int64(math.Round(distance * 1000))
+		}
+	}
+}
+
+// solve runs successive shortest paths with Bellman-Ford, which keeps tie-breaking tied to edge order
+func (s *centerSolver) solve(forbidden map[[2]int]bool) flowResult {
+	source := 0
+	collegeOffset := 1
+	centerOffset := collegeOffset + len(s.colleges)
+	sink := centerOffset + len(s.centers)
+	graph := make([][]flowEdge, sink+1)
+
+	addEdge := func(from, to, capacity int, cost int64) {
+		graph[from] = append(graph[from], flowEdge{to: to, rev: len(graph[to]), cap: capacity, cost: cost})
+		graph[to] = append(graph[to], flowEdge{to: from, rev: len(graph[from]) - 1, cap: 0, cost: -cost})
+	}
+
+	for i, college := range s.colleges {
+		addEdge(source, collegeOffset+i, college.StudentsCount, 0)
+	}
+	for i, college := range s.colleges {
+		for j := range s.centers {
+			if s.distances[i][j] < 0 || forbidden[[2]int{i, j}] {
+				continue
+			}
+			addEdge(collegeOffset+i, centerOffset+j, college.StudentsCount, s.distances[i][j])
+		}
+	}
+	for j, center := range s.centers {
+		addEdge(centerOffset+j, sink, center.Capacity, 0)
+	}
+
+	result := flowResult{}
+	dist := make([]int64, len(graph))
+	prevNode := make([]int, len(graph))
+	prevEdge := make([]int, len(graph))
+
+	for {
+		for v := range dist {
+			dist[v] = math.MaxInt64
+			prevNode[v] = -1
+		}
+		dist[source] = 0
+
+		// Bellman-Ford over the residual graph
+		for iteration := 0; iteration < len(graph); iteration++ {
+			updated := false
+			for v := range graph {
+				if dist[v] == math.MaxInt64 {
+					continue
+				}
+				for e, edge := range graph[v] {
+					if edge.cap > 0 && dist[v]+edge.cost < dist[edge.to] {
+						dist[edge.to] = dist[v] + edge.cost
+						prevNode[edge.to] = v
+						prevEdge[edge.to] = e
+						updated = true
+					}
+				}
+			}
+			if !updated {
+				break
+			}
+		}
+
+		if dist[sink] == math.MaxInt64 {
+			break
+		}
+
+		// Find the bottleneck along the path and push flow through it
+		push := math.MaxInt
+		for v := sink; v != source; v = prevNode[v] {
+			push = min(push, graph[prevNode[v]][prevEdge[v]].cap)
+		}
+		for v := sink; v != source; v = prevNode[v] {
+			edge := &graph[prevNode[v]][prevEdge[v]]
+			edge.cap -= push
+			graph[v][edge.rev].cap += push
+		}
+
+		result.flow += push
+		result.cost += int64(push) * dist[sink]
+	}
+
+	// Read the seats back from the college→center edges
+	result.seats = make([][]int, len(s.colleges))
+	for i := range s.colleges {
+		result.seats[i] = make([]int, len(s.centers))
+		for _, edge := range graph[collegeOffset+i] {
+			if edge.to >= centerOffset && edge.to < sink {
+				result.seats[i][edge.to-centerOffset] = graph[edge.to][edge.rev].cap
+			}
+		}
+	}
+
+	return result
+}
+
+// firstReciprocal returns the first college→center pair whose reverse direction also carries students,
+// as [college, center] index pairs for both directions
+func (s *centerSolver) firstReciprocal(result flowResult) ([2]int, [2]int, bool) {
+	collegeIndex := make(map[uint]int, len(s.colleges))
+	for i, college := range s.colleges {
+		collegeIndex[college.CollegeID] = i
+	}
+	centerIndex := make(map[uint]int, len(s.centers))
+	for j, center := range s.centers {
+		centerIndex[center.CollegeID] = j
+	}
+
+	for i, college := range s.colleges {
+		for j, center := range s.centers {
+			if result.seats[i][j] == 0 {
+				continue
+			}
+			reverseCollege, isCollege := collegeIndex[center.CollegeID]
+			reverseCenter, isCenter := centerIndex[college.CollegeID]
+			if isCollege && isCenter && result.seats[reverseCollege][reverseCenter] > 0 {
+				return [2]int{i, j}, [2]int{reverseCollege, reverseCenter}, true
+			}
+		}
+	}
+	return [2]int{}, [2]int{}, false
+}
+
+func (s *centerSolver) assignments(result flowResult) []CenterAssignment {
+	remaining := make([]int, len(s.centers))
+	for j, center := range s.centers {
+		remaining[j] = center.Capacity
+		for i := range s.colleges {
+			remaining[j] -= result.seats[i][j]
+		}
+	}
+
+	var assignments []CenterAssignment
+	for i, college := range s.colleges {
+		for j, center := range s.centers {
+			if result.seats[i][j] == 0 {
+				continue
+			}
+			assignments = append(assignments, CenterAssignment{
+				CollegeID:     college.CollegeID,
+				CenterID:      center.CollegeID,
+				CollegeName:   college.College.CollegeName,
+				CenterName:    center.College.CollegeName,
+				AssignedSeat:  result.seats[i][j],
+				RemainingSeat: remaining[j],
+				DistanceKm:    float64(s.distances[i][j]) / 1000,
+			})
+		}
+	}
+	return assignments
+}
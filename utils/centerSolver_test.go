@@ -0,0 +1,127 @@
+package utils
+
+import (
+	"math"
+	"testing"
+
+	"github.com/mysterybee07/result-distribution-system/models"
+)
+
+// capacityRow is a college on the equator; 0.01 degrees of latitude are about 1.11 km
+func capacityRow(id uint, name string, latitude float64, students, capacity int) models.CapacityAndCount {
+	return models.CapacityAndCount{
+		CollegeID:     id,
+		StudentsCount: students,
+		Capacity:      capacity,
+		IsCenter:      capacity > 0,
+		College:       models.College{CollegeName: name, Latitude: latitude},
+	}
+}
+
+func TestSolveCenterAllocation(t *testing.T) {
+	unflagged := capacityRow(2, "B", 0.01, 0, 20)
+	unflagged.IsCenter = false
+
+	tests := []struct {
+		name           string
+		rows           []models.CapacityAndCount
+		seats          map[[2]uint]int // [college, center] to seats
+		unassigned     map[string]int
+		reciprocalLoss int
+		forbiddenPairs int
+		lowerBoundKm   float64
+		optimalityGap  float64
+	}{
+		{
+			name: "capacity shortfall leaves students unassigned",
+			rows: []models.CapacityAndCount{
+				capacityRow(1, "A", 0, 30, 0),
+				capacityRow(2, "B", 0.01, 0, 20),
+			},
+			seats:        map[[2]uint]int{{1, 2}: 20},
+			unassigned:   map[string]int{"A": 10},
+			lowerBoundKm: 20 * Haversine(0, 0, 0.01, 0),
+		},
+		{
+			name: "capacity without the center flag seats nobody",
+			rows: []models.CapacityAndCount{
+				capacityRow(1, "A", 0, 10, 0),
+				unflagged,
+			},
+			seats:      map[[2]uint]int{},
+			unassigned: map[string]int{"A": 10},
+		},
+		{
+			name: "reciprocal pair keeps the direction that seats more",
+			rows: []models.CapacityAndCount{
+				capacityRow(1, "A", 0, 10, 10),
+				capacityRow(2, "B", 0.01, 5, 10),
+			},
+			seats:          map[[2]uint]int{{1, 2}: 10},
+			unassigned:     map[string]int{"B": 5},
+			reciprocalLoss: 5,
+			forbiddenPairs: 1,
+			lowerBoundKm:   15 * Haversine(0, 0, 0.01, 0),
+		},
+		{
+			name: "reciprocal pair moved to a farther center reports the gap",
+			rows: []models.CapacityAndCount{
+				capacityRow(1, "A", 0, 10, 10),
+				capacityRow(2, "B", 0.01, 10, 10),
+				capacityRow(3, "C", 0.03, 0, 10),
+			},
+			seats:          map[[2]uint]int{{1, 2}: 10, {2, 3}: 10},
+			forbiddenPairs: 1,
+			lowerBoundKm:   20 * Haversine(0, 0, 0.01, 0),
+			optimalityGap:  0.5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			solution, err := SolveCenterAllocation(tt.rows, CenterSolverOptions{RadiusKm: 10})
+			if err != nil {
+				t.Fatalf("SolveCenterAllocation: %v", err)
+			}
+
+			seats := make(map[[2]uint]int)
+			for _, assignment := range solution.Assignments {
+				seats[[2]uint{assignment.CollegeID, assignment.CenterID}] += assignment.AssignedSeat
+			}
+			if len(seats) != len(tt.seats) {
+				t.Errorf("seats = %v, want %v", seats, tt.seats)
+			}
+			for pair, want := range tt.seats {
+				if seats[pair] != want {
+					t.Errorf("seats from college %d at center %d = %d, want %d", pair[0], pair[1], seats[pair], want)
+				}
+			}
+
+			unassigned := 0
+			for _, missing := range tt.unassigned {
+				unassigned += missing
+			}
+			if solution.UnassignedStudents != unassigned {
+				t.Errorf("UnassignedStudents = %d, want %d", solution.UnassignedStudents, unassigned)
+			}
+			for name, want := range tt.unassigned {
+				if solution.UnassignedColleges[name] != want {
+					t.Errorf("UnassignedColleges[%s] = %d, want %d", name, solution.UnassignedColleges[name], want)
+				}
+			}
+
+			if solution.ReciprocalLoss != tt.reciprocalLoss {
+				t.Errorf("ReciprocalLoss = %d, want %d", solution.ReciprocalLoss, tt.reciprocalLoss)
+			}
+			if solution.ForbiddenPairs != tt.forbiddenPairs {
+				t.Errorf("ForbiddenPairs = %d, want %d", solution.ForbiddenPairs, tt.forbiddenPairs)
+			}
+			if math.Abs(solution.LowerBoundKm-tt.lowerBoundKm) > 0.01 {
+				t.Errorf("LowerBoundKm = %.3f, want %.3f", solution.LowerBoundKm, tt.lowerBoundKm)
+			}
+			if math.Abs(solution.OptimalityGap-tt.optimalityGap) > 0.01 {
+				t.Errorf("OptimalityGap = %.3f, want %.3f", solution.OptimalityGap, tt.optimalityGap)
+			}
+		})
+	}
+}
@@ -0,0 +1,315 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mysterybee07/result-distribution-system/initializers"
+	"github.com/mysterybee07/result-distribution-system/models"
+	"gorm.io/gorm"
+)
+
+// CenterLoad is the number of seats all routines need at one center on one exam date
+type CenterLoad struct {
+	Date           string `json:"date"`
+	CenterID       uint   `json:"center_id"`
+	CenterName     string `json:"center_name"`
+	SeatsNeeded    int    `json:"seats_needed"`
+	Capacity       int    `json:"capacity"`
+	OverCapacity   bool   `json:"over_capacity"`
+	ExamRoutineIDs []uint `json:"exam_routine_ids"` // Zero stands for a routine that is not stored yet
+}
+
+// ClashReport lists the load of every center on every exam date of a window
+type ClashReport struct {
+	Loads     []CenterLoad `json:"loads"`
+	Clashes   int          `json:"clashes"`
+	Projected []uint       `json:"projected_routine_ids"` // Routines without a published allocation, seated with a solver projection
+}
+
+// ClashCandidate is a routine that is about to be created, published or re-allocated
+type ClashCandidate struct {
+	ExamRoutineID uint // Zero for a routine that is not stored yet
+	BatchID       uint
+	ProgramID     uint
+	SemesterID    uint
+	Kind          string // regular or back_paper
+	Dates         []time.Time
+	Seats         map[uint]int // Seats per center; nil uses the published allocation or a projection
+}
+
+// CenterClashError lists the centers a candidate routine would push over capacity
+type CenterClashError struct {
+	Clashes []CenterLoad
+}
+
+func (e *CenterClashError) Error() string {
+	var parts []string
+	for _, clash := range e.Clashes {
+		parts = append(parts, fmt.Sprintf("%s needs %d of %d seats on %s", clash.CenterName, clash.SeatsNeeded, clash.Capacity, clash.Date))
+	}
+	return "centers over capacity: " + strings.Join(parts, "; ")
+}
+
+type clashRoutine struct {
+	id, batchID, programID, semesterID uint
+	kind                               string
+	dates                              map[string]bool
+	seats                              map[uint]int
+}
+
+// AnalyzeCenterClashes adds up, per date and center, the seats every routine needs between start
+// and end. A routine uses its published center allocation; routines without one are projected
+// with the allocation solver. The candidate, if given, replaces the stored routine with its ID.
+func AnalyzeCenterClashes(start, end time.Time, candidate *ClashCandidate) (*ClashReport, error) {
+	start, end = truncateToDay(start), truncateToDay(end)
+
+	var schedules []models.ExamSchedules
+	if err := initializers.DB.Preload("ExamRoutine").
+		Where("exam_date >= ? AND exam_date < ?", start, end.AddDate(0, 0, 1)).
+		Find(&schedules).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch exam schedules: %w", err)
+	}
+
+	// Group the schedules into routines; courses sharing a day need one seat per student
+	routines := make(map[uint]*clashRoutine)
+	for _, schedule := range schedules {
+		if schedule.ExamRoutine.ID == 0 || (candidate != nil && schedule.ExamRoutineID == candidate.ExamRoutineID) {
+			continue
+		}
+		routine, ok := routines[schedule.ExamRoutineID]
+		if !ok {
+			routine = &clashRoutine{
+				id:         schedule.ExamRoutineID,
+				batchID:    schedule.ExamRoutine.BatchID,
+				programID:  schedule.ExamRoutine.ProgramID,
+				semesterID: schedule.ExamRoutine.SemesterID,
+				kind:       schedule.ExamRoutine.Kind,
+				dates:      make(map[string]bool),
+			}
+			routines[routine.id] = routine
+		}
+		routine.dates[DateKey(schedule.ExamDate)] = true
+	}
+	if candidate != nil {
+		routine := &clashRoutine{
+			id:         candidate.ExamRoutineID,
+			batchID:    candidate.BatchID,
+			programID:  candidate.ProgramID,
+			semesterID: candidate.SemesterID,
+			kind:       candidate.Kind,
+			dates:      make(map[string]bool),
+			seats:      candidate.Seats,
+		}
+		for _, date := range candidate.Dates {
+			routine.dates[DateKey(date)] = true
+		}
+		routines[routine.id] = routine
+	}
+
+	report := &ClashReport{}
+	projections := make(map[[2]uint]map[uint]int)
+	for _, routine := range routines {
+		if routine.seats != nil {
+			continue
+		}
+		seats, projected, err := routineCenterSeats(routine, projections)
+		if err != nil {
+			return nil, err
+		}
+		routine.seats = seats
+		if projected {
+			report.Projected = append(report.Projected, routine.id)
+		}
+	}
+	sort.Slice(report.Projected, func(i, j int) bool { return report.Projected[i] < report.Projected[j] })
+
+	capacities, names, err := centerCapacities()
+	if err != nil {
+		return nil, err
+	}
+
+	type loadKey struct {
+		date     string
+		centerID uint
+	}
+	loads := make(map[loadKey]*CenterLoad)
+	for _, routine := range routines {
+		for date := range routine.dates {
+			for centerID, seats := range routine.seats {
+				if seats == 0 {
+					continue
+				}
+				key := loadKey{date, centerID}
+				load, ok := loads[key]
+				if !ok {
+					load = &CenterLoad{Date: date, CenterID: centerID, CenterName: names[centerID], Capacity: capacities[centerID]}
+					loads[key] = load
+				}
+				load.SeatsNeeded += seats
+				load.ExamRoutineIDs = append(load.ExamRoutineIDs, routine.id)
+			}
+		}
+	}
+
+	for _, load := range loads {
+		sort.Slice(load.ExamRoutineIDs, func(i, j int) bool { return load.ExamRoutineIDs[i] < load.ExamRoutineIDs[j] })
+		load.OverCapacity = load.SeatsNeeded > load.Capacity
+		if load.OverCapacity {
+			report.Clashes++
+		}
+		report.Loads = append(report.Loads, *load)
+	}
+	sort.Slice(report.Loads, func(i, j int) bool {
+		if report.Loads[i].Date != report.Loads[j].Date {
+			return report.Loads[i].Date < report.Loads[j].Date
+		}
+		return report.Loads[i].CenterID < report.Loads[j].CenterID
+	})
+
+	return report, nil
+}
+
+// CheckCenterClashes fails with a CenterClashError when the candidate would take part in a
+// center that is over capacity. Clashes between other routines do not block the candidate.
+func CheckCenterClashes(candidate ClashCandidate) error {
+	if len(candidate.Dates) == 0 {
+		return nil
+	}
+	start, end := candidate.Dates[0], candidate.Dates[0]
+	for _, date := range candidate.Dates {
+		if date.Before(start) {
+			start = date
+		}
+		if date.After(end) {
+			end = date
+		}
+	}
+
+	report, err := AnalyzeCenterClashes(start, end, &candidate)
+	if err != nil {
+		return err
+	}
+
+	var clashes []CenterLoad
+	for _, load := range report.Loads {
+		if !load.OverCapacity {
+			continue
+		}
+		for _, id := range load.ExamRoutineIDs {
+			if id == candidate.ExamRoutineID {
+				clashes = append(clashes, load)
+				break
+			}
+		}
+	}
+	if len(clashes) > 0 {
+		return &CenterClashError{Clashes: clashes}
+	}
+	return nil
+}
+
+// CheckRoutineCenterClashes checks a stored routine against every other routine
+func CheckRoutineCenterClashes(routine models.ExamRoutine) error {
+	var schedules []models.ExamSchedules
+	if err := initializers.DB.Where("exam_routine_id = ?", routine.ID).Find(&schedules).Error; err != nil {
+		return fmt.Errorf("failed to fetch exam schedules: %w", err)
+	}
+
+	candidate := ClashCandidate{
+		ExamRoutineID: routine.ID,
+		BatchID:       routine.BatchID,
+		ProgramID:     routine.ProgramID,
+		SemesterID:    routine.SemesterID,
+		Kind:          routine.Kind,
+	}
+	for _, schedule := range schedules {
+		candidate.Dates = append(candidate.Dates, schedule.ExamDate)
+	}
+	return CheckCenterClashes(candidate)
+}
+
+// routineCenterSeats reads the seats per center from the published allocation of a routine, or
+// projects them with the solver when nothing is published yet. A regular routine seats the whole
+// cohort; a back paper routine only its registered students.
+func routineCenterSeats(routine *clashRoutine, projections map[[2]uint]map[uint]int) (map[uint]int, bool, error) {
+	if routine.id != 0 {
+		var allocation models.CenterAllocation
+		err := initializers.DB.Preload("Items").
+			Where("exam_routine_id = ? AND status = ?", routine.id, "Published").
+			First(&allocation).Error
+		if err == nil {
+			seats := make(map[uint]int)
+			for _, item := range allocation.Items {
+				seats[item.CenterID] += item.AssignedSeat
+			}
+			return seats, false, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, false, fmt.Errorf("failed to fetch center allocation: %w", err)
+		}
+	}
+
+	opts := CenterSolverOptions{RadiusKm: CenterRadiusFromEnv()}
+	if routine.kind == "back_paper" {
+		counts, err := backPaperCollegeCounts(routine.id, routine.batchID, routine.programID, routine.semesterID)
+		if err != nil {
+			return nil, false, err
+		}
+		solution, err := assignCentersWithCounts(routine.batchID, routine.programID, counts, opts)
+		if err != nil {
+			return nil, false, err
+		}
+		return solutionSeats(solution), true, nil
+	}
+
+	cohort := [2]uint{routine.batchID, routine.programID}
+	if seats, ok := projections[cohort]; ok {
+		return seats, true, nil
+	}
+	solution, err := AssignCenters(routine.batchID, routine.programID, opts)
+	if err != nil {
+		return nil, false, err
+	}
+	projections[cohort] = solutionSeats(solution)
+	return projections[cohort], true, nil
+}
+
+func solutionSeats(solution *CenterSolution) map[uint]int {
+	seats := make(map[uint]int)
+	for _, assignment := range solution.Assignments {
+		seats[assignment.CenterID] += assignment.AssignedSeat
+	}
+	return seats
+}
+
+// centerCapacities takes the largest capacity a college registered as a center with. The halls
+// are shared, so registrations for several programs do not add up.
+func centerCapacities() (map[uint]int, map[uint]string, error) {
+	var rows []models.CapacityAndCount
+	if err := initializers.DB.Preload("College").Scopes(examCenters).Find(&rows).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch center capacities: %w", err)
+	}
+
+	capacities := make(map[uint]int)
+	names := make(map[uint]string)
+	for _, row := range rows {
+		capacities[row.CollegeID] = max(capacities[row.CollegeID], row.Capacity)
+		names[row.CollegeID] = row.College.CollegeName
+	}
+
+	// Centers that are used but no longer registered still need a name in the report
+	var colleges []models.College
+	if err := initializers.DB.Select("id", "college_name").Find(&colleges).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch colleges: %w", err)
+	}
+	for _, college := range colleges {
+		if _, ok := names[college.ID]; !ok {
+			names[college.ID] = college.CollegeName
+		}
+	}
+	return capacities, names, nil
+}
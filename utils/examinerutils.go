@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mysterybee07/result-distribution-system/initializers"
+	"github.com/mysterybee07/result-distribution-system/models"
+	"gorm.io/gorm"
+)
+
+// CheckMarkEntry decides whether a user may enter or edit the marks of a course for a batch.
+// Admins may enter any course and examiners only the courses assigned to them, and nobody
+// once the entry deadline of the course has passed.
+func CheckMarkEntry(userID string, batchID, courseID uint) error {
+	if err := CheckExaminer(userID, batchID, courseID); err != nil {
+		return err
+	}
+	return CheckMarkEntryDeadline(batchID, courseID)
+}
+
+// CheckExaminer decides whether a user may handle the marks of a course for a batch at all.
+// marks:write covers every course in the user's programs, marks:write-assigned only the
+// courses the user is assigned to.
+func CheckExaminer(userID string, batchID, courseID uint) error {
+	access, err := UserAccess(userID)
+	if err != nil {
+		return err
+	}
+
+	var course models.Course
+	if err := initializers.DB.First(&course, courseID).Error; err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "Course not found")
+	}
+	if !access.AllowsProgram(course.ProgramID) {
+		return fiber.NewError(fiber.StatusForbidden, "The course is outside your programs")
+	}
+
+	switch {
+	case access.Can("marks:write"):
+	case access.Can("marks:write-assigned"):
+		var count int64
+		if err := initializers.DB.Model(&models.ExaminerAssignment{}).
+			Where("user_id = ? AND batch_id = ? AND course_id = ?", access.UserID, batchID, courseID).
+			Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to fetch examiner assignments: %w", err)
+		}
+		if count == 0 {
+			return fiber.NewError(fiber.StatusForbidden, "You are not assigned to this course for the batch")
+		}
+	default:
+		return fiber.NewError(fiber.StatusForbidden, "Only examiners and admins can enter marks")
+	}
+	return nil
+}
+
+// CheckMarkEntryDeadline fails once mark entry of a course for a batch has closed
+func CheckMarkEntryDeadline(batchID, courseID uint) error {
+	var deadline models.MarkEntryDeadline
+	err := initializers.DB.Where("batch_id = ? AND course_id = ?", batchID, courseID).First(&deadline).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to fetch mark entry deadline: %w", err)
+	}
+	if time.Now().After(deadline.ClosesAt) {
+		return fiber.NewError(fiber.StatusLocked, fmt.Sprintf("Mark entry for the course closed on %s", deadline.ClosesAt.Format("2006-01-02 15:04")))
+	}
+	return nil
+}
@@ -2,216 +2,232 @@ package utils
 
 import (
 	"fmt"
-	"math/rand"
 	"os"
+	"sort"
 	"time"
 
 	"github.com/mysterybee07/result-distribution-system/initializers"
 	"github.com/mysterybee07/result-distribution-system/models"
+	"gorm.io/gorm"
 )
 
-func ExamRoutine(batchID, programID, semesterID uint, startDate, endDate time.Time) (string, interface{}, error) {
+func ExamRoutine(req models.ExamRoutineRequest) (string, []models.ExamSchedules, *TimetableScore, error) {
+	programID, semesterID := req.ProgramID, req.SemesterID
+	startDate, endDate := req.StartDate, req.EndDate
+
 	// Check for overlapping exams within a 20-day range
 	overlapRangeStart := startDate.AddDate(0, 0, -20)
 	overlapRangeEnd := endDate.AddDate(0, 0, 20)
 
 	var overlappingExams []models.ExamRoutine
 	if err := initializers.DB.Where(
-		"program_id = ? AND (start_date BETWEEN ? AND ? OR end_date BETWEEN ? AND ?)",
-		programID, overlapRangeStart, overlapRangeEnd, overlapRangeStart, overlapRangeEnd,
+		"program_id = ? AND kind = ? AND (start_date BETWEEN ? AND ? OR end_date BETWEEN ? AND ?)",
+		programID, "regular", overlapRangeStart, overlapRangeEnd, overlapRangeStart, overlapRangeEnd,
 	).Find(&overlappingExams).Error; err != nil {
-		return "", nil, fmt.Errorf("database error: %w", err)
+		return "", nil, nil, fmt.Errorf("database error: %w", err)
 	}
 
 	if len(overlappingExams) > 0 {
-		return "", nil, fmt.Errorf("overlapping exams detected: Ensure a 20-day gap between exams for the same program")
+		return "", nil, nil, fmt.Errorf("overlapping exams detected: Ensure a 20-day gap between exams for the same program")
 	}
 
 	// Fetch courses
 	var courses []models.Course
 	if err := initializers.DB.Where("program_id = ? AND semester_id = ?", programID, semesterID).Find(&courses).Error; err != nil {
-		return "", nil, fmt.Errorf("failed to fetch courses: %w", err)
+		return "", nil, nil, fmt.Errorf("failed to fetch courses: %w", err)
 	}
 
-	if len(courses) == 0 {
-		return "", nil, fmt.Errorf("no courses found for the given program and semester")
-	}
+	return scheduleRoutine(req, "regular", courses, nil)
+}
 
-	// Separate courses into compulsory and non-compulsory
-	var compulsoryCourses, nonCompulsoryCourses []models.Course
-	for _, course := range courses {
-		if course.IsCompulsory {
-			compulsoryCourses = append(compulsoryCourses, course)
-		} else {
-			nonCompulsoryCourses = append(nonCompulsoryCourses, course)
-		}
+// scheduleRoutine places the courses in the window of the request and stores the routine, its
+// schedules and a CSV copy. afterCreate, if set, runs in the same transaction as the routine.
+func scheduleRoutine(req models.ExamRoutineRequest, kind string, courses []models.Course, afterCreate func(tx *gorm.DB, routine models.ExamRoutine) error) (string, []models.ExamSchedules, *TimetableScore, error) {
+	batchID, programID, semesterID := req.BatchID, req.ProgramID, req.SemesterID
+	startDate, endDate := req.StartDate, req.EndDate
+
+	opts, err := TimetableOptionsFromRequest(req, kind)
+	if err != nil {
+		return "", nil, nil, err
 	}
 
-	// Shuffle compulsory courses for randomness
-	compulsoryCourses = ShuffleCourses(compulsoryCourses)
+	// Students of the batch cannot sit two routines on the same day
+	taken, err := cohortExamDates(batchID, programID, startDate, endDate)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	for _, date := range taken {
+		opts.Holidays[date] = "another exam of the batch"
+	}
 
-	// Create the exam routine record
-	examRoutine := models.ExamRoutine{
-		StartDate:  startDate,
-		EndDate:    endDate,
-		BatchID:    batchID,
-		ProgramID:  programID,
-		SemesterID: semesterID,
-		Status:     false,
+	// Place the courses on exam days before anything is stored
+	slots, score, err := BuildTimetable(courses, startDate, endDate, opts)
+	if err != nil {
+		return "", nil, nil, err
 	}
 
-	if err := initializers.DB.Create(&examRoutine).Error; err != nil {
-		return "", nil, fmt.Errorf("failed to save exam routine: %w", err)
+	// Other programs may sit at the same centers on the chosen days
+	candidate := ClashCandidate{BatchID: batchID, ProgramID: programID, SemesterID: semesterID, Kind: kind}
+	for _, slot := range slots {
+		candidate.Dates = append(candidate.Dates, slot.Date)
+	}
+	if err := CheckCenterClashes(candidate); err != nil {
+		return "", nil, nil, err
 	}
 
 	fileContent := "Course Code,Course Name,Exam Date\n"
-	examSchedules := make([]models.ExamSchedules, 0)
-
-	// Schedule compulsory courses with gap logic
-	currentDate := startDate
-	gap := calculateGap(startDate, endDate, len(compulsoryCourses))
-
-	for _, course := range compulsoryCourses {
-		for isWeekend(currentDate) {
-			currentDate = currentDate.AddDate(0, 0, 1) // Skip weekends
+	examSchedules := make([]models.ExamSchedules, 0, len(courses))
+
+	err = initializers.DB.Transaction(func(tx *gorm.DB) error {
+		// Create the exam routine record
+		examRoutine := models.ExamRoutine{
+			StartDate:  startDate,
+			EndDate:    endDate,
+			BatchID:    batchID,
+			ProgramID:  programID,
+			SemesterID: semesterID,
+			Status:     false,
+			Kind:       kind,
 		}
-
-		fileContent += fmt.Sprintf("%s,%s,%s\n", course.CourseCode, course.Name, currentDate.Format("2006-01-02"))
-
-		examSchedule := models.ExamSchedules{
-			CourseID:      course.ID,
-			ExamRoutineID: examRoutine.ID,
-			ExamDate:      currentDate,
+		if err := tx.Create(&examRoutine).Error; err != nil {
+			return fmt.Errorf("failed to save exam routine: %w", err)
 		}
 
-		if err := initializers.DB.Create(&examSchedule).Error; err != nil {
-			return "", nil, fmt.Errorf("failed to save exam schedule: %w", err)
-		}
-
-		examSchedules = append(examSchedules, examSchedule)
-		currentDate = currentDate.AddDate(0, 0, gap) // Move to the next exam date
-	}
-
-	// Schedule non-compulsory courses on the last day (endDate)
-	for _, course := range nonCompulsoryCourses {
-		for isWeekend(endDate) {
-			endDate = endDate.AddDate(0, 0, -1) // Avoid weekends
-		}
-
-		fileContent += fmt.Sprintf("%s,%s,%s\n", course.CourseCode, course.Name, endDate.Format("2006-01-02"))
+		for _, slot := range slots {
+			for _, course := range slot.Courses {
+				fileContent += fmt.Sprintf("%s,%s,%s\n", course.CourseCode, course.Name, slot.Date.Format("2006-01-02"))
 
-		examSchedule := models.ExamSchedules{
-			CourseID:      course.ID,
-			ExamRoutineID: examRoutine.ID,
-			ExamDate:      endDate,
+				examSchedule := models.ExamSchedules{
+					CourseID:      course.ID,
+					ExamRoutineID: examRoutine.ID,
+					ExamDate:      slot.Date,
+				}
+				if err := tx.Create(&examSchedule).Error; err != nil {
+					return fmt.Errorf("failed to save exam schedule: %w", err)
+				}
+				examSchedules = append(examSchedules, examSchedule)
+			}
 		}
-
-		if err := initializers.DB.Create(&examSchedule).Error; err != nil {
-			return "", nil, fmt.Errorf("failed to save non-compulsory exam schedule: %w", err)
+		if afterCreate != nil {
+			return afterCreate(tx, examRoutine)
 		}
-
-		examSchedules = append(examSchedules, examSchedule)
+		return nil
+	})
+	if err != nil {
+		return "", nil, nil, err
 	}
 
 	// Save the exam routine to a CSV file
 	fileName := fmt.Sprintf("ExamRoutine_Batch%d_Program%d_Semester%d.csv", batchID, programID, semesterID)
+	if kind == "back_paper" {
+		fileName = fmt.Sprintf("BackPaperRoutine_Batch%d_Program%d_Semester%d.csv", batchID, programID, semesterID)
+	}
 
 	if err := os.WriteFile(fileName, []byte(fileContent), 0644); err != nil {
-		return "", nil, fmt.Errorf("failed to write to file: %w", err)
+		return "", nil, nil, fmt.Errorf("failed to write to file: %w", err)
 	}
 
-	return fileName, examSchedules, nil
+	return fileName, examSchedules, score, nil
 }
 
-// Helper Functions
-func calculateGap(startDate, endDate time.Time, numCourses int) int {
-	totalDays := int(endDate.Sub(startDate).Hours() / 24)
-	if numCourses == 0 {
-		return 0
+// TimetableOptionsFromRequest turns the request for a regular or back_paper routine into
+// scheduler constraints
+func TimetableOptionsFromRequest(req models.ExamRoutineRequest, kind string) (TimetableOptions, error) {
+	opts := TimetableOptions{
+		OffDays:     DefaultOffDays,
+		Holidays:    make(map[string]string),
+		MinRestDays: req.MinRestDays,
 	}
-	return totalDays / numCourses
-}
 
-// Helper function to check if a date falls on a weekend
-func isWeekend(date time.Time) bool {
-	return date.Weekday() == time.Saturday || date.Weekday() == time.Sunday
-}
-
-// shuffleCourses randomizes the course order
-func ShuffleCourses(courses []models.Course) []models.Course {
-	shuffled := make([]models.Course, len(courses))
-	copy(shuffled, courses)
-	rand.Shuffle(len(shuffled), func(i, j int) {
-		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
-	})
-	return shuffled
-}
+	if len(req.OffDays) > 0 {
+		offDays, err := ParseWeekdays(req.OffDays)
+		if err != nil {
+			return opts, err
+		}
+		opts.OffDays = offDays
+	}
 
-// calculateFitness evaluates the quality of each schedule
-func CalculateFitness(population [][]models.Course, startDate time.Time) []int {
-	fitnessScores := make([]int, len(population))
-	for i, schedule := range population {
-		score := 0
-		currentDate := startDate
-		for _, course := range schedule {
-			if course.IsCompulsory {
-				if currentDate.Weekday() == time.Saturday {
-					currentDate = currentDate.AddDate(0, 0, 1) // Skip Saturday
-				}
-				score++
-			}
-			currentDate = currentDate.AddDate(0, 0, rand.Intn(3)+1) // Add random gap
+	for _, holiday := range req.Holidays {
+		date, err := time.Parse("2006-01-02", holiday)
+		if err != nil {
+			return opts, fmt.Errorf("invalid holiday date: %s", holiday)
 		}
-		fitnessScores[i] = score
+		opts.Holidays[DateKey(date)] = "holiday"
 	}
-	return fitnessScores
-}
 
-// selectBest selects the top schedules based on fitness
-func SelectBest(population [][]models.Course, fitnessScores []int) [][]models.Course {
-	// Sort by fitness scores and select the best half
-	bestIndexes := make([]int, len(fitnessScores))
-	for i := range fitnessScores {
-		bestIndexes[i] = i
+	// Days closed by the academic calendar are never open for exams
+	closed, err := ClosedDates(req.BatchID, req.ProgramID, req.StartDate, req.EndDate)
+	if err != nil {
+		return opts, err
+	}
+	for date, title := range closed {
+		opts.Holidays[date] = title
 	}
 
-	// Sort indices based on scores
-	for i := 0; i < len(fitnessScores)-1; i++ {
-		for j := i + 1; j < len(fitnessScores); j++ {
-			if fitnessScores[i] < fitnessScores[j] {
-				bestIndexes[i], bestIndexes[j] = bestIndexes[j], bestIndexes[i]
-			}
-		}
+	if opts.MinRestDays < 0 {
+		return opts, fmt.Errorf("min_rest_days cannot be negative")
 	}
 
-	selected := make([][]models.Course, len(population)/2)
-	for i := 0; i < len(population)/2; i++ {
-		selected[i] = population[bestIndexes[i]]
+	candidate := ClashCandidate{BatchID: req.BatchID, ProgramID: req.ProgramID, SemesterID: req.SemesterID, Kind: kind}
+	opts.CenterLoad, opts.Centers, err = CenterDayLoad(candidate, req.StartDate, req.EndDate)
+	if err != nil {
+		return opts, err
 	}
-	return selected
-}
 
-// crossover combines two schedules to produce offspring
-func Crossover(parent1, parent2 []models.Course) ([]models.Course, []models.Course) {
-	crossoverPoint := rand.Intn(len(parent1))
-	child1 := append([]models.Course{}, parent1[:crossoverPoint]...)
-	child1 = append(child1, parent2[crossoverPoint:]...)
+	return opts, nil
+}
 
-	child2 := append([]models.Course{}, parent2[:crossoverPoint]...)
-	child2 = append(child2, parent1[crossoverPoint:]...)
+// cohortExamDates lists the days on which a batch of a program already sits an exam
+func cohortExamDates(batchID, programID uint, startDate, endDate time.Time) ([]string, error) {
+	var schedules []models.ExamSchedules
+	if err := initializers.DB.
+		Joins("JOIN exam_routines ON exam_routines.id = exam_schedules.exam_routine_id AND exam_routines.deleted_at IS NULL").
+		Where("exam_routines.batch_id = ? AND exam_routines.program_id = ?", batchID, programID).
+		Where("exam_schedules.exam_date >= ? AND exam_schedules.exam_date < ?", truncateToDay(startDate), truncateToDay(endDate).AddDate(0, 0, 1)).
+		Find(&schedules).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch exam schedules: %w", err)
+	}
 
-	return child1, child2
+	var dates []string
+	for _, schedule := range schedules {
+		dates = append(dates, DateKey(schedule.ExamDate))
+	}
+	return dates, nil
 }
 
-// mutate makes small random changes to a schedule
-func Mutate(schedule []models.Course) {
-	if len(schedule) > 1 {
-		i, j := rand.Intn(len(schedule)), rand.Intn(len(schedule))
-		schedule[i], schedule[j] = schedule[j], schedule[i]
+// CenterDayLoad returns the seats the existing routines need by date and center between two
+// dates, and the centers the candidate's students would sit at, from the same allocations and
+// projections the clash check uses
+func CenterDayLoad(candidate ClashCandidate, startDate, endDate time.Time) (map[string]map[uint]int, []uint, error) {
+	report, err := AnalyzeCenterClashes(startDate, endDate, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	load := make(map[string]map[uint]int)
+	for _, centerLoad := range report.Loads {
+		if load[centerLoad.Date] == nil {
+			load[centerLoad.Date] = make(map[uint]int)
+		}
+		load[centerLoad.Date][centerLoad.CenterID] = centerLoad.SeatsNeeded
+	}
+
+	seats, _, err := routineCenterSeats(&clashRoutine{
+		id:         candidate.ExamRoutineID,
+		batchID:    candidate.BatchID,
+		programID:  candidate.ProgramID,
+		semesterID: candidate.SemesterID,
+		kind:       candidate.Kind,
+	}, make(map[[2]uint]map[uint]int))
+	if err != nil {
+		return nil, nil, err
+	}
+	var centers []uint
+	for centerID, count := range seats {
+		if count > 0 {
+			centers = append(centers, centerID)
+		}
 	}
-}
+	sort.Slice(centers, func(i, j int) bool { return centers[i] < centers[j] })
 
-// getBestSchedule returns the most optimized schedule from the population
-func GetBestSchedule(population [][]models.Course) []models.Course {
-	return population[0] // Assuming population is sorted by fitness
+	return load, centers, nil
 }
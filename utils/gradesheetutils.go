@@ -0,0 +1,475 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jung-kurt/gofpdf"
+	"github.com/mysterybee07/result-distribution-system/initializers"
+	"github.com/mysterybee07/result-distribution-system/models"
+	"gorm.io/gorm"
+)
+
+// GradeSheet is the official result of one student in one published semester
+type GradeSheet struct {
+	Student      models.Student
+	Result       models.Result
+	Courses      []CourseGrade
+	Status       string // pass or fail
+	TotalMarks   int
+	FullMarks    int
+	Credits      float64
+	SGPA         float64
+	SerialNumber string
+	Hash         string
+	IssuedAt     time.Time
+}
+
+// Transcript gathers the grade sheets of every published semester of a student
+type Transcript struct {
+	Student      models.Student
+	Semesters    []GradeSheet
+	TotalCredits float64
+	CGPA         float64
+	SerialNumber string
+	Hash         string
+	IssuedAt     time.Time
+}
+
+// LoadGradeSheet builds and registers the grade sheet of a student for a published semester
+func LoadGradeSheet(studentID, semesterID uint) (*GradeSheet, error) {
+	student, err := documentStudent(studentID)
+	if err != nil {
+		return nil, err
+	}
+	result, err := publishedResult(student.BatchID, student.ProgramID, semesterID)
+	if err != nil {
+		return nil, err
+	}
+
+	sheets, err := gradeSheetsFor([]models.Student{*student}, *result)
+	if err != nil {
+		return nil, err
+	}
+	if len(sheets) == 0 {
+		return nil, fiber.NewError(fiber.StatusNotFound, "The student has no published marks in the semester")
+	}
+	if err := issueGradeSheet(&sheets[0]); err != nil {
+		return nil, err
+	}
+	return &sheets[0], nil
+}
+
+// LoadCohortGradeSheets builds and registers the grade sheets of every student with marks in a
+// published semester of a batch and program
+func LoadCohortGradeSheets(batchID, programID, semesterID uint) ([]GradeSheet, *models.Result, error) {
+	result, err := publishedResult(batchID, programID, semesterID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var studentIDs []uint
+	if err := initializers.DB.Model(&models.Mark{}).
+		Where("batch_id = ? AND program_id = ? AND semester_id = ?", batchID, programID, semesterID).
+		Distinct().Pluck("student_id", &studentIDs).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch students: %w", err)
+	}
+	var students []models.Student
+	if len(studentIDs) > 0 {
+		if err := initializers.DB.Preload("College").Preload("Program").Preload("Batch").
+			Where("id IN ?", studentIDs).Order("symbol_number").Find(&students).Error; err != nil {
+			return nil, nil, fmt.Errorf("failed to fetch students: %w", err)
+		}
+	}
+
+	sheets, err := gradeSheetsFor(students, *result)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range sheets {
+		if err := issueGradeSheet(&sheets[i]); err != nil {
+			return nil, nil, err
+		}
+	}
+	return sheets, result, nil
+}
+
+// LoadTranscript builds and registers the transcript of a student over every published semester
+func LoadTranscript(studentID uint) (*Transcript, error) {
+	student, err := documentStudent(studentID)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []models.Result
+	if err := initializers.DB.Preload("Semester").
+		Where("batch_id = ? AND program_id = ? AND status = ?", student.BatchID, student.ProgramID, "Published").
+		Find(&results).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch results: %w", err)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Semester.SemesterName < results[j].Semester.SemesterName })
+
+	transcript := &Transcript{Student: *student}
+	report := &GradeReport{StudentID: student.ID}
+	for _, result := range results {
+		sheets, err := gradeSheetsFor([]models.Student{*student}, result)
+		if err != nil {
+			return nil, err
+		}
+		if len(sheets) == 0 {
+			continue
+		}
+		transcript.Semesters = append(transcript.Semesters, sheets[0])
+		report.Courses = append(report.Courses, sheets[0].Courses...)
+	}
+	if len(transcript.Semesters) == 0 {
+		return nil, fiber.NewError(fiber.StatusNotFound, "The student has no published results")
+	}
+
+	summarizeGrades(report)
+	transcript.TotalCredits = report.TotalCredits
+	transcript.CGPA = report.CGPA
+
+	h := sha256.New()
+	fmt.Fprintf(h, "transcript|%d|%s|%s\n", student.ID, student.SymbolNumber, student.RegistrationNumber)
+	for _, sheet := range transcript.Semesters {
+		writeGradeSheetLines(h, sheet)
+	}
+	fmt.Fprintf(h, "%.2f|%.2f\n", transcript.TotalCredits, transcript.CGPA)
+	transcript.Hash = hex.EncodeToString(h.Sum(nil))
+
+	document, err := issueDocument("transcript", student.ID, nil, 0, transcript.Hash)
+	if err != nil {
+		return nil, err
+	}
+	transcript.SerialNumber = document.SerialNumber
+	transcript.IssuedAt = document.IssuedAt
+	return transcript, nil
+}
+
+// GradeSheetZipPath is where the grade sheets of a published semester are bundled
+func GradeSheetZipPath(result models.Result) string {
+	return filepath.Join("data", "grade_sheets", fmt.Sprintf("batch_%d", result.BatchID), fmt.Sprintf("program_%d", result.ProgramID),
+		fmt.Sprintf("semester_%d_revision_%d.zip", result.SemesterID, result.Revision))
+}
+
+// WriteCohortGradeSheets renders the grade sheet of every student of a published semester into
+// one zip and returns its path and the number of sheets
+func WriteCohortGradeSheets(batchID, programID, semesterID uint) (string, int, error) {
+	sheets, result, err := LoadCohortGradeSheets(batchID, programID, semesterID)
+	if err != nil {
+		return "", 0, err
+	}
+	if len(sheets) == 0 {
+		return "", 0, fiber.NewError(fiber.StatusNotFound, "No student has published marks in the semester")
+	}
+
+	var entries []ZipEntry
+	for _, sheet := range sheets {
+		content, err := RenderGradeSheetsPDF([]GradeSheet{sheet})
+		if err != nil {
+			return "", 0, fmt.Errorf("student %s: %w", sheet.Student.SymbolNumber, err)
+		}
+		entries = append(entries, ZipEntry{
+			Name:    SanitizeFileName(sheet.Student.SymbolNumber) + ".pdf",
+			Content: content,
+		})
+	}
+
+	path := GradeSheetZipPath(*result)
+	if err := WriteZip(path, entries); err != nil {
+		return "", 0, err
+	}
+	return path, len(entries), nil
+}
+
+// RenderGradeSheetsPDF renders one page per grade sheet
+func RenderGradeSheetsPDF(sheets []GradeSheet) ([]byte, error) {
+	pdf := newDocumentPDF("GRADE SHEET")
+
+	for _, sheet := range sheets {
+		pdf.AddPage()
+		drawDocumentDetails(pdf, sheet.Student, sheet.SerialNumber, [2]string{"Semester", fmt.Sprint(sheet.Result.Semester.SemesterName)})
+
+		pdf.Ln(4)
+		drawCourseTable(pdf, sheet.Courses)
+
+		pdf.Ln(4)
+		pdf.SetFont("Helvetica", "B", 10)
+		pdf.CellFormat(60, 7, fmt.Sprintf("Total: %d / %d", sheet.TotalMarks, sheet.FullMarks), "", 0, "L", false, 0, "")
+		pdf.CellFormat(60, 7, fmt.Sprintf("SGPA: %.2f", sheet.SGPA), "", 0, "L", false, 0, "")
+		pdf.CellFormat(60, 7, "Result: "+resultLabel(sheet.Status), "", 1, "L", false, 0, "")
+
+		verifyURL := DocumentVerifyURL(GradeSheetClaims(sheet))
+		if err := drawDocumentFooter(pdf, sheet.SerialNumber, verifyURL, sheet.Hash, sheet.IssuedAt, sheet.Result.Revision); err != nil {
+			return nil, err
+		}
+	}
+
+	return pdfBytes(pdf)
+}
+
+// RenderTranscriptPDF renders the transcript with one table per semester
+func RenderTranscriptPDF(transcript Transcript) ([]byte, error) {
+	pdf := newDocumentPDF("ACADEMIC TRANSCRIPT")
+	pdf.AddPage()
+	drawDocumentDetails(pdf, transcript.Student, transcript.SerialNumber, [2]string{"Semesters", fmt.Sprint(len(transcript.Semesters))})
+
+	for _, sheet := range transcript.Semesters {
+		pdf.Ln(4)
+		pdf.SetFont("Helvetica", "B", 11)
+		pdf.CellFormat(0, 7, fmt.Sprintf("Semester %d", sheet.Result.Semester.SemesterName), "", 1, "L", false, 0, "")
+		drawCourseTable(pdf, sheet.Courses)
+		pdf.SetFont("Helvetica", "", 9)
+		pdf.CellFormat(0, 6, fmt.Sprintf("Total: %d / %d    Credits: %g    SGPA: %.2f    Result: %s",
+			sheet.TotalMarks, sheet.FullMarks, sheet.Credits, sheet.SGPA, resultLabel(sheet.Status)), "", 1, "L", false, 0, "")
+	}
+
+	pdf.Ln(4)
+	pdf.SetFont("Helvetica", "B", 11)
+	pdf.CellFormat(90, 7, fmt.Sprintf("Total Credits: %g", transcript.TotalCredits), "", 0, "L", false, 0, "")
+	pdf.CellFormat(90, 7, fmt.Sprintf("CGPA: %.2f", transcript.CGPA), "", 1, "L", false, 0, "")
+
+	verifyURL := DocumentVerifyURL(TranscriptClaims(transcript))
+	if err := drawDocumentFooter(pdf, transcript.SerialNumber, verifyURL, transcript.Hash, transcript.IssuedAt, 0); err != nil {
+		return nil, err
+	}
+	return pdfBytes(pdf)
+}
+
+func drawDocumentDetails(pdf *gofpdf.Fpdf, student models.Student, serialNumber string, extra [2]string) {
+	details := [][2]string{
+		{"Name", student.Fullname},
+		{"Symbol Number", student.SymbolNumber},
+		{"Registration Number", student.RegistrationNumber},
+		{"College", student.College.CollegeName},
+		{"Program", student.Program.ProgramName},
+		{"Batch", fmt.Sprint(student.Batch.Batch)},
+		extra,
+		{"Serial Number", serialNumber},
+	}
+	for _, detail := range details {
+		pdf.SetFont("Helvetica", "B", 10)
+		pdf.CellFormat(45, 6, detail[0], "", 0, "L", false, 0, "")
+		pdf.SetFont("Helvetica", "", 10)
+		pdf.CellFormat(135, 6, detail[1], "", 1, "L", false, 0, "")
+	}
+}
+
+func drawCourseTable(pdf *gofpdf.Fpdf, courses []CourseGrade) {
+	widths := []float64{22, 54, 12, 16, 16, 16, 16, 14, 14}
+	headers := []string{"Code", "Course", "Credit", "Semester", "Practical", "Assistant", "Total", "Grade", "Result"}
+
+	pdf.SetFont("Helvetica", "B", 8)
+	pdf.SetFillColor(230, 230, 230)
+	for i, header := range headers {
+		pdf.CellFormat(widths[i], 7, header, "1", 0, "C", true, 0, "")
+	}
+	pdf.Ln(-1)
+
+	pdf.SetFont("Helvetica", "", 8)
+	for _, course := range courses {
+		row := []string{
+			course.CourseCode,
+			course.CourseName,
+			fmt.Sprintf("%g", course.CreditHours),
+			fmt.Sprint(course.SemesterMarks),
+			fmt.Sprint(course.PracticalMarks),
+			fmt.Sprint(course.AssistantMarks),
+			fmt.Sprintf("%d/%d", course.TotalMarks, course.FullMarks),
+			course.Letter,
+			resultLabel(course.Status),
+		}
+		for i, value := range row {
+			align := "C"
+			if i == 1 {
+				align = "L"
+			}
+			pdf.CellFormat(widths[i], 6, value, "1", 0, align, false, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+}
+
+// drawDocumentFooter prints the QR code anyone can scan to verify the document, next to its
+// issue date and hash, and the signature line
+func drawDocumentFooter(pdf *gofpdf.Fpdf, serialNumber, verifyURL, documentHash string, issuedAt time.Time, revision int) error {
+	pdf.Ln(8)
+	_, pageHeight := pdf.GetPageSize()
+	_, _, _, bottom := pdf.GetMargins()
+	if pdf.GetY()+40 > pageHeight-bottom {
+		pdf.AddPage()
+	}
+
+	top := pdf.GetY()
+	if err := addQRCode(pdf, "verify-"+serialNumber, verifyURL, 15, top, 32); err != nil {
+		return err
+	}
+
+	pdf.SetXY(52, top)
+	pdf.SetFont("Helvetica", "", 8)
+	note := "Issued on " + issuedAt.Format("2006-01-02")
+	if revision > 1 {
+		note += fmt.Sprintf(", result revision %d", revision)
+	}
+	pdf.MultiCell(143, 4, note+"\nVerification hash: "+documentHash+"\nScan the QR code or visit "+AppURL()+"/verify to check this document", "", "L", false)
+
+	pdf.SetXY(140, top+28)
+	pdf.CellFormat(55, 5, "Controller of Examinations", "T", 1, "C", false, 0, "")
+	return nil
+}
+
+func resultLabel(status string) string {
+	if status == "pass" {
+		return "Pass"
+	}
+	return "Fail"
+}
+
+func documentStudent(studentID uint) (*models.Student, error) {
+	var student models.Student
+	if err := initializers.DB.Preload("College").Preload("Program").Preload("Batch").First(&student, studentID).Error; err != nil {
+		return nil, fiber.NewError(fiber.StatusNotFound, "Student not found")
+	}
+	return &student, nil
+}
+
+func publishedResult(batchID, programID, semesterID uint) (*models.Result, error) {
+	var result models.Result
+	if err := initializers.DB.Preload("Semester").
+		Where("batch_id = ? AND program_id = ? AND semester_id = ? AND status = ?", batchID, programID, semesterID, "Published").
+		First(&result).Error; err != nil {
+		return nil, fiber.NewError(fiber.StatusNotFound, "No published result for the given semester with batch and program")
+	}
+	return &result, nil
+}
+
+// gradeSheetsFor grades the students in a published result. Back paper marks recorded after the
+// latest publication are left out until the result is revised with them.
+func gradeSheetsFor(students []models.Student, result models.Result) ([]GradeSheet, error) {
+	if len(students) == 0 {
+		return nil, nil
+	}
+
+	publishedAt := time.Now()
+	var snapshot models.ResultSnapshot
+	err := initializers.DB.Where("result_id = ? AND action <> ?", result.ID, "withdrawn").Order("version desc").First(&snapshot).Error
+	if err == nil {
+		publishedAt = snapshot.CreatedAt
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to fetch result snapshot: %w", err)
+	}
+
+	var studentIDs []uint
+	for _, student := range students {
+		studentIDs = append(studentIDs, student.ID)
+	}
+	var marks []models.Mark
+	if err := initializers.DB.Preload("Course").
+		Where("batch_id = ? AND program_id = ? AND semester_id = ? AND student_id IN ? AND created_at <= ?",
+			result.BatchID, result.ProgramID, result.SemesterID, studentIDs, publishedAt).
+		Find(&marks).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch marks: %w", err)
+	}
+	reports, err := BuildGradeReports(marks)
+	if err != nil {
+		return nil, err
+	}
+
+	var sheets []GradeSheet
+	for _, student := range students {
+		report, ok := reports[student.ID]
+		if !ok {
+			continue
+		}
+		sheet := GradeSheet{
+			Student: student,
+			Result:  result,
+			Courses: report.Courses,
+			Status:  "pass",
+			SGPA:    report.SGPA(result.SemesterID),
+		}
+		for _, course := range report.Courses {
+			sheet.TotalMarks += course.TotalMarks
+			sheet.FullMarks += course.FullMarks
+			sheet.Credits += course.CreditHours
+			if course.Status != "pass" {
+				sheet.Status = "fail"
+			}
+		}
+		sheets = append(sheets, sheet)
+	}
+	return sheets, nil
+}
+
+func issueGradeSheet(sheet *GradeSheet) error {
+	h := sha256.New()
+	fmt.Fprintf(h, "grade_sheet|%d|%s|%s\n", sheet.Student.ID, sheet.Student.SymbolNumber, sheet.Student.RegistrationNumber)
+	writeGradeSheetLines(h, *sheet)
+	sheet.Hash = hex.EncodeToString(h.Sum(nil))
+
+	semesterID := sheet.Result.SemesterID
+	document, err := issueDocument("grade_sheet", sheet.Student.ID, &semesterID, sheet.Result.Revision, sheet.Hash)
+	if err != nil {
+		return err
+	}
+	sheet.SerialNumber = document.SerialNumber
+	sheet.IssuedAt = document.IssuedAt
+	return nil
+}
+
+// writeGradeSheetLines feeds everything printed about a semester into a document hash
+func writeGradeSheetLines(h hash.Hash, sheet GradeSheet) {
+	fmt.Fprintf(h, "semester|%d|%d\n", sheet.Result.SemesterID, sheet.Result.Revision)
+	for _, course := range sheet.Courses {
+		fmt.Fprintf(h, "%s|%g|%d|%d|%d|%d|%d|%s|%.2f|%s\n", course.CourseCode, course.CreditHours, course.SemesterMarks,
+			course.PracticalMarks, course.AssistantMarks, course.TotalMarks, course.FullMarks, course.Letter, course.GradePoint, course.Status)
+	}
+	fmt.Fprintf(h, "%s|%d|%d|%.2f\n", sheet.Status, sheet.TotalMarks, sheet.FullMarks, sheet.SGPA)
+}
+
+// issueDocument registers a document, or returns the earlier registration when the same content
+// was already issued, so printing a copy again keeps its serial number
+func issueDocument(kind string, studentID uint, semesterID *uint, revision int, documentHash string) (*models.IssuedDocument, error) {
+	var document models.IssuedDocument
+	err := initializers.DB.Where("kind = ? AND student_id = ? AND hash = ?", kind, studentID, documentHash).First(&document).Error
+	if err == nil {
+		return &document, nil
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to fetch issued document: %w", err)
+	}
+
+	prefix := "GS"
+	if kind == "transcript" {
+		prefix = "TR"
+	}
+	now := time.Now()
+	err = initializers.DB.Transaction(func(tx *gorm.DB) error {
+		// The serial number is taken from the row ID, so it is set once the row exists
+		document = models.IssuedDocument{
+			SerialNumber: fmt.Sprintf("TMP-%d", now.UnixNano()),
+			Kind:         kind,
+			StudentID:    studentID,
+			SemesterID:   semesterID,
+			Revision:     revision,
+			Hash:         documentHash,
+			IssuedAt:     now,
+		}
+		if err := tx.Create(&document).Error; err != nil {
+			return err
+		}
+		document.SerialNumber = fmt.Sprintf("%s-%d-%06d", prefix, now.Year(), document.ID)
+		return tx.Model(&document).Update("serial_number", document.SerialNumber).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to register document: %w", err)
+	}
+	return &document, nil
+}
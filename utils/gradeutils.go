@@ -0,0 +1,328 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mysterybee07/result-distribution-system/initializers"
+	"github.com/mysterybee07/result-distribution-system/models"
+	"gorm.io/gorm"
+)
+
+// DefaultGradeBands are used when neither the program nor the institution has a grading scheme
+var DefaultGradeBands = []models.GradeBand{
+	{MinPercentage: 90, Letter: "A", GradePoint: 4.0},
+	{MinPercentage: 80, Letter: "A-", GradePoint: 3.7},
+	{MinPercentage: 70, Letter: "B+", GradePoint: 3.3},
+	{MinPercentage: 60, Letter: "B", GradePoint: 3.0},
+	{MinPercentage: 50, Letter: "B-", GradePoint: 2.7},
+	{MinPercentage: 40, Letter: "C", GradePoint: 2.0},
+}
+
+// CourseGrade is the counted result of one course graded with the program's scheme
+type CourseGrade struct {
+	CourseID       uint    `json:"course_id"`
+	CourseCode     string  `json:"course_code"`
+	CourseName     string  `json:"course_name"`
+	SemesterID     uint    `json:"semester_id"`
+	Attempt        int     `json:"attempt"`
+	CreditHours    float64 `json:"credit_hours"`
+	SemesterMarks  int     `json:"semester_marks"`
+	PracticalMarks int     `json:"practical_marks"`
+	AssistantMarks int     `json:"assistant_marks"`
+	TotalMarks     int     `json:"total_marks"`
+	FullMarks      int     `json:"full_marks"`
+	Percentage     float64 `json:"percentage"`
+	Letter         string  `json:"letter"`
+	GradePoint     float64 `json:"grade_point"`
+	Status         string  `json:"status"`
+}
+
+// SemesterGPA is the credit weighted grade point average of one semester
+type SemesterGPA struct {
+	SemesterID    uint    `json:"semester_id"`
+	Credits       float64 `json:"credits"`
+	EarnedCredits float64 `json:"earned_credits"` // Credits of the courses that were passed
+	SGPA          float64 `json:"sgpa"`
+}
+
+// GradeReport holds the grades of one student over every semester found in the marks
+type GradeReport struct {
+	StudentID    uint          `json:"student_id"`
+	Scheme       string        `json:"grading_scheme"`
+	Courses      []CourseGrade `json:"courses"`
+	Semesters    []SemesterGPA `json:"semesters"`
+	TotalCredits float64       `json:"total_credits"`
+	CGPA         float64       `json:"cgpa"`
+}
+
+// SGPA returns the grade point average of a semester, or zero when the report has none
+func (r *GradeReport) SGPA(semesterID uint) float64 {
+	for _, semester := range r.Semesters {
+		if semester.SemesterID == semesterID {
+			return semester.SGPA
+		}
+	}
+	return 0
+}
+
+// ProgramGradingScheme returns the scheme of a program, the default scheme, or the built-in bands
+func ProgramGradingScheme(programID uint) (models.GradingScheme, error) {
+	var program models.Program
+	if err := initializers.DB.First(&program, programID).Error; err != nil {
+		return models.GradingScheme{}, fmt.Errorf("failed to fetch program: %w", err)
+	}
+
+	var scheme models.GradingScheme
+	query := initializers.DB.Preload("Bands")
+	var err error
+	if program.GradingSchemeID != nil {
+		err = query.First(&scheme, *program.GradingSchemeID).Error
+	} else {
+		err = query.Where("is_default = ?", true).First(&scheme).Error
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		scheme = models.GradingScheme{Name: "Default", FailLetter: "F", Bands: DefaultGradeBands}
+	} else if err != nil {
+		return models.GradingScheme{}, fmt.Errorf("failed to fetch grading scheme: %w", err)
+	}
+
+	sort.Slice(scheme.Bands, func(i, j int) bool { return scheme.Bands[i].MinPercentage > scheme.Bands[j].MinPercentage })
+	return scheme, nil
+}
+
+// CourseFullMarks adds up the theory, practical and assistant full marks of a course
+func CourseFullMarks(course models.Course) int {
+	full := course.SemesterTotalMarks
+	if course.PracticalTotalMarks != nil {
+		full += *course.PracticalTotalMarks
+	}
+	if course.AssistantTotalMarks != nil {
+		full += *course.AssistantTotalMarks
+	}
+	return full
+}
+
+// GradeCourse grades a mark whose Course is loaded. A failed course always gets the fail letter,
+// and a passed course at least the lowest band, even when the course's pass marks are below it.
+func GradeCourse(mark models.Mark, scheme models.GradingScheme) CourseGrade {
+	grade := CourseGrade{
+		CourseID:       mark.CourseID,
+		CourseCode:     mark.Course.CourseCode,
+		CourseName:     mark.Course.Name,
+		SemesterID:     mark.SemesterID,
+		Attempt:        mark.Attempt,
+		CreditHours:    mark.Course.CreditHours,
+		SemesterMarks:  mark.SemesterMarks,
+		PracticalMarks: mark.PracticalMarks,
+		AssistantMarks: mark.AssistantMarks,
+		TotalMarks:     mark.TotalMarks,
+		FullMarks:      CourseFullMarks(mark.Course),
+		Letter:         scheme.FailLetter,
+		Status:         mark.Status,
+	}
+	if grade.FullMarks > 0 {
+		grade.Percentage = math.Round(float64(mark.TotalMarks)/float64(grade.FullMarks)*10000) / 100
+	}
+	if mark.Status != "pass" {
+		return grade
+	}
+
+	var lowest *models.GradeBand
+	for i, band := range scheme.Bands {
+		if grade.Percentage >= band.MinPercentage {
+			grade.Letter = band.Letter
+			grade.GradePoint = band.GradePoint
+			return grade
+		}
+		if lowest == nil || band.MinPercentage < lowest.MinPercentage {
+			lowest = &scheme.Bands[i]
+		}
+	}
+	if lowest != nil {
+		grade.Letter = lowest.Letter
+		grade.GradePoint = lowest.GradePoint
+	}
+	return grade
+}
+
+// BuildGradeReports grades the counted attempts of the marks, which must have Course loaded, and
+// returns one report per student. Failed courses count towards credits with zero grade points.
+func BuildGradeReports(marks []models.Mark) (map[uint]*GradeReport, error) {
+	counted, err := CountedMarks(marks)
+	if err != nil {
+		return nil, err
+	}
+
+	schemes := make(map[uint]models.GradingScheme)
+	reports := make(map[uint]*GradeReport)
+	for _, mark := range counted {
+		scheme, ok := schemes[mark.ProgramID]
+		if !ok {
+			if scheme, err = ProgramGradingScheme(mark.ProgramID); err != nil {
+				return nil, err
+			}
+			schemes[mark.ProgramID] = scheme
+		}
+
+		report, ok := reports[mark.StudentID]
+		if !ok {
+			report = &GradeReport{StudentID: mark.StudentID, Scheme: scheme.Name}
+			reports[mark.StudentID] = report
+		}
+		report.Courses = append(report.Courses, GradeCourse(mark, scheme))
+	}
+
+	for _, report := range reports {
+		summarizeGrades(report)
+	}
+	return reports, nil
+}
+
+// StudentGradeReport grades every counted course of one student
+func StudentGradeReport(studentID uint) (*GradeReport, error) {
+	var marks []models.Mark
+	if err := initializers.DB.Preload("Course").Where("student_id = ?", studentID).Find(&marks).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch marks: %w", err)
+	}
+
+	reports, err := BuildGradeReports(marks)
+	if err != nil {
+		return nil, err
+	}
+	if report, ok := reports[studentID]; ok {
+		return report, nil
+	}
+	return &GradeReport{StudentID: studentID}, nil
+}
+
+func summarizeGrades(report *GradeReport) {
+	sort.Slice(report.Courses, func(i, j int) bool {
+		if report.Courses[i].SemesterID != report.Courses[j].SemesterID {
+			return report.Courses[i].SemesterID < report.Courses[j].SemesterID
+		}
+		return report.Courses[i].CourseCode < report.Courses[j].CourseCode
+	})
+
+	bySemester := make(map[uint]*SemesterGPA)
+	points := make(map[uint]float64)
+	var semesterIDs []uint
+	totalPoints := 0.0
+	report.TotalCredits = 0
+	for _, course := range report.Courses {
+		semester, ok := bySemester[course.SemesterID]
+		if !ok {
+			semester = &SemesterGPA{SemesterID: course.SemesterID}
+			bySemester[course.SemesterID] = semester
+			semesterIDs = append(semesterIDs, course.SemesterID)
+		}
+		semester.Credits += course.CreditHours
+		if course.Status == "pass" {
+			semester.EarnedCredits += course.CreditHours
+		}
+		points[course.SemesterID] += course.CreditHours * course.GradePoint
+
+		report.TotalCredits += course.CreditHours
+		totalPoints += course.CreditHours * course.GradePoint
+	}
+
+	report.Semesters = nil
+	for _, semesterID := range semesterIDs {
+		semester := bySemester[semesterID]
+		if semester.Credits > 0 {
+			semester.SGPA = roundGPA(points[semesterID] / semester.Credits)
+		}
+		report.Semesters = append(report.Semesters, *semester)
+	}
+	if report.TotalCredits > 0 {
+		report.CGPA = roundGPA(totalPoints / report.TotalCredits)
+	}
+}
+
+func roundGPA(value float64) float64 {
+	return math.Round(value*100) / 100
+}
+
+// ResultGrade is the line of one student in a published semester result
+type ResultGrade struct {
+	StudentID    uint          `json:"student_id"`
+	SymbolNumber string        `json:"symbol_number"`
+	Fullname     string        `json:"fullname"`
+	Status       string        `json:"status"` // pass when every course of the semester was passed
+	TotalMarks   int           `json:"total_marks"`
+	SGPA         float64       `json:"sgpa"`
+	CGPA         float64       `json:"cgpa"` // Over this semester and the ones before it
+	Courses      []CourseGrade `json:"courses"`
+}
+
+// ResultGrades grades every student of a published semester result
+func ResultGrades(batchID, programID, semesterID uint) ([]ResultGrade, error) {
+	var result models.Result
+	if err := initializers.DB.Where("batch_id = ? AND program_id = ? AND semester_id = ? AND status = ?", batchID, programID, semesterID, "Published").
+		First(&result).Error; err != nil {
+		return nil, fiber.NewError(fiber.StatusNotFound, "No published result for the given semester with batch and program")
+	}
+	return cohortGrades(initializers.DB, batchID, programID, semesterID)
+}
+
+// cohortGrades grades the students of a batch and program who have marks in a semester. The marks
+// are read through db so a transaction sees its own changes.
+func cohortGrades(db *gorm.DB, batchID, programID, semesterID uint) ([]ResultGrade, error) {
+	// The CGPA of a semester result only covers the semesters up to it
+	var semester models.Semester
+	if err := db.First(&semester, semesterID).Error; err != nil {
+		return nil, fiber.NewError(fiber.StatusNotFound, "Semester not found")
+	}
+	var semesterIDs []uint
+	if err := db.Model(&models.Semester{}).
+		Where("program_id = ? AND semester_name <= ?", programID, semester.SemesterName).
+		Pluck("id", &semesterIDs).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch semesters: %w", err)
+	}
+
+	var marks []models.Mark
+	if err := db.Preload("Course").Preload("Student").
+		Where("batch_id = ? AND program_id = ? AND semester_id IN ?", batchID, programID, semesterIDs).
+		Find(&marks).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch marks: %w", err)
+	}
+	students := make(map[uint]models.Student)
+	for _, mark := range marks {
+		students[mark.StudentID] = mark.Student
+	}
+
+	reports, err := BuildGradeReports(marks)
+	if err != nil {
+		return nil, err
+	}
+
+	var grades []ResultGrade
+	for studentID, report := range reports {
+		line := ResultGrade{
+			StudentID:    studentID,
+			SymbolNumber: students[studentID].SymbolNumber,
+			Fullname:     students[studentID].Fullname,
+			Status:       "pass",
+			SGPA:         report.SGPA(semesterID),
+			CGPA:         report.CGPA,
+		}
+		for _, course := range report.Courses {
+			if course.SemesterID != semesterID {
+				continue
+			}
+			line.Courses = append(line.Courses, course)
+			line.TotalMarks += course.TotalMarks
+			if course.Status != "pass" {
+				line.Status = "fail"
+			}
+		}
+		if len(line.Courses) > 0 {
+			grades = append(grades, line)
+		}
+	}
+	sort.Slice(grades, func(i, j int) bool { return grades[i].SymbolNumber < grades[j].SymbolNumber })
+	return grades, nil
+}
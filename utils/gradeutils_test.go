@@ -0,0 +1,118 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/mysterybee07/result-distribution-system/models"
+)
+
+// A course that passes at 32 of 100 while the lowest default band starts at 40 percent
+var lowPassCourse = models.Course{CourseCode: "MTH101", SemesterPassMarks: 32, SemesterTotalMarks: 100, CreditHours: 3}
+
+func TestGradeCourse(t *testing.T) {
+	scheme := models.GradingScheme{Name: "Default", FailLetter: "F", Bands: DefaultGradeBands}
+
+	tests := []struct {
+		name   string
+		total  int
+		status string
+		letter string
+		point  float64
+	}{
+		{name: "pass below the lowest band gets the lowest band", total: 35, status: "pass", letter: "C", point: 2.0},
+		{name: "pass on a band boundary gets that band", total: 80, status: "pass", letter: "A-", point: 3.7},
+		{name: "pass at full marks gets the top band", total: 100, status: "pass", letter: "A", point: 4.0},
+		{name: "fail gets the fail letter", total: 20, status: "failed", letter: "F", point: 0},
+		{name: "fail inside a band still gets the fail letter", total: 75, status: "failed", letter: "F", point: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mark := models.Mark{SemesterMarks: tt.total, TotalMarks: tt.total, Status: tt.status, Attempt: 1, Course: lowPassCourse}
+			grade := GradeCourse(mark, scheme)
+			if grade.Letter != tt.letter || grade.GradePoint != tt.point {
+				t.Errorf("got %s (%.1f), want %s (%.1f)", grade.Letter, grade.GradePoint, tt.letter, tt.point)
+			}
+			if grade.Percentage != float64(tt.total) {
+				t.Errorf("Percentage = %.2f, want %d", grade.Percentage, tt.total)
+			}
+		})
+	}
+}
+
+func TestGradeCourseLowestBandOutOfOrder(t *testing.T) {
+	// Bands are only sorted by ProgramGradingScheme, so the lowest one is found wherever it is
+	scheme := models.GradingScheme{FailLetter: "F", Bands: []models.GradeBand{
+		{MinPercentage: 50, Letter: "B", GradePoint: 3.0},
+		{MinPercentage: 45, Letter: "D", GradePoint: 1.0},
+		{MinPercentage: 80, Letter: "A", GradePoint: 4.0},
+	}}
+	mark := models.Mark{SemesterMarks: 33, TotalMarks: 33, Status: "pass", Course: lowPassCourse}
+	if grade := GradeCourse(mark, scheme); grade.Letter != "D" || grade.GradePoint != 1.0 {
+		t.Errorf("got %s (%.1f), want D (1.0)", grade.Letter, grade.GradePoint)
+	}
+}
+
+func TestSummarizeGrades(t *testing.T) {
+	scheme := models.GradingScheme{FailLetter: "F", Bands: DefaultGradeBands}
+	grade := func(semesterID uint, code string, credits float64, total int, status string) CourseGrade {
+		course := lowPassCourse
+		course.CourseCode, course.CreditHours = code, credits
+		return GradeCourse(models.Mark{SemesterID: semesterID, SemesterMarks: total, TotalMarks: total, Status: status, Course: course}, scheme)
+	}
+
+	tests := []struct {
+		name    string
+		courses []CourseGrade
+		want    []SemesterGPA
+		credits float64
+		cgpa    float64
+	}{
+		{
+			name: "low passes count with the lowest band",
+			courses: []CourseGrade{
+				grade(1, "MTH101", 3, 35, "pass"),
+				grade(1, "PHY101", 3, 92, "pass"),
+			},
+			want:    []SemesterGPA{{SemesterID: 1, Credits: 6, EarnedCredits: 6, SGPA: 3.0}},
+			credits: 6,
+			cgpa:    3.0,
+		},
+		{
+			name: "failed courses carry credits without points or earned credits",
+			courses: []CourseGrade{
+				grade(2, "CSC201", 4, 20, "failed"),
+				grade(1, "MTH101", 2, 35, "pass"),
+				grade(2, "CSC202", 2, 85, "pass"),
+			},
+			want: []SemesterGPA{
+				{SemesterID: 1, Credits: 2, EarnedCredits: 2, SGPA: 2.0},
+				{SemesterID: 2, Credits: 6, EarnedCredits: 2, SGPA: 1.23},
+			},
+			credits: 8,
+			cgpa:    1.43,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := &GradeReport{Courses: tt.courses}
+			summarizeGrades(report)
+
+			if len(report.Semesters) != len(tt.want) {
+				t.Fatalf("got %d semesters, want %d", len(report.Semesters), len(tt.want))
+			}
+			for i, want := range tt.want {
+				if report.Semesters[i] != want {
+					t.Errorf("semester %d = %+v, want %+v", want.SemesterID, report.Semesters[i], want)
+				}
+			}
+			if report.TotalCredits != tt.credits {
+				t.Errorf("TotalCredits = %.1f, want %.1f", report.TotalCredits, tt.credits)
+			}
+			if report.CGPA != tt.cgpa {
+				t.Errorf("CGPA = %.2f, want %.2f", report.CGPA, tt.cgpa)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"crypto/rand"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// devKeyDir keeps the keys made up in development mode, outside version control
+const devKeyDir = ".dev-keys"
+
+// DevelopmentMode reports whether APP_ENV is development. Only then may the server make up keys
+// and settings a deployment has to configure.
+func DevelopmentMode() bool {
+	return os.Getenv("APP_ENV") == "development"
+}
+
+// developmentKey returns the random key stored under name in .dev-keys, creating it the first
+// time, so development keys survive restarts without anyone being able to read them in the source
+func developmentKey(name string, size int) ([]byte, error) {
+	path := filepath.Join(devKeyDir, name)
+	if key, err := os.ReadFile(path); err == nil {
+		if len(key) != size {
+			return nil, fmt.Errorf("the development key %s has %d bytes, want %d; delete it to make a new one", path, len(key), size)
+		}
+		return key, nil
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read development key: %w", err)
+	}
+
+	key := make([]byte, size)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate development key: %w", err)
+	}
+	if err := os.MkdirAll(devKeyDir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create development key directory: %w", err)
+	}
+	if err := os.WriteFile(path, key, 0o600); err != nil {
+		return nil, fmt.Errorf("failed to save development key: %w", err)
+	}
+	return key, nil
+}
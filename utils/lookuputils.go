@@ -0,0 +1,278 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mysterybee07/result-distribution-system/initializers"
+	"github.com/mysterybee07/result-distribution-system/models"
+	"gorm.io/gorm"
+)
+
+// PublicCourseResult is one course as a published result shows it to the student
+type PublicCourseResult struct {
+	CourseCode     string  `json:"course_code"`
+	CourseName     string  `json:"course_name"`
+	CreditHours    float64 `json:"credit_hours"`
+	SemesterMarks  int     `json:"semester_marks"`
+	PracticalMarks int     `json:"practical_marks"`
+	AssistantMarks int     `json:"assistant_marks"`
+	TotalMarks     int     `json:"total_marks"`
+	FullMarks      int     `json:"full_marks"`
+	Letter         string  `json:"letter"`
+	GradePoint     float64 `json:"grade_point"`
+	Status         string  `json:"status"`
+}
+
+// PublicSemesterResult is the published result of one semester
+type PublicSemesterResult struct {
+	SemesterID   uint                 `json:"semester_id"`
+	SemesterName uint                 `json:"semester_name"`
+	Revision     int                  `json:"revision"`
+	PublishedAt  time.Time            `json:"published_at"`
+	Status       string               `json:"status"`
+	TotalMarks   int                  `json:"total_marks"`
+	SGPA         float64              `json:"sgpa"`
+	CGPA         float64              `json:"cgpa"`
+	Courses      []PublicCourseResult `json:"courses"`
+}
+
+// PublicLookup is everything a student sees when looking up their results
+type PublicLookup struct {
+	SymbolNumber string                 `json:"symbol_number"`
+	Fullname     string                 `json:"fullname"`
+	Program      string                 `json:"program"`
+	Batch        string                 `json:"batch"`
+	Semesters    []PublicSemesterResult `json:"semesters"`
+}
+
+// ErrLookupMismatch hides whether the symbol number or the second detail was wrong
+var ErrLookupMismatch = fiber.NewError(fiber.StatusNotFound, "No published result matches the given details")
+
+type cachedLookup struct {
+	studentID          uint
+	batchID, programID uint
+	registrationNumber string
+	dateOfBirth        string
+	lookup             *PublicLookup
+	expires            time.Time
+}
+
+// publicResults caches stored transcripts by symbol number in front of the database. Symbol numbers are only unique per
+// batch and program, so one key can hold several students. Misses are not cached, so the cache
+// never holds more than the stored transcripts.
+var publicResults = struct {
+	sync.RWMutex
+	entries map[string][]cachedLookup
+}{entries: make(map[string][]cachedLookup)}
+
+// PublicResultCacheTTL reads PUBLIC_RESULT_CACHE_TTL in minutes, 30 by default. Changes refresh
+// the cache right away; the expiry only catches changes made by other instances.
+func PublicResultCacheTTL() time.Duration {
+	if minutes, err := strconv.Atoi(os.Getenv("PUBLIC_RESULT_CACHE_TTL")); err == nil && minutes > 0 {
+		return time.Duration(minutes) * time.Minute
+	}
+	return 30 * time.Minute
+}
+
+// LookupPublishedResults returns the published semesters of the student with the symbol number
+// whose date of birth (2006-01-02) or registration number matches. Anything that does not match
+// fails with ErrLookupMismatch.
+func LookupPublishedResults(symbolNumber, dateOfBirth, registrationNumber string) (*PublicLookup, error) {
+	symbolNumber = strings.TrimSpace(symbolNumber)
+	dateOfBirth = strings.TrimSpace(dateOfBirth)
+	registrationNumber = strings.TrimSpace(registrationNumber)
+	if symbolNumber == "" || (dateOfBirth == "" && registrationNumber == "") {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "Symbol number and either date of birth or registration number are required")
+	}
+
+	now := time.Now()
+	publicResults.RLock()
+	entries := publicResults.entries[symbolNumber]
+	publicResults.RUnlock()
+	if len(entries) > 0 && now.Before(entries[0].expires) {
+		for _, entry := range entries {
+			if lookupMatches(entry.dateOfBirth, entry.registrationNumber, dateOfBirth, registrationNumber) {
+				return entry.lookup, nil
+			}
+		}
+		return nil, ErrLookupMismatch
+	}
+
+	entries, err := loadPublicLookups(initializers.DB.Where("symbol_number = ?", symbolNumber))
+	if err != nil {
+		return nil, err
+	}
+	// Only symbol numbers with results are kept, so unknown ones cannot grow the cache
+	publicResults.Lock()
+	if len(entries) > 0 {
+		publicResults.entries[symbolNumber] = entries
+	} else {
+		delete(publicResults.entries, symbolNumber)
+	}
+	publicResults.Unlock()
+
+	for _, entry := range entries {
+		if lookupMatches(entry.dateOfBirth, entry.registrationNumber, dateOfBirth, registrationNumber) {
+			return entry.lookup, nil
+		}
+	}
+	return nil, ErrLookupMismatch
+}
+
+// WarmPublicResults drops the cached lookups of a batch and program and loads them again from
+// the stored transcripts, so the first lookups after a publication do not hit the database
+func WarmPublicResults(batchID, programID uint) error {
+	InvalidatePublicResults(batchID, programID)
+
+	entries, err := loadPublicLookups(initializers.DB.Where("batch_id = ? AND program_id = ?", batchID, programID))
+	if err != nil {
+		return err
+	}
+
+	// Symbol numbers shared with other cohorts are left to be built on their next lookup
+	grouped := make(map[string][]cachedLookup)
+	for _, entry := range entries {
+		grouped[entry.lookup.SymbolNumber] = append(grouped[entry.lookup.SymbolNumber], entry)
+	}
+	publicResults.Lock()
+	defer publicResults.Unlock()
+	for symbolNumber, group := range grouped {
+		if _, ok := publicResults.entries[symbolNumber]; !ok {
+			publicResults.entries[symbolNumber] = group
+		}
+	}
+	return nil
+}
+
+// InvalidatePublicResults drops every cached lookup that involves a batch and program
+func InvalidatePublicResults(batchID, programID uint) {
+	publicResults.Lock()
+	defer publicResults.Unlock()
+	for symbolNumber, entries := range publicResults.entries {
+		for _, entry := range entries {
+			if entry.batchID == batchID && entry.programID == programID {
+				delete(publicResults.entries, symbolNumber)
+				break
+			}
+		}
+	}
+}
+
+// refreshPublicResults warms the cache after a result changed, without holding up the caller
+func refreshPublicResults(batchID, programID uint) {
+	InvalidatePublicResults(batchID, programID)
+	go func() {
+		if err := WarmPublicResults(batchID, programID); err != nil {
+			log.Printf("Failed to warm public results for batch %d, program %d: %v\n", batchID, programID, err)
+		}
+	}()
+}
+
+func lookupMatches(storedDOB, storedRegistration, dateOfBirth, registrationNumber string) bool {
+	if dateOfBirth != "" && storedDOB != "" && dateOfBirth == storedDOB {
+		return true
+	}
+	return registrationNumber != "" && strings.EqualFold(registrationNumber, storedRegistration)
+}
+
+// loadPublicLookups reads the stored transcripts the query selects
+func loadPublicLookups(query *gorm.DB) ([]cachedLookup, error) {
+	var transcripts []models.PublishedTranscript
+	if err := query.Find(&transcripts).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch transcripts: %w", err)
+	}
+
+	expires := time.Now().Add(PublicResultCacheTTL())
+	var entries []cachedLookup
+	for _, transcript := range transcripts {
+		lookup := &PublicLookup{
+			SymbolNumber: transcript.SymbolNumber,
+			Fullname:     transcript.Fullname,
+			Program:      transcript.ProgramName,
+			Batch:        transcript.Batch,
+		}
+		if err := json.Unmarshal([]byte(transcript.Semesters), &lookup.Semesters); err != nil {
+			return nil, fmt.Errorf("failed to read the transcript of student %d: %w", transcript.StudentID, err)
+		}
+		entries = append(entries, cachedLookup{
+			studentID:          transcript.StudentID,
+			batchID:            transcript.BatchID,
+			programID:          transcript.ProgramID,
+			registrationNumber: transcript.RegistrationNumber,
+			dateOfBirth:        transcript.DateOfBirth,
+			lookup:             lookup,
+			expires:            expires,
+		})
+	}
+	return entries, nil
+}
+
+type lookupFailures struct {
+	count       int
+	last        time.Time
+	lockedUntil time.Time
+}
+
+// lookupThrottle slows down an IP that keeps giving details that do not match. Failures are only
+// forgotten after a quiet spell, so a lookup of the prober's own result does not clear them.
+var lookupThrottle = struct {
+	sync.Mutex
+	ips map[string]*lookupFailures
+}{ips: make(map[string]*lookupFailures)}
+
+const (
+	lookupFreeFailures = 5                // Failures allowed before the IP has to wait
+	lookupFailureReset = 15 * time.Minute // Quiet time after which the failures are forgotten
+	lookupMaxLock      = 15 * time.Minute
+)
+
+// LookupWait returns how long an IP has to wait before it may look up results again
+func LookupWait(ip string) time.Duration {
+	lookupThrottle.Lock()
+	defer lookupThrottle.Unlock()
+	failures, ok := lookupThrottle.ips[ip]
+	if !ok {
+		return 0
+	}
+	if wait := time.Until(failures.lockedUntil); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// RecordLookupFailure counts a lookup that did not match. Past the free failures every further
+// one doubles the wait, from 30 seconds up to 15 minutes.
+func RecordLookupFailure(ip string) {
+	lookupThrottle.Lock()
+	defer lookupThrottle.Unlock()
+
+	now := time.Now()
+	for key, failures := range lookupThrottle.ips {
+		if now.Sub(failures.last) > lookupFailureReset && now.After(failures.lockedUntil) {
+			delete(lookupThrottle.ips, key)
+		}
+	}
+
+	failures, ok := lookupThrottle.ips[ip]
+	if !ok {
+		failures = &lookupFailures{}
+		lookupThrottle.ips[ip] = failures
+	}
+	failures.count++
+	failures.last = now
+	if over := failures.count - lookupFreeFailures; over > 0 {
+		wait := lookupMaxLock
+		if over <= 5 && 30*time.Second<<(over-1) < lookupMaxLock {
+			wait = 30 * time.Second << (over - 1)
+		}
+		failures.lockedUntil = now.Add(wait)
+	}
+}
@@ -0,0 +1,171 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Mail is one plain text email
+type Mail struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers mail. MAIL_DRIVER picks the implementation the server uses: smtp, file or,
+// in development mode only, stdout, which is also the development default.
+type Mailer interface {
+	Send(mail Mail) error
+}
+
+// SMTPMailer sends through an SMTP server, upgrading to TLS when the server offers it
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func (m SMTPMailer) Send(mail Mail) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+	if err := smtp.SendMail(net.JoinHostPort(m.Host, m.Port), auth, m.From, []string{mail.To}, formatMail(m.From, mail)); err != nil {
+		return fmt.Errorf("failed to send mail: %w", err)
+	}
+	return nil
+}
+
+// FileMailer writes every mail to a file in Dir, or to stdout when Dir is empty, so the links in
+// them can be followed during local testing
+type FileMailer struct {
+	Dir  string
+	From string
+}
+
+func (m FileMailer) Send(mail Mail) error {
+	content := formatMail(m.From, mail)
+	if m.Dir == "" {
+		_, err := os.Stdout.Write(append(content, '\n'))
+		return err
+	}
+
+	if err := os.MkdirAll(m.Dir, os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create mail directory: %w", err)
+	}
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), SanitizeFileName(mail.To))
+	if err := os.WriteFile(filepath.Join(m.Dir, name), content, 0o600); err != nil {
+		return fmt.Errorf("failed to write mail: %w", err)
+	}
+	return nil
+}
+
+// formatMail renders the message with its headers. Line breaks are dropped from header values
+// so user input cannot add headers.
+func formatMail(from string, mail Mail) []byte {
+	header := strings.NewReplacer("\r", "", "\n", "")
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", header.Replace(from))
+	fmt.Fprintf(&buf, "To: %s\r\n", header.Replace(mail.To))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", header.Replace(mail.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	buf.WriteString(strings.ReplaceAll(mail.Body, "\n", "\r\n"))
+	return buf.Bytes()
+}
+
+var mailer struct {
+	sync.Mutex
+	current Mailer
+}
+
+// SetMailer replaces the mailer, to plug in another provider
+func SetMailer(m Mailer) {
+	mailer.Lock()
+	mailer.current = m
+	mailer.Unlock()
+}
+
+// LoadMailer configures the mailer from the environment. Mail carries password reset links, so
+// a deploy that configured none stops rather than writing them to the logs.
+func LoadMailer() {
+	CurrentMailer()
+}
+
+// CurrentMailer returns the mailer, configured from the environment on first use
+func CurrentMailer() Mailer {
+	mailer.Lock()
+	defer mailer.Unlock()
+	if mailer.current == nil {
+		mailer.current = mailerFromEnv()
+	}
+	return mailer.current
+}
+
+func mailerFromEnv() Mailer {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "no-reply@localhost"
+	}
+
+	driver := os.Getenv("MAIL_DRIVER")
+	if driver == "" && DevelopmentMode() {
+		driver = "stdout"
+	}
+	switch driver {
+	case "smtp":
+		host := os.Getenv("SMTP_HOST")
+		if host == "" {
+			log.Fatalf("MAIL_DRIVER is smtp but SMTP_HOST is not set")
+		}
+		port := os.Getenv("SMTP_PORT")
+		if port == "" {
+			port = "587"
+		}
+		return SMTPMailer{
+			Host:     host,
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		}
+	case "file":
+		dir := os.Getenv("MAIL_DIR")
+		if dir == "" {
+			dir = "mail"
+		}
+		return FileMailer{Dir: dir, From: from}
+	case "stdout":
+		if !DevelopmentMode() {
+			log.Fatalf("MAIL_DRIVER stdout writes password reset links to the logs and is only allowed in development mode")
+		}
+		log.Println("Mail is written to stdout")
+		return FileMailer{From: from}
+	case "":
+		log.Fatalf("MAIL_DRIVER is not set; configure smtp or file")
+	default:
+		log.Fatalf("Unknown MAIL_DRIVER %q; use smtp, file or stdout", driver)
+	}
+	return nil
+}
+
+// SendMail delivers in the background, so a slow mail server neither delays the response nor
+// shows by its timing whether a mail was sent at all
+func SendMail(mail Mail) {
+	go func() {
+		if err := CurrentMailer().Send(mail); err != nil {
+			log.Printf("Failed to mail %s: %v", mail.To, err)
+		}
+	}()
+}
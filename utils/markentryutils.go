@@ -0,0 +1,334 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mysterybee07/result-distribution-system/initializers"
+	"github.com/mysterybee07/result-distribution-system/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MarkEntryOutcome tells what became of one staged entry
+type MarkEntryOutcome struct {
+	StudentID        uint   `json:"student_id"`
+	CourseID         uint   `json:"course_id"`
+	EntryID          uint   `json:"entry_id"`
+	Outcome          string `json:"outcome"` // awaiting_second_entry, replaced, recorded or mismatch
+	MarkID           *uint  `json:"mark_id,omitempty"`
+	ReconciliationID *uint  `json:"reconciliation_id,omitempty"`
+}
+
+// StageMarks stages the marks of a payload as entries of one user, in one transaction. The
+// payload must already have passed the checks of a marks entry.
+func StageMarks(payload models.MarksPayload, enteredBy string) ([]MarkEntryOutcome, error) {
+	var entries []models.MarkEntry
+	for _, mark := range payload.Marks {
+		entries = append(entries, models.MarkEntry{
+			BatchID:        payload.BatchID,
+			ProgramID:      payload.ProgramID,
+			SemesterID:     payload.SemesterID,
+			CourseID:       payload.CourseID,
+			StudentID:      mark.StudentID,
+			SemesterMarks:  mark.SemesterMarks,
+			AssistantMarks: mark.AssistantMarks,
+			PracticalMarks: mark.PracticalMarks,
+		})
+	}
+	return StageMarkEntries(entries, enteredBy)
+}
+
+// StageMarkCorrections stages new marks for the latest recorded attempt of each student in a
+// payload. Like a first entry, a correction only changes the mark once a second user enters the
+// same marks; marks a published result showed can only be amended.
+func StageMarkCorrections(payload models.MarksPayload, enteredBy string) ([]MarkEntryOutcome, error) {
+	var entries []models.MarkEntry
+	for _, entry := range payload.Marks {
+		var mark models.Mark
+		if err := initializers.DB.Where("batch_id = ? AND program_id = ? AND semester_id = ? AND course_id = ? AND student_id = ?",
+			payload.BatchID, payload.ProgramID, payload.SemesterID, payload.CourseID, entry.StudentID).
+			Order("attempt desc").First(&mark).Error; err != nil {
+			return nil, fiber.NewError(fiber.StatusNotFound, fmt.Sprintf("No recorded mark of student %d to correct", entry.StudentID))
+		}
+		frozen, err := MarkFrozen(mark)
+		if err != nil {
+			return nil, err
+		}
+		if frozen {
+			return nil, fiber.NewError(fiber.StatusConflict, "The result of this semester is published; submit an amendment with a reason instead")
+		}
+
+		entries = append(entries, models.MarkEntry{
+			BatchID:        payload.BatchID,
+			ProgramID:      payload.ProgramID,
+			SemesterID:     payload.SemesterID,
+			CourseID:       payload.CourseID,
+			StudentID:      entry.StudentID,
+			SemesterMarks:  entry.SemesterMarks,
+			AssistantMarks: entry.AssistantMarks,
+			PracticalMarks: entry.PracticalMarks,
+			CorrectsMarkID: &mark.ID,
+		})
+	}
+	return StageMarkEntries(entries, enteredBy)
+}
+
+// StageMarkEntries records independent entries of marks. The first entry of a student's course
+// waits for a second one by another user; matching entries become the mark, and differing ones
+// open a reconciliation. A user entering again before anyone else replaces their own entry.
+func StageMarkEntries(entries []models.MarkEntry, enteredBy string) ([]MarkEntryOutcome, error) {
+	if enteredBy == "" {
+		return nil, fiber.NewError(fiber.StatusUnauthorized, "Marks entries need a signed in user")
+	}
+
+	var outcomes []MarkEntryOutcome
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		outcomes = nil
+		for _, entry := range entries {
+			entry.EnteredBy = enteredBy
+			outcome, err := stageMarkEntry(tx, entry)
+			if err != nil {
+				return err
+			}
+			outcomes = append(outcomes, outcome)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return outcomes, nil
+}
+
+func stageMarkEntry(tx *gorm.DB, entry models.MarkEntry) (MarkEntryOutcome, error) {
+	outcome := MarkEntryOutcome{StudentID: entry.StudentID, CourseID: entry.CourseID}
+
+	var open int64
+	if err := tx.Model(&models.MarkReconciliation{}).
+		Where("course_id = ? AND student_id = ? AND status = ?", entry.CourseID, entry.StudentID, "open").
+		Count(&open).Error; err != nil {
+		return outcome, fmt.Errorf("failed to check reconciliations: %w", err)
+	}
+	if open > 0 {
+		return outcome, fiber.NewError(fiber.StatusConflict, fmt.Sprintf("Marks of student %d are awaiting reconciliation", entry.StudentID))
+	}
+
+	// Locked so two users entering at once cannot both become the first entry. A correction is
+	// only matched with another correction of the same mark.
+	query := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("batch_id = ? AND program_id = ? AND semester_id = ? AND course_id = ? AND student_id = ? AND status = ?",
+			entry.BatchID, entry.ProgramID, entry.SemesterID, entry.CourseID, entry.StudentID, "pending")
+	if entry.CorrectsMarkID != nil {
+		query = query.Where("corrects_mark_id = ?", *entry.CorrectsMarkID)
+	} else {
+		query = query.Where("corrects_mark_id IS NULL")
+	}
+	var pending models.MarkEntry
+	err := query.First(&pending).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		entry.Status = "pending"
+		if err := tx.Create(&entry).Error; err != nil {
+			return outcome, fmt.Errorf("failed to save marks entry: %w", err)
+		}
+		outcome.EntryID = entry.ID
+		outcome.Outcome = "awaiting_second_entry"
+		return outcome, nil
+	} else if err != nil {
+		return outcome, fmt.Errorf("failed to fetch marks entry: %w", err)
+	}
+
+	if pending.EnteredBy == entry.EnteredBy {
+		if err := tx.Model(&pending).Updates(map[string]interface{}{
+			"semester_marks":  entry.SemesterMarks,
+			"assistant_marks": entry.AssistantMarks,
+			"practical_marks": entry.PracticalMarks,
+		}).Error; err != nil {
+			return outcome, fmt.Errorf("failed to update marks entry: %w", err)
+		}
+		outcome.EntryID = pending.ID
+		outcome.Outcome = "replaced"
+		return outcome, nil
+	}
+
+	if pending.SemesterMarks != entry.SemesterMarks || pending.AssistantMarks != entry.AssistantMarks ||
+		pending.PracticalMarks != entry.PracticalMarks {
+		entry.Status = "mismatched"
+		if err := tx.Create(&entry).Error; err != nil {
+			return outcome, fmt.Errorf("failed to save marks entry: %w", err)
+		}
+		if err := tx.Model(&pending).Update("status", "mismatched").Error; err != nil {
+			return outcome, fmt.Errorf("failed to update marks entry: %w", err)
+		}
+		reconciliation := models.MarkReconciliation{
+			BatchID:       entry.BatchID,
+			ProgramID:     entry.ProgramID,
+			SemesterID:    entry.SemesterID,
+			CourseID:      entry.CourseID,
+			StudentID:     entry.StudentID,
+			FirstEntryID:  pending.ID,
+			SecondEntryID: entry.ID,
+			Status:        "open",
+		}
+		if err := tx.Omit(clause.Associations).Create(&reconciliation).Error; err != nil {
+			return outcome, fmt.Errorf("failed to open reconciliation: %w", err)
+		}
+		outcome.EntryID = entry.ID
+		outcome.Outcome = "mismatch"
+		outcome.ReconciliationID = &reconciliation.ID
+		return outcome, nil
+	}
+
+	mark, err := recordStagedMark(tx, entry)
+	if err != nil {
+		return outcome, err
+	}
+	entry.Status = "matched"
+	entry.MarkID = &mark.ID
+	if err := tx.Create(&entry).Error; err != nil {
+		return outcome, fmt.Errorf("failed to save marks entry: %w", err)
+	}
+	if err := tx.Model(&pending).Updates(map[string]interface{}{"status": "matched", "mark_id": mark.ID}).Error; err != nil {
+		return outcome, fmt.Errorf("failed to update marks entry: %w", err)
+	}
+	outcome.EntryID = entry.ID
+	outcome.Outcome = "recorded"
+	outcome.MarkID = &mark.ID
+	return outcome, nil
+}
+
+// recordStagedMark saves the agreed marks, as a new mark or over the mark a correction replaces;
+// the mark sets its own pass or fail status
+func recordStagedMark(tx *gorm.DB, entry models.MarkEntry) (*models.Mark, error) {
+	if entry.CorrectsMarkID != nil {
+		return correctStagedMark(tx, *entry.CorrectsMarkID, entry)
+	}
+
+	mark := models.Mark{
+		BatchID:        entry.BatchID,
+		ProgramID:      entry.ProgramID,
+		SemesterID:     entry.SemesterID,
+		CourseID:       entry.CourseID,
+		StudentID:      entry.StudentID,
+		SemesterMarks:  entry.SemesterMarks,
+		AssistantMarks: entry.AssistantMarks,
+		PracticalMarks: entry.PracticalMarks,
+	}
+	if err := tx.Create(&mark).Error; err != nil {
+		return nil, fmt.Errorf("failed to save mark: %w", err)
+	}
+	return &mark, nil
+}
+
+// correctStagedMark replaces the marks of a recorded mark, unless a result published it since the
+// correction was entered
+func correctStagedMark(tx *gorm.DB, markID uint, entry models.MarkEntry) (*models.Mark, error) {
+	var mark models.Mark
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&mark, markID).Error; err != nil {
+		return nil, fiber.NewError(fiber.StatusNotFound, "The corrected mark no longer exists")
+	}
+	frozen, err := markFrozen(tx, mark)
+	if err != nil {
+		return nil, err
+	}
+	if frozen {
+		return nil, fiber.NewError(fiber.StatusConflict, "The result of this semester is published; submit an amendment with a reason instead")
+	}
+
+	mark.SemesterMarks = entry.SemesterMarks
+	mark.AssistantMarks = entry.AssistantMarks
+	mark.PracticalMarks = entry.PracticalMarks
+	if err := tx.Omit(clause.Associations).Save(&mark).Error; err != nil {
+		return nil, fmt.Errorf("failed to correct mark: %w", err)
+	}
+	return &mark, nil
+}
+
+// ResolveReconciliation settles a disagreement with the marks a supervisor decided on. The
+// supervisor cannot be one of the users whose entries disagreed.
+func ResolveReconciliation(id uint, req models.MarkReconciliationRequest, resolvedBy string) (*models.MarkReconciliation, error) {
+	var reconciliation models.MarkReconciliation
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("FirstEntry").Preload("SecondEntry").
+			First(&reconciliation, id).Error; err != nil {
+			return fiber.NewError(fiber.StatusNotFound, "Reconciliation not found")
+		}
+		if reconciliation.Status != "open" {
+			return fiber.NewError(fiber.StatusConflict, "Reconciliation is already resolved")
+		}
+		if resolvedBy == reconciliation.FirstEntry.EnteredBy || resolvedBy == reconciliation.SecondEntry.EnteredBy {
+			return fiber.NewError(fiber.StatusForbidden, "A reconciliation must be resolved by someone who did not enter the marks")
+		}
+
+		var course models.Course
+		if err := tx.First(&course, reconciliation.CourseID).Error; err != nil {
+			return fiber.NewError(fiber.StatusNotFound, "Course not found")
+		}
+		if req.SemesterMarks < 0 || req.PracticalMarks < 0 || req.AssistantMarks < 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "Marks cannot be negative")
+		}
+		if req.SemesterMarks > course.SemesterTotalMarks ||
+			(course.PracticalTotalMarks != nil && req.PracticalMarks > *course.PracticalTotalMarks) ||
+			(course.AssistantTotalMarks != nil && req.AssistantMarks > *course.AssistantTotalMarks) {
+			return fiber.NewError(fiber.StatusBadRequest, "Obtained marks cannot exceed total marks")
+		}
+
+		// Both entries of a reconciliation are corrections of the same mark, or neither is
+		correctsMarkID := reconciliation.FirstEntry.CorrectsMarkID
+		if correctsMarkID == nil {
+			var existing int64
+			if err := tx.Model(&models.Mark{}).
+				Where("batch_id = ? AND program_id = ? AND semester_id = ? AND course_id = ? AND student_id = ?",
+					reconciliation.BatchID, reconciliation.ProgramID, reconciliation.SemesterID, reconciliation.CourseID, reconciliation.StudentID).
+				Count(&existing).Error; err != nil {
+				return fmt.Errorf("failed to check marks: %w", err)
+			}
+			if existing > 0 {
+				return fiber.NewError(fiber.StatusConflict, "Mark entry already exists for the student")
+			}
+		}
+
+		mark, err := recordStagedMark(tx, models.MarkEntry{
+			BatchID:        reconciliation.BatchID,
+			ProgramID:      reconciliation.ProgramID,
+			SemesterID:     reconciliation.SemesterID,
+			CourseID:       reconciliation.CourseID,
+			StudentID:      reconciliation.StudentID,
+			SemesterMarks:  req.SemesterMarks,
+			AssistantMarks: req.AssistantMarks,
+			PracticalMarks: req.PracticalMarks,
+			CorrectsMarkID: correctsMarkID,
+		})
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if err := tx.Model(&reconciliation).Omit(clause.Associations).Updates(map[string]interface{}{
+			"status":      "resolved",
+			"resolved_by": resolvedBy,
+			"resolved_at": now,
+			"note":        req.Note,
+			"mark_id":     mark.ID,
+		}).Error; err != nil {
+			return fmt.Errorf("failed to resolve reconciliation: %w", err)
+		}
+		if err := tx.Model(&models.MarkEntry{}).
+			Where("id IN ?", []uint{reconciliation.FirstEntryID, reconciliation.SecondEntryID}).
+			Updates(map[string]interface{}{"status": "resolved", "mark_id": mark.ID}).Error; err != nil {
+			return fmt.Errorf("failed to update marks entries: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := initializers.DB.Preload("FirstEntry").Preload("SecondEntry").Preload("Course").Preload("Student").
+		First(&reconciliation, id).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch reconciliation: %w", err)
+	}
+	return &reconciliation, nil
+}
@@ -0,0 +1,269 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mysterybee07/result-distribution-system/initializers"
+	"github.com/mysterybee07/result-distribution-system/models"
+)
+
+// MarksImportRow is the outcome of one row of an uploaded marks sheet
+type MarksImportRow struct {
+	Row          int      `json:"row"` // Line in the file, the header being line 1
+	SymbolNumber string   `json:"symbol_number"`
+	CourseCode   string   `json:"course_code"`
+	Errors       []string `json:"errors,omitempty"`
+}
+
+// MarksImportReport sums up an import. Nothing is saved unless Committed is set.
+type MarksImportReport struct {
+	Rows       int                `json:"rows"`
+	Valid      int                `json:"valid"`
+	Invalid    int                `json:"invalid"`
+	Committed  bool               `json:"committed"`
+	Staged     int                `json:"staged"`     // Entries saved for the uploader
+	Recorded   int                `json:"recorded"`   // Entries that matched an earlier one and became marks
+	Mismatched int                `json:"mismatched"` // Entries that differed and wait for reconciliation
+	Errors     []MarksImportRow   `json:"errors"`
+	Entries    []MarkEntryOutcome `json:"entries,omitempty"`
+}
+
+// marksImportColumns are the headers of a marks sheet; the last two may be left out
+var marksImportColumns = []string{"symbol_number", "course_code", "semester_marks", "practical_marks", "assistant_marks"}
+
+// ImportMarks checks every row of a marks sheet keyed by symbol number and course code against
+// the same rules as a marks entry, and stages the rows as entries of the uploader. Students
+// outside the uploader's scope are reported like unknown ones. The rows are saved only when every
+// one is valid, or, with partial set, the valid rows are saved and the rest reported.
+func ImportMarks(batchID, programID, semesterID uint, rows [][]string, partial bool, enteredBy string, access *Access) (*MarksImportReport, error) {
+	var program models.Program
+	if err := initializers.DB.First(&program, programID).Error; err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "Program not found")
+	}
+	var semester models.Semester
+	if err := initializers.DB.First(&semester, semesterID).Error; err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "Semester not found")
+	}
+
+	// A published result is only changed through an amendment
+	published, err := ResultPublished(batchID, programID, semesterID)
+	if err != nil {
+		return nil, err
+	}
+	if published {
+		return nil, fiber.NewError(fiber.StatusConflict, "The result of this semester is published; marks can only be amended")
+	}
+
+	// Blank lines above the header are skipped; row numbers still count them
+	first := 0
+	for first < len(rows) && strings.TrimSpace(strings.Join(rows[first], "")) == "" {
+		first++
+	}
+	if first == len(rows) {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "The file is empty")
+	}
+	columns := make(map[string]int)
+	for i, header := range rows[first] {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(header, "\ufeff")))] = i
+	}
+	for _, required := range marksImportColumns[:3] {
+		if _, ok := columns[required]; !ok {
+			return nil, fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("Missing column %s, expected %s", required, strings.Join(marksImportColumns, ", ")))
+		}
+	}
+
+	var students []models.Student
+	if err := initializers.DB.Where("batch_id = ? AND program_id = ?", batchID, programID).Find(&students).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch students: %w", err)
+	}
+	studentsBySymbol := make(map[string]models.Student)
+	for _, student := range students {
+		studentsBySymbol[student.SymbolNumber] = student
+	}
+
+	var courses []models.Course
+	if err := initializers.DB.Where("program_id = ? AND semester_id = ?", programID, semesterID).Find(&courses).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch courses: %w", err)
+	}
+	coursesByCode := make(map[string]models.Course)
+	for _, course := range courses {
+		coursesByCode[strings.ToUpper(course.CourseCode)] = course
+	}
+
+	var existing []models.Mark
+	if err := initializers.DB.Select("student_id", "course_id").
+		Where("batch_id = ? AND program_id = ? AND semester_id = ?", batchID, programID, semesterID).
+		Find(&existing).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch marks: %w", err)
+	}
+	type markKey struct{ studentID, courseID uint }
+	recorded := make(map[markKey]bool)
+	for _, mark := range existing {
+		recorded[markKey{mark.StudentID, mark.CourseID}] = true
+	}
+	var reconciliations []models.MarkReconciliation
+	if err := initializers.DB.Select("student_id", "course_id").
+		Where("batch_id = ? AND program_id = ? AND semester_id = ? AND status = ?", batchID, programID, semesterID, "open").
+		Find(&reconciliations).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch reconciliations: %w", err)
+	}
+	disputed := make(map[markKey]bool)
+	for _, reconciliation := range reconciliations {
+		disputed[markKey{reconciliation.StudentID, reconciliation.CourseID}] = true
+	}
+
+	// Examiners may only import the courses assigned to them, each until its deadline
+	denials := make(map[uint]error)
+
+	report := &MarksImportReport{Errors: []MarksImportRow{}}
+	seen := make(map[markKey]int)
+	var entries []models.MarkEntry
+	for i, row := range rows[first+1:] {
+		cell := func(name string) string {
+			if index, ok := columns[name]; ok && index < len(row) {
+				return strings.TrimSpace(row[index])
+			}
+			return ""
+		}
+		line := MarksImportRow{Row: first + i + 2, SymbolNumber: cell("symbol_number"), CourseCode: cell("course_code")}
+		if line.SymbolNumber == "" && line.CourseCode == "" && cell("semester_marks") == "" {
+			continue // Blank lines between and after the marks
+		}
+		report.Rows++
+
+		student, ok := studentsBySymbol[line.SymbolNumber]
+		if line.SymbolNumber == "" {
+			line.Errors = append(line.Errors, "Symbol number is required")
+		} else if !ok || !access.AllowsStudent(student) {
+			ok = false
+			line.Errors = append(line.Errors, "Student not found for the given batch and program")
+		}
+		course, courseFound := coursesByCode[strings.ToUpper(line.CourseCode)]
+		if line.CourseCode == "" {
+			line.Errors = append(line.Errors, "Course code is required")
+		} else if !courseFound {
+			line.Errors = append(line.Errors, "Course not found for the given program and semester")
+		}
+
+		semesterMarks, err := parseImportedMarks(cell("semester_marks"), true)
+		if err != nil {
+			line.Errors = append(line.Errors, "Semester marks "+err.Error())
+		}
+		practicalMarks, err := parseImportedMarks(cell("practical_marks"), false)
+		if err != nil {
+			line.Errors = append(line.Errors, "Practical marks "+err.Error())
+		}
+		assistantMarks, err := parseImportedMarks(cell("assistant_marks"), false)
+		if err != nil {
+			line.Errors = append(line.Errors, "Assistant marks "+err.Error())
+		}
+
+		if courseFound {
+			denied, checked := denials[course.ID]
+			if !checked {
+				denied = CheckMarkEntry(enteredBy, batchID, course.ID)
+				var fiberErr *fiber.Error
+				if denied != nil && !errors.As(denied, &fiberErr) {
+					return nil, denied
+				}
+				denials[course.ID] = denied
+			}
+			if denied != nil {
+				line.Errors = append(line.Errors, denied.Error())
+			}
+			if semesterMarks > course.SemesterTotalMarks {
+				line.Errors = append(line.Errors, fmt.Sprintf("Semester marks cannot exceed %d", course.SemesterTotalMarks))
+			}
+			if limit := optionalTotal(course.PracticalTotalMarks); practicalMarks > limit {
+				line.Errors = append(line.Errors, fmt.Sprintf("Practical marks cannot exceed %d", limit))
+			}
+			if limit := optionalTotal(course.AssistantTotalMarks); assistantMarks > limit {
+				line.Errors = append(line.Errors, fmt.Sprintf("Assistant marks cannot exceed %d", limit))
+			}
+		}
+
+		if ok && courseFound {
+			key := markKey{student.ID, course.ID}
+			if recorded[key] {
+				line.Errors = append(line.Errors, "Mark entry already exists for the student")
+			} else if disputed[key] {
+				line.Errors = append(line.Errors, "Marks of the student are awaiting reconciliation")
+			} else if first, duplicate := seen[key]; duplicate {
+				line.Errors = append(line.Errors, fmt.Sprintf("Duplicate of row %d", first))
+			} else {
+				seen[key] = line.Row
+			}
+		}
+
+		if len(line.Errors) > 0 {
+			report.Invalid++
+			report.Errors = append(report.Errors, line)
+			continue
+		}
+		report.Valid++
+		entries = append(entries, models.MarkEntry{
+			BatchID:        batchID,
+			ProgramID:      programID,
+			SemesterID:     semesterID,
+			CourseID:       course.ID,
+			StudentID:      student.ID,
+			SemesterMarks:  semesterMarks,
+			PracticalMarks: practicalMarks,
+			AssistantMarks: assistantMarks,
+		})
+	}
+
+	if report.Rows == 0 {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "The file has no marks")
+	}
+	if len(entries) == 0 || (report.Invalid > 0 && !partial) {
+		return report, nil
+	}
+
+	outcomes, err := StageMarkEntries(entries, enteredBy)
+	if err != nil {
+		return nil, err
+	}
+	report.Committed = true
+	report.Staged = len(outcomes)
+	report.Entries = outcomes
+	for _, outcome := range outcomes {
+		switch outcome.Outcome {
+		case "recorded":
+			report.Recorded++
+		case "mismatch":
+			report.Mismatched++
+		}
+	}
+	return report, nil
+}
+
+// parseImportedMarks reads a whole, non-negative number. Spreadsheets may store 45 as 45.0.
+func parseImportedMarks(value string, required bool) (int, error) {
+	if value == "" {
+		if required {
+			return 0, errors.New("are required")
+		}
+		return 0, nil
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil || number != math.Trunc(number) {
+		return 0, errors.New("must be a whole number")
+	}
+	if number < 0 {
+		return 0, errors.New("cannot be negative")
+	}
+	return int(number), nil
+}
+
+func optionalTotal(total *int) int {
+	if total == nil {
+		return 0
+	}
+	return *total
+}
@@ -0,0 +1,271 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mysterybee07/result-distribution-system/initializers"
+	"github.com/mysterybee07/result-distribution-system/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ModerationPreview shows what a rule would do to the marks it covers
+type ModerationPreview struct {
+	Rule         models.ModerationRule   `json:"rule"`
+	Considered   int                     `json:"considered"`    // Marks in the scope of the rule
+	Frozen       int                     `json:"frozen"`        // Marks of published results, which the rule leaves alone
+	Affected     int                     `json:"affected"`      // Marks the rule changes
+	NewlyPassing int                     `json:"newly_passing"` // Failed marks the rule turns into a pass
+	NewlyFailing int                     `json:"newly_failing"` // Passed marks the rule turns into a fail
+	Changes      []models.MarkModeration `json:"changes"`
+}
+
+// ModerateMarks returns the marks a rule turns the raw marks of a course into. Grace adds up to
+// the grace marks across the failed components of a course, and only when that makes the course
+// a pass. Scaling multiplies one component, rounded and kept within its full marks.
+func ModerateMarks(rule models.ModerationRule, course models.Course, semesterMarks, practicalMarks, assistantMarks int) (int, int, int) {
+	switch rule.Kind {
+	case "grace":
+		if course.MarkStatus(semesterMarks, practicalMarks, assistantMarks) == "pass" {
+			break
+		}
+		needed := 0
+		moderatedSemester, moderatedPractical, moderatedAssistant := semesterMarks, practicalMarks, assistantMarks
+		if semesterMarks < course.SemesterPassMarks {
+			needed += course.SemesterPassMarks - semesterMarks
+			moderatedSemester = course.SemesterPassMarks
+		}
+		if course.PracticalPassMarks != nil && practicalMarks < *course.PracticalPassMarks {
+			needed += *course.PracticalPassMarks - practicalMarks
+			moderatedPractical = *course.PracticalPassMarks
+		}
+		if course.AssistantPassMarks != nil && assistantMarks < *course.AssistantPassMarks {
+			needed += *course.AssistantPassMarks - assistantMarks
+			moderatedAssistant = *course.AssistantPassMarks
+		}
+		if needed <= rule.GraceMarks {
+			return moderatedSemester, moderatedPractical, moderatedAssistant
+		}
+	case "scale":
+		switch rule.Component {
+		case "semester":
+			semesterMarks = scaleMarks(semesterMarks, course.SemesterTotalMarks, rule.ScaleFactor)
+		case "practical":
+			if course.PracticalTotalMarks != nil {
+				practicalMarks = scaleMarks(practicalMarks, *course.PracticalTotalMarks, rule.ScaleFactor)
+			}
+		case "assistant":
+			if course.AssistantTotalMarks != nil {
+				assistantMarks = scaleMarks(assistantMarks, *course.AssistantTotalMarks, rule.ScaleFactor)
+			}
+		}
+	}
+	return semesterMarks, practicalMarks, assistantMarks
+}
+
+func scaleMarks(marks, fullMarks int, factor float64) int {
+	scaled := int(math.Round(float64(marks) * factor))
+	if scaled > fullMarks {
+		return fullMarks
+	}
+	if scaled < 0 {
+		return 0
+	}
+	return scaled
+}
+
+// PreviewModeration works out the changes of a rule without saving anything
+func PreviewModeration(rule models.ModerationRule) (*ModerationPreview, error) {
+	return previewModeration(initializers.DB, rule)
+}
+
+// previewModeration reads the marks through db so a transaction sees its own changes
+func previewModeration(db *gorm.DB, rule models.ModerationRule) (*ModerationPreview, error) {
+	query := db.Preload("Course").Preload("Student").Where("program_id = ?", rule.ProgramID)
+	if rule.BatchID != nil {
+		query = query.Where("batch_id = ?", *rule.BatchID)
+	}
+	if rule.SemesterID != nil {
+		query = query.Where("semester_id = ?", *rule.SemesterID)
+	}
+	if rule.CourseID != nil {
+		query = query.Where("course_id = ?", *rule.CourseID)
+	}
+	if !rule.AllAttempts {
+		query = query.Where("attempt = ?", 1)
+	}
+	var marks []models.Mark
+	if err := query.Order("semester_id, course_id, student_id").Find(&marks).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch marks: %w", err)
+	}
+
+	type cohort struct{ batchID, semesterID uint }
+	type cutoff struct {
+		published bool
+		at        time.Time
+	}
+	cutoffs := make(map[cohort]cutoff)
+
+	preview := &ModerationPreview{Rule: rule, Considered: len(marks), Changes: []models.MarkModeration{}}
+	for _, mark := range marks {
+		key := cohort{mark.BatchID, mark.SemesterID}
+		publication, ok := cutoffs[key]
+		if !ok {
+			published, at, err := publicationCutoff(db, mark.BatchID, mark.ProgramID, mark.SemesterID)
+			if err != nil {
+				return nil, err
+			}
+			publication = cutoff{published, at}
+			cutoffs[key] = publication
+		}
+		if publication.published && !mark.CreatedAt.After(publication.at) {
+			preview.Frozen++
+			continue
+		}
+
+		semesterMarks, practicalMarks, assistantMarks := ModerateMarks(rule, mark.Course, mark.SemesterMarks, mark.PracticalMarks, mark.AssistantMarks)
+		if semesterMarks == mark.SemesterMarks && practicalMarks == mark.PracticalMarks && assistantMarks == mark.AssistantMarks {
+			continue
+		}
+
+		change := models.MarkModeration{
+			ModerationRuleID:        rule.ID,
+			MarkID:                  mark.ID,
+			StudentID:               mark.StudentID,
+			CourseID:                mark.CourseID,
+			SymbolNumber:            mark.Student.SymbolNumber,
+			CourseCode:              mark.Course.CourseCode,
+			RawSemesterMarks:        mark.SemesterMarks,
+			RawPracticalMarks:       mark.PracticalMarks,
+			RawAssistantMarks:       mark.AssistantMarks,
+			RawStatus:               mark.Course.MarkStatus(mark.SemesterMarks, mark.PracticalMarks, mark.AssistantMarks),
+			ModeratedSemesterMarks:  semesterMarks,
+			ModeratedPracticalMarks: practicalMarks,
+			ModeratedAssistantMarks: assistantMarks,
+			ModeratedStatus:         mark.Course.MarkStatus(semesterMarks, practicalMarks, assistantMarks),
+		}
+		preview.Affected++
+		if change.RawStatus != "pass" && change.ModeratedStatus == "pass" {
+			preview.NewlyPassing++
+		} else if change.RawStatus == "pass" && change.ModeratedStatus != "pass" {
+			preview.NewlyFailing++
+		}
+		preview.Changes = append(preview.Changes, change)
+	}
+	return preview, nil
+}
+
+// ApplyModerationRule changes the marks a draft rule covers and records the raw marks next to the
+// moderated ones, in one transaction
+func ApplyModerationRule(id uint, appliedBy string) (*ModerationPreview, error) {
+	var preview *ModerationPreview
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		var rule models.ModerationRule
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&rule, id).Error; err != nil {
+			return fiber.NewError(fiber.StatusNotFound, "Moderation rule not found")
+		}
+		if rule.Status != "draft" {
+			return fiber.NewError(fiber.StatusConflict, fmt.Sprintf("Moderation rule is already %s", rule.Status))
+		}
+
+		var err error
+		if preview, err = previewModeration(tx, rule); err != nil {
+			return err
+		}
+		for i := range preview.Changes {
+			change := &preview.Changes[i]
+			var mark models.Mark
+			if err := tx.First(&mark, change.MarkID).Error; err != nil {
+				return fmt.Errorf("failed to fetch mark %d: %w", change.MarkID, err)
+			}
+			mark.SemesterMarks = change.ModeratedSemesterMarks
+			mark.PracticalMarks = change.ModeratedPracticalMarks
+			mark.AssistantMarks = change.ModeratedAssistantMarks
+			if err := tx.Save(&mark).Error; err != nil {
+				return fmt.Errorf("failed to moderate mark %d: %w", mark.ID, err)
+			}
+			if err := tx.Create(change).Error; err != nil {
+				return fmt.Errorf("failed to record moderation: %w", err)
+			}
+		}
+
+		now := time.Now()
+		rule.Status = "applied"
+		rule.AppliedBy = appliedBy
+		rule.AppliedAt = &now
+		if err := tx.Save(&rule).Error; err != nil {
+			return fmt.Errorf("failed to update moderation rule: %w", err)
+		}
+		preview.Rule = rule
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return preview, nil
+}
+
+// RevertModerationRule puts back the raw marks an applied rule changed. Marks that were published
+// or changed again since are left as they are and returned.
+func RevertModerationRule(id uint, revertedBy string) (*models.ModerationRule, int, []models.MarkModeration, error) {
+	var rule models.ModerationRule
+	reverted := 0
+	var skipped []models.MarkModeration
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		reverted, skipped = 0, nil
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&rule, id).Error; err != nil {
+			return fiber.NewError(fiber.StatusNotFound, "Moderation rule not found")
+		}
+		if rule.Status != "applied" {
+			return fiber.NewError(fiber.StatusConflict, "Only an applied moderation rule can be reverted")
+		}
+
+		var moderations []models.MarkModeration
+		if err := tx.Where("moderation_rule_id = ? AND reverted = ?", rule.ID, false).Find(&moderations).Error; err != nil {
+			return fmt.Errorf("failed to fetch moderations: %w", err)
+		}
+		for _, moderation := range moderations {
+			var mark models.Mark
+			if err := tx.First(&mark, moderation.MarkID).Error; err != nil {
+				skipped = append(skipped, moderation)
+				continue
+			}
+			published, at, err := publicationCutoff(tx, mark.BatchID, mark.ProgramID, mark.SemesterID)
+			if err != nil {
+				return err
+			}
+			if (published && !mark.CreatedAt.After(at)) || mark.SemesterMarks != moderation.ModeratedSemesterMarks ||
+				mark.PracticalMarks != moderation.ModeratedPracticalMarks || mark.AssistantMarks != moderation.ModeratedAssistantMarks {
+				skipped = append(skipped, moderation)
+				continue
+			}
+
+			mark.SemesterMarks = moderation.RawSemesterMarks
+			mark.PracticalMarks = moderation.RawPracticalMarks
+			mark.AssistantMarks = moderation.RawAssistantMarks
+			if err := tx.Save(&mark).Error; err != nil {
+				return fmt.Errorf("failed to revert mark %d: %w", mark.ID, err)
+			}
+			if err := tx.Model(&moderation).Update("reverted", true).Error; err != nil {
+				return fmt.Errorf("failed to update moderation: %w", err)
+			}
+			reverted++
+		}
+
+		now := time.Now()
+		rule.Status = "reverted"
+		rule.RevertedBy = revertedBy
+		rule.RevertedAt = &now
+		if err := tx.Save(&rule).Error; err != nil {
+			return fmt.Errorf("failed to update moderation rule: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	return &rule, reverted, skipped, nil
+}
@@ -0,0 +1,23 @@
+package utils
+
+import (
+	"fmt"
+
+	"github.com/mysterybee07/result-distribution-system/models"
+	"gorm.io/gorm"
+)
+
+// Notify leaves a message for a user. It writes through db so the message is only kept when the
+// change it reports is.
+func Notify(db *gorm.DB, userID uint, title, message, link string) error {
+	notification := models.Notification{
+		UserID:  userID,
+		Title:   title,
+		Message: message,
+		Link:    link,
+	}
+	if err := db.Create(&notification).Error; err != nil {
+		return fmt.Errorf("failed to save notification: %w", err)
+	}
+	return nil
+}
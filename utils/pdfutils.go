@@ -0,0 +1,122 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+	"github.com/skip2/go-qrcode"
+)
+
+// InstitutionName is printed at the top of every generated document
+func InstitutionName() string {
+	if name := os.Getenv("INSTITUTION_NAME"); name != "" {
+		return name
+	}
+	return "Office of the Controller of Examinations"
+}
+
+// AppURL is the public base URL used in links printed on documents
+func AppURL() string {
+	if url := os.Getenv("APP_URL"); url != "" {
+		return strings.TrimRight(url, "/")
+	}
+	return "http://localhost:" + os.Getenv("PORT")
+}
+
+// newDocumentPDF creates an A4 page with the institution header and a document title
+func newDocumentPDF(title string) *gofpdf.Fpdf {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(15, 15, 15)
+	pdf.SetAutoPageBreak(true, 15)
+	pdf.SetTitle(title, true)
+	pdf.SetHeaderFunc(func() {
+		pdf.SetFont("Helvetica", "B", 15)
+		pdf.CellFormat(0, 8, InstitutionName(), "", 1, "C", false, 0, "")
+		pdf.SetFont("Helvetica", "B", 12)
+		pdf.CellFormat(0, 7, title, "", 1, "C", false, 0, "")
+		pdf.Line(15, pdf.GetY()+1, 195, pdf.GetY()+1)
+		pdf.Ln(4)
+	})
+	return pdf
+}
+
+// addQRCode draws a QR code for content with its top-left corner at x, y
+func addQRCode(pdf *gofpdf.Fpdf, name, content string, x, y, size float64) error {
+	png, err := qrcode.Encode(content, qrcode.Medium, 256)
+	if err != nil {
+		return fmt.Errorf("failed to encode QR code: %w", err)
+	}
+	pdf.RegisterImageOptionsReader(name, gofpdf.ImageOptions{ImageType: "PNG"}, bytes.NewReader(png))
+	pdf.ImageOptions(name, x, y, size, size, false, gofpdf.ImageOptions{ImageType: "PNG"}, 0, "")
+	return nil
+}
+
+// addPhoto draws an uploaded image if it exists and is a type the PDF library can embed
+func addPhoto(pdf *gofpdf.Fpdf, path string, x, y, w, h float64) bool {
+	if path == "" {
+		return false
+	}
+	imageType := strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	if imageType == "jpeg" {
+		imageType = "jpg"
+	}
+	if imageType != "jpg" && imageType != "png" && imageType != "gif" {
+		return false
+	}
+	if _, err := os.Stat(path); err != nil {
+		return false
+	}
+
+	pdf.ImageOptions(path, x, y, w, h, false, gofpdf.ImageOptions{ImageType: imageType}, 0, "")
+	if pdf.Err() {
+		// A broken image should not stop the rest of the document
+		pdf.ClearError()
+		return false
+	}
+	return true
+}
+
+// pdfBytes renders the document into memory
+func pdfBytes(pdf *gofpdf.Fpdf) ([]byte, error) {
+	var buffer bytes.Buffer
+	if err := pdf.Output(&buffer); err != nil {
+		return nil, fmt.Errorf("failed to render PDF: %w", err)
+	}
+	return buffer.Bytes(), nil
+}
+
+// ZipEntry is a single file inside a generated archive
+type ZipEntry struct {
+	Name    string
+	Content []byte
+}
+
+// WriteZip stores the given entries in a zip archive at path
+func WriteZip(path string, entries []ZipEntry) error {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create zip file: %w", err)
+	}
+	defer file.Close()
+
+	writer := zip.NewWriter(file)
+	for _, entry := range entries {
+		zipFile, err := writer.Create(entry.Name)
+		if err != nil {
+			return fmt.Errorf("failed to add %s to zip: %w", entry.Name, err)
+		}
+		if _, err := zipFile.Write(entry.Content); err != nil {
+			return fmt.Errorf("failed to write %s to zip: %w", entry.Name, err)
+		}
+	}
+	return writer.Close()
+}
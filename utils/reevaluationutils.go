@@ -0,0 +1,296 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mysterybee07/result-distribution-system/initializers"
+	"github.com/mysterybee07/result-distribution-system/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// StudentForUser finds the student record of a signed in user through the symbol number
+func StudentForUser(userID string) (*models.User, *models.Student, error) {
+	var user models.User
+	if userID == "" {
+		return nil, nil, fiber.NewError(fiber.StatusUnauthorized, "unauthorized user")
+	}
+	if err := initializers.DB.First(&user, userID).Error; err != nil {
+		return nil, nil, fiber.NewError(fiber.StatusUnauthorized, "unauthorized user")
+	}
+
+	var student models.Student
+	err := initializers.DB.Where("symbol_number = ?", user.SymbolNumber).First(&student).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) || user.SymbolNumber == "" {
+		return nil, nil, fiber.NewError(fiber.StatusForbidden, "No student record is linked to this account")
+	} else if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch student: %w", err)
+	}
+	return &user, &student, nil
+}
+
+// FileReevaluation records a student's request to re-total or re-check a published mark
+func FileReevaluation(userID string, filing models.ReevaluationFiling) (*models.ReevaluationRequest, error) {
+	if filing.Kind != "retotaling" && filing.Kind != "rechecking" {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "kind must be retotaling or rechecking")
+	}
+
+	user, student, err := StudentForUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var mark models.Mark
+	if err := initializers.DB.Preload("Course").Where("id = ? AND student_id = ?", filing.MarkID, student.ID).
+		First(&mark).Error; err != nil {
+		return nil, fiber.NewError(fiber.StatusNotFound, "Mark not found")
+	}
+
+	frozen, err := MarkFrozen(mark)
+	if err != nil {
+		return nil, err
+	}
+	if !frozen {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "Only marks of a published result can be re-evaluated")
+	}
+
+	// Amendments correct the latest attempt, so that is the one a student can question
+	var later int64
+	if err := initializers.DB.Model(&models.Mark{}).
+		Where("student_id = ? AND course_id = ? AND semester_id = ? AND attempt > ?", mark.StudentID, mark.CourseID, mark.SemesterID, mark.Attempt).
+		Count(&later).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch attempts: %w", err)
+	}
+	if later > 0 {
+		return nil, fiber.NewError(fiber.StatusConflict, "Only the latest attempt of a course can be re-evaluated")
+	}
+
+	var open int64
+	if err := initializers.DB.Model(&models.ReevaluationRequest{}).
+		Where("mark_id = ? AND status NOT IN ?", mark.ID, []string{"changed", "unchanged"}).
+		Count(&open).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch re-evaluation requests: %w", err)
+	}
+	if open > 0 {
+		return nil, fiber.NewError(fiber.StatusConflict, "A re-evaluation of this mark is already in progress")
+	}
+
+	request := models.ReevaluationRequest{
+		UserID:            user.ID,
+		StudentID:         student.ID,
+		MarkID:            mark.ID,
+		BatchID:           mark.BatchID,
+		ProgramID:         mark.ProgramID,
+		SemesterID:        mark.SemesterID,
+		CourseID:          mark.CourseID,
+		Kind:              filing.Kind,
+		Reason:            filing.Reason,
+		Status:            "submitted",
+		OldSemesterMarks:  mark.SemesterMarks,
+		OldAssistantMarks: mark.AssistantMarks,
+		OldPracticalMarks: mark.PracticalMarks,
+		OldStatus:         mark.Status,
+	}
+	err = initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Create(&request).Error; err != nil {
+			return fmt.Errorf("failed to save re-evaluation request: %w", err)
+		}
+		return notifyReevaluation(tx, request, "Re-evaluation request received",
+			fmt.Sprintf("Your %s request for %s was received. It is reviewed once the fee is paid.", request.Kind, mark.Course.CourseCode))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+// RecordReevaluationFee marks the fee of a submitted request as paid
+func RecordReevaluationFee(id uint, reference string) (*models.ReevaluationRequest, error) {
+	var request models.ReevaluationRequest
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Course").First(&request, id).Error; err != nil {
+			return fiber.NewError(fiber.StatusNotFound, "Re-evaluation request not found")
+		}
+		if request.Status != "submitted" {
+			return fiber.NewError(fiber.StatusConflict, fmt.Sprintf("The fee can only be recorded for a submitted request, this one is %s", request.Status))
+		}
+
+		now := time.Now()
+		request.Status = "fee_paid"
+		request.FeeReference = reference
+		request.FeePaidAt = &now
+		if err := tx.Omit(clause.Associations).Save(&request).Error; err != nil {
+			return fmt.Errorf("failed to update re-evaluation request: %w", err)
+		}
+		return notifyReevaluation(tx, request, "Re-evaluation fee received",
+			fmt.Sprintf("The fee for your %s request for %s was received.", request.Kind, request.Course.CourseCode))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+// AssignReevaluationReviewer puts a paid request under review by an admin or an examiner of the
+// course. A request under review can be handed to another reviewer.
+func AssignReevaluationReviewer(id, reviewerID uint) (*models.ReevaluationRequest, error) {
+	var request models.ReevaluationRequest
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Course").First(&request, id).Error; err != nil {
+			return fiber.NewError(fiber.StatusNotFound, "Re-evaluation request not found")
+		}
+		if request.Status != "fee_paid" && request.Status != "under_review" {
+			return fiber.NewError(fiber.StatusConflict, fmt.Sprintf("Only a paid request can be reviewed, this one is %s", request.Status))
+		}
+
+		var reviewer models.User
+		if err := tx.First(&reviewer, reviewerID).Error; err != nil {
+			return fiber.NewError(fiber.StatusNotFound, "Reviewer not found")
+		}
+		access, err := UserAccess(strconv.FormatUint(uint64(reviewer.ID), 10))
+		if err != nil {
+			return err
+		}
+		if !access.Can("reevaluations:review") {
+			return fiber.NewError(fiber.StatusBadRequest, "The reviewer's role cannot review re-evaluations")
+		}
+		if err := CheckExaminer(strconv.FormatUint(uint64(reviewer.ID), 10), request.BatchID, request.CourseID); err != nil {
+			var fiberErr *fiber.Error
+			if errors.As(err, &fiberErr) {
+				return fiber.NewError(fiber.StatusBadRequest, "The reviewer cannot enter marks of the course")
+			}
+			return err
+		}
+
+		firstAssignment := request.Status == "fee_paid"
+		now := time.Now()
+		request.Status = "under_review"
+		request.ReviewerID = &reviewer.ID
+		request.AssignedAt = &now
+		if err := tx.Omit(clause.Associations).Save(&request).Error; err != nil {
+			return fmt.Errorf("failed to update re-evaluation request: %w", err)
+		}
+		if !firstAssignment {
+			return nil
+		}
+		return notifyReevaluation(tx, request, "Re-evaluation under review",
+			fmt.Sprintf("Your %s request for %s is under review.", request.Kind, request.Course.CourseCode))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+// DecideReevaluation records the reviewer's outcome. Marks that differ from the published ones
+// are amended, which recomputes the pass or fail status of the mark and the standing of the
+// student in a new revision of the result. The student is told the outcome either way.
+func DecideReevaluation(id uint, reviewerID string, decision models.ReevaluationDecision) (*models.ReevaluationRequest, error) {
+	var request models.ReevaluationRequest
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Course").First(&request, id).Error; err != nil {
+			return fiber.NewError(fiber.StatusNotFound, "Re-evaluation request not found")
+		}
+		if request.Status != "under_review" {
+			return fiber.NewError(fiber.StatusConflict, fmt.Sprintf("Only a request under review can be decided, this one is %s", request.Status))
+		}
+		if request.ReviewerID == nil || strconv.FormatUint(uint64(*request.ReviewerID), 10) != reviewerID {
+			return fiber.NewError(fiber.StatusForbidden, "Only the assigned reviewer can decide this request")
+		}
+
+		var mark models.Mark
+		if err := tx.First(&mark, request.MarkID).Error; err != nil {
+			return fiber.NewError(fiber.StatusNotFound, "Mark not found")
+		}
+
+		var result models.Result
+		if err := tx.Where("batch_id = ? AND program_id = ? AND semester_id = ? AND status = ?", mark.BatchID, mark.ProgramID, mark.SemesterID, "Published").
+			First(&result).Error; err != nil {
+			return fiber.NewError(fiber.StatusConflict, "The result of the mark is no longer published")
+		}
+		published, err := latestPublication(tx, result.ID)
+		if err != nil {
+			return err
+		}
+		for _, student := range published.Students {
+			if student.StudentID == request.StudentID {
+				request.OldStanding = student.Status
+				request.OldSGPA = student.SGPA
+			}
+		}
+
+		now := time.Now()
+		request.Remarks = decision.Remarks
+		request.DecidedAt = &now
+		if decision.SemesterMarks == mark.SemesterMarks && decision.AssistantMarks == mark.AssistantMarks &&
+			decision.PracticalMarks == mark.PracticalMarks {
+			request.Status = "unchanged"
+			request.NewStatus = mark.Status
+			request.NewStanding = request.OldStanding
+			request.NewSGPA = request.OldSGPA
+		} else {
+			amendment := models.MarkAmendmentRequest{
+				MarksPayload: models.MarksPayload{
+					BatchID:    mark.BatchID,
+					ProgramID:  mark.ProgramID,
+					SemesterID: mark.SemesterID,
+					CourseID:   mark.CourseID,
+					Marks: []models.StudentMarks{{
+						StudentID:      mark.StudentID,
+						SemesterMarks:  decision.SemesterMarks,
+						AssistantMarks: decision.AssistantMarks,
+						PracticalMarks: decision.PracticalMarks,
+					}},
+				},
+				Reason: fmt.Sprintf("Re-evaluation request %d (%s)", request.ID, request.Kind),
+			}
+			snapshot, amendments, err := amendMarks(tx, request.Course, amendment, reviewerID)
+			if err != nil {
+				return err
+			}
+			if amendments[0].MarkID != mark.ID {
+				return fiber.NewError(fiber.StatusConflict, "A later attempt of the course was recorded after the request was filed")
+			}
+
+			request.Status = "changed"
+			request.NewStatus = amendments[0].NewStatus
+			request.MarkAmendmentID = &amendments[0].ID
+			for _, student := range snapshot.Students {
+				if student.StudentID == request.StudentID {
+					request.NewStanding = student.Status
+					request.NewSGPA = student.SGPA
+				}
+			}
+		}
+		request.NewSemesterMarks = &decision.SemesterMarks
+		request.NewAssistantMarks = &decision.AssistantMarks
+		request.NewPracticalMarks = &decision.PracticalMarks
+
+		if err := tx.Omit(clause.Associations).Save(&request).Error; err != nil {
+			return fmt.Errorf("failed to update re-evaluation request: %w", err)
+		}
+
+		message := fmt.Sprintf("The re-evaluation of %s did not change your marks.", request.Course.CourseCode)
+		if request.Status == "changed" {
+			message = fmt.Sprintf("The re-evaluation of %s changed your total from %d to %d and the course is now %s. Your semester standing is %s with an SGPA of %.2f.",
+				request.Course.CourseCode, request.OldSemesterMarks+request.OldAssistantMarks+request.OldPracticalMarks,
+				decision.SemesterMarks+decision.AssistantMarks+decision.PracticalMarks, request.NewStatus, request.NewStanding, request.NewSGPA)
+		}
+		return notifyReevaluation(tx, request, "Re-evaluation decided", message)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if request.Status == "changed" {
+		refreshPublicResults(request.BatchID, request.ProgramID)
+	}
+	return &request, nil
+}
+
+func notifyReevaluation(tx *gorm.DB, request models.ReevaluationRequest, title, message string) error {
+	return Notify(tx, request.UserID, title, message, "/reevaluations/my")
+}
@@ -0,0 +1,159 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mysterybee07/result-distribution-system/initializers"
+	"github.com/mysterybee07/result-distribution-system/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SubmitRegistrationList records the students a college wants registered, pending approval
+func SubmitRegistrationList(collegeID, userID uint, input models.StudentRegistrationInput) (*models.StudentRegistrationList, error) {
+	var batch models.Batch
+	if err := initializers.DB.First(&batch, input.BatchID).Error; err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "Batch not found")
+	}
+	var program models.Program
+	if err := initializers.DB.First(&program, input.ProgramID).Error; err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "Program not found")
+	}
+	if len(input.Students) == 0 {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "The list has no students")
+	}
+
+	list := models.StudentRegistrationList{
+		CollegeID:   collegeID,
+		BatchID:     input.BatchID,
+		ProgramID:   input.ProgramID,
+		SubmittedBy: userID,
+		Status:      "Pending",
+	}
+	symbols := make(map[string]bool)
+	registrations := make(map[string]bool)
+	for _, s := range input.Students {
+		entry := models.StudentRegistrationEntry{
+			SymbolNumber:       strings.TrimSpace(s.SymbolNumber),
+			RegistrationNumber: strings.TrimSpace(s.RegistrationNumber),
+			Fullname:           strings.TrimSpace(s.Fullname),
+		}
+		if entry.SymbolNumber == "" || entry.RegistrationNumber == "" || entry.Fullname == "" {
+			return nil, fiber.NewError(fiber.StatusBadRequest, "Every student needs a fullname, symbol number and registration number")
+		}
+		if symbols[entry.SymbolNumber] || registrations[entry.RegistrationNumber] {
+			return nil, fiber.NewError(fiber.StatusBadRequest,
+				fmt.Sprintf("Symbol number %s or its registration number appears twice in the list", entry.SymbolNumber))
+		}
+		symbols[entry.SymbolNumber] = true
+		registrations[entry.RegistrationNumber] = true
+
+		if s.DateOfBirth != "" {
+			dob, err := time.Parse("2006-01-02", s.DateOfBirth)
+			if err != nil {
+				return nil, fiber.NewError(fiber.StatusBadRequest,
+					fmt.Sprintf("Invalid date of birth for symbol number %s, use 2006-01-02", entry.SymbolNumber))
+			}
+			entry.DateOfBirth = &dob
+		}
+		if err := checkRegistrationEntry(initializers.DB, list, entry); err != nil {
+			return nil, err
+		}
+		list.Entries = append(list.Entries, entry)
+	}
+
+	if err := initializers.DB.Create(&list).Error; err != nil {
+		return nil, fmt.Errorf("failed to save registration list: %w", err)
+	}
+	return &list, nil
+}
+
+// ReviewRegistrationList approves or rejects a pending list. Approval creates the students, so
+// a symbol or registration number taken since the list was submitted fails the whole list.
+func ReviewRegistrationList(listID uint, access *Access, approve bool, remarks string) (*models.StudentRegistrationList, error) {
+	var list models.StudentRegistrationList
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Entries").First(&list, listID).Error; err != nil {
+			return fiber.NewError(fiber.StatusNotFound, "Registration list not found")
+		}
+		if !access.AllowsStudent(models.Student{ProgramID: list.ProgramID, CollegeID: list.CollegeID}) {
+			return fiber.NewError(fiber.StatusNotFound, "Registration list not found")
+		}
+		if list.Status != "Pending" {
+			return fiber.NewError(fiber.StatusConflict, fmt.Sprintf("Registration list is already %s", strings.ToLower(list.Status)))
+		}
+
+		if approve {
+			for i := range list.Entries {
+				entry := &list.Entries[i]
+				if err := checkRegistrationEntry(tx, list, *entry); err != nil {
+					return err
+				}
+				student := models.Student{
+					SymbolNumber:       entry.SymbolNumber,
+					RegistrationNumber: entry.RegistrationNumber,
+					Fullname:           entry.Fullname,
+					DateOfBirth:        entry.DateOfBirth,
+					BatchID:            list.BatchID,
+					ProgramID:          list.ProgramID,
+					CollegeID:          list.CollegeID,
+				}
+				if err := tx.Create(&student).Error; err != nil {
+					return fmt.Errorf("failed to create student: %w", err)
+				}
+				entry.StudentID = &student.ID
+				if err := tx.Model(entry).Update("student_id", student.ID).Error; err != nil {
+					return fmt.Errorf("failed to update registration entry: %w", err)
+				}
+			}
+		}
+
+		now := time.Now()
+		list.Status = "Rejected"
+		if approve {
+			list.Status = "Approved"
+		}
+		list.Remarks = remarks
+		list.ReviewedBy = &access.UserID
+		list.ReviewedAt = &now
+		if err := tx.Model(&list).Updates(map[string]interface{}{
+			"status":      list.Status,
+			"remarks":     list.Remarks,
+			"reviewed_by": access.UserID,
+			"reviewed_at": now,
+		}).Error; err != nil {
+			return fmt.Errorf("failed to update registration list: %w", err)
+		}
+
+		message := fmt.Sprintf("Your registration list #%d of %d students was %s.", list.ID, len(list.Entries), strings.ToLower(list.Status))
+		if remarks != "" {
+			message += " " + remarks
+		}
+		return Notify(tx, list.SubmittedBy, "Registration list "+strings.ToLower(list.Status), message,
+			fmt.Sprintf("/college-portal/registrations/%d", list.ID))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// checkRegistrationEntry fails when a student of the batch and program already has the symbol
+// or registration number
+func checkRegistrationEntry(db *gorm.DB, list models.StudentRegistrationList, entry models.StudentRegistrationEntry) error {
+	var count int64
+	if err := db.Model(&models.Student{}).
+		Where("batch_id = ? AND program_id = ? AND (symbol_number = ? OR registration_number = ?)",
+			list.BatchID, list.ProgramID, entry.SymbolNumber, entry.RegistrationNumber).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check existing students: %w", err)
+	}
+	if count > 0 {
+		return fiber.NewError(fiber.StatusConflict,
+			fmt.Sprintf("Symbol number %s or its registration number is already taken for the batch and program", entry.SymbolNumber))
+	}
+	return nil
+}
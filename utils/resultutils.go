@@ -1,10 +1,16 @@
 package utils
 
 import (
+	"errors"
+	"fmt"
 	"log"
+	"time"
 
+	"github.com/gofiber/fiber/v2"
 	"github.com/mysterybee07/result-distribution-system/initializers"
 	"github.com/mysterybee07/result-distribution-system/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 func GetPassStatusBySemester(semesterID string) (map[uint]string, error) {
@@ -14,6 +20,13 @@ func GetPassStatusBySemester(semesterID string) (map[uint]string, error) {
 		return nil, err
 	}
 
+	// Only the attempt the program counts decides a course
+	marks, err := CountedMarks(marks)
+	if err != nil {
+		log.Printf("Failed to pick counted marks: %v\n", err)
+		return nil, err
+	}
+
 	// Track pass status for each student
 	passStatus := make(map[uint]string)
 
@@ -27,3 +40,541 @@ func GetPassStatusBySemester(semesterID string) (map[uint]string, error) {
 
 	return passStatus, nil
 }
+
+// StudentPublication is what publishing a semester result does to one student
+type StudentPublication struct {
+	StudentID       uint     `json:"student_id"`
+	SymbolNumber    string   `json:"symbol_number"`
+	Fullname        string   `json:"fullname"`
+	CurrentSemester uint     `json:"current_semester"`
+	Standing        string   `json:"standing"`                  // pass or fail over the counted marks of the semester
+	Action          string   `json:"action"`                    // promote, graduate, carry (promoted with back papers) or hold
+	FailedCourses   []string `json:"failed_courses,omitempty"`  // Counted fails of the semester
+	Outstanding     []string `json:"outstanding,omitempty"`     // Counted fails of earlier semesters
+	MissingCourses  []string `json:"missing_courses,omitempty"` // Compulsory courses without marks
+	OptionalMarked  int      `json:"optional_marked"`
+	Problem         string   `json:"problem,omitempty"`
+}
+
+// PublicationReport previews the publication of a semester result
+type PublicationReport struct {
+	BatchID          uint                 `json:"batch_id"`
+	ProgramID        uint                 `json:"program_id"`
+	SemesterID       uint                 `json:"semester_id"`
+	PromotionRule    string               `json:"promotion_rule"`
+	DryRun           bool                 `json:"dry_run"`
+	Ready            bool                 `json:"ready"` // No student is missing marks
+	Promoted         int                  `json:"promoted"`
+	Carried          int                  `json:"carried"` // Promoted with back papers
+	Held             int                  `json:"held"`
+	Graduated        int                  `json:"graduated"`
+	Incomplete       int                  `json:"incomplete"`
+	BackPapers       int                  `json:"back_papers"` // Registered by the publication
+	BackPaperSkipped []string             `json:"back_paper_skipped,omitempty"`
+	Students         []StudentPublication `json:"students"`
+}
+
+// finalSemester is the semester whose result graduates the students who passed everything
+const finalSemester = 8
+
+// promotionAction decides what a publication does to a student from their counted standing. A
+// student graduates only with nothing failed in any semester; below the final semester a fail
+// carries the student up with back papers or holds them back, as the program's rule says.
+func promotionAction(passed bool, currentSemester uint, outstanding bool, rule string) string {
+	if currentSemester >= finalSemester {
+		if passed && !outstanding {
+			return "graduate"
+		}
+		return "hold"
+	}
+	switch {
+	case passed:
+		return "promote"
+	case rule == "hold":
+		return "hold"
+	default:
+		return "carry"
+	}
+}
+
+// courseStanding is what a student failed by their counted marks
+type courseStanding struct {
+	failed      []string // Course codes of the semester
+	outstanding []string // Course codes of the semesters before it
+}
+
+// cohortStandings reads every attempt of the semester and the semesters before it through db
+// and returns each student's counted fails, along with the semester's marks with Student and
+// Course loaded
+func cohortStandings(db *gorm.DB, program models.Program, batchID, semesterID uint) (map[uint]*courseStanding, []models.Mark, error) {
+	var semester models.Semester
+	if err := db.First(&semester, semesterID).Error; err != nil {
+		return nil, nil, fiber.NewError(fiber.StatusNotFound, "Semester not found")
+	}
+	var semesterIDs []uint
+	if err := db.Model(&models.Semester{}).
+		Where("program_id = ? AND semester_name <= ?", program.ID, semester.SemesterName).
+		Pluck("id", &semesterIDs).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch semesters: %w", err)
+	}
+
+	var marks []models.Mark
+	if err := db.Preload("Student").Preload("Course").
+		Where("batch_id = ? AND program_id = ? AND semester_id IN ?", batchID, program.ID, semesterIDs).
+		Order("student_id, course_id, attempt").
+		Find(&marks).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch marks: %w", err)
+	}
+
+	standings := make(map[uint]*courseStanding)
+	for _, mark := range countedMarks(marks, map[uint]string{program.ID: program.BackPaperRule}) {
+		standing, ok := standings[mark.StudentID]
+		if !ok {
+			standing = &courseStanding{}
+			standings[mark.StudentID] = standing
+		}
+		if mark.Status == "pass" {
+			continue
+		}
+		if mark.SemesterID == semesterID {
+			standing.failed = append(standing.failed, mark.Course.CourseCode)
+		} else {
+			standing.outstanding = append(standing.outstanding, mark.Course.CourseCode)
+		}
+	}
+
+	var semesterMarks []models.Mark
+	for _, mark := range marks {
+		if mark.SemesterID == semesterID {
+			semesterMarks = append(semesterMarks, mark)
+		}
+	}
+	return standings, semesterMarks, nil
+}
+
+// PlanResultPublication checks that every active student of the cohort has marks for all
+// compulsory courses and exactly one optional course of each elective group, and works out from
+// the counted marks who is promoted, carried up with back papers, held back or graduates
+func PlanResultPublication(db *gorm.DB, batchID, programID, semesterID uint) (*PublicationReport, error) {
+	report, _, err := planResultPublication(db, batchID, programID, semesterID)
+	return report, err
+}
+
+// planResultPublication also returns the program and the semester's marks, which publishing
+// registers back papers from
+func planResultPublication(db *gorm.DB, batchID, programID, semesterID uint) (*PublicationReport, []models.Mark, error) {
+	var program models.Program
+	if err := db.First(&program, programID).Error; err != nil {
+		return nil, nil, fiber.NewError(fiber.StatusNotFound, "Program not found")
+	}
+	if !PromotionRules[program.PromotionRule] {
+		program.PromotionRule = "carry"
+	}
+
+	var students []models.Student
+	if err := db.Where("status = ? AND batch_id = ? AND program_id = ?", "active", batchID, programID).
+		Order("symbol_number").Find(&students).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch students: %w", err)
+	}
+
+	var courses []models.Course
+	if err := db.Where("program_id = ? AND semester_id = ?", programID, semesterID).Find(&courses).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch courses: %w", err)
+	}
+	// Optional courses without a group form one group of the semester
+	electiveGroups := make(map[string]bool)
+	for _, course := range courses {
+		if !course.IsCompulsory {
+			electiveGroups[course.ElectiveGroup] = true
+		}
+	}
+
+	standings, marks, err := cohortStandings(db, program, batchID, semesterID)
+	if err != nil {
+		return nil, nil, err
+	}
+	marked := make(map[uint]map[uint]bool)
+	for _, mark := range marks {
+		if marked[mark.StudentID] == nil {
+			marked[mark.StudentID] = make(map[uint]bool)
+		}
+		marked[mark.StudentID][mark.CourseID] = true
+	}
+
+	report := &PublicationReport{
+		BatchID:       batchID,
+		ProgramID:     programID,
+		SemesterID:    semesterID,
+		PromotionRule: program.PromotionRule,
+		Ready:         true,
+	}
+	for _, student := range students {
+		line := StudentPublication{
+			StudentID:       student.ID,
+			SymbolNumber:    student.SymbolNumber,
+			Fullname:        student.Fullname,
+			CurrentSemester: student.CurrentSemester,
+			Standing:        "pass",
+		}
+		if standing, ok := standings[student.ID]; ok {
+			line.FailedCourses = standing.failed
+			line.Outstanding = standing.outstanding
+		}
+		if len(line.FailedCourses) > 0 {
+			line.Standing = "fail"
+		}
+		line.Action = promotionAction(line.Standing == "pass", student.CurrentSemester, len(line.Outstanding) > 0, program.PromotionRule)
+
+		groupMarked := make(map[string]int)
+		for _, course := range courses {
+			if !marked[student.ID][course.ID] {
+				if course.IsCompulsory {
+					line.MissingCourses = append(line.MissingCourses, course.CourseCode)
+				}
+			} else if !course.IsCompulsory {
+				line.OptionalMarked++
+				groupMarked[course.ElectiveGroup]++
+			}
+		}
+		electivesMarked := true
+		for group := range electiveGroups {
+			if groupMarked[group] != 1 {
+				electivesMarked = false
+			}
+		}
+
+		switch {
+		case len(line.MissingCourses) > 0:
+			line.Problem = "Compulsory courses are not marked"
+		case !electivesMarked:
+			line.Problem = "Exactly one optional course of each elective group must be marked"
+		}
+
+		if line.Problem != "" {
+			report.Ready = false
+			report.Incomplete++
+		} else {
+			switch line.Action {
+			case "graduate":
+				report.Graduated++
+			case "carry":
+				report.Carried++
+			case "hold":
+				report.Held++
+			default:
+				report.Promoted++
+			}
+		}
+		report.Students = append(report.Students, line)
+	}
+	return report, marks, nil
+}
+
+// PublishResults applies the planned promotions to the active students of the cohort, registers
+// the back papers of the students carried up and stores the published result in one
+// transaction, so a failure leaves nothing half published. A withdrawn result is published again
+// under the next revision. A dry run only returns the report.
+func PublishResults(batchID, programID, semesterID uint, dryRun bool) (*PublicationReport, *models.Result, error) {
+	var report *PublicationReport
+	var result *models.Result
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		// Check if results are already published for the given batch, program, and semester. The
+		// row lock makes a concurrent publication wait for this one.
+		var existing models.Result
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("batch_id = ? AND program_id = ? AND semester_id = ?", batchID, programID, semesterID).First(&existing).Error
+		if err == nil && existing.Status != "Withdrawn" {
+			return fiber.NewError(fiber.StatusBadRequest, "Result already published for the given semester with batch and program")
+		} else if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("failed to check existing result: %w", err)
+		}
+
+		var marks []models.Mark
+		if report, marks, err = planResultPublication(tx, batchID, programID, semesterID); err != nil {
+			return err
+		}
+		report.DryRun = dryRun
+		if dryRun {
+			return nil
+		}
+		if !report.Ready {
+			return fiber.NewError(fiber.StatusBadRequest, "All compulsory courses and exactly one optional course of each elective group are required to be marked for each student")
+		}
+
+		var students []models.ResultSnapshotStudent
+		for _, line := range report.Students {
+			if err := applyPromotion(tx, line.StudentID, line.Action); err != nil {
+				return err
+			}
+			students = append(students, models.ResultSnapshotStudent{
+				StudentID:        line.StudentID,
+				SymbolNumber:     line.SymbolNumber,
+				Fullname:         line.Fullname,
+				Action:           line.Action,
+				PreviousSemester: line.CurrentSemester,
+				PreviousStatus:   "active",
+			})
+		}
+
+		// Under the carry rule every counted fail of the semester becomes a back paper
+		var program models.Program
+		if err := tx.First(&program, programID).Error; err != nil {
+			return fmt.Errorf("failed to fetch program: %w", err)
+		}
+		if report.PromotionRule == "carry" {
+			registrations, skipped, err := registerFailedCourses(tx, program, batchID, semesterID, marks)
+			if err != nil {
+				return err
+			}
+			report.BackPapers = len(registrations)
+			report.BackPaperSkipped = skipped
+		}
+
+		action := "published"
+		if existing.Model != nil {
+			// Publishing a withdrawn result again
+			now := time.Now()
+			existing.Status = "Published"
+			existing.Revision++
+			existing.RepublishedAt = &now
+			existing.WithdrawnAt = nil
+			if err := tx.Save(&existing).Error; err != nil {
+				return fmt.Errorf("failed to save result: %w", err)
+			}
+			result = &existing
+			action = "republished"
+		} else {
+			result = &models.Result{
+				BatchID:    batchID,
+				ProgramID:  programID,
+				SemesterID: semesterID,
+				Status:     "Published",
+			}
+			// Without a row to lock, the unique index stops a second first publication
+			if err := tx.Create(result).Error; err != nil {
+				if isDuplicateKey(err) {
+					return fiber.NewError(fiber.StatusBadRequest, "Result already published for the given semester with batch and program")
+				}
+				return fmt.Errorf("failed to save result: %w", err)
+			}
+		}
+
+		_, err = takeResultSnapshot(tx, result, action, "", students)
+		return err
+	})
+	if err != nil {
+		return report, nil, err
+	}
+	if !dryRun {
+		refreshPublicResults(batchID, programID)
+	}
+	return report, result, nil
+}
+
+// WithdrawResult takes a published result back and undoes the promotions and graduations its
+// last publication applied. Students whose semester or status changed since are left alone and
+// returned by symbol number.
+func WithdrawResult(batchID, programID, semesterID uint, reason string) (*models.Result, []string, error) {
+	var result models.Result
+	var skipped []string
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("batch_id = ? AND program_id = ? AND semester_id = ? AND status = ?", batchID, programID, semesterID, "Published").
+			First(&result).Error; err != nil {
+			return fiber.NewError(fiber.StatusNotFound, "No published result for the given semester with batch and program")
+		}
+
+		// Promotions of a later semester build on this one
+		var semester models.Semester
+		if err := tx.First(&semester, semesterID).Error; err != nil {
+			return fiber.NewError(fiber.StatusNotFound, "Semester not found")
+		}
+		var later int64
+		if err := tx.Model(&models.Result{}).
+			Joins("JOIN semesters ON semesters.id = results.semester_id").
+			Where("results.batch_id = ? AND results.program_id = ? AND results.status = ? AND semesters.semester_name > ?", batchID, programID, "Published", semester.SemesterName).
+			Count(&later).Error; err != nil {
+			return fmt.Errorf("failed to check later results: %w", err)
+		}
+		if later > 0 {
+			return fiber.NewError(fiber.StatusConflict, "A later semester result is published for the batch and program; withdraw it first")
+		}
+
+		published, err := latestPublication(tx, result.ID)
+		if err != nil {
+			return err
+		}
+
+		for _, student := range published.Students {
+			if student.Action == "hold" {
+				continue
+			}
+			undo := tx.Model(&models.Student{}).Where("id = ? AND current_semester = ?", student.StudentID, student.PreviousSemester+1)
+			updates := map[string]interface{}{"current_semester": student.PreviousSemester}
+			if student.Action == "graduate" {
+				undo = tx.Model(&models.Student{}).Where("id = ? AND status = ?", student.StudentID, "Graduated")
+				updates = map[string]interface{}{"status": student.PreviousStatus}
+			}
+			res := undo.Updates(updates)
+			if res.Error != nil {
+				return fmt.Errorf("failed to undo the promotion of student %d: %w", student.StudentID, res.Error)
+			}
+			if res.RowsAffected == 0 {
+				skipped = append(skipped, student.SymbolNumber)
+			}
+		}
+
+		// The fails the back papers were registered for are withdrawn too; back papers already
+		// scheduled are left, and publishing again registers the rest anew
+		if err := tx.Model(&models.BackPaperRegistration{}).
+			Where("batch_id = ? AND program_id = ? AND semester_id = ? AND status = ?", batchID, programID, semesterID, "Registered").
+			Update("status", "Cancelled").Error; err != nil {
+			return fmt.Errorf("failed to cancel back paper registrations: %w", err)
+		}
+
+		now := time.Now()
+		result.Status = "Withdrawn"
+		result.WithdrawnAt = &now
+		if err := tx.Save(&result).Error; err != nil {
+			return fmt.Errorf("failed to withdraw result: %w", err)
+		}
+
+		_, err = takeResultSnapshot(tx, &result, "withdrawn", reason, nil)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	refreshPublicResults(batchID, programID)
+	return &result, skipped, nil
+}
+
+// applyPromotion moves a student as a publication decided: up a semester, out as a graduate, or
+// nowhere when held back
+func applyPromotion(tx *gorm.DB, studentID uint, action string) error {
+	var updates map[string]interface{}
+	switch action {
+	case "promote", "carry":
+		updates = map[string]interface{}{"current_semester": gorm.Expr("current_semester + 1")}
+	case "graduate":
+		updates = map[string]interface{}{"status": "Graduated"}
+	default:
+		return nil
+	}
+	if err := tx.Model(&models.Student{}).Where("id = ?", studentID).Updates(updates).Error; err != nil {
+		return fmt.Errorf("failed to update semester for student %d: %w", studentID, err)
+	}
+	return nil
+}
+
+// ResultHistory returns a result with every snapshot taken of it, oldest first
+func ResultHistory(batchID, programID, semesterID uint) (*models.Result, error) {
+	var result models.Result
+	if err := initializers.DB.
+		Preload("Snapshots", func(db *gorm.DB) *gorm.DB { return db.Order("version") }).
+		Preload("Snapshots.Students", func(db *gorm.DB) *gorm.DB { return db.Order("symbol_number") }).
+		Preload("Snapshots.Students.Courses").
+		Where("batch_id = ? AND program_id = ? AND semester_id = ?", batchID, programID, semesterID).
+		First(&result).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fiber.NewError(fiber.StatusNotFound, "Result not found for the given semester with batch and program")
+		}
+		return nil, fmt.Errorf("failed to fetch result: %w", err)
+	}
+	return &result, nil
+}
+
+// latestPublication is the newest snapshot of a result that students could see
+func latestPublication(db *gorm.DB, resultID uint) (*models.ResultSnapshot, error) {
+	var snapshot models.ResultSnapshot
+	if err := db.Preload("Students").
+		Where("result_id = ? AND action <> ?", resultID, "withdrawn").
+		Order("version desc").First(&snapshot).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fiber.NewError(fiber.StatusConflict, "The result has no recorded publication")
+		}
+		return nil, fmt.Errorf("failed to fetch result snapshot: %w", err)
+	}
+	return &snapshot, nil
+}
+
+// carriedPromotions copies the students of a publication, without their grades, so a revision
+// keeps the promotions it does not apply again
+func carriedPromotions(published *models.ResultSnapshot) []models.ResultSnapshotStudent {
+	var students []models.ResultSnapshotStudent
+	for _, student := range published.Students {
+		students = append(students, models.ResultSnapshotStudent{
+			StudentID:        student.StudentID,
+			SymbolNumber:     student.SymbolNumber,
+			Fullname:         student.Fullname,
+			Action:           student.Action,
+			PreviousSemester: student.PreviousSemester,
+			PreviousStatus:   student.PreviousStatus,
+		})
+	}
+	return students
+}
+
+// takeResultSnapshot stores the grades the students now have as the next version of a result and
+// rebuilds the transcripts of the cohort. The students carry the promotion that goes with the
+// publication; grades are filled in here.
+func takeResultSnapshot(tx *gorm.DB, result *models.Result, action, reason string, students []models.ResultSnapshotStudent) (*models.ResultSnapshot, error) {
+	var count int64
+	if err := tx.Model(&models.ResultSnapshot{}).Where("result_id = ?", result.ID).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("failed to count result snapshots: %w", err)
+	}
+
+	if len(students) > 0 {
+		grades, err := cohortGrades(tx, result.BatchID, result.ProgramID, result.SemesterID)
+		if err != nil {
+			return nil, err
+		}
+		byStudent := make(map[uint]ResultGrade)
+		for _, grade := range grades {
+			byStudent[grade.StudentID] = grade
+		}
+
+		for i := range students {
+			grade := byStudent[students[i].StudentID]
+			students[i].Status = grade.Status
+			students[i].TotalMarks = grade.TotalMarks
+			students[i].SGPA = grade.SGPA
+			students[i].CGPA = grade.CGPA
+			students[i].Courses = nil
+			for _, course := range grade.Courses {
+				students[i].Courses = append(students[i].Courses, models.ResultSnapshotCourse{
+					CourseID:       course.CourseID,
+					CourseCode:     course.CourseCode,
+					CourseName:     course.CourseName,
+					Attempt:        course.Attempt,
+					CreditHours:    course.CreditHours,
+					SemesterMarks:  course.SemesterMarks,
+					PracticalMarks: course.PracticalMarks,
+					AssistantMarks: course.AssistantMarks,
+					TotalMarks:     course.TotalMarks,
+					FullMarks:      course.FullMarks,
+					Letter:         course.Letter,
+					GradePoint:     course.GradePoint,
+					Status:         course.Status,
+				})
+			}
+		}
+	}
+
+	snapshot := models.ResultSnapshot{
+		ResultID: result.ID,
+		Version:  int(count) + 1,
+		Action:   action,
+		Revision: result.Revision,
+		Reason:   reason,
+		Students: students,
+	}
+	if err := tx.Create(&snapshot).Error; err != nil {
+		return nil, fmt.Errorf("failed to save result snapshot: %w", err)
+	}
+
+	// Lookups read the stored transcripts, which follow every new version
+	if err := RebuildTranscripts(tx, result.BatchID, result.ProgramID); err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
@@ -0,0 +1,218 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mysterybee07/result-distribution-system/initializers"
+	"github.com/mysterybee07/result-distribution-system/models"
+	"gorm.io/gorm"
+)
+
+// GenerateSeatPlan gives every student covered by a published allocation a center, room and seat.
+// Any seat plan generated earlier for the same exam routine is replaced. The number of students
+// left without a seat is returned alongside the seats.
+func GenerateSeatPlan(allocationID uint) ([]models.SeatAssignment, int, error) {
+	var allocation models.CenterAllocation
+	if err := initializers.DB.Preload("Items").Preload("ExamRoutine").First(&allocation, allocationID).Error; err != nil {
+		return nil, 0, fiber.NewError(fiber.StatusNotFound, "Center allocation not found")
+	}
+	if allocation.Status != "Published" {
+		return nil, 0, fiber.NewError(fiber.StatusBadRequest, "Seat plans can only be generated from a published allocation")
+	}
+
+	// A back paper routine only seats the students registered for it
+	var backPaperStudents []uint
+	if allocation.ExamRoutine.Kind == "back_paper" {
+		ids, err := BackPaperStudentIDs(allocation.ExamRoutineID)
+		if err != nil {
+			return nil, 0, err
+		}
+		backPaperStudents = ids
+	}
+
+	// Hand out each college's students to its centers in symbol number order
+	items := allocation.Items
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].CollegeID != items[j].CollegeID {
+			return items[i].CollegeID < items[j].CollegeID
+		}
+		return items[i].CenterID < items[j].CenterID
+	})
+
+	studentsByCenter := make(map[uint][]models.Student)
+	remaining := make(map[uint][]models.Student)
+	loaded := make(map[uint]bool)
+	for _, item := range items {
+		if !loaded[item.CollegeID] {
+			var students []models.Student
+			query := initializers.DB.
+				Where("college_id = ? AND batch_id = ? AND program_id = ?", item.CollegeID, allocation.BatchID, allocation.ProgramID).
+				Order("symbol_number")
+			if allocation.ExamRoutine.Kind == "back_paper" {
+				query = query.Where("id IN ?", backPaperStudents)
+			} else {
+				query = query.Where("status = ?", "active")
+			}
+			if err := query.Find(&students).Error; err != nil {
+				return nil, 0, fmt.Errorf("failed to fetch students: %w", err)
+			}
+			remaining[item.CollegeID] = students
+			loaded[item.CollegeID] = true
+		}
+
+		queue := remaining[item.CollegeID]
+		take := min(item.AssignedSeat, len(queue))
+		studentsByCenter[item.CenterID] = append(studentsByCenter[item.CenterID], queue[:take]...)
+		remaining[item.CollegeID] = queue[take:]
+	}
+
+	// Students registered after the allocation was solved have no seat
+	unseated := 0
+	for _, queue := range remaining {
+		unseated += len(queue)
+	}
+
+	var centerIDs []uint
+	for centerID := range studentsByCenter {
+		centerIDs = append(centerIDs, centerID)
+	}
+	sort.Slice(centerIDs, func(i, j int) bool { return centerIDs[i] < centerIDs[j] })
+
+	var seats []models.SeatAssignment
+	for _, centerID := range centerIDs {
+		var rooms []models.ExamRoom
+		if err := initializers.DB.Where("center_id = ?", centerID).Order("id").Find(&rooms).Error; err != nil {
+			return nil, 0, fmt.Errorf("failed to fetch rooms: %w", err)
+		}
+
+		centerSeats, err := seatStudentsInRooms(studentsByCenter[centerID], rooms)
+		if err != nil {
+			return nil, 0, fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("Center %d: %s", centerID, err.Error()))
+		}
+		for i := range centerSeats {
+			centerSeats[i].ExamRoutineID = allocation.ExamRoutineID
+			centerSeats[i].CenterAllocationID = allocation.ID
+			centerSeats[i].CenterID = centerID
+		}
+		seats = append(seats, centerSeats...)
+	}
+
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("exam_routine_id = ?", allocation.ExamRoutineID).Delete(&models.SeatAssignment{}).Error; err != nil {
+			return fmt.Errorf("failed to clear previous seat plan: %w", err)
+		}
+		if len(seats) == 0 {
+			return nil
+		}
+		if err := tx.Create(&seats).Error; err != nil {
+			return fmt.Errorf("failed to save seat plan: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return seats, unseated, nil
+}
+
+// mixStudentsByCollege interleaves colleges so that neighbouring seats rarely share a college
+func mixStudentsByCollege(students []models.Student) []models.Student {
+	byCollege := make(map[uint][]models.Student)
+	var collegeIDs []uint
+	for _, student := range students {
+		if _, ok := byCollege[student.CollegeID]; !ok {
+			collegeIDs = append(collegeIDs, student.CollegeID)
+		}
+		byCollege[student.CollegeID] = append(byCollege[student.CollegeID], student)
+	}
+	sort.Slice(collegeIDs, func(i, j int) bool { return collegeIDs[i] < collegeIDs[j] })
+
+	mixed := make([]models.Student, 0, len(students))
+	for len(mixed) < len(students) {
+		for _, collegeID := range collegeIDs {
+			if queue := byCollege[collegeID]; len(queue) > 0 {
+				mixed = append(mixed, queue[0])
+				byCollege[collegeID] = queue[1:]
+			}
+		}
+	}
+	return mixed
+}
+
+// seatStudentsInRooms deals students, grouped by college in symbol number order, across the rooms
+// one at a time, so every room gets its share of each college and consecutive symbol numbers of a
+// college end up in different rooms. Inside a room the seats alternate between colleges.
+func seatStudentsInRooms(students []models.Student, rooms []models.ExamRoom) ([]models.SeatAssignment, error) {
+	totalCapacity := 0
+	for _, room := range rooms {
+		totalCapacity += room.Capacity
+	}
+	if totalCapacity < len(students) {
+		return nil, fmt.Errorf("rooms hold %d students but %d are assigned", totalCapacity, len(students))
+	}
+
+	byRoom := make([][]models.Student, len(rooms))
+	room := 0
+	for _, student := range students {
+		for len(byRoom[room]) >= rooms[room].Capacity {
+			room = (room + 1) % len(rooms)
+		}
+		byRoom[room] = append(byRoom[room], student)
+		room = (room + 1) % len(rooms)
+	}
+
+	seats := make([]models.SeatAssignment, 0, len(students))
+	for i, roomStudents := range byRoom {
+		for seat, student := range mixStudentsByCollege(roomStudents) {
+			seats = append(seats, models.SeatAssignment{
+				StudentID:  student.ID,
+				ExamRoomID: rooms[i].ID,
+				SeatNumber: seat + 1,
+			})
+		}
+	}
+	return seats, nil
+}
+
+// SeatPlanCSV renders the seat plan of one room as CSV
+func SeatPlanCSV(examRoutineID, roomID uint) ([]byte, error) {
+	var seats []models.SeatAssignment
+	if err := initializers.DB.Preload("Student.College").Preload("ExamRoom").
+		Where("exam_routine_id = ? AND exam_room_id = ?", examRoutineID, roomID).
+		Order("seat_number").
+		Find(&seats).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch seat plan: %w", err)
+	}
+	if len(seats) == 0 {
+		return nil, fiber.NewError(fiber.StatusNotFound, "No seat plan found for the room")
+	}
+
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+	if err := writer.Write([]string{"Room", "Seat Number", "Symbol Number", "Student Name", "College"}); err != nil {
+		return nil, fmt.Errorf("failed to write header to CSV: %w", err)
+	}
+	for _, seat := range seats {
+		if err := writer.Write([]string{
+			seat.ExamRoom.Name,
+			strconv.Itoa(seat.SeatNumber),
+			seat.Student.SymbolNumber,
+			seat.Student.Fullname,
+			seat.Student.College.CollegeName,
+		}); err != nil {
+			return nil, fmt.Errorf("failed to write seat to CSV: %w", err)
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return nil, fmt.Errorf("failed to write seat plan: %w", err)
+	}
+
+	return buffer.Bytes(), nil
+}
@@ -0,0 +1,291 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mysterybee07/result-distribution-system/initializers"
+	"github.com/mysterybee07/result-distribution-system/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RefreshTokenTTL is how long a session lasts without being used, REFRESH_TOKEN_TTL days or 14
+func RefreshTokenTTL() time.Duration {
+	if days, err := strconv.Atoi(os.Getenv("REFRESH_TOKEN_TTL")); err == nil && days > 0 {
+		return time.Duration(days) * 24 * time.Hour
+	}
+	return 14 * 24 * time.Hour
+}
+
+// StartSession signs a user in: it records a session and sets the access and refresh cookies
+func StartSession(c *fiber.Ctx, user models.User) (*models.Session, error) {
+	now := time.Now()
+	userAgent := c.Get(fiber.HeaderUserAgent)
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+	session := models.Session{
+		UserID:     user.ID,
+		UserAgent:  userAgent,
+		IPAddress:  c.IP(),
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(RefreshTokenTTL()),
+	}
+
+	var raw string
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		// Expired refresh tokens can no longer be replayed, so they need not be kept
+		if err := tx.Unscoped().Where("expires_at < ? AND session_id IN (?)", now,
+			tx.Model(&models.Session{}).Select("id").Where("user_id = ?", user.ID)).
+			Delete(&models.RefreshToken{}).Error; err != nil {
+			return fmt.Errorf("failed to prune refresh tokens: %w", err)
+		}
+		if err := tx.Create(&session).Error; err != nil {
+			return fmt.Errorf("failed to create session: %w", err)
+		}
+		var err error
+		raw, err = issueRefreshToken(tx, session)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	setRefreshCookie(c, raw, session.ExpiresAt)
+
+	if _, err := GenerateJwt(user.ID, user.Role, session.ID, c); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// RefreshSession trades the refresh cookie for a new access token and refresh token. A refresh
+// token that was already used revokes its session, since only a copy can be used twice.
+func RefreshSession(c *fiber.Ctx) (*models.User, error) {
+	presented := c.Cookies("refresh_token")
+	if presented == "" {
+		return nil, fiber.NewError(fiber.StatusUnauthorized, "No refresh token")
+	}
+
+	var user models.User
+	var session models.Session
+	var raw string
+	reused := false
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		var token models.RefreshToken
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", hashToken(presented)).First(&token).Error; err != nil {
+			return fiber.NewError(fiber.StatusUnauthorized, "Invalid refresh token")
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&session, token.SessionID).Error; err != nil {
+			return fiber.NewError(fiber.StatusUnauthorized, "Invalid refresh token")
+		}
+
+		now := time.Now()
+		if session.RevokedAt != nil || now.After(session.ExpiresAt) || now.After(token.ExpiresAt) {
+			return fiber.NewError(fiber.StatusUnauthorized, "The session has ended, sign in again")
+		}
+		if token.UsedAt != nil {
+			reused = true
+			return tx.Model(&session).Update("revoked_at", now).Error
+		}
+
+		if err := tx.Model(&token).Update("used_at", now).Error; err != nil {
+			return fmt.Errorf("failed to update refresh token: %w", err)
+		}
+		session.LastUsedAt = now
+		session.ExpiresAt = now.Add(RefreshTokenTTL())
+		if err := tx.Model(&session).Updates(map[string]interface{}{
+			"last_used_at": session.LastUsedAt,
+			"expires_at":   session.ExpiresAt,
+		}).Error; err != nil {
+			return fmt.Errorf("failed to update session: %w", err)
+		}
+		if err := tx.First(&user, session.UserID).Error; err != nil {
+			return fiber.NewError(fiber.StatusUnauthorized, "unauthorized user")
+		}
+		var err error
+		raw, err = issueRefreshToken(tx, session)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if reused {
+		forgetSessions()
+		ClearAuthCookies(c)
+		return nil, fiber.NewError(fiber.StatusUnauthorized, "The refresh token was already used, the session has been revoked")
+	}
+	setRefreshCookie(c, raw, session.ExpiresAt)
+
+	if _, err := GenerateJwt(user.ID, user.Role, session.ID, c); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// EndSession revokes the session of the request, found through the access token or, once that
+// expired, the refresh token, and clears the cookies
+func EndSession(c *fiber.Ctx) error {
+	defer ClearAuthCookies(c)
+
+	var sessionID uint
+	if claims, err := ParseJwt(c.Cookies("jwt")); err == nil {
+		sessionID = claims.SessionID
+	} else if raw := c.Cookies("refresh_token"); raw != "" {
+		var token models.RefreshToken
+		if err := initializers.DB.Where("token_hash = ?", hashToken(raw)).First(&token).Error; err == nil {
+			sessionID = token.SessionID
+		}
+	}
+	if sessionID == 0 {
+		return nil
+	}
+
+	if err := initializers.DB.Model(&models.Session{}).Where("id = ? AND revoked_at IS NULL", sessionID).
+		Update("revoked_at", time.Now()).Error; err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	forgetSessions()
+	return nil
+}
+
+// ActiveSessions lists the sessions of a user that have not ended, most recently used first
+func ActiveSessions(userID uint) ([]models.Session, error) {
+	var sessions []models.Session
+	if err := initializers.DB.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_used_at desc").Find(&sessions).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+// RevokeSession ends one active session of a user
+func RevokeSession(userID, sessionID uint) error {
+	result := initializers.DB.Model(&models.Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL AND expires_at > ?", sessionID, userID, time.Now()).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("failed to revoke session: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fiber.NewError(fiber.StatusNotFound, "Session not found")
+	}
+	forgetSessions()
+	return nil
+}
+
+// RevokeSessions ends every active session of a user but exceptSessionID, 0 to end them all,
+// and returns how many were ended
+func RevokeSessions(userID, exceptSessionID uint) (int64, error) {
+	result := initializers.DB.Model(&models.Session{}).
+		Where("user_id = ? AND id <> ? AND revoked_at IS NULL AND expires_at > ?", userID, exceptSessionID, time.Now()).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to revoke sessions: %w", result.Error)
+	}
+	forgetSessions()
+	return result.RowsAffected, nil
+}
+
+// ClearAuthCookies removes the access and refresh cookies
+func ClearAuthCookies(c *fiber.Ctx) {
+	c.Cookie(&fiber.Cookie{
+		Name:     "jwt",
+		Value:    "",
+		Expires:  time.Now().Add(-1 * time.Second), // Set the cookie to expire immediately
+		HTTPOnly: true,
+	})
+	c.Cookie(&fiber.Cookie{
+		Name:     "refresh_token",
+		Value:    "",
+		Path:     "/user",
+		Expires:  time.Now().Add(-1 * time.Second),
+		HTTPOnly: true,
+	})
+}
+
+const sessionCacheTTL = 30 * time.Second
+
+// sessionCache remembers sessions recently found active, so AuthRequired does not query the
+// sessions on every request. Revoking clears it; the expiry catches revocations made by other
+// instances.
+var sessionCache = struct {
+	sync.RWMutex
+	entries map[uint]cachedSession
+}{entries: make(map[uint]cachedSession)}
+
+type cachedSession struct {
+	userID  string
+	expires time.Time
+}
+
+// SessionActive reports whether the session of an access token belongs to the user and has not
+// been revoked. This is the revocation check of every signed in request.
+func SessionActive(sessionID uint, userID string) bool {
+	sessionCache.RLock()
+	entry, ok := sessionCache.entries[sessionID]
+	sessionCache.RUnlock()
+	if ok && entry.userID == userID && time.Now().Before(entry.expires) {
+		return true
+	}
+
+	var count int64
+	if err := initializers.DB.Model(&models.Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL AND expires_at > ?", sessionID, userID, time.Now()).
+		Count(&count).Error; err != nil || count == 0 {
+		return false
+	}
+
+	sessionCache.Lock()
+	sessionCache.entries[sessionID] = cachedSession{userID: userID, expires: time.Now().Add(sessionCacheTTL)}
+	sessionCache.Unlock()
+	return true
+}
+
+func forgetSessions() {
+	sessionCache.Lock()
+	sessionCache.entries = make(map[uint]cachedSession)
+	sessionCache.Unlock()
+}
+
+// issueRefreshToken stores a new refresh token of the session and returns it
+func issueRefreshToken(tx *gorm.DB, session models.Session) (string, error) {
+	raw, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+	token := models.RefreshToken{
+		SessionID: session.ID,
+		TokenHash: hashToken(raw),
+		ExpiresAt: session.ExpiresAt,
+	}
+	if err := tx.Create(&token).Error; err != nil {
+		return "", fmt.Errorf("failed to save refresh token: %w", err)
+	}
+	return raw, nil
+}
+
+// setRefreshCookie sets the refresh token as a cookie sent only to the /user routes
+func setRefreshCookie(c *fiber.Ctx, raw string, expires time.Time) {
+	c.Cookie(&fiber.Cookie{
+		Name:     "refresh_token",
+		Value:    raw,
+		Path:     "/user",
+		Expires:  expires,
+		HTTPOnly: true,
+		Secure:   false,
+		SameSite: "None",
+	})
+}
+
+func hashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
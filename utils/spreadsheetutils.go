@@ -0,0 +1,251 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ReadSpreadsheet returns the rows of an uploaded .csv or .xlsx file. Only the first worksheet of
+// a workbook is read, and rows it leaves out are returned empty, so row i is line i+1 of the sheet.
+func ReadSpreadsheet(fileName string, reader io.Reader) ([][]string, error) {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".csv":
+		csvReader := csv.NewReader(reader)
+		csvReader.FieldsPerRecord = -1
+		csvReader.TrimLeadingSpace = true
+		var rows [][]string
+		for {
+			record, err := csvReader.Read()
+			if errors.Is(err, io.EOF) {
+				return rows, nil
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid CSV file: %w", err)
+			}
+			// The reader skips empty lines; keep them so rows match the lines of the file
+			line, _ := csvReader.FieldPos(0)
+			for len(rows) < line-1 {
+				rows = append(rows, nil)
+			}
+			rows = append(rows, record)
+		}
+	case ".xlsx":
+		content, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file: %w", err)
+		}
+		return readXLSX(content)
+	default:
+		return nil, errors.New("only .csv and .xlsx files are supported")
+	}
+}
+
+const (
+	xlsxMaxColumns  = 16384    // Column XFD, the last one a worksheet can have
+	xlsxMaxRows     = 1048576  // The last row a worksheet can have
+	xlsxMaxPartSize = 32 << 20 // Most bytes a part of the package may unpack to
+)
+
+type xlsxWorkbook struct {
+	Sheets []struct {
+		Name string `xml:"name,attr"`
+		RID  string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRelationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+type xlsxSharedStrings struct {
+	Items []xlsxRichText `xml:"si"`
+}
+
+// xlsxRichText is either plain text or a list of formatted runs
+type xlsxRichText struct {
+	Text string `xml:"t"`
+	Runs []struct {
+		Text string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxRichText) String() string {
+	if len(t.Runs) == 0 {
+		return t.Text
+	}
+	var builder strings.Builder
+	for _, run := range t.Runs {
+		builder.WriteString(run.Text)
+	}
+	return builder.String()
+}
+
+type xlsxWorksheet struct {
+	Rows []struct {
+		Ref   string `xml:"r,attr"`
+		Cells []struct {
+			Ref       string       `xml:"r,attr"`
+			Type      string       `xml:"t,attr"`
+			Value     string       `xml:"v"`
+			InlineStr xlsxRichText `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+func readXLSX(content []byte) ([][]string, error) {
+	archive, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid XLSX file: %w", err)
+	}
+	files := make(map[string]*zip.File)
+	for _, file := range archive.File {
+		files[file.Name] = file
+	}
+
+	var workbook xlsxWorkbook
+	if err := readXLSXPart(files, "xl/workbook.xml", &workbook); err != nil {
+		return nil, err
+	}
+	if len(workbook.Sheets) == 0 {
+		return nil, errors.New("the workbook has no worksheets")
+	}
+	var relationships xlsxRelationships
+	if err := readXLSXPart(files, "xl/_rels/workbook.xml.rels", &relationships); err != nil {
+		return nil, err
+	}
+	sheetPath := ""
+	for _, relationship := range relationships.Relationships {
+		if relationship.ID == workbook.Sheets[0].RID {
+			// Targets are relative to xl/ unless they start at the root of the package
+			if strings.HasPrefix(relationship.Target, "/") {
+				sheetPath = strings.TrimPrefix(relationship.Target, "/")
+			} else {
+				sheetPath = path.Join("xl", relationship.Target)
+			}
+		}
+	}
+	if sheetPath == "" {
+		return nil, errors.New("the first worksheet could not be found")
+	}
+
+	var shared xlsxSharedStrings
+	if _, ok := files["xl/sharedStrings.xml"]; ok {
+		if err := readXLSXPart(files, "xl/sharedStrings.xml", &shared); err != nil {
+			return nil, err
+		}
+	}
+
+	var sheet xlsxWorksheet
+	if err := readXLSXPart(files, sheetPath, &sheet); err != nil {
+		return nil, err
+	}
+
+	var rows [][]string
+	for _, sheetRow := range sheet.Rows {
+		// Empty rows are left out of the file, so the reference gives the line; rows without one
+		// follow the previous row
+		if sheetRow.Ref != "" {
+			line, err := strconv.Atoi(sheetRow.Ref)
+			if err != nil || line < 1 || line > xlsxMaxRows {
+				return nil, fmt.Errorf("invalid row reference %s", sheetRow.Ref)
+			}
+			if line <= len(rows) {
+				return nil, fmt.Errorf("row %d is listed out of order", line)
+			}
+			for len(rows) < line-1 {
+				rows = append(rows, nil)
+			}
+		} else if len(rows) >= xlsxMaxRows {
+			return nil, errors.New("the worksheet has more rows than a worksheet can have")
+		}
+
+		var row []string
+		column := -1
+		for _, cell := range sheetRow.Cells {
+			// Empty cells are left out too, so the reference gives the column; cells without one
+			// follow the previous cell
+			if cell.Ref != "" {
+				if column, err = xlsxColumn(cell.Ref); err != nil {
+					return nil, err
+				}
+			} else {
+				column++
+			}
+			if column >= xlsxMaxColumns {
+				return nil, errors.New("a row has more cells than a worksheet has columns")
+			}
+			for len(row) <= column {
+				row = append(row, "")
+			}
+
+			switch cell.Type {
+			case "s":
+				index, err := strconv.Atoi(cell.Value)
+				if err != nil || index < 0 || index >= len(shared.Items) {
+					return nil, fmt.Errorf("invalid shared string in cell %s", cell.Ref)
+				}
+				row[column] = shared.Items[index].String()
+			case "inlineStr":
+				row[column] = cell.InlineStr.String()
+			default:
+				row[column] = cell.Value
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// readXLSXPart decodes one part of the package. Parts that unpack to more than xlsxMaxPartSize
+// are refused, so a small upload cannot expand into gigabytes.
+func readXLSXPart(files map[string]*zip.File, name string, target interface{}) error {
+	file, ok := files[name]
+	if !ok {
+		return fmt.Errorf("invalid XLSX file: %s is missing", name)
+	}
+	if file.UncompressedSize64 > xlsxMaxPartSize {
+		return fmt.Errorf("invalid XLSX file: %s is larger than %d MB", name, xlsxMaxPartSize>>20)
+	}
+	reader, err := file.Open()
+	if err != nil {
+		return fmt.Errorf("invalid XLSX file: %w", err)
+	}
+	defer reader.Close()
+	// The zip reader fails parts that unpack past their declared size; the limit is kept anyway
+	if err := xml.NewDecoder(io.LimitReader(reader, xlsxMaxPartSize)).Decode(target); err != nil {
+		return fmt.Errorf("invalid XLSX file: %s: %w", name, err)
+	}
+	return nil
+}
+
+// xlsxColumn turns the letters of a cell reference such as AB12 into a zero based column. Columns
+// past XFD do not exist in a worksheet and are refused.
+func xlsxColumn(ref string) (int, error) {
+	column := 0
+	for _, r := range ref {
+		if r >= 'A' && r <= 'Z' {
+			column = column*26 + int(r-'A') + 1
+			if column > xlsxMaxColumns {
+				return 0, fmt.Errorf("cell reference %s is past the last column", ref)
+			}
+		} else {
+			break
+		}
+	}
+	if column == 0 {
+		return 0, fmt.Errorf("invalid cell reference %s", ref)
+	}
+	return column - 1, nil
+}
@@ -0,0 +1,193 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const (
+	testWorkbook = `<workbook xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="Marks" r:id="rId1"/></sheets></workbook>`
+	testRelationships = `<Relationships><Relationship Id="rId1" Target="worksheets/sheet1.xml"/></Relationships>`
+)
+
+// xlsxFile packs the parts into a workbook with one worksheet
+func xlsxFile(t *testing.T, sheet string, extra map[string]string) []byte {
+	t.Helper()
+	parts := map[string]string{
+		"xl/workbook.xml":            testWorkbook,
+		"xl/_rels/workbook.xml.rels": testRelationships,
+		"xl/worksheets/sheet1.xml":   `<worksheet><sheetData>` + sheet + `</sheetData></worksheet>`,
+	}
+	for name, content := range extra {
+		parts[name] = content
+	}
+
+	var buffer bytes.Buffer
+	archive := zip.NewWriter(&buffer)
+	for name, content := range parts {
+		writer, err := archive.Create(name)
+		if err != nil {
+			t.Fatalf("create %s: %v", name, err)
+		}
+		if _, err := writer.Write([]byte(content)); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatalf("close workbook: %v", err)
+	}
+	return buffer.Bytes()
+}
+
+func TestReadSpreadsheetXLSX(t *testing.T) {
+	shared := map[string]string{
+		"xl/sharedStrings.xml": `<sst><si><t>symbol_number</t></si><si><r><t>course</t></r><r><t>_code</t></r></si></sst>`,
+	}
+
+	tests := []struct {
+		name  string
+		sheet string
+		extra map[string]string
+		want  [][]string
+	}{
+		{
+			name: "shared and inline strings",
+			sheet: `<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c></row>` +
+				`<row r="2"><c r="A2" t="inlineStr"><is><t>S-001</t></is></c><c r="B2"><v>45</v></c></row>`,
+			extra: shared,
+			want:  [][]string{{"symbol_number", "course_code"}, {"S-001", "45"}},
+		},
+		{
+			name:  "rows left out of the file stay empty",
+			sheet: `<row r="1"><c r="A1"><v>1</v></c></row><row r="4"><c r="A4"><v>4</v></c></row>`,
+			want:  [][]string{{"1"}, nil, nil, {"4"}},
+		},
+		{
+			name:  "rows without a reference follow the previous row",
+			sheet: `<row r="2"><c r="A2"><v>2</v></c></row><row><c r="A3"><v>3</v></c></row>`,
+			want:  [][]string{nil, {"2"}, {"3"}},
+		},
+		{
+			name:  "cells left out of the file stay empty",
+			sheet: `<row r="1"><c r="B1"><v>b</v></c><c r="D1"><v>d</v></c></row>`,
+			want:  [][]string{{"", "b", "", "d"}},
+		},
+		{
+			name:  "cells without a reference follow the previous cell",
+			sheet: `<row r="1"><c r="C1"><v>c</v></c><c><v>d</v></c><c><v>e</v></c></row>`,
+			want:  [][]string{{"", "", "c", "d", "e"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := ReadSpreadsheet("marks.xlsx", bytes.NewReader(xlsxFile(t, tt.sheet, tt.extra)))
+			if err != nil {
+				t.Fatalf("ReadSpreadsheet: %v", err)
+			}
+			assertRows(t, rows, tt.want)
+		})
+	}
+}
+
+func TestReadSpreadsheetXLSXRefused(t *testing.T) {
+	tests := []struct {
+		name  string
+		sheet string
+		extra map[string]string
+		err   string
+	}{
+		{
+			name:  "column past XFD",
+			sheet: `<row r="1"><c r="XFE1"><v>1</v></c></row>`,
+			err:   "past the last column",
+		},
+		{
+			name:  "cell after XFD without a reference",
+			sheet: `<row r="1"><c r="XFD1"><v>1</v></c><c><v>2</v></c></row>`,
+			err:   "more cells than a worksheet has columns",
+		},
+		{
+			name:  "row past the last row",
+			sheet: `<row r="1048577"><c r="A1048577"><v>1</v></c></row>`,
+			err:   "invalid row reference",
+		},
+		{
+			name:  "rows out of order",
+			sheet: `<row r="3"><c r="A3"><v>3</v></c></row><row r="2"><c r="A2"><v>2</v></c></row>`,
+			err:   "out of order",
+		},
+		{
+			name:  "shared string that does not exist",
+			sheet: `<row r="1"><c r="A1" t="s"><v>7</v></c></row>`,
+			err:   "invalid shared string",
+		},
+		{
+			name:  "part that unpacks past 32 MB",
+			sheet: `<row r="1"><c r="A1"><v>1</v></c></row>`,
+			extra: map[string]string{"xl/sharedStrings.xml": "<sst>" + strings.Repeat(" ", xlsxMaxPartSize) + "</sst>"},
+			err:   "larger than 32 MB",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadSpreadsheet("marks.xlsx", bytes.NewReader(xlsxFile(t, tt.sheet, tt.extra)))
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("error = %v, want one mentioning %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestReadSpreadsheetCSV(t *testing.T) {
+	content := "symbol_number,course_code\n\nS-001, CSC101\n\nS-002,CSC102\n"
+	rows, err := ReadSpreadsheet("marks.CSV", strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("ReadSpreadsheet: %v", err)
+	}
+	assertRows(t, rows, [][]string{{"symbol_number", "course_code"}, nil, {"S-001", "CSC101"}, nil, {"S-002", "CSC102"}})
+}
+
+func TestXLSXColumn(t *testing.T) {
+	tests := []struct {
+		ref     string
+		want    int
+		wantErr bool
+	}{
+		{ref: "A1", want: 0},
+		{ref: "Z9", want: 25},
+		{ref: "AA10", want: 26},
+		{ref: "XFD1048576", want: xlsxMaxColumns - 1},
+		{ref: "XFE1", wantErr: true},
+		{ref: "AAAAAAAAAAAAAAAAAAAA1", wantErr: true},
+		{ref: "12", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			got, err := xlsxColumn(tt.ref)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("column = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func assertRows(t *testing.T, rows, want [][]string) {
+	t.Helper()
+	if len(rows) != len(want) {
+		t.Fatalf("got %d rows %q, want %d rows %q", len(rows), rows, len(want), want)
+	}
+	for i := range want {
+		if strings.Join(rows[i], "|") != strings.Join(want[i], "|") || len(rows[i]) != len(want[i]) {
+			t.Errorf("row %d = %q, want %q", i+1, rows[i], want[i])
+		}
+	}
+}
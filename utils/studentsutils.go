@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
 
@@ -35,3 +36,19 @@ func ParseUintFormValue(value string) (uint, error) {
 func RespondError(c *fiber.Ctx, status int, message string) error {
 	return c.Status(status).JSON(fiber.Map{"error": message})
 }
+
+// Helper function to respond with the code of a *fiber.Error, 409 for center clashes, or 500 for any other error
+func RespondFiberError(c *fiber.Ctx, err error) error {
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		return RespondError(c, fiberErr.Code, fiberErr.Message)
+	}
+	var clashErr *CenterClashError
+	if errors.As(err, &clashErr) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":   "Exam centers would be over capacity",
+			"clashes": clashErr.Clashes,
+		})
+	}
+	return RespondError(c, fiber.StatusInternalServerError, err.Error())
+}
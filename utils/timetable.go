@@ -0,0 +1,353 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mysterybee07/result-distribution-system/models"
+)
+
+// TimetableOptions holds the constraints of one scheduling run
+type TimetableOptions struct {
+	OffDays     []time.Weekday          // Weekly days on which no exam is held
+	Holidays    map[string]string       // Closed dates as 2006-01-02, mapped to the reason
+	MinRestDays int                     // Free days required between two exams of the cohort
+	CenterLoad  map[string]map[uint]int // Seats other routines need, by date and center
+	Centers     []uint                  // Centers the cohort sits at; empty counts every center
+}
+
+// dayLoad adds up the seats other routines need on a date at the centers of the cohort
+func (opts TimetableOptions) dayLoad(date time.Time) int {
+	loads := opts.CenterLoad[DateKey(date)]
+	if len(opts.Centers) == 0 {
+		total := 0
+		for _, seats := range loads {
+			total += seats
+		}
+		return total
+	}
+	total := 0
+	for _, centerID := range opts.Centers {
+		total += loads[centerID]
+	}
+	return total
+}
+
+// TimetableSlot is one exam day of the cohort. The optional courses of one elective group share a
+// slot because every student sits exactly one of them.
+type TimetableSlot struct {
+	Date    time.Time       `json:"date"`
+	Courses []models.Course `json:"courses"`
+}
+
+// TimetableScore reports how well the soft goals were met
+type TimetableScore struct {
+	MinGapDays       int     `json:"min_gap_days"`
+	HardCourseGap    int     `json:"hard_course_gap"` // Smallest gap between two of the hardest courses
+	DifficultyCost   float64 `json:"difficulty_cost"`
+	CenterLoad       int     `json:"center_load"` // Seats other routines need at the cohort's centers on the chosen days
+	AvailableDays    int     `json:"available_days"`
+	ExcludedOffDays  int     `json:"excluded_off_days"`
+	ExcludedHolidays int     `json:"excluded_holidays"`
+}
+
+// ScheduleInfeasibleError explains why no timetable fits the window
+type ScheduleInfeasibleError struct {
+	Reasons []string
+}
+
+func (e *ScheduleInfeasibleError) Error() string {
+	return "no exam timetable fits the window: " + strings.Join(e.Reasons, "; ")
+}
+
+// DefaultOffDays keeps the previous behaviour of skipping weekends
+var DefaultOffDays = []time.Weekday{time.Saturday, time.Sunday}
+
+// ParseWeekdays turns names such as "saturday" or "Sat" into weekdays, each listed once
+func ParseWeekdays(names []string) ([]time.Weekday, error) {
+	var weekdays []time.Weekday
+	seen := make(map[time.Weekday]bool)
+	for _, name := range names {
+		found := false
+		for day := time.Sunday; day <= time.Saturday; day++ {
+			full := strings.ToLower(day.String())
+			lower := strings.ToLower(strings.TrimSpace(name))
+			if lower == full || lower == full[:3] {
+				if !seen[day] {
+					weekdays = append(weekdays, day)
+					seen[day] = true
+				}
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("invalid weekday: %s", name)
+		}
+	}
+	return weekdays, nil
+}
+
+// DateKey formats a date the way holidays and loads are keyed
+func DateKey(date time.Time) string {
+	return date.Format("2006-01-02")
+}
+
+func truncateToDay(date time.Time) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+}
+
+type timetableUnit struct {
+	courses    []models.Course
+	difficulty int
+}
+
+type timetableDay struct {
+	date   time.Time
+	offset int // Calendar days since the start of the window
+}
+
+// BuildTimetable places the courses of one cohort on exam days between start and end.
+// Hard constraints: one exam day per unit, no exam on off-days or holidays and at least
+// MinRestDays free days between exams. Soft goals: spread days evenly, keep hard courses
+// apart and prefer days on which the centers are less busy.
+func BuildTimetable(courses []models.Course, start, end time.Time, opts TimetableOptions) ([]TimetableSlot, *TimetableScore, error) {
+	if len(courses) == 0 {
+		return nil, nil, &ScheduleInfeasibleError{Reasons: []string{"no courses found for the given program and semester"}}
+	}
+
+	units := timetableUnits(courses)
+	start, end = truncateToDay(start), truncateToDay(end)
+
+	offDays := make(map[time.Weekday]bool)
+	for _, day := range opts.OffDays {
+		offDays[day] = true
+	}
+
+	// Collect the open days of the window
+	score := &TimetableScore{}
+	var days []timetableDay
+	for date, offset := start, 0; !date.After(end); date, offset = date.AddDate(0, 0, 1), offset+1 {
+		if offDays[date.Weekday()] {
+			score.ExcludedOffDays++
+			continue
+		}
+		if _, closed := opts.Holidays[DateKey(date)]; closed {
+			score.ExcludedHolidays++
+			continue
+		}
+		days = append(days, timetableDay{date: date, offset: offset})
+	}
+	score.AvailableDays = len(days)
+
+	if reasons := explainInfeasible(units, days, score, opts.MinRestDays); len(reasons) > 0 {
+		return nil, nil, &ScheduleInfeasibleError{Reasons: reasons}
+	}
+
+	chosen := chooseExamDays(len(units), days, opts)
+	ordered := orderUnits(units, days, chosen)
+
+	var slots []TimetableSlot
+	score.MinGapDays = math.MaxInt
+	score.HardCourseGap = math.MaxInt
+	hardest := 0
+	for _, unit := range units {
+		hardest = max(hardest, unit.difficulty)
+	}
+	lastHard := -1
+	for k, dayIndex := range chosen {
+		day := days[dayIndex]
+		slots = append(slots, TimetableSlot{Date: day.date, Courses: ordered[k].courses})
+		score.CenterLoad += opts.dayLoad(day.date)
+
+		if k > 0 {
+			score.MinGapDays = min(score.MinGapDays, day.offset-days[chosen[k-1]].offset)
+		}
+		if ordered[k].difficulty == hardest {
+			if lastHard >= 0 {
+				score.HardCourseGap = min(score.HardCourseGap, day.offset-lastHard)
+			}
+			lastHard = day.offset
+		}
+	}
+	if score.MinGapDays == math.MaxInt {
+		score.MinGapDays = 0
+	}
+	if score.HardCourseGap == math.MaxInt {
+		score.HardCourseGap = 0
+	}
+	score.DifficultyCost = difficultyCost(ordered, days, chosen)
+
+	return slots, score, nil
+}
+
+// timetableUnits gives each compulsory course its own day and each elective group one day for
+// all of its courses. Optional courses without a group form one group of the semester.
+func timetableUnits(courses []models.Course) []timetableUnit {
+	sorted := make([]models.Course, len(courses))
+	copy(sorted, courses)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].CourseCode < sorted[j].CourseCode })
+
+	var units []timetableUnit
+	var groups []string
+	electives := make(map[string]*timetableUnit)
+	for _, course := range sorted {
+		if course.IsCompulsory {
+			units = append(units, timetableUnit{courses: []models.Course{course}, difficulty: course.Difficulty})
+			continue
+		}
+		group, ok := electives[course.ElectiveGroup]
+		if !ok {
+			group = &timetableUnit{}
+			electives[course.ElectiveGroup] = group
+			groups = append(groups, course.ElectiveGroup)
+		}
+		group.courses = append(group.courses, course)
+		group.difficulty = max(group.difficulty, course.Difficulty)
+	}
+	sort.Strings(groups)
+	for _, name := range groups {
+		units = append(units, *electives[name])
+	}
+	return units
+}
+
+func explainInfeasible(units []timetableUnit, days []timetableDay, score *TimetableScore, minRest int) []string {
+	var reasons []string
+	if len(days) == 0 {
+		return append(reasons, fmt.Sprintf("every day of the window is closed (%d off-days, %d holidays)",
+			score.ExcludedOffDays, score.ExcludedHolidays))
+	}
+
+	if len(units) > len(days) {
+		reasons = append(reasons, fmt.Sprintf("%d exam days are needed but only %d days are open (%d off-days and %d holidays excluded)",
+			len(units), len(days), score.ExcludedOffDays, score.ExcludedHolidays))
+	}
+
+	// Greedily count how many exams fit when every exam is followed by the rest days
+	fits := 1
+	last := days[0].offset
+	for _, day := range days[1:] {
+		if day.offset-last > minRest {
+			fits++
+			last = day.offset
+		}
+	}
+	if fits < len(units) && len(units) <= len(days) {
+		reasons = append(reasons, fmt.Sprintf("with %d rest days between exams the window fits at most %d of the %d exam days needed",
+			minRest, fits, len(units)))
+	}
+	return reasons
+}
+
+// chooseExamDays picks n open days with dynamic programming. The cost of a choice is how far the
+// gaps are from an even spread plus the load already placed on the centers on those days.
+func chooseExamDays(n int, days []timetableDay, opts TimetableOptions) []int {
+	if n == 1 {
+		best := 0
+		for j := range days {
+			if opts.dayLoad(days[j].date) < opts.dayLoad(days[best].date) {
+				best = j
+			}
+		}
+		return []int{best}
+	}
+
+	span := float64(days[len(days)-1].offset - days[0].offset)
+	idealGap := span / float64(n-1)
+
+	loads := make([]int, len(days))
+	maxLoad := 0
+	for j := range days {
+		loads[j] = opts.dayLoad(days[j].date)
+		maxLoad = max(maxLoad, loads[j])
+	}
+	dayCost := func(j int) float64 {
+		if maxLoad == 0 {
+			return 0
+		}
+		// Scale the load so a fully loaded day costs as much as missing the ideal gap entirely
+		return float64(loads[j]) / float64(maxLoad) * idealGap * idealGap
+	}
+
+	inf := math.Inf(1)
+	cost := make([][]float64, n)
+	prev := make([][]int, n)
+	for k := range cost {
+		cost[k] = make([]float64, len(days))
+		prev[k] = make([]int, len(days))
+		for j := range cost[k] {
+			cost[k][j] = inf
+			prev[k][j] = -1
+		}
+	}
+	for j := range days {
+		cost[0][j] = dayCost(j)
+	}
+
+	for k := 1; k < n; k++ {
+		for j := range days {
+			for i := 0; i < j; i++ {
+				gap := days[j].offset - days[i].offset
+				if cost[k-1][i] == inf || gap <= opts.MinRestDays {
+					continue
+				}
+				deviation := float64(gap) - idealGap
+				candidate := cost[k-1][i] + deviation*deviation + dayCost(j)
+				if candidate < cost[k][j] {
+					cost[k][j] = candidate
+					prev[k][j] = i
+				}
+			}
+		}
+	}
+
+	best := -1
+	for j := range days {
+		if cost[n-1][j] < inf && (best < 0 || cost[n-1][j] < cost[n-1][best]) {
+			best = j
+		}
+	}
+
+	chosen := make([]int, n)
+	for k := n - 1; k >= 0; k-- {
+		chosen[k] = best
+		best = prev[k][best]
+	}
+	return chosen
+}
+
+// orderUnits decides which unit sits on which chosen day by swapping units while that lowers the
+// difficulty cost, so hard courses end up with long gaps between them
+func orderUnits(units []timetableUnit, days []timetableDay, chosen []int) []timetableUnit {
+	ordered := make([]timetableUnit, len(units))
+	copy(ordered, units)
+
+	for improved := true; improved; {
+		improved = false
+		for i := 0; i < len(ordered); i++ {
+			for j := i + 1; j < len(ordered); j++ {
+				before := difficultyCost(ordered, days, chosen)
+				ordered[i], ordered[j] = ordered[j], ordered[i]
+				if difficultyCost(ordered, days, chosen) < before-1e-9 {
+					improved = true
+					continue
+				}
+				ordered[i], ordered[j] = ordered[j], ordered[i]
+			}
+		}
+	}
+	return ordered
+}
+
+func difficultyCost(ordered []timetableUnit, days []timetableDay, chosen []int) float64 {
+	total := 0.0
+	for k := 1; k < len(ordered); k++ {
+		gap := float64(days[chosen[k]].offset - days[chosen[k-1]].offset)
+		total += float64(ordered[k].difficulty*ordered[k-1].difficulty) / gap
+	}
+	return total
+}
@@ -0,0 +1,162 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mysterybee07/result-distribution-system/models"
+)
+
+func timetableCourse(code string, compulsory bool, group string) models.Course {
+	return models.Course{CourseCode: code, IsCompulsory: compulsory, ElectiveGroup: group, Difficulty: 3}
+}
+
+func TestBuildTimetableInfeasible(t *testing.T) {
+	monday := time.Date(2026, time.January, 5, 0, 0, 0, 0, time.UTC)
+	compulsory := []models.Course{
+		timetableCourse("CSC101", true, ""),
+		timetableCourse("CSC102", true, ""),
+		timetableCourse("CSC103", true, ""),
+	}
+
+	tests := []struct {
+		name    string
+		courses []models.Course
+		end     time.Time
+		opts    TimetableOptions
+		reason  string
+	}{
+		{
+			name:   "no courses",
+			end:    monday.AddDate(0, 0, 6),
+			opts:   TimetableOptions{OffDays: DefaultOffDays},
+			reason: "no courses found",
+		},
+		{
+			name:    "every day is an off-day or a holiday",
+			courses: compulsory,
+			end:     monday.AddDate(0, 0, 6),
+			opts: TimetableOptions{
+				OffDays: []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Saturday, time.Sunday},
+				Holidays: map[string]string{
+					DateKey(monday.AddDate(0, 0, 3)): "Founding day",
+					DateKey(monday.AddDate(0, 0, 4)): "Festival",
+				},
+			},
+			reason: "every day of the window is closed (5 off-days, 2 holidays)",
+		},
+		{
+			name:    "more exam days than open days",
+			courses: compulsory,
+			end:     monday.AddDate(0, 0, 6),
+			opts: TimetableOptions{
+				OffDays: DefaultOffDays,
+				Holidays: map[string]string{
+					DateKey(monday.AddDate(0, 0, 1)): "Festival",
+					DateKey(monday.AddDate(0, 0, 2)): "Festival",
+					DateKey(monday.AddDate(0, 0, 3)): "Festival",
+				},
+			},
+			reason: "3 exam days are needed but only 2 days are open (2 off-days and 3 holidays excluded)",
+		},
+		{
+			name:    "rest days leave too few exam days",
+			courses: compulsory,
+			end:     monday.AddDate(0, 0, 4),
+			opts:    TimetableOptions{OffDays: DefaultOffDays, MinRestDays: 2},
+			reason:  "with 2 rest days between exams the window fits at most 2 of the 3 exam days needed",
+		},
+		{
+			name: "each elective group needs its own day",
+			courses: append([]models.Course{
+				timetableCourse("CSC104", false, "maths"),
+				timetableCourse("CSC105", false, "maths"),
+				timetableCourse("CSC106", false, "science"),
+			}, compulsory...),
+			end:    monday.AddDate(0, 0, 3),
+			opts:   TimetableOptions{},
+			reason: "5 exam days are needed but only 4 days are open",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			slots, _, err := BuildTimetable(tt.courses, monday, tt.end, tt.opts)
+			var infeasible *ScheduleInfeasibleError
+			if !errors.As(err, &infeasible) {
+				t.Fatalf("got %d slots and error %v, want a ScheduleInfeasibleError", len(slots), err)
+			}
+			if !strings.Contains(strings.Join(infeasible.Reasons, "; "), tt.reason) {
+				t.Errorf("reasons %q do not mention %q", infeasible.Reasons, tt.reason)
+			}
+		})
+	}
+}
+
+func TestBuildTimetableElectiveGroups(t *testing.T) {
+	monday := time.Date(2026, time.January, 5, 0, 0, 0, 0, time.UTC)
+	courses := []models.Course{
+		timetableCourse("CSC101", true, ""),
+		timetableCourse("CSC104", false, "maths"),
+		timetableCourse("CSC105", false, "maths"),
+		timetableCourse("CSC106", false, "science"),
+		timetableCourse("CSC107", false, ""),
+	}
+
+	slots, score, err := BuildTimetable(courses, monday, monday.AddDate(0, 0, 13), TimetableOptions{OffDays: DefaultOffDays})
+	if err != nil {
+		t.Fatalf("BuildTimetable: %v", err)
+	}
+	if len(slots) != 4 {
+		t.Fatalf("got %d exam days, want 4", len(slots))
+	}
+	if score.ExcludedOffDays != 4 || score.AvailableDays != 10 {
+		t.Errorf("excluded %d off-days with %d open days, want 4 and 10", score.ExcludedOffDays, score.AvailableDays)
+	}
+
+	// Courses of one group share a day, and different groups never do
+	dayOf := make(map[string]string)
+	for _, slot := range slots {
+		for _, course := range slot.Courses {
+			dayOf[course.CourseCode] = DateKey(slot.Date)
+		}
+	}
+	if dayOf["CSC104"] != dayOf["CSC105"] {
+		t.Errorf("CSC104 on %s and CSC105 on %s, want the same day", dayOf["CSC104"], dayOf["CSC105"])
+	}
+	if dayOf["CSC104"] == dayOf["CSC106"] || dayOf["CSC106"] == dayOf["CSC107"] {
+		t.Errorf("elective groups share a day: %v", dayOf)
+	}
+}
+
+func TestParseWeekdays(t *testing.T) {
+	tests := []struct {
+		names   []string
+		want    []time.Weekday
+		wantErr bool
+	}{
+		{names: []string{"saturday", "Sun"}, want: []time.Weekday{time.Saturday, time.Sunday}},
+		{names: []string{"Fri", " friday ", "FRIDAY"}, want: []time.Weekday{time.Friday}},
+		{names: nil, want: nil},
+		{names: []string{"someday"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.names, ","), func(t *testing.T) {
+			got, err := ParseWeekdays(tt.names)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("got %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/mysterybee07/result-distribution-system/initializers"
+	"github.com/mysterybee07/result-distribution-system/models"
+	"gorm.io/gorm"
+)
+
+// RebuildTranscripts replaces the stored transcripts of a batch and program with the latest
+// publication of each of their published results. Run it with the transaction that changed a
+// result so lookups switch over at commit.
+func RebuildTranscripts(db *gorm.DB, batchID, programID uint) error {
+	var students []models.Student
+	if err := db.Preload("Program").Preload("Batch").
+		Where("batch_id = ? AND program_id = ?", batchID, programID).
+		Find(&students).Error; err != nil {
+		return fmt.Errorf("failed to fetch students: %w", err)
+	}
+
+	semesters, err := publishedSemesters(db, batchID, programID)
+	if err != nil {
+		return err
+	}
+
+	var transcripts []models.PublishedTranscript
+	for _, student := range students {
+		var results []PublicSemesterResult
+		for _, semester := range semesters {
+			line, ok := semester.students[student.ID]
+			if !ok {
+				continue
+			}
+			results = append(results, publicSemesterResult(semester, line))
+		}
+		if len(results) == 0 {
+			continue
+		}
+
+		data, err := json.Marshal(results)
+		if err != nil {
+			return fmt.Errorf("failed to encode the transcript of student %d: %w", student.ID, err)
+		}
+		transcript := models.PublishedTranscript{
+			StudentID:          student.ID,
+			SymbolNumber:       student.SymbolNumber,
+			BatchID:            student.BatchID,
+			ProgramID:          student.ProgramID,
+			RegistrationNumber: student.RegistrationNumber,
+			Fullname:           student.Fullname,
+			ProgramName:        student.Program.ProgramName,
+			Batch:              fmt.Sprint(student.Batch.Batch),
+			Semesters:          string(data),
+		}
+		if student.DateOfBirth != nil {
+			transcript.DateOfBirth = DateKey(*student.DateOfBirth)
+		}
+		transcripts = append(transcripts, transcript)
+	}
+
+	if err := db.Unscoped().Where("batch_id = ? AND program_id = ?", batchID, programID).
+		Delete(&models.PublishedTranscript{}).Error; err != nil {
+		return fmt.Errorf("failed to clear transcripts: %w", err)
+	}
+	if len(transcripts) > 0 {
+		if err := db.CreateInBatches(&transcripts, 500).Error; err != nil {
+			return fmt.Errorf("failed to store transcripts: %w", err)
+		}
+	}
+	return nil
+}
+
+// RebuildAllTranscripts rebuilds the stored transcripts of every cohort with a published result
+// and returns the number of cohorts
+func RebuildAllTranscripts() (int, error) {
+	var cohorts []struct {
+		BatchID   uint
+		ProgramID uint
+	}
+	if err := initializers.DB.Model(&models.Result{}).
+		Where("status = ?", "Published").
+		Distinct("batch_id", "program_id").
+		Find(&cohorts).Error; err != nil {
+		return 0, fmt.Errorf("failed to fetch results: %w", err)
+	}
+
+	for _, cohort := range cohorts {
+		err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+			return RebuildTranscripts(tx, cohort.BatchID, cohort.ProgramID)
+		})
+		if err != nil {
+			return 0, err
+		}
+		refreshPublicResults(cohort.BatchID, cohort.ProgramID)
+	}
+	return len(cohorts), nil
+}
+
+type publishedSemester struct {
+	semesterID   uint
+	semesterName uint
+	snapshot     models.ResultSnapshot
+	students     map[uint]models.ResultSnapshotStudent
+}
+
+// publishedSemesters loads the latest publication of every published result of a cohort
+func publishedSemesters(db *gorm.DB, batchID, programID uint) ([]publishedSemester, error) {
+	var results []models.Result
+	if err := db.Preload("Semester").
+		Where("batch_id = ? AND program_id = ? AND status = ?", batchID, programID, "Published").
+		Find(&results).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch results: %w", err)
+	}
+
+	var semesters []publishedSemester
+	for _, result := range results {
+		var snapshot models.ResultSnapshot
+		err := db.Preload("Students.Courses").
+			Where("result_id = ? AND action <> ?", result.ID, "withdrawn").
+			Order("version desc").First(&snapshot).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue // Published before snapshots were kept
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to fetch result snapshot: %w", err)
+		}
+
+		semester := publishedSemester{
+			semesterID:   result.SemesterID,
+			semesterName: result.Semester.SemesterName,
+			snapshot:     snapshot,
+			students:     make(map[uint]models.ResultSnapshotStudent),
+		}
+		for _, student := range snapshot.Students {
+			semester.students[student.StudentID] = student
+		}
+		semesters = append(semesters, semester)
+	}
+	sort.Slice(semesters, func(i, j int) bool { return semesters[i].semesterName < semesters[j].semesterName })
+	return semesters, nil
+}
+
+func publicSemesterResult(semester publishedSemester, line models.ResultSnapshotStudent) PublicSemesterResult {
+	result := PublicSemesterResult{
+		SemesterID:   semester.semesterID,
+		SemesterName: semester.semesterName,
+		Revision:     semester.snapshot.Revision,
+		PublishedAt:  semester.snapshot.CreatedAt,
+		Status:       line.Status,
+		TotalMarks:   line.TotalMarks,
+		SGPA:         line.SGPA,
+		CGPA:         line.CGPA,
+	}
+	for _, course := range line.Courses {
+		result.Courses = append(result.Courses, PublicCourseResult{
+			CourseCode:     course.CourseCode,
+			CourseName:     course.CourseName,
+			CreditHours:    course.CreditHours,
+			SemesterMarks:  course.SemesterMarks,
+			PracticalMarks: course.PracticalMarks,
+			AssistantMarks: course.AssistantMarks,
+			TotalMarks:     course.TotalMarks,
+			FullMarks:      course.FullMarks,
+			Letter:         course.Letter,
+			GradePoint:     course.GradePoint,
+			Status:         course.Status,
+		})
+	}
+	return result
+}
+
+// SyncTranscriptStudent copies changed details of a student into their stored transcript
+func SyncTranscriptStudent(student models.Student) error {
+	dateOfBirth := ""
+	if student.DateOfBirth != nil {
+		dateOfBirth = DateKey(*student.DateOfBirth)
+	}
+	if err := initializers.DB.Model(&models.PublishedTranscript{}).
+		Where("student_id = ?", student.ID).
+		Updates(map[string]interface{}{
+			"symbol_number":       student.SymbolNumber,
+			"registration_number": student.RegistrationNumber,
+			"date_of_birth":       dateOfBirth,
+			"fullname":            student.Fullname,
+		}).Error; err != nil {
+		return fmt.Errorf("failed to update transcript: %w", err)
+	}
+	refreshPublicResults(student.BatchID, student.ProgramID)
+	return nil
+}
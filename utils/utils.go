@@ -1,11 +1,14 @@
 package utils
 
 import (
+	"errors"
 	"math/rand"
 	"regexp"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/go-sql-driver/mysql"
 )
 
 var letters = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
@@ -48,3 +51,10 @@ func ConvertIDs(batchIDStr, programIDStr string) (batchID, programID *uint, err
 
 	return batchID, programID, nil // Return both pointers and nil error
 }
+
+// isDuplicateKey reports whether an insert broke a unique index, which is how concurrent requests
+// racing to create the same row find out they lost
+func isDuplicateKey(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1062
+}
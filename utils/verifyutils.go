@@ -0,0 +1,353 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mysterybee07/result-distribution-system/initializers"
+	"github.com/mysterybee07/result-distribution-system/models"
+	"gorm.io/gorm"
+)
+
+// SignedSemester is what a signed document states about one semester
+type SignedSemester struct {
+	SemesterID uint
+	Revision   int
+	TotalMarks int
+	SGPA       float64
+	Status     string // pass or fail
+}
+
+// DocumentClaims is the payload signed into the QR code of a grade sheet or transcript
+type DocumentClaims struct {
+	SerialNumber string
+	SymbolNumber string
+	Semesters    []SignedSemester
+	CGPA         float64 // Only set on a transcript
+}
+
+// SemesterVerification is the outcome for one semester a document covers
+type SemesterVerification struct {
+	SemesterID      uint   `json:"semester_id"`
+	SemesterName    uint   `json:"semester_name"`
+	Revision        int    `json:"revision"`
+	CurrentRevision int    `json:"current_revision"`
+	Status          string `json:"status"`
+}
+
+// DocumentVerification is the answer given to whoever scanned a document
+type DocumentVerification struct {
+	Status       string                 `json:"status"` // valid, tampered, withdrawn or superseded
+	Message      string                 `json:"message"`
+	Kind         string                 `json:"kind,omitempty"`
+	SerialNumber string                 `json:"serial_number,omitempty"`
+	SymbolNumber string                 `json:"symbol_number,omitempty"`
+	Fullname     string                 `json:"fullname,omitempty"`
+	IssuedAt     string                 `json:"issued_at,omitempty"`
+	Semesters    []SemesterVerification `json:"semesters,omitempty"`
+}
+
+var documentKey struct {
+	sync.Once
+	key ed25519.PrivateKey
+}
+
+// LoadDocumentSigningKey reads DOCUMENT_SIGNING_KEY, a base64 Ed25519 seed of 32 bytes. A
+// missing or malformed key stops the server, since documents signed with a guessable key could
+// be forged; only development mode makes up a random one and keeps it in .dev-keys.
+func LoadDocumentSigningKey() {
+	documentKey.Do(func() {
+		config := strings.TrimSpace(os.Getenv("DOCUMENT_SIGNING_KEY"))
+		var seed []byte
+		switch {
+		case config != "":
+			var err error
+			seed, err = base64.StdEncoding.DecodeString(config)
+			if err != nil {
+				log.Fatalf("DOCUMENT_SIGNING_KEY is not valid base64: %v", err)
+			}
+			if len(seed) != ed25519.SeedSize {
+				log.Fatalf("DOCUMENT_SIGNING_KEY must be a seed of %d bytes, got %d", ed25519.SeedSize, len(seed))
+			}
+		case DevelopmentMode():
+			var err error
+			seed, err = developmentKey("document-signing", ed25519.SeedSize)
+			if err != nil {
+				log.Fatalf("Failed to load the development document signing key: %v", err)
+			}
+			log.Println("DOCUMENT_SIGNING_KEY is not set, documents are signed with a development key")
+		default:
+			log.Fatalf("DOCUMENT_SIGNING_KEY is not set; configure a base64 Ed25519 seed of %d bytes", ed25519.SeedSize)
+		}
+		documentKey.key = ed25519.NewKeyFromSeed(seed)
+	})
+}
+
+// DocumentSigningKey is the key grade sheet and transcript QR codes are signed with
+func DocumentSigningKey() ed25519.PrivateKey {
+	LoadDocumentSigningKey()
+	return documentKey.key
+}
+
+// DocumentPublicKey is published so others can check documents without calling the endpoint
+func DocumentPublicKey() string {
+	return base64.StdEncoding.EncodeToString(DocumentSigningKey().Public().(ed25519.PublicKey))
+}
+
+// GradeSheetClaims is what the QR code of a grade sheet states
+func GradeSheetClaims(sheet GradeSheet) DocumentClaims {
+	return DocumentClaims{
+		SerialNumber: sheet.SerialNumber,
+		SymbolNumber: sheet.Student.SymbolNumber,
+		Semesters:    []SignedSemester{signedSemester(sheet)},
+	}
+}
+
+// TranscriptClaims is what the QR code of a transcript states
+func TranscriptClaims(transcript Transcript) DocumentClaims {
+	claims := DocumentClaims{
+		SerialNumber: transcript.SerialNumber,
+		SymbolNumber: transcript.Student.SymbolNumber,
+		CGPA:         transcript.CGPA,
+	}
+	for _, sheet := range transcript.Semesters {
+		claims.Semesters = append(claims.Semesters, signedSemester(sheet))
+	}
+	return claims
+}
+
+func signedSemester(sheet GradeSheet) SignedSemester {
+	return SignedSemester{
+		SemesterID: sheet.Result.SemesterID,
+		Revision:   sheet.Result.Revision,
+		TotalMarks: sheet.TotalMarks,
+		SGPA:       sheet.SGPA,
+		Status:     sheet.Status,
+	}
+}
+
+// encode writes the claims as 1|serial|symbol|cgpa|semester,revision,total,sgpa,status;...
+func (claims DocumentClaims) encode() string {
+	var semesters []string
+	for _, semester := range claims.Semesters {
+		semesters = append(semesters, fmt.Sprintf("%d,%d,%d,%.2f,%s",
+			semester.SemesterID, semester.Revision, semester.TotalMarks, semester.SGPA, semester.Status))
+	}
+	return strings.Join([]string{
+		"1",
+		url.QueryEscape(claims.SerialNumber),
+		url.QueryEscape(claims.SymbolNumber),
+		fmt.Sprintf("%.2f", claims.CGPA),
+		strings.Join(semesters, ";"),
+	}, "|")
+}
+
+func decodeClaims(payload string) (DocumentClaims, error) {
+	var claims DocumentClaims
+	parts := strings.Split(payload, "|")
+	if len(parts) != 5 || parts[0] != "1" {
+		return claims, errors.New("unknown payload format")
+	}
+
+	var err error
+	if claims.SerialNumber, err = url.QueryUnescape(parts[1]); err != nil {
+		return claims, err
+	}
+	if claims.SymbolNumber, err = url.QueryUnescape(parts[2]); err != nil {
+		return claims, err
+	}
+	if claims.CGPA, err = strconv.ParseFloat(parts[3], 64); err != nil {
+		return claims, err
+	}
+	for _, field := range strings.Split(parts[4], ";") {
+		values := strings.Split(field, ",")
+		if len(values) != 5 {
+			return claims, errors.New("invalid semester in payload")
+		}
+		var semester SignedSemester
+		semesterID, err := strconv.ParseUint(values[0], 10, 32)
+		if err != nil {
+			return claims, err
+		}
+		semester.SemesterID = uint(semesterID)
+		if semester.Revision, err = strconv.Atoi(values[1]); err != nil {
+			return claims, err
+		}
+		if semester.TotalMarks, err = strconv.Atoi(values[2]); err != nil {
+			return claims, err
+		}
+		if semester.SGPA, err = strconv.ParseFloat(values[3], 64); err != nil {
+			return claims, err
+		}
+		semester.Status = values[4]
+		claims.Semesters = append(claims.Semesters, semester)
+	}
+	return claims, nil
+}
+
+// SignDocument returns the compact code for the claims: the payload and its Ed25519 signature,
+// both base64url encoded and joined by a dot
+func SignDocument(claims DocumentClaims) string {
+	payload := []byte(claims.encode())
+	signature := ed25519.Sign(DocumentSigningKey(), payload)
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// DocumentVerifyURL is the link encoded in the QR code of a document
+func DocumentVerifyURL(claims DocumentClaims) string {
+	return AppURL() + "/verify?code=" + SignDocument(claims)
+}
+
+// VerifyDocument checks the signature of a scanned code and compares what it states with the
+// published snapshots the document was issued from
+func VerifyDocument(code string) (*DocumentVerification, error) {
+	tampered := &DocumentVerification{Status: "tampered", Message: "The document could not be verified and may have been altered"}
+
+	encodedPayload, encodedSignature, ok := strings.Cut(strings.TrimSpace(code), ".")
+	if !ok {
+		return tampered, nil
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return tampered, nil
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil || !ed25519.Verify(DocumentSigningKey().Public().(ed25519.PublicKey), payload, signature) {
+		return tampered, nil
+	}
+	claims, err := decodeClaims(string(payload))
+	if err != nil || len(claims.Semesters) == 0 {
+		return tampered, nil
+	}
+
+	var document models.IssuedDocument
+	if err := initializers.DB.Preload("Student").Where("serial_number = ?", claims.SerialNumber).First(&document).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return tampered, nil
+		}
+		return nil, fmt.Errorf("failed to fetch issued document: %w", err)
+	}
+	if document.Student.SymbolNumber != claims.SymbolNumber {
+		return tampered, nil
+	}
+
+	verification := &DocumentVerification{
+		Status:       "valid",
+		Message:      "The document matches the published result",
+		Kind:         document.Kind,
+		SerialNumber: document.SerialNumber,
+		SymbolNumber: document.Student.SymbolNumber,
+		Fullname:     document.Student.Fullname,
+		IssuedAt:     document.IssuedAt.Format("2006-01-02"),
+	}
+	var courses []CourseGrade
+	for _, claimed := range claims.Semesters {
+		semester, semesterCourses, err := verifySemester(document.Student, claimed)
+		if err != nil {
+			return nil, err
+		}
+		verification.Semesters = append(verification.Semesters, *semester)
+		courses = append(courses, semesterCourses...)
+
+		// A tampered semester outweighs a withdrawn one, which outweighs a superseded one
+		switch {
+		case semester.Status == "tampered":
+			verification.Status = "tampered"
+		case semester.Status == "withdrawn" && verification.Status != "tampered":
+			verification.Status = "withdrawn"
+		case semester.Status == "superseded" && verification.Status == "valid":
+			verification.Status = "superseded"
+		}
+	}
+
+	// A transcript's CGPA is worked out again from the courses of the revisions it states, the
+	// way the transcript was; a grade sheet states none
+	cgpa := 0.0
+	if document.Kind == "transcript" {
+		report := &GradeReport{Courses: courses}
+		summarizeGrades(report)
+		cgpa = report.CGPA
+	}
+	if fmt.Sprintf("%.2f", cgpa) != fmt.Sprintf("%.2f", claims.CGPA) {
+		verification.Status = "tampered"
+	}
+
+	switch verification.Status {
+	case "tampered":
+		verification.Message = tampered.Message
+	case "withdrawn":
+		verification.Message = "The result the document was issued from has been withdrawn"
+	case "superseded":
+		verification.Message = "The document was genuine but the result has since been revised"
+	}
+	return verification, nil
+}
+
+// verifySemester compares a claimed semester with the snapshot at its revision and returns the
+// snapshot's courses when they match
+func verifySemester(student models.Student, claimed SignedSemester) (*SemesterVerification, []CourseGrade, error) {
+	semester := &SemesterVerification{SemesterID: claimed.SemesterID, Revision: claimed.Revision, Status: "tampered"}
+
+	var result models.Result
+	if err := initializers.DB.Preload("Semester").
+		Where("batch_id = ? AND program_id = ? AND semester_id = ?", student.BatchID, student.ProgramID, claimed.SemesterID).
+		First(&result).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return semester, nil, nil
+		}
+		return nil, nil, fmt.Errorf("failed to fetch result: %w", err)
+	}
+	semester.SemesterName = result.Semester.SemesterName
+	semester.CurrentRevision = result.Revision
+
+	// The snapshot the document was printed from, at the revision it states
+	var snapshot models.ResultSnapshot
+	err := initializers.DB.Where("result_id = ? AND revision = ? AND action <> ?", result.ID, claimed.Revision, "withdrawn").
+		Order("version desc").First(&snapshot).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return semester, nil, nil
+	} else if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch result snapshot: %w", err)
+	}
+	var line models.ResultSnapshotStudent
+	err = initializers.DB.Preload("Courses").Where("result_snapshot_id = ? AND student_id = ?", snapshot.ID, student.ID).First(&line).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return semester, nil, nil
+	} else if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch result snapshot: %w", err)
+	}
+	if line.TotalMarks != claimed.TotalMarks || line.Status != claimed.Status ||
+		fmt.Sprintf("%.2f", line.SGPA) != fmt.Sprintf("%.2f", claimed.SGPA) {
+		return semester, nil, nil
+	}
+
+	var courses []CourseGrade
+	for _, course := range line.Courses {
+		courses = append(courses, CourseGrade{
+			CourseID:    course.CourseID,
+			CourseCode:  course.CourseCode,
+			SemesterID:  claimed.SemesterID,
+			CreditHours: course.CreditHours,
+			GradePoint:  course.GradePoint,
+			Status:      course.Status,
+		})
+	}
+
+	switch {
+	case result.Status == "Withdrawn":
+		semester.Status = "withdrawn"
+	case result.Revision != claimed.Revision:
+		semester.Status = "superseded"
+	default:
+		semester.Status = "valid"
+	}
+	return semester, courses, nil
+}